@@ -1,20 +1,28 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
-	_ "github.com/jnovack/go-version"
+	build "github.com/jnovack/go-version"
 
 	"github.com/tabalt/pidfile"
 
 	"github.com/coreos/pkg/flagutil"
 	// "github.com/jnovack/cloudkey/display"
 	"cloudkey/display"
+	"cloudkey/src/api"
+	"cloudkey/src/chaos"
+	"cloudkey/src/ratelimit"
+	"cloudkey/src/tracing"
 	_ "github.com/jnovack/cloudkey/fonts"
+	"github.com/llajas/cloudkey/src/network"
 )
 
 var tags = map[string]string{
@@ -23,35 +31,247 @@ var tags = map[string]string{
 
 var opts display.CmdLineOpts
 
+// stopTracing flushes the queued spans, nil while tracing is off
+var stopTracing func(context.Context) error
+
 func main() {
+	if flag.Arg(0) == "config" {
+		os.Exit(configCommand(flag.Args()[1:]))
+	}
+	if flag.Arg(0) == "export" {
+		os.Exit(exportCommand(flag.Args()[1:]))
+	}
+	if flag.Arg(0) == "setup" {
+		os.Exit(setupCommand(flag.Args()[1:]))
+	}
+	if flag.Arg(0) == "record" {
+		os.Exit(recordCommand(flag.Args()[1:]))
+	}
+	if flag.Arg(0) == "diag" {
+		os.Exit(diagCommand(flag.Args()[1:]))
+	}
+
+	if errs := opts.Validate(); len(errs) > 0 {
+		for _, err := range errs {
+			fmt.Printf("Configuration error: %s\n", optionHint(err))
+		}
+		display.SignalConfigError(errs[0], opts.Headless)
+	}
+
+	if opts.TracingEndpoint != "" {
+		stop, err := tracing.Start(context.Background(), opts.TracingEndpoint, build.Version)
+		if err != nil {
+			fmt.Printf("Tracing disabled: %s\n", err)
+		} else {
+			stopTracing = stop
+			fmt.Printf("Exporting traces to %s\n", opts.TracingEndpoint)
+		}
+	}
+
+	if err := ratelimit.Configure(opts.OutboundRate, opts.OutboundRates); err != nil {
+		fmt.Printf("Configuration error: %s\n", err)
+		display.SignalConfigError(err, opts.Headless)
+	}
+
+	if opts.ChaosRate > 0 {
+		chaos.Configure(opts.ChaosRate, opts.ChaosFaults)
+		fmt.Printf("Chaos: failing %g%% of controller and Kubernetes requests\n", opts.ChaosRate*100)
+	}
+
+	startService()
+	display.SetIgnoredKeys(ignoredKeys())
+	display.Init(opts)
+
+	if opts.APIListen != "" {
+		api.SetFrameSource(display.Snapshot)
+		api.SetScreenSource(display.ScreenNames, display.ScreenImage)
+		api.SetLivenessSource(display.Alive)
+		api.SetHealthSource(func() (any, error) { return display.HealthHistory(opts) })
+		api.SetConfigSource(func() any { return display.Report(opts) })
+		api.SetVersionSource(func() any { return display.BuildInfo() })
+		if opts.StatusPageEnabled {
+			api.SetStatusPageSource(func(w io.Writer) error { return display.WriteStatusPage(w, opts) })
+		}
+		if opts.BeeperPath != "" {
+			api.SetAlertAcknowledger(display.AcknowledgeAlert)
+		}
+		if opts.UDMWriteEnabled {
+			actions := display.NewDeviceActions(opts)
+			api.SetDeviceController(actions)
+			api.SetClientController(actions)
+		}
+		err := api.Start(api.Config{
+			Addr:          opts.APIListen,
+			ReadTokens:    opts.APIReadTokens,
+			ControlTokens: opts.APICtlTokens,
+			TLS:           opts.APITLS,
+			TLSCert:       opts.APITLSCert,
+			TLSKey:        opts.APITLSKey,
+			Disabled:      opts.APIDisable,
+			LiveMirror:    opts.Feature("experimental.websocket"),
+		})
+		if err != nil {
+			fmt.Printf("Error starting API: %s\n", err)
+			os.Exit(1)
+		}
+	}
 	display.New(opts)
 }
 
 func init() {
-	flag.Float64Var(&opts.Delay, "delay", 7500, "delay in milliseconds between screens")
+	opts.Delay = display.DefaultDelay
+	flag.Var(&opts.Delay, "delay", "how long each screen is shown, e.g. 7.5s (a plain number is milliseconds)")
+	flag.Var(&opts.ScreenWeights, "screen-weights", "comma-separated screen=weight multipliers of the delay, e.g. speedtest=2,swap=0.5")
+	flag.Var(&opts.RefreshIntervals, "refresh-intervals", "comma-separated collector=duration overrides of how often data is fetched, e.g. network=10m,kubernetes=1m")
+	flag.Var(&opts.ScreenLayouts, "screen-layouts", "comma-separated screen=reading pairs showing one reading in large digits instead of the screen, e.g. speedtest=download,cpu=temperature")
+	flag.Float64Var(&opts.WarningDwell, "warning-dwell", 2, "multiplier of the delay of screens showing a warning (1 to disable)")
 	flag.BoolVar(&opts.Reset, "reset", false, "reset/clear the screen")
 	flag.BoolVar(&opts.Demo, "demo", false, "use fake data for display only")
+	flag.StringVar(&opts.Framebuffer, "framebuffer", "/dev/fb0", "framebuffer device")
+	flag.StringVar(&opts.PanelSize, "panel-size", "160x60", "size of the panel when headless or simulated, e.g. 320x120 to preview a larger display")
+	flag.StringVar(&opts.LEDsDir, "leds-dir", "/sys/class/leds", "directory holding the LEDs, e.g. where /sys/class/leds is mounted in a container")
+	flag.BoolVar(&opts.Headless, "headless", false, "run without framebuffer and LEDs, as a monitoring agent serving the API and metrics")
 	flag.StringVar(&opts.Pidfile, "pidfile", "/var/run/zeromon.pid", "pidfile")
+	flag.StringVar(&opts.TracingEndpoint, "tracing-endpoint", "", "OTLP/HTTP collector receiving OpenTelemetry traces of each refresh, e.g. localhost:4318 (disabled if empty)")
+	flag.Float64Var(&opts.ChaosRate, "chaos-rate", 0, "testing: share of controller and Kubernetes requests (0 to 1) failed on purpose")
+	flag.IntVar(&opts.OutboundRate, "outbound-rate", ratelimit.DefaultRate, "requests a minute sent to each controller, push service or lookup service at most (0 for unlimited)")
+	flag.Var(&opts.OutboundRates, "outbound-rates", "comma-separated host=requests overrides of outbound-rate by destination, e.g. 192.168.1.1=600")
+	flag.Var(&opts.ChaosFaults, "chaos-faults", "testing: comma-separated failures to inject: timeout, unauthorized, malformed, refused, error (default all)")
 	flag.StringVar(&opts.UDMBaseURL, "udm-baseurl", "https://192.168.1.1:443", "UDM Pro base URL")
 	flag.StringVar(&opts.UDMUsername, "udm-username", "", "UDM Pro username")
 	flag.StringVar(&opts.UDMPassword, "udm-password", "", "UDM Pro password")
-	flag.StringVar(&opts.UDMSite, "udm-site", "default", "UDM Pro site ID")
+	flag.StringVar(&opts.UDMSite, "udm-site", "default", "UDM Pro site, by ID or by the name shown in the UniFi interface")
 	flag.StringVar(&opts.UDMVersion, "udm-version", "8.0.28", "UDM Pro controller version")
+	flag.DurationVar(&opts.UDMDetectTimeout, "udm-detect-timeout", network.DefaultTimeouts.Detect, "timeout of the request detecting the controller type")
+	flag.DurationVar(&opts.UDMLoginTimeout, "udm-login-timeout", network.DefaultTimeouts.Login, "timeout of the controller login")
+	flag.DurationVar(&opts.UDMQueryTimeout, "udm-query-timeout", network.DefaultTimeouts.Query, "timeout of each controller API request")
+	flag.DurationVar(&opts.UDMLoginRetry, "udm-login-retry", 10*time.Second, "how long screens reuse the error of a failed controller login before logging in again (0 to always retry)")
+	flag.IntVar(&opts.UDMMaxRequests, "udm-max-requests", network.DefaultMaxRequests, "controller API requests sent at once")
+	flag.BoolVar(&opts.UDMWriteEnabled, "udm-write-enabled", false, "allow actions that change controller state (restart, locate, block, kick)")
+	flag.StringVar(&opts.CaptureAPIDir, "capture-api-dir", "", "debug: write sanitized controller responses to this directory")
+	flag.BoolVar(&opts.DualWANEnabled, "dual-wan-enabled", false, "enable dual WAN failover status screen")
+	flag.BoolVar(&opts.VPNEnabled, "vpn-enabled", false, "enable remote-access VPN sessions screen")
+	flag.BoolVar(&opts.ThreatsEnabled, "threats-enabled", false, "enable IPS/IDS blocked threats screen")
+	flag.BoolVar(&opts.TickerEnabled, "ticker-enabled", false, "enable recent events ticker screen")
+	flag.BoolVar(&opts.UpdatesEnabled, "updates-enabled", false, "enable controller and device firmware updates screen")
+	flag.StringVar(&opts.FirmwareChannel, "firmware-channel", "release", "firmware release channel to compare against (release, release-candidate)")
+	flag.BoolVar(&opts.PortWatchEnabled, "port-watch-enabled", false, "report device port link up/down changes on the ticker")
+	flag.BoolVar(&opts.NewDeviceWatchEnabled, "new-device-watch-enabled", false, "report MAC addresses joining the network for the first time on the ticker and as notifications")
+	flag.Var(&opts.NewDeviceAllowlist, "new-device-allowlist", "comma-separated MAC addresses or prefixes (OUIs) of known guests not to report as new devices")
+	flag.BoolVar(&opts.WiFiEnabled, "wifi-enabled", false, "enable Wi-Fi experience score screen")
+	flag.BoolVar(&opts.AirtimeEnabled, "airtime-enabled", false, "enable channel utilization screen for the busiest access point")
+	flag.IntVar(&opts.AirtimeThreshold, "airtime-threshold", 70, "channel utilization percent that raises a warning when sustained")
+	flag.BoolVar(&opts.DHCPEnabled, "dhcp-enabled", false, "enable DHCP pool utilization screen")
+	flag.IntVar(&opts.DHCPThreshold, "dhcp-threshold", 90, "DHCP pool utilization percent that raises a warning")
+	flag.BoolVar(&opts.DevicesEnabled, "devices-enabled", false, "enable the screen listing the adopted devices one at a time")
+	flag.StringVar(&opts.ButtonDevice, "button-device", "", "input device of a button acting on the devices screen, e.g. /dev/input/event0")
+	flag.BoolVar(&opts.QuotaEnabled, "quota-enabled", false, "enable screen with this month's WAN usage against the data cap")
+	flag.IntVar(&opts.QuotaCap, "quota-cap", 0, "ISP data cap in GB per month (0 for none)")
+	flag.IntVar(&opts.QuotaResetDay, "quota-reset-day", 1, "day of the month (1-28) the ISP billing cycle starts")
+	flag.BoolVar(&opts.DiscoveryEnabled, "discovery-enabled", false, "enable screen counting LAN devices that answer mDNS and SSDP searches")
+	flag.StringVar(&opts.SpeedtestSchedule, "speedtest-schedule", "", "keep the controller's speedtest schedule at an interval (6h), a cron expression or off (needs -udm-write-enabled)")
+	flag.BoolVar(&opts.SpeedtestSummaryEnabled, "speedtest-summary-enabled", false, "enable screen with yesterday's download range and this week's average against last week")
+	flag.StringVar(&opts.Timezone, "timezone", "", "IANA timezone days and weeks are counted in, e.g. Europe/Berlin (system timezone if empty)")
+	flag.BoolVar(&opts.LatencyHeatmapEnabled, "latency-heatmap-enabled", false, "enable the ping monitor and a screen with a 7 day by 24 hour heatmap of its median latency")
+	flag.StringVar(&opts.PingTarget, "ping-target", "1.1.1.1:443", "host:port the ping monitor times TCP connections to")
+	flag.BoolVar(&opts.TopProcessesEnabled, "top-processes-enabled", false, "enable screen listing the processes using the most memory")
+	flag.StringVar(&opts.TopProcessesSort, "top-processes-sort", "memory", "order the top processes screen by memory or cpu")
+	flag.BoolVar(&opts.DiagnosticsEnabled, "diagnostics-enabled", false, "enable cloudkey process diagnostics screen (heap, goroutines, GC)")
+	flag.BoolVar(&opts.VersionScreenEnabled, "version-screen-enabled", false, "enable a screen with the cloudkey version, build, Go runtime and uptime")
+	flag.Var(&opts.Features, "features", "comma-separated experimental features to turn on: experimental.websocket")
+	flag.IntVar(&opts.MemoryLimit, "memory-limit", 0, "restart cloudkey when it holds more than this many MB (0 to disable)")
+	flag.IntVar(&opts.GoroutineLimit, "goroutine-limit", 1000, "raise a health warning above this many goroutines (0 to disable)")
+	opts.HealthRules = display.DefaultHealthRules
+	flag.Var(&opts.HealthRules, "health-rules", "comma-separated metric:warning:critical health thresholds (metrics: cpu, ram, swap, pressure; none to disable)")
+	flag.IntVar(&opts.HealthWindow, "health-window", 1, "consecutive 5 second samples a new health state must last before the LEDs change")
+	flag.StringVar(&opts.LEDProfile, "led-profile", "auto", "LEDs used for health: auto (detect), rackmount, desk or headless")
+	flag.IntVar(&opts.LogoBrightness, "logo-brightness", 100, "brightness of the Ubiquiti logo LED in percent")
+	flag.BoolVar(&opts.LogoBreathing, "logo-breathing", false, "slowly fade the Ubiquiti logo LED in and out instead of keeping it on")
+	flag.StringVar(&opts.BeeperPath, "beeper-path", "", "sysfs file driving a buzzer, beeped when the health becomes critical (disabled if empty)")
+	flag.StringVar(&opts.BeeperQuietHours, "beeper-quiet-hours", "", "daily period when the beeper stays silent, e.g. 22:00-07:00")
+	flag.StringVar(&opts.ActivityIndicator, "activity-indicator", "none", "show each successful data refresh: none, led (pulse the profile's activity LED), glyph (corner of the screen) or both")
+	flag.StringVar(&opts.FreshnessIndicator, "freshness-indicator", "none", "show the age of each screen's data in its bottom right corner: none, dot (dims with age) or age (e.g. 5m)")
+	flag.Var(&opts.ScreenSchedule, "screen-schedule", "semicolon-separated screens=cron windows limiting when screens rotate in, e.g. kubernetes,diagnostics=* 9-17 * * 1-5")
+	flag.Var(&opts.ConditionalScreens, "conditional-screens", "comma-separated screens only shown while they have something to report, e.g. vpn,threats,updates")
+	flag.DurationVar(&opts.ConditionalLinger, "conditional-linger", time.Hour, "how long a conditional screen stays in the rotation after it has nothing left to report")
+	flag.BoolVar(&opts.LEDDryRun, "led-dry-run", false, "log LED changes instead of writing them, for development without LEDs")
+	flag.Var(&opts.GPIOLEDs, "gpio-leds", "comma-separated led=chip:offset GPIO lines driving LEDs on boards without /sys/class/leds, e.g. rack:blue=gpiochip0:17")
+	flag.Var(&opts.HealthLEDs, "health-leds", "comma-separated state=led[:blink] overrides of the LED profile (states: ok, warning, critical)")
 	flag.BoolVar(&opts.K8sEnabled, "k8s-enabled", false, "enable Kubernetes status screen")
 	flag.StringVar(&opts.K8sKubeconfig, "k8s-kubeconfig", "", "path to kubeconfig file (uses in-cluster config if empty)")
+	flag.IntVar(&opts.K8sAuthWarningDays, "k8s-auth-warning-days", 7, "warn this many days before the Kubernetes client certificate or token expires")
+	flag.IntVar(&opts.K8sLatencyWarning, "k8s-latency-warning", 500, "raise a health warning when the 95th percentile Kubernetes API round trip exceeds this many milliseconds (0 to disable)")
+	flag.BoolVar(&opts.K8sHelmEnabled, "k8s-helm-enabled", false, "show failed and stuck Helm releases on the Kubernetes screen")
+	flag.StringVar(&opts.K8sHelmDriver, "k8s-helm-driver", "secret", "Helm storage driver holding the releases: secret or configmap")
+	flag.BoolVar(&opts.GitOpsEnabled, "gitops-enabled", false, "enable the Flux / Argo CD sync status screen (uses the Kubernetes settings)")
+	flag.StringVar(&opts.LeaderElection, "leader-election", "none", "elect one of several instances to publish metrics and notifications: none, lease (Kubernetes Lease) or file (lock file)")
+	flag.StringVar(&opts.LeaderLease, "leader-lease", "default/cloudkey", "namespace/name of the Lease used with -leader-election lease")
+	flag.StringVar(&opts.LeaderLockFile, "leader-lock-file", "/run/lock/cloudkey.lock", "lock file used with -leader-election file")
+	flag.StringVar(&opts.Upstream, "upstream", "", "mirror the screens of another cloudkey's API, e.g. https://rack.lan:8080, instead of collecting data")
+	flag.StringVar(&opts.UpstreamToken, "upstream-token", "", "read token for the upstream API")
+	flag.BoolVar(&opts.K8sMessagesEnabled, "k8s-messages-enabled", false, "enable the screen showing CloudKeyScreen resources from the cluster")
+	flag.StringVar(&opts.K8sMessagesNamespace, "k8s-messages-namespace", "", "namespace watched for CloudKeyScreen resources (all namespaces if empty)")
+	flag.BoolVar(&opts.AlertmanagerEnabled, "alertmanager-enabled", false, "enable the screen listing the alerts firing in Prometheus Alertmanager")
+	flag.StringVar(&opts.AlertmanagerURL, "alertmanager-url", "http://localhost:9093", "Alertmanager URL, with user:password@ for basic auth")
+	flag.Var(&opts.AlertmanagerFilter, "alertmanager-filter", "comma-separated Alertmanager matchers the alerts shown must match, e.g. team=\"infra\"")
+	flag.BoolVar(&opts.AlertmanagerHealth, "alertmanager-health", true, "raise the health LEDs to the severity of the worst firing alert")
+	flag.StringVar(&opts.StateDir, "state-dir", "/var/lib/cloudkey", "directory for persistent state such as speedtest history")
+	flag.BoolVar(&opts.CrashRestart, "crash-restart", true, "save a crash report to the state directory and restart in place on a panic, instead of exiting")
+	flag.BoolVar(&opts.StatusPageEnabled, "status-page-enabled", false, "serve a plain status page for the household at /status on the API, without authentication")
+	flag.StringVar(&opts.StatusPageDir, "status-page-dir", "", "also write the status page as index.html to this directory every minute (disabled if empty)")
+	flag.StringVar(&opts.APIListen, "api-listen", "", "address for the local HTTP API, e.g. :8080 (disabled if empty)")
+	flag.Var(&opts.APIReadTokens, "api-read-tokens", "comma-separated API tokens with read-only access")
+	flag.Var(&opts.APICtlTokens, "api-control-tokens", "comma-separated API tokens with control access")
+	flag.BoolVar(&opts.APITLS, "api-tls", false, "serve the API over TLS")
+	flag.StringVar(&opts.APITLSCert, "api-tls-cert", "/var/lib/cloudkey/api.crt", "API TLS certificate (self-signed one is generated if missing)")
+	flag.StringVar(&opts.APITLSKey, "api-tls-key", "/var/lib/cloudkey/api.key", "API TLS private key")
+	flag.Var(&opts.APIDisable, "api-disable", "comma-separated API endpoints to disable (events, metrics, health, config, version, alerts, mirror, devices, clients)")
+	opts.OutageProbes = display.StringList{"1.1.1.1:443", "9.9.9.9:443"}
+	flag.Var(&opts.OutageProbes, "outage-probes", "comma-separated host:port pairs probed on every network refresh to detect a WAN outage (none to disable)")
+	flag.StringVar(&opts.WANIPMask, "wan-ip-mask", "none", "hide the public IP on the network screen: none, partial (203.0.x.x) or asn (the provider's name)")
+	flag.BoolVar(&opts.WANEnrich, "wan-enrich", false, "write the provider and ASN of the public IP after it on the network screen, e.g. Comcast (AS7922)")
+	opts.WANIPProviders = display.StringList{"ipify", "icanhazip", "cloudflare", "controller"}
+	flag.Var(&opts.WANIPProviders, "wan-ip-providers", "comma-separated WAN IP providers asked in turn: ipify, icanhazip, cloudflare, controller")
+	flag.IntVar(&opts.WANIPAgree, "wan-ip-agree", 1, "how many WAN IP providers must report the same address")
+	flag.BoolVar(&opts.ExternalLookups, "external-lookups", true, "look up the public IP and new cloudkey releases with third-party services (false asks only the controller for the IP)")
+	flag.StringVar(&opts.NtfyURL, "ntfy-url", "", "ntfy topic URL to push notifications to, e.g. https://ntfy.sh/my-rack (disabled if empty)")
+	flag.StringVar(&opts.NtfyToken, "ntfy-token", "", "ntfy access token for protected topics")
+	flag.StringVar(&opts.GotifyURL, "gotify-url", "", "Gotify server URL to push notifications to (disabled if empty)")
+	flag.StringVar(&opts.GotifyToken, "gotify-token", "", "Gotify application token")
+	flag.StringVar(&opts.NotifyMinSeverity, "notify-min-severity", "warning", "least severe notification pushed: info, warning or critical")
+	flag.StringVar(&opts.TelegramToken, "telegram-token", "", "Telegram bot token, to send alerts and answer commands (disabled if empty)")
+	flag.Var(&opts.TelegramChats, "telegram-chats", "comma-separated Telegram chat IDs receiving alerts and allowed to send commands")
+	flag.StringVar(&opts.SMTPHost, "smtp-host", "", "SMTP server for critical alerts and the daily digest (disabled if empty)")
+	flag.IntVar(&opts.SMTPPort, "smtp-port", 587, "SMTP server port")
+	flag.StringVar(&opts.SMTPUsername, "smtp-username", "", "SMTP username (no authentication if empty)")
+	flag.StringVar(&opts.SMTPPassword, "smtp-password", "", "SMTP password")
+	flag.StringVar(&opts.SMTPTLS, "smtp-tls", "starttls", "SMTP encryption: starttls, tls (implicit, usually port 465) or none")
+	flag.StringVar(&opts.SMTPFrom, "smtp-from", "", "sender address of alert emails")
+	flag.Var(&opts.SMTPTo, "smtp-to", "comma-separated recipients of alert emails")
+	flag.BoolVar(&opts.EmailDigest, "email-digest", false, "also mail a daily digest of speedtests, outages and health transitions")
+	flag.StringVar(&opts.EmailDigestTime, "email-digest-time", "07:00", "time of day the digest is mailed, in the configured timezone")
 	flag.BoolVar(&opts.Version, "version", false, "print version and exit")
-	flagutil.SetFlagsFromEnv(flag.CommandLine, "CLOUDKEY")
+	if err := flagutil.SetFlagsFromEnv(flag.CommandLine, "CLOUDKEY"); err != nil {
+		display.SignalConfigError(err, opts.Headless)
+	}
 	flag.Parse()
 
 	if opts.Version {
 		// already printed version
 		os.Exit(0)
 	}
+}
 
-	pid, err := pidfile.Create(opts.Pidfile)
-	if err != nil {
-		fmt.Printf("Error creating PID file: %s\n", err)
-		os.Exit(1)
+// startService creates the pidfile and shuts down cleanly on SIGINT/SIGTERM
+func startService() {
+	// After a re-exec the pidfile already holds this process, which Create takes for another
+	// instance: it would write a .tmp file instead, which Clear then moves over the pidfile
+	pid := pidfile.NewPidFile(opts.Pidfile)
+	if own, err := pid.ReadPidFromFile(pid.File); err != nil || own.Id != os.Getpid() {
+		if err := pid.Create(); err != nil {
+			fmt.Printf("Error creating PID file: %s\n", err)
+			os.Exit(1)
+		}
 	}
 
 	// Setup Service
@@ -66,6 +286,11 @@ func init() {
 		s := <-sigs
 		display.Shutdown()
 		fmt.Printf("Received signal '%s', shutting down\n", s)
+		if stopTracing != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			_ = stopTracing(ctx)
+			cancel()
+		}
 		fmt.Println("Stopping cloudkey service")
 		_ = pid.Clear()
 		os.Exit(1)
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/coreos/pkg/flagutil"
+
+	"cloudkey/display"
+	"cloudkey/src/envfile"
+)
+
+// defaultConfigFile is the environment file loaded by cloudkey.service
+const defaultConfigFile = "/etc/cloudkey.env"
+
+// configCommand runs `cloudkey config <subcommand>` and returns the exit code
+func configCommand(args []string) int {
+	if len(args) == 0 || args[0] != "validate" {
+		fmt.Println("Usage: cloudkey config validate [file]")
+		return 2
+	}
+
+	path := defaultConfigFile
+	if len(args) > 1 {
+		path = args[1]
+	}
+	return validateConfig(path)
+}
+
+// validateConfig applies an environment file on top of the current options and reports every problem
+func validateConfig(path string) int {
+	if err := envfile.Load(path); err != nil {
+		fmt.Printf("Error: %s\n", err)
+		return 1
+	}
+	if err := flagutil.SetFlagsFromEnv(flag.CommandLine, "CLOUDKEY"); err != nil {
+		fmt.Printf("%s: %s\n", path, err)
+		return 1
+	}
+
+	errs := opts.Validate()
+	for _, err := range errs {
+		fmt.Printf("%s: %s\n", path, optionHint(err))
+	}
+	if len(errs) > 0 {
+		return display.ExitCode(display.CodeConfigError, errs[0])
+	}
+
+	fmt.Printf("%s: OK\n", path)
+	fmt.Printf("Health rules: %s\n", opts.HealthRules.String())
+	fmt.Printf("LED profile: %s\n", opts.LEDProfile)
+	if len(opts.HealthLEDs) > 0 {
+		fmt.Printf("Health LED overrides: %s\n", opts.HealthLEDs.String())
+	}
+	for _, k := range ignoredKeys() {
+		fmt.Printf("Ignored %s: %s\n", k.Key, k.Reason)
+	}
+	return 0
+}
+
+// optionHint adds where the option of an *OptionError is set, so the message says what to change
+func optionHint(err error) string {
+	var optionErr *display.OptionError
+	if !errors.As(err, &optionErr) {
+		return err.Error()
+	}
+	return fmt.Sprintf("%s (set with -%s or %s)", err, optionErr.Option, envName(optionErr.Option))
+}
+
+// dependentKeys are options only read while another option is on
+var dependentKeys = map[string]string{
+	"airtime-threshold":      "airtime-enabled",
+	"dhcp-threshold":         "dhcp-enabled",
+	"quota-cap":              "quota-enabled",
+	"quota-reset-day":        "quota-enabled",
+	"ping-target":            "latency-heatmap-enabled",
+	"top-processes-sort":     "top-processes-enabled",
+	"firmware-channel":       "updates-enabled",
+	"new-device-allowlist":   "new-device-watch-enabled",
+	"k8s-helm-driver":        "k8s-helm-enabled",
+	"k8s-messages-namespace": "k8s-messages-enabled",
+	"upstream-token":         "upstream",
+	"email-digest-time":      "email-digest",
+	"button-device":          "devices-enabled",
+	"alertmanager-url":       "alertmanager-enabled",
+	"alertmanager-filter":    "alertmanager-enabled",
+	"alertmanager-health":    "alertmanager-enabled",
+}
+
+// ignoredKeys lists the CLOUDKEY_ variables of the environment that have no effect: those
+// matching no option, overridden on the command line, or belonging to a feature that is off
+func ignoredKeys() []display.IgnoredKey {
+	onCommandLine := map[string]bool{}
+	for _, arg := range os.Args[1:] {
+		if arg == "--" || !strings.HasPrefix(arg, "-") {
+			break
+		}
+		name, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		onCommandLine[name] = true
+	}
+
+	var ignored []display.IgnoredKey
+	for _, env := range os.Environ() {
+		key, value, _ := strings.Cut(env, "=")
+		if !strings.HasPrefix(key, "CLOUDKEY_") || value == "" {
+			continue
+		}
+		name := strings.ReplaceAll(strings.ToLower(strings.TrimPrefix(key, "CLOUDKEY_")), "_", "-")
+
+		reason := ""
+		if flag.Lookup(name) == nil {
+			reason = "no such option"
+		} else if onCommandLine[name] {
+			reason = "overridden by -" + name + " on the command line"
+		} else if dep, ok := dependentKeys[name]; ok {
+			if v := flag.Lookup(dep).Value.String(); v == "false" || v == "" {
+				reason = "only used with " + strings.ToUpper(strings.ReplaceAll("cloudkey_"+dep, "-", "_"))
+			}
+		}
+		if reason != "" {
+			ignored = append(ignored, display.IgnoredKey{Key: key, Reason: reason})
+		}
+	}
+	sort.Slice(ignored, func(a, b int) bool { return ignored[a].Key < ignored[b].Key })
+	return ignored
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/coreos/pkg/flagutil"
+	build "github.com/jnovack/go-version"
+
+	"cloudkey/display"
+	"cloudkey/src/api"
+	"cloudkey/src/envfile"
+)
+
+// diagCaptures is how many of the most recent API captures go into a bundle
+const diagCaptures = 50
+
+// diagCommand runs `cloudkey diag [flags]` and returns the exit code
+func diagCommand(args []string) int {
+	fs := flag.NewFlagSet("diag", flag.ContinueOnError)
+	config := fs.String("config", defaultConfigFile, "environment file the service runs with, empty to use the current environment only")
+	since := fs.String("since", "24h", "how far back to collect logs and API captures, e.g. 24h or 7d")
+	unit := fs.String("unit", "cloudkey", "systemd unit whose journal is collected")
+	output := fs.String("output", "", "tarball to write (default cloudkey-diag-<time>.tar.gz)")
+	if err := fs.Parse(args); err != nil || fs.NArg() > 0 {
+		fmt.Println("Usage: cloudkey diag [--config /etc/cloudkey.env] [--since 24h] [--unit cloudkey] [--output file]")
+		return 2
+	}
+	window, err := parseSince(*since)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 2
+	}
+
+	// Collect what the service sees, not what this shell happens to have set
+	if *config != "" {
+		if _, err := os.Stat(*config); err == nil {
+			if err := envfile.Load(*config); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return 1
+			}
+			if err := flagutil.SetFlagsFromEnv(flag.CommandLine, "CLOUDKEY"); err != nil {
+				fmt.Printf("%s: %s\n", *config, err)
+				return 1
+			}
+		}
+	}
+
+	now := time.Now()
+	name := "cloudkey-diag-" + now.Format("20060102-150405")
+	if *output == "" {
+		*output = name + ".tar.gz"
+	}
+
+	b := &diagBundle{dir: name, modTime: now}
+	b.add("version.txt", diagVersion())
+	b.add("config.env", redactedConfig())
+	b.add("validation.txt", diagValidation())
+	b.addJSON("hardware.json", display.DetectHardware(opts))
+	b.addJournal(*unit, window)
+	b.addInstance()
+	b.addCaptures(now.Add(-window))
+	b.addCrashes(now.Add(-window))
+
+	file, err := os.OpenFile(*output, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+	defer file.Close()
+	if err := b.write(file); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("Wrote %s (%d files)\n", *output, len(b.files))
+	for _, note := range b.notes {
+		fmt.Printf("  not included: %s\n", note)
+	}
+	fmt.Println("Secrets are redacted, but the bundle holds addresses and names from your network: look through it before attaching it to a public issue.")
+	return 0
+}
+
+// diagFile is a file of the bundle
+type diagFile struct {
+	name    string
+	data    []byte
+	modTime time.Time
+}
+
+// diagBundle gathers the files of the tarball, noting what could not be collected
+type diagBundle struct {
+	dir     string
+	modTime time.Time
+	files   []diagFile
+	notes   []string
+}
+
+// add adds a file written now
+func (b *diagBundle) add(name string, data []byte) {
+	b.files = append(b.files, diagFile{name, data, b.modTime})
+}
+
+// addJSON adds v as indented JSON
+func (b *diagBundle) addJSON(name string, v any) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		b.note("%s: %v", name, err)
+		return
+	}
+	b.add(name, append(data, '\n'))
+}
+
+// note records something missing from the bundle, for notes.txt and the summary
+func (b *diagBundle) note(format string, args ...any) {
+	b.notes = append(b.notes, fmt.Sprintf(format, args...))
+}
+
+// addJournal adds the logs of the service from the systemd journal
+func (b *diagBundle) addJournal(unit string, window time.Duration) {
+	since := fmt.Sprintf("-%ds", int(window.Seconds()))
+	out, err := exec.Command("journalctl", "--unit", unit, "--since", since, "--no-pager", "--output", "short-iso").Output()
+	if err != nil {
+		b.note("journal of %s: %v (in a container, attach `docker logs` instead)", unit, err)
+		return
+	}
+	b.add("journal.log", out)
+}
+
+// addInstance adds the health, configuration report and metrics of the running instance,
+// read through its API
+func (b *diagBundle) addInstance() {
+	if opts.APIListen == "" {
+		b.note("health, configuration report and metrics of the running instance: the API is off (CLOUDKEY_API_LISTEN)")
+		return
+	}
+	host, port, err := net.SplitHostPort(opts.APIListen)
+	if err != nil {
+		b.note("running instance: api-listen %q: %v", opts.APIListen, err)
+		return
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+	}
+	scheme := "http"
+	if opts.APITLS {
+		scheme = "https"
+	}
+	token := ""
+	if len(opts.APIReadTokens) > 0 {
+		token = opts.APIReadTokens[0]
+	}
+	client := api.NewClient(scheme+"://"+net.JoinHostPort(host, port), token)
+
+	for _, file := range []struct{ name, path string }{
+		{"instance/health.json", "/api/health"},
+		{"instance/config.json", "/api/config"},
+		{"instance/metrics.txt", "/metrics"},
+	} {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		data, err := client.Get(ctx, file.path)
+		cancel()
+		if err != nil {
+			b.note("%s: %v", file.name, err)
+			continue
+		}
+		b.add(file.name, data)
+	}
+}
+
+// addCaptures adds the most recent controller responses captured since, which are sanitized
+// when written
+func (b *diagBundle) addCaptures(since time.Time) {
+	if opts.CaptureAPIDir == "" {
+		return
+	}
+	entries, err := os.ReadDir(opts.CaptureAPIDir)
+	if err != nil {
+		b.note("API captures: %v", err)
+		return
+	}
+
+	var recent []diagFile
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() || info.ModTime().Before(since) || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		recent = append(recent, diagFile{name: entry.Name(), modTime: info.ModTime()})
+	}
+	sort.Slice(recent, func(i, j int) bool { return recent[i].modTime.After(recent[j].modTime) })
+	if len(recent) > diagCaptures {
+		b.note("API captures: only the %d most recent of %d", diagCaptures, len(recent))
+		recent = recent[:diagCaptures]
+	}
+
+	for _, f := range recent {
+		data, err := os.ReadFile(filepath.Join(opts.CaptureAPIDir, f.name))
+		if err != nil {
+			b.note("API capture %s: %v", f.name, err)
+			continue
+		}
+		b.files = append(b.files, diagFile{"captures/" + f.name, data, f.modTime})
+	}
+}
+
+// addCrashes adds the crash reports written since
+func (b *diagBundle) addCrashes(since time.Time) {
+	paths, _ := filepath.Glob(filepath.Join(opts.StateDir, "crashes", "crash-*.txt"))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || info.ModTime().Before(since) {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			b.note("crash report %s: %v", filepath.Base(path), err)
+			continue
+		}
+		b.files = append(b.files, diagFile{"crashes/" + filepath.Base(path), data, info.ModTime()})
+	}
+}
+
+// write writes the bundle as a gzipped tarball, with notes.txt listing what is missing
+func (b *diagBundle) write(w *os.File) error {
+	if len(b.notes) > 0 {
+		b.add("notes.txt", []byte(strings.Join(b.notes, "\n")+"\n"))
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, f := range b.files {
+		header := &tar.Header{
+			Name:    b.dir + "/" + f.name,
+			Mode:    0600,
+			Size:    int64(len(f.data)),
+			ModTime: f.modTime,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			return err
+		}
+	}
+	return errors.Join(tw.Close(), gz.Close())
+}
+
+// diagVersion describes the build and the system it runs on
+func diagVersion() []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "version: %s\n", build.Version)
+	fmt.Fprintf(&buf, "commit: %s\n", build.Commit)
+	fmt.Fprintf(&buf, "built: %s\n", build.BuildRFC3339)
+	fmt.Fprintf(&buf, "go: %s %s/%s\n", build.GoVersion, runtime.GOOS, runtime.GOARCH)
+	if hostname, err := os.Hostname(); err == nil {
+		fmt.Fprintf(&buf, "hostname: %s\n", hostname)
+	}
+	if kernel, err := os.ReadFile("/proc/version"); err == nil {
+		fmt.Fprintf(&buf, "kernel: %s", kernel)
+	}
+	fmt.Fprintf(&buf, "collected: %s\n", time.Now().Format(time.RFC3339))
+	return buf.Bytes()
+}
+
+// diagValidation lists the problems cloudkey config validate would report
+func diagValidation() []byte {
+	var buf bytes.Buffer
+	errs := opts.Validate()
+	for _, err := range errs {
+		fmt.Fprintln(&buf, optionHint(err))
+	}
+	if len(errs) == 0 {
+		fmt.Fprintln(&buf, "OK")
+	}
+	for _, k := range ignoredKeys() {
+		fmt.Fprintf(&buf, "Ignored %s: %s\n", k.Key, k.Reason)
+	}
+	return buf.Bytes()
+}
+
+// redactedConfig writes every option as an environment file, with credentials replaced
+func redactedConfig() []byte {
+	var lines []string
+	flag.VisitAll(func(f *flag.Flag) {
+		lines = append(lines, envName(f.Name)+"="+envfile.Quote(redactOption(f.Name, f.Value.String())))
+	})
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+// redactOption hides the value of passwords and tokens, and the password of URLs
+func redactOption(name, value string) string {
+	if value == "" {
+		return value
+	}
+	for _, secret := range []string{"password", "token", "secret"} {
+		if strings.Contains(name, secret) {
+			return "REDACTED"
+		}
+	}
+	if u, err := url.Parse(value); err == nil && u.User != nil {
+		return u.Redacted()
+	}
+	return value
+}
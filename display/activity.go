@@ -0,0 +1,118 @@
+package display
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/draw"
+	"sync/atomic"
+	"time"
+
+	"cloudkey/src/leds"
+	"cloudkey/src/metrics"
+	"cloudkey/src/tracing"
+)
+
+const (
+	// activityPulse is how long the activity LED changes state after a refresh
+	activityPulse = 150 * time.Millisecond
+	// activityGlyphTime is how long the activity glyph stays on screen after a refresh
+	activityGlyphTime = time.Second
+)
+
+// activityGlyphRect is where the activity glyph is drawn, in the top right corner
+var activityGlyphRect = image.Rect(155, 1, 159, 5)
+
+var (
+	activityLED     string // LED pulsed on refresh, empty when disabled
+	activityLEDOn   bool   // the LED is normally on, so the pulse turns it off instead
+	activityGlyph   bool   // draw the glyph on refresh
+	activityGlyphUp bool   // the glyph is currently on the framebuffer
+	activityPulsing atomic.Bool
+	lastActivity    atomic.Int64 // unix nanoseconds of the last refresh
+)
+
+// ledProfile returns the configured LED profile, falling back to detection
+func ledProfile(opts CmdLineOpts) leds.Profile {
+	if headless {
+		return leds.Profiles["headless"]
+	}
+	profile, err := leds.SelectProfile(opts.LEDProfile)
+	if err != nil {
+		fmt.Printf("Error selecting LED profile: %v\n", err)
+		return leds.DetectProfile()
+	}
+	return profile
+}
+
+// startActivity sets up the activity indicator selected by -activity-indicator
+func startActivity(opts CmdLineOpts) {
+	mode := opts.ActivityIndicator
+	if mode == "led" || mode == "both" {
+		profile := ledProfile(opts)
+		activityLED = profile.Activity
+		activityLEDOn = profile.Activity == profile.Running
+		if activityLED == "" {
+			fmt.Printf("LED profile %s has no activity LED\n", profile.Name)
+		}
+	}
+	activityGlyph = mode == "glyph" || mode == "both"
+}
+
+// activity signals that the collector of a screen successfully fetched new data
+func activity(screen string) {
+	lastActivity.Store(time.Now().UnixNano())
+	markRefreshed(screen)
+
+	// Pulses arriving while one is running are merged into it
+	if activityLED == "" || !activityPulsing.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer recoverCrash()
+		defer activityPulsing.Store(false)
+
+		led := myLeds.LED(activityLED)
+		if activityLEDOn {
+			led.Off()
+			time.Sleep(activityPulse)
+			restoreLogo(activityLED)
+		} else {
+			led.On()
+			time.Sleep(activityPulse)
+			led.Off()
+		}
+	}()
+}
+
+// drawActivityGlyph shows or clears the activity glyph on the framebuffer. It is called by the
+// renderer with fbMutex held.
+func drawActivityGlyph() {
+	if !activityGlyph {
+		return
+	}
+
+	recent := time.Since(time.Unix(0, lastActivity.Load())) < activityGlyphTime
+	if recent {
+		fill(fb, activityGlyphRect, image.White)
+	} else if activityGlyphUp {
+		draw.Draw(fb, scaled(activityGlyphRect), presented, scaled(activityGlyphRect).Min, draw.Src)
+	}
+	activityGlyphUp = recent
+}
+
+// collect starts the trace of one refresh of a screen under parent, ended with the refresh's
+// error, which is also counted in the collector metrics
+func collect(parent context.Context, name string) (context.Context, func(error)) {
+	lastCollector.Store(name)
+	ctx, span := tracing.Span(parent, "collect "+name)
+	start := time.Now()
+	return ctx, func(err error) {
+		tracing.End(span, err)
+		metrics.Set("cloudkey_collector_duration_seconds", "How long the last refresh of a collector took.", time.Since(start).Seconds(), "collector", name)
+		metrics.Add("cloudkey_collector_runs_total", "Refreshes of a collector.", 1, "collector", name)
+		if err != nil {
+			metrics.Add("cloudkey_collector_errors_total", "Refreshes of a collector that failed.", 1, "collector", name)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package display
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/draw"
+	"time"
+
+	"cloudkey/images"
+	"cloudkey/src/alertmanager"
+	"cloudkey/src/metrics"
+)
+
+// alertSeverities are the severity buckets of cloudkey_alertmanager_alerts
+var alertSeverities = []string{"critical", "warning", "info"}
+
+// alertmanagerLines summarizes the firing alerts: how many fire, then the two most severe
+func alertmanagerLines(alerts []alertmanager.Alert) (string, string, string) {
+	total := 0
+	for _, a := range alerts {
+		total += a.Count
+	}
+
+	lines := [3]string{fmt.Sprintf("%d alerts firing", total)}
+	switch total {
+	case 0:
+		lines[0] = "No alerts firing"
+	case 1:
+		lines[0] = "1 alert firing"
+	}
+	for n, a := range alerts {
+		if n == 2 {
+			break
+		}
+		lines[n+1] = alertLine(a)
+	}
+	return lines[0], lines[1], lines[2]
+}
+
+// alertLine shows an alert as its severity, name and how many fire, e.g. CRIT NodeDown x2
+func alertLine(a alertmanager.Alert) string {
+	severity := "info"
+	switch alertmanager.SeverityRank(a.Severity) {
+	case 2:
+		severity = "CRIT"
+	case 1:
+		severity = "WARN"
+	}
+	line := severity + " " + a.Name
+	if a.Count > 1 {
+		line += fmt.Sprintf(" x%d", a.Count)
+	}
+	return line
+}
+
+// alertHealthLevel is the health the most severe firing alert holds the LEDs at
+func alertHealthLevel(alerts []alertmanager.Alert) HealthState {
+	level := HealthOK
+	for _, a := range alerts {
+		switch alertmanager.SeverityRank(a.Severity) {
+		case 2:
+			return HealthCritical
+		case 1:
+			level = HealthWarning
+		}
+	}
+	return level
+}
+
+// buildAlertmanager lists the alerts firing in Alertmanager and, unless -alertmanager-health is
+// off, mirrors the most severe onto the health LEDs
+func buildAlertmanager(i int, demo bool, opts CmdLineOpts) {
+	screen := setupImage(i)
+
+	draw.Draw(screen, screen.Bounds(), image.Black, image.ZP, draw.Src)
+	drawIcon(screen, 0, images.Load("host"))
+
+	if demo {
+		countMsg, firstMsg, secondMsg := alertmanagerLines([]alertmanager.Alert{
+			{Name: "NodeDown", Severity: "critical", Count: 1},
+			{Name: "DiskFilling", Severity: "warning", Count: 2},
+		})
+		drawIcon(screen, 0, images.Badged("host", "warning"))
+		writeRow(screen, 0, countMsg)
+		writeRow(screen, 1, firstMsg)
+		writeRow(screen, 2, secondMsg)
+		return
+	}
+
+	client := alertmanager.NewClient(opts.AlertmanagerURL)
+
+	run := pipelineContext(i)
+	go func() {
+		defer recoverCrash()
+		for {
+			var countMsg, firstMsg, secondMsg string
+			var badge string // on the host icon
+
+			ctx, done := collect(run, "alertmanager")
+			ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+			alerts, err := client.Firing(ctx, opts.AlertmanagerFilter)
+			cancel()
+			done(err)
+			if run.Err() != nil {
+				return
+			}
+
+			if err != nil {
+				// The LEDs keep the last severity seen rather than turning green while blind
+				fmt.Printf("Error fetching Alertmanager alerts: %v\n", err)
+				countMsg = "Alertmanager"
+				firstMsg = "unavailable"
+				secondMsg = "check logs"
+			} else {
+				activity("alertmanager")
+				countMsg, firstMsg, secondMsg = alertmanagerLines(alerts)
+
+				counts := map[string]int{}
+				for _, a := range alerts {
+					counts[alertSeverities[2-alertmanager.SeverityRank(a.Severity)]] += a.Count
+				}
+				for _, severity := range alertSeverities {
+					metrics.Set("cloudkey_alertmanager_alerts", "Alerts firing in Alertmanager by severity.", float64(counts[severity]), "severity", severity)
+				}
+
+				level := alertHealthLevel(alerts)
+				if opts.AlertmanagerHealth {
+					raiseHealth("alertmanager", level)
+				}
+				setRelevant("alertmanager", len(alerts) > 0)
+				if level > HealthOK {
+					badge = "warning"
+				}
+			}
+
+			redraw(i, func(screen draw.Image) {
+				drawIcon(screen, 0, images.Badged("host", badge))
+				clearText(screen)
+				writeRow(screen, 0, countMsg)
+				writeRow(screen, 1, firstMsg)
+				writeRow(screen, 2, secondMsg)
+			})
+
+			if !pause(run, refreshInterval("alertmanager")) {
+				return
+			}
+		}
+	}()
+}
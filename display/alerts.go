@@ -0,0 +1,135 @@
+package display
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cloudkey/src/beeper"
+	"cloudkey/src/events"
+)
+
+// alertRepeat is how often the beeper sounds again while the health stays CRITICAL
+const alertRepeat = 5 * time.Minute
+
+// alertPattern is three short beeps
+var alertPattern = []time.Duration{150 * time.Millisecond, 150 * time.Millisecond, 150 * time.Millisecond}
+
+var (
+	alertBeeper   *beeper.Beeper
+	alertQuiet    quietHours
+	alertMutex    sync.Mutex
+	alertAcked    bool      // the current CRITICAL period was acknowledged
+	alertLastBeep time.Time // when the beeper last sounded
+)
+
+// quietHours is a daily period, possibly spanning midnight, during which the beeper stays silent
+type quietHours struct {
+	start, end int // minutes after midnight; equal when there are no quiet hours
+}
+
+// parseQuietHours parses "22:00-07:00" (or "22-7"); an empty value disables quiet hours
+func parseQuietHours(value string) (quietHours, error) {
+	if value == "" {
+		return quietHours{}, nil
+	}
+
+	from, to, ok := strings.Cut(value, "-")
+	if !ok {
+		return quietHours{}, fmt.Errorf("quiet hours %q: expected HH:MM-HH:MM", value)
+	}
+	start, err := parseClock(from)
+	if err != nil {
+		return quietHours{}, fmt.Errorf("quiet hours %q: %v", value, err)
+	}
+	end, err := parseClock(to)
+	if err != nil {
+		return quietHours{}, fmt.Errorf("quiet hours %q: %v", value, err)
+	}
+	return quietHours{start: start, end: end}, nil
+}
+
+// parseClock parses HH:MM or HH into minutes after midnight
+func parseClock(value string) (int, error) {
+	hours, minutes, _ := strings.Cut(strings.TrimSpace(value), ":")
+	h, err := strconv.Atoi(hours)
+	if err != nil || h < 0 || h > 23 {
+		return 0, fmt.Errorf("invalid hour %q", hours)
+	}
+	m := 0
+	if minutes != "" {
+		m, err = strconv.Atoi(minutes)
+		if err != nil || m < 0 || m > 59 {
+			return 0, fmt.Errorf("invalid minute %q", minutes)
+		}
+	}
+	return h*60 + m, nil
+}
+
+// contains reports whether t falls within the quiet hours
+func (q quietHours) contains(t time.Time) bool {
+	now := t.Hour()*60 + t.Minute()
+	if q.start == q.end {
+		return false
+	}
+	if q.start < q.end {
+		return now >= q.start && now < q.end
+	}
+	return now >= q.start || now < q.end
+}
+
+// startBeeper enables audible alerts when a beeper is configured
+func startBeeper(opts CmdLineOpts) {
+	if opts.BeeperPath == "" {
+		return
+	}
+
+	b := beeper.New(opts.BeeperPath)
+	if !b.Available() {
+		fmt.Printf("Beeper %s not found - audible alerts disabled\n", opts.BeeperPath)
+		return
+	}
+	// Validated at startup
+	alertQuiet, _ = parseQuietHours(opts.BeeperQuietHours)
+	alertBeeper = b
+	fmt.Printf("Beeper enabled on %s\n", opts.BeeperPath)
+}
+
+// alertHealth sounds the beeper when the health becomes CRITICAL, and again every alertRepeat
+// until it is acknowledged or the health recovers. It is called by the health monitor.
+func alertHealth(health HealthState) {
+	if alertBeeper == nil {
+		return
+	}
+
+	alertMutex.Lock()
+	defer alertMutex.Unlock()
+
+	if health != HealthCritical {
+		alertAcked = false
+		alertLastBeep = time.Time{}
+		return
+	}
+	if alertAcked || time.Since(alertLastBeep) < alertRepeat || alertQuiet.contains(time.Now()) {
+		return
+	}
+
+	alertLastBeep = time.Now()
+	go func() {
+		defer recoverCrash()
+		if err := alertBeeper.Beep(alertPattern, 100*time.Millisecond); err != nil {
+			fmt.Printf("Beeper error: %v\n", err)
+		}
+	}()
+}
+
+// AcknowledgeAlert silences the beeper until the health next becomes CRITICAL
+func AcknowledgeAlert() {
+	alertMutex.Lock()
+	alertAcked = true
+	alertMutex.Unlock()
+
+	events.Publish("alert_ack", map[string]string{"state": healthState().String()})
+}
@@ -0,0 +1,181 @@
+package display
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/llajas/cloudkey/src/panel"
+)
+
+const (
+	// bigDigitsMax is the largest font size of a big reading, the smallest is bigDigitsMin
+	bigDigitsMax, bigDigitsMin = 42, 16
+	// bigLabelSize is the font size of the reading's name above it and of its unit
+	bigLabelSize = 9
+)
+
+// bigReadings are the values a screen can show in large digits, with their label
+var bigReadings = map[string]string{
+	"download":    "Download",    // latest speedtest
+	"upload":      "Upload",      // latest speedtest
+	"latency":     "Latency",     // latest speedtest
+	"cpu":         "CPU",         // cloudkey host
+	"ram":         "RAM",         // cloudkey host
+	"temperature": "Temperature", // hottest thermal zone of the cloudkey host
+	"clients":     "Clients",     // connected clients, from the new device watch
+}
+
+// ScreenLayouts replace the three lines of some screens with one reading in large digits,
+// usable with flag.Var
+type ScreenLayouts map[string]string
+
+// String joins the layouts back into their flag form
+func (l *ScreenLayouts) String() string {
+	var parts []string
+	for screen, reading := range *l {
+		parts = append(parts, screen+"="+reading)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+// Set replaces the layouts, e.g. "speedtest=download,cpu=temperature"
+func (l *ScreenLayouts) Set(value string) error {
+	layouts := make(ScreenLayouts)
+
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		screen, reading, ok := strings.Cut(item, "=")
+		if !ok {
+			return fmt.Errorf("screen layout %q: expected screen=reading", item)
+		}
+		if _, ok := bigReadings[reading]; !ok {
+			names := make([]string, 0, len(bigReadings))
+			for name := range bigReadings {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return fmt.Errorf("screen layout %q: unknown reading %q (one of %s)", item, reading, strings.Join(names, ", "))
+		}
+		layouts[screen] = reading
+	}
+
+	*l = layouts
+	return nil
+}
+
+var (
+	screenLayouts ScreenLayouts // from -screen-layouts
+
+	readingsMutex sync.Mutex
+	readings      = map[string]string{} // reading name to its latest value, e.g. "1.2 Gb/s"
+)
+
+// setReading records the latest value of a reading, a number optionally followed by a space
+// and its unit, and redraws the screens showing it
+func setReading(name, value string) {
+	readingsMutex.Lock()
+	changed := readings[name] != value
+	readings[name] = value
+	readingsMutex.Unlock()
+
+	if !changed || !screensBuilt.Load() {
+		return
+	}
+	for i, s := range screens {
+		if screenLayouts[s.Name()] == name {
+			redraw(i, nil)
+		}
+	}
+}
+
+// reading returns the latest value of a reading, -- until it is known
+func reading(name string) string {
+	readingsMutex.Lock()
+	defer readingsMutex.Unlock()
+
+	if value, ok := readings[name]; ok {
+		return value
+	}
+	return "--"
+}
+
+// bigDigits draws a reading across the whole panel: its name in the top left corner, then
+// the number as large as fits with its unit after it
+func bigDigits(name, value string) func(screen draw.Image) {
+	return func(screen draw.Image) {
+		draw.Draw(screen, screen.Bounds(), image.Black, image.ZP, draw.Src)
+		write(screen, bigReadings[name], 2, 0, bigLabelSize, "lato-regular")
+
+		number, unit, _ := strings.Cut(value, " ")
+		unitWidth := 0
+		if unit != "" {
+			unitWidth = 3 + textWidth(unit, bigLabelSize, "lato-regular")
+		}
+
+		size := float64(bigDigitsMax)
+		for size > bigDigitsMin && 2+textWidth(number, size, "lato-regular")+unitWidth > panel.Logical.Dx()-2 {
+			size -= 2
+		}
+
+		// Sit the number on the bottom of the panel, below the label
+		y := panel.Logical.Max.Y - 4 - int(size) - 2
+		write(screen, number, 2, y, size, "lato-regular")
+		if unit != "" {
+			x := 2 + textWidth(number, size, "lato-regular") + 3
+			write(screen, unit, x, panel.Logical.Max.Y-4-bigLabelSize-2, bigLabelSize, "lato-regular")
+		}
+	}
+}
+
+// applyLayouts draws the big reading over the screens configured for one, replacing what
+// they drew when they were built. Their later redraws are replaced in redraw.
+func applyLayouts() {
+	for i, s := range screens {
+		if _, ok := screenLayouts[s.Name()]; ok {
+			redraw(i, nil)
+		}
+	}
+}
+
+// cpuTemperature returns the temperature of the hottest thermal zone in degrees Celsius
+func cpuTemperature() (float64, bool) {
+	zones, _ := filepath.Glob("/sys/class/thermal/thermal_zone*/temp")
+	hottest, found := 0.0, false
+	for _, zone := range zones {
+		data, err := os.ReadFile(zone)
+		if err != nil {
+			continue
+		}
+		millidegrees, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		if err != nil {
+			continue
+		}
+		if t := float64(millidegrees) / 1000; !found || t > hottest {
+			hottest, found = t, true
+		}
+	}
+	return hottest, found
+}
+
+// setDemoReadings fills the readings with the values of the demo screens
+func setDemoReadings() {
+	setReading("download", "1.2 Gb/s")
+	setReading("upload", "43.9 Mb/s")
+	setReading("latency", "12 ms")
+	setReading("cpu", "23%")
+	setReading("ram", "61%")
+	setReading("temperature", "54 °C")
+	setReading("clients", "42")
+}
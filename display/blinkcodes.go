@@ -0,0 +1,88 @@
+package display
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"cloudkey/src/leds"
+)
+
+// Blink codes tell failures apart on the LEDs when the screen cannot show them:
+// the LED blinks the code's number of times, pauses, and repeats.
+const (
+	CodeNoFramebuffer         = 2
+	CodeConfigError           = 3
+	CodeControllerUnreachable = 4
+	CodeCrashLoop             = 7
+)
+
+// Options that are missing or conflicting exit with codes of their own; their blink code is
+// CodeConfigError like any other configuration problem
+const (
+	ExitMissingOption      = 5
+	ExitConflictingOptions = 6
+)
+
+// configBlinkTime is how long a configuration error is blinked before cloudkey exits
+const configBlinkTime = 10 * time.Second
+
+// stopBlinkCode stops the blink code currently shown by the health monitor, if any
+var stopBlinkCode chan struct{}
+
+// showBlinkCode blinks code on the LED until clearBlinkCode is called
+func showBlinkCode(led string, code int) {
+	clearBlinkCode()
+	stopBlinkCode = make(chan struct{})
+	go myLeds.LED(led).BlinkCode(code, stopBlinkCode)
+}
+
+// clearBlinkCode stops the blink code started by showBlinkCode
+func clearBlinkCode() {
+	if stopBlinkCode != nil {
+		close(stopBlinkCode)
+		stopBlinkCode = nil
+	}
+}
+
+// SignalFatal reports an error that stops cloudkey from running. When the hardware has LEDs the
+// code is blinked on the critical health LED forever, so a unit without a working screen can
+// still tell what is wrong; otherwise cloudkey exits with the code, or that of an *OptionError.
+func SignalFatal(code int, err error) {
+	fmt.Printf("Fatal error (blink code %d): %v\n", code, err)
+
+	profile := leds.DetectProfile()
+	led := profile.Health["critical"].LED
+	if led == "" || headless {
+		os.Exit(ExitCode(code, err))
+	}
+
+	myLeds.AllOff()
+	myLeds.LED(led).BlinkCode(code, nil)
+}
+
+// SignalConfigError reports options cloudkey cannot start with. Unlike SignalFatal it always
+// exits, with the code of err, so systemd and scripts can tell the problems apart; the
+// CodeConfigError blink code is only shown for a while before, and not at all when headless.
+func SignalConfigError(err error, headless bool) {
+	fmt.Printf("Fatal error (blink code %d): %v\n", CodeConfigError, err)
+
+	led := leds.DetectProfile().Health["critical"].LED
+	if led != "" && !headless {
+		stop := make(chan struct{})
+		time.AfterFunc(configBlinkTime, func() { close(stop) })
+		myLeds.AllOff()
+		myLeds.LED(led).BlinkCode(CodeConfigError, stop)
+	}
+	os.Exit(ExitCode(CodeConfigError, err))
+}
+
+// ExitCode is the status cloudkey exits with over err: the code of an *OptionError, or code
+func ExitCode(code int, err error) int {
+	var optionErr *OptionError
+	if errors.As(err, &optionErr) {
+		return optionErr.ExitCode()
+	}
+	return code
+}
@@ -0,0 +1,104 @@
+package display
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/draw"
+	"sync"
+	"time"
+
+	"cloudkey/images"
+	"cloudkey/src/kubernetes"
+)
+
+const (
+	// clusterMessagesShown is how many CloudKeyScreen messages fit on the screen
+	clusterMessagesShown = 3
+	// clusterMessagesExpiry is how often the screen is redrawn to drop expired messages
+	clusterMessagesExpiry = 5 * time.Second
+)
+
+var (
+	clusterMessages      []kubernetes.ScreenMessage
+	clusterMessagesMutex sync.Mutex
+
+	// clusterMessagesChanged wakes the screen, holding at most one wakeup so that the burst
+	// of changes when the watch starts is drawn once
+	clusterMessagesChanged = make(chan struct{}, 1)
+)
+
+// activeClusterMessages returns the messages whose TTL has not run out, highest priority first
+func activeClusterMessages(now time.Time) []kubernetes.ScreenMessage {
+	clusterMessagesMutex.Lock()
+	defer clusterMessagesMutex.Unlock()
+
+	var active []kubernetes.ScreenMessage
+	for _, message := range clusterMessages {
+		if !message.Expired(now) {
+			active = append(active, message)
+		}
+	}
+	return active
+}
+
+// buildClusterMessages shows the content pushed to the cluster as CloudKeyScreen resources
+func buildClusterMessages(i int, demo bool, opts CmdLineOpts) {
+	if demo {
+		clusterMessages = []kubernetes.ScreenMessage{
+			{Namespace: "flux-system", Name: "maintenance", Text: "Upgrade window 22:00", Priority: 10},
+			{Namespace: "default", Name: "welcome", Text: "Hello from GitOps"},
+		}
+	} else {
+		go func() {
+			defer recoverCrash()
+			client, err := kubernetes.NewClient(opts.K8sKubeconfig)
+			if err != nil {
+				fmt.Printf("K8s messages client init error: %v\n", err)
+				return
+			}
+			client.WatchScreens(context.Background(), opts.K8sMessagesNamespace, func(messages []kubernetes.ScreenMessage) {
+				activity("k8smessages")
+				clusterMessagesMutex.Lock()
+				clusterMessages = messages
+				clusterMessagesMutex.Unlock()
+
+				select {
+				case clusterMessagesChanged <- struct{}{}:
+				default:
+				}
+			})
+		}()
+	}
+
+	go func() {
+		defer recoverCrash()
+		for {
+			messages := activeClusterMessages(time.Now())
+			setRelevant("k8smessages", len(messages) > 0)
+			if len(messages) > clusterMessagesShown {
+				messages = messages[:clusterMessagesShown]
+			}
+
+			redraw(i, func(screen draw.Image) {
+				draw.Draw(screen, screen.Bounds(), image.Black, image.ZP, draw.Src)
+				drawIcon(screen, 0, images.Load("kubernetes"))
+
+				if len(messages) == 0 {
+					writeRow(screen, 0, "No cluster messages")
+				}
+				for n, message := range messages {
+					y := 1 + n*20
+					write(screen, message.Namespace+"/"+message.Name, 22, y, 8, "lato-regular")
+					write(screen, message.Text, 22, y+9, 8, "lato-regular")
+				}
+			})
+
+			// Redraw for changes, and every clusterMessagesExpiry to drop expired messages
+			select {
+			case <-clusterMessagesChanged:
+			case <-time.After(clusterMessagesExpiry):
+			}
+		}
+	}()
+}
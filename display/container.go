@@ -0,0 +1,37 @@
+package display
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// livenessTimeout is how long the health monitor may go without evaluating before cloudkey counts as stuck
+const livenessTimeout = 30 * time.Second
+
+// lastHealthCheck is when the health monitor last evaluated the rules, in unix nanoseconds
+var lastHealthCheck atomic.Int64
+
+// inContainer reports whether cloudkey runs in a Docker, Podman or Kubernetes container
+func inContainer() bool {
+	for _, marker := range []string{"/.dockerenv", "/run/.containerenv"} {
+		if _, err := os.Stat(marker); err == nil {
+			return true
+		}
+	}
+	return os.Getenv("KUBERNETES_SERVICE_HOST") != ""
+}
+
+// Alive reports an error when the health monitor has stopped evaluating, for liveness probes
+func Alive() error {
+	last := lastHealthCheck.Load()
+	if last == 0 {
+		// Still starting up
+		return nil
+	}
+	if since := time.Since(time.Unix(0, last)); since > livenessTimeout {
+		return fmt.Errorf("health monitor last ran %s ago", since.Round(time.Second))
+	}
+	return nil
+}
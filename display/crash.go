@@ -0,0 +1,167 @@
+package display
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	build "github.com/jnovack/go-version"
+
+	"cloudkey/src/events"
+)
+
+const (
+	// crashKeep is how many crash reports are kept in the state directory
+	crashKeep = 10
+	// crashLoop is how many crashes within crashLoopWindow stop cloudkey instead of restarting
+	// it, since restarting again would not help
+	crashLoop       = 3
+	crashLoopWindow = 10 * time.Minute
+	// crashNotice is how long the restarting screen stays up before the restart
+	crashNotice = 3 * time.Second
+	// crashStacks caps the dump of every goroutine in a report
+	crashStacks = 1 << 20
+)
+
+var (
+	crashOpts     CmdLineOpts
+	crashing      atomic.Bool
+	lastScreen    atomic.Value // name of the screen shown last
+	lastCollector atomic.Value // name of the collector that refreshed last
+)
+
+// recoverCrash turns a panic of the goroutine it is deferred in into a crash report and a
+// restart, unless -crash-restart is off. A panic cannot be recovered from another goroutine,
+// so every long-running goroutine of the display defers it.
+func recoverCrash() {
+	if !crashOpts.CrashRestart {
+		return
+	}
+	if r := recover(); r != nil {
+		crash(r, debug.Stack())
+	}
+}
+
+// crash saves a report of the panic r, shows that cloudkey is restarting and re-executes it.
+// After crashLoop crashes in a row it stops with a blink code instead.
+func crash(r any, stack []byte) {
+	if !crashing.CompareAndSwap(false, true) {
+		// Another goroutine panicked first and is restarting
+		select {}
+	}
+
+	fmt.Printf("Panic: %v\n%s", r, stack)
+	dir := filepath.Join(crashOpts.StateDir, "crashes")
+	path, err := writeCrashReport(dir, r, stack)
+	if err != nil {
+		fmt.Printf("Crash report not saved: %v\n", err)
+	} else {
+		fmt.Printf("Crash report saved to %s\n", path)
+	}
+
+	if recent := recentCrashes(dir); recent >= crashLoop {
+		SignalFatal(CodeCrashLoop, fmt.Errorf("crashed %d times in %s, not restarting: %v", recent, crashLoopWindow, r))
+		os.Exit(CodeCrashLoop)
+	}
+
+	if !headless {
+		showRestarting()
+		time.Sleep(crashNotice)
+	}
+	reexec("crash")
+	os.Exit(1)
+}
+
+// showRestarting replaces the panel with a notice that cloudkey is restarting. The lock is
+// skipped if the panicking goroutine held it.
+func showRestarting() {
+	if fbMutex.TryLock() {
+		defer fbMutex.Unlock()
+	}
+	visible = -1
+	clearText(fb)
+	writeRow(fb, 0, "cloudkey crashed")
+	writeRow(fb, 1, "Restarting...")
+}
+
+// writeCrashReport saves the panic with what cloudkey was doing into dir, and removes all but
+// the crashKeep newest reports
+func writeCrashReport(dir string, r any, stack []byte) (string, error) {
+	all := make([]byte, crashStacks)
+	all = all[:runtime.Stack(all, true)]
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "cloudkey crash report\n\n")
+	fmt.Fprintf(&b, "time: %s\n", time.Now().Format(time.RFC3339))
+	fmt.Fprintf(&b, "version: %s (%s)\n", build.Version, build.Commit)
+	fmt.Fprintf(&b, "uptime: %s\n", time.Since(started).Round(time.Second))
+	fmt.Fprintf(&b, "panic: %v\n", r)
+	fmt.Fprintf(&b, "last screen: %s\n", loadName(&lastScreen))
+	fmt.Fprintf(&b, "last collector: %s\n", loadName(&lastCollector))
+	fmt.Fprintf(&b, "\n%s\nall goroutines:\n\n%s", stack, all)
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, "crash-"+time.Now().Format("20060102-150405")+".txt")
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		return "", err
+	}
+
+	reports := crashReports(dir)
+	for len(reports) > crashKeep {
+		os.Remove(reports[0])
+		reports = reports[1:]
+	}
+	return path, nil
+}
+
+// loadName returns the name stored in v, or none
+func loadName(v *atomic.Value) string {
+	if name, ok := v.Load().(string); ok {
+		return name
+	}
+	return "none"
+}
+
+// crashReports lists the crash reports in dir, oldest first
+func crashReports(dir string) []string {
+	paths, _ := filepath.Glob(filepath.Join(dir, "crash-*.txt"))
+	sort.Strings(paths)
+	return paths
+}
+
+// recentCrashes counts the crash reports in dir written within crashLoopWindow
+func recentCrashes(dir string) int {
+	n := 0
+	for _, path := range crashReports(dir) {
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) < crashLoopWindow {
+			n++
+		}
+	}
+	return n
+}
+
+// announceCrash tells on the ticker and the event stream that cloudkey came back from a
+// crash, when the newest report is from just before this start
+func announceCrash(opts CmdLineOpts) {
+	reports := crashReports(filepath.Join(opts.StateDir, "crashes"))
+	if len(reports) == 0 {
+		return
+	}
+	path := reports[len(reports)-1]
+	info, err := os.Stat(path)
+	if err != nil || time.Since(info.ModTime()) > crashNotice+time.Minute {
+		return
+	}
+
+	fmt.Printf("Restarted after a crash, see %s\n", path)
+	pushTicker("cloudkey restarted after a crash")
+	events.Publish("crash", map[string]string{"report": path})
+}
@@ -0,0 +1,216 @@
+package display
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/draw"
+	"sort"
+	"time"
+
+	"github.com/llajas/cloudkey/src/network"
+
+	"cloudkey/images"
+	"cloudkey/src/button"
+)
+
+// Button gestures on the devices screen, by how long the button is held
+const (
+	// buttonLocateHold toggles flashing the LED of the selected device; shorter presses select
+	// the next device
+	buttonLocateHold = time.Second
+	// buttonRestartHold asks to restart the selected device, and restarts it when repeated
+	// within buttonConfirmWindow
+	buttonRestartHold   = 4 * time.Second
+	buttonConfirmWindow = 30 * time.Second
+)
+
+// devicePresses are the button presses made while the devices screen is shown
+var devicePresses = make(chan button.Press, 4)
+
+// startButton reads the button of -button-device and hands its presses to the devices screen
+// while it is shown
+func startButton(opts CmdLineOpts) {
+	if opts.ButtonDevice == "" || !opts.DevicesEnabled || opts.Demo {
+		return
+	}
+
+	presses, err := button.Watch(context.Background(), opts.ButtonDevice)
+	if err != nil {
+		fmt.Printf("Button disabled: %v\n", err)
+		return
+	}
+	fmt.Printf("Button %s acts on the devices screen\n", opts.ButtonDevice)
+
+	go func() {
+		defer recoverCrash()
+		for press := range presses {
+			if loadName(&lastScreen) != "devices" {
+				continue
+			}
+			keepScreen()
+			select {
+			case devicePresses <- press:
+			default:
+			}
+		}
+	}()
+}
+
+// deviceState describes the state code of a device
+func deviceState(state int) string {
+	switch state {
+	case 0:
+		return "offline"
+	case 1:
+		return "online"
+	case 4:
+		return "upgrading"
+	default:
+		return "busy"
+	}
+}
+
+// deviceLines shows the selected device of the list, with status below it when set and the
+// button gestures otherwise
+func deviceLines(devices []network.Device, selected int, status string) (string, string, string) {
+	if len(devices) == 0 {
+		return "No devices", "", status
+	}
+	d := devices[selected]
+	if status == "" {
+		status = "hold 1s: locate"
+	}
+	return d.DisplayName(), d.Model + " " + deviceState(d.State), fmt.Sprintf("%d/%d %s", selected+1, len(devices), status)
+}
+
+// buildDevices lists the adopted devices one at a time. With -button-device, a short press
+// selects the next device, holding the button flashes its LED and a long hold, repeated to
+// confirm, restarts it.
+func buildDevices(i int, demo bool, opts CmdLineOpts) {
+	screen := setupImage(i)
+
+	draw.Draw(screen, screen.Bounds(), image.Black, image.ZP, draw.Src)
+	drawIcon(screen, 0, images.Load("network"))
+	drawIcon(screen, 1, images.Load("host"))
+
+	if demo {
+		name, model, status := deviceLines([]network.Device{
+			{Name: "Office AP", Model: "U6-Pro", State: 1},
+			{Name: "Rack switch", Model: "USW-24", State: 1},
+		}, 0, "")
+		writeRow(screen, 0, name)
+		writeRow(screen, 1, model)
+		writeRow(screen, 2, status)
+		return
+	}
+
+	actions := NewDeviceActions(opts)
+
+	run := pipelineContext(i)
+	go func() {
+		defer recoverCrash()
+		var devices []network.Device
+		var fetchErr error
+		selected := 0
+		status := ""
+		locating := map[string]bool{}
+		var restartMAC string // device asked to restart, until restartBy
+		var restartBy time.Time
+
+		for {
+			ctx, done := collect(run, "devices")
+			client, err := udmClient(ctx, opts)
+			var fetched []network.Device
+			if err == nil {
+				fetched, err = client.GetDevices(ctx)
+			}
+			done(err)
+			if run.Err() != nil {
+				return
+			}
+			fetchErr = err
+			if err != nil {
+				fmt.Printf("Error fetching devices: %v\n", err)
+			} else {
+				activity("devices")
+				sort.Slice(fetched, func(a, b int) bool { return fetched[a].DisplayName() < fetched[b].DisplayName() })
+				// Keep the selection on the same device as the list changes
+				if selected < len(devices) {
+					mac := devices[selected].MAC
+					selected = 0
+					for n, d := range fetched {
+						if d.MAC == mac {
+							selected = n
+						}
+					}
+				}
+				devices = fetched
+				if selected >= len(devices) {
+					selected = 0
+				}
+			}
+
+			timer := time.NewTimer(refreshInterval("devices"))
+			for refresh := false; !refresh; {
+				redraw(i, func(screen draw.Image) {
+					clearText(screen)
+					if fetchErr != nil && len(devices) == 0 {
+						writeRow(screen, 0, "Devices")
+						writeRow(screen, 1, "unavailable")
+						writeRow(screen, 2, "check logs")
+						return
+					}
+					name, model, line := deviceLines(devices, selected, status)
+					writeRow(screen, 0, name)
+					writeRow(screen, 1, model)
+					writeRow(screen, 2, line)
+				})
+
+				select {
+				case <-timer.C:
+					refresh = true
+				case <-run.Done():
+					timer.Stop()
+					return
+				case press := <-devicePresses:
+					if len(devices) == 0 {
+						continue
+					}
+					d := devices[selected]
+					switch {
+					case press.Held < buttonLocateHold:
+						selected = (selected + 1) % len(devices)
+						status = ""
+						restartMAC = ""
+					case !opts.UDMWriteEnabled:
+						status = "writes disabled"
+					case press.Held < buttonRestartHold:
+						locate := !locating[d.MAC]
+						status = "locating"
+						if !locate {
+							status = "locate off"
+						}
+						if err := actions.LocateDevice(d.MAC, locate); err != nil {
+							fmt.Printf("Error locating device %s: %v\n", d.MAC, err)
+							status = "locate failed"
+						} else {
+							locating[d.MAC] = locate
+						}
+					case restartMAC != d.MAC || time.Now().After(restartBy):
+						restartMAC, restartBy = d.MAC, time.Now().Add(buttonConfirmWindow)
+						status = "hold again: restart"
+					default:
+						restartMAC = ""
+						status = "restarting"
+						if err := actions.RestartDevice(d.MAC); err != nil {
+							fmt.Printf("Error restarting device %s: %v\n", d.MAC, err)
+							status = "restart failed"
+						}
+					}
+				}
+			}
+			timer.Stop()
+		}
+	}()
+}
@@ -0,0 +1,154 @@
+package display
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/llajas/cloudkey/src/network"
+
+	"cloudkey/src/events"
+	"cloudkey/src/history"
+	"cloudkey/src/leader"
+	"cloudkey/src/notify"
+)
+
+// digestWindow is the period covered by the daily digest
+const digestWindow = 24 * time.Hour
+
+// digestEntry is a notable event kept for the next digest
+type digestEntry struct {
+	Time  time.Time
+	Title string
+}
+
+var (
+	// digestEntries are the outages and other events since the last digest. They are only
+	// kept in memory, unlike speedtests and health transitions which are read from history.
+	digestEntries []digestEntry
+	digestMutex   sync.Mutex
+)
+
+// emailTarget returns the SMTP settings in opts, or nil when email is not configured
+func emailTarget(opts CmdLineOpts) *notify.Email {
+	if opts.SMTPHost == "" {
+		return nil
+	}
+	return &notify.Email{
+		Host:     opts.SMTPHost,
+		Port:     opts.SMTPPort,
+		Username: opts.SMTPUsername,
+		Password: opts.SMTPPassword,
+		TLS:      opts.SMTPTLS,
+		From:     opts.SMTPFrom,
+		To:       opts.SMTPTo,
+	}
+}
+
+// nextClock returns the first time after now at minutes past midnight, in the location of now
+func nextClock(now time.Time, minutes int) time.Time {
+	y, m, d := now.Date()
+	next := time.Date(y, m, d, minutes/60, minutes%60, 0, 0, now.Location())
+	if !next.After(now) {
+		next = time.Date(y, m, d+1, minutes/60, minutes%60, 0, 0, now.Location())
+	}
+	return next
+}
+
+// digestBody summarizes the speedtests, outages and health transitions of the window ending at now
+func digestBody(opts CmdLineOpts, now time.Time, entries []digestEntry) string {
+	var b strings.Builder
+	start := now.Add(-digestWindow)
+
+	b.WriteString("Speedtests\n")
+	results, err := history.Open[network.SpeedtestResult](filepath.Join(opts.StateDir, "speedtests.jsonl")).All()
+	if err != nil {
+		fmt.Fprintf(&b, "  cannot read the history: %v\n", err)
+	} else if stats := history.DownloadStats(results, start, now); stats.Samples == 0 {
+		b.WriteString("  no results\n")
+	} else {
+		fmt.Fprintf(&b, "  %d results, download min %s / avg %s / max %s\n", stats.Samples,
+			network.FormatSpeed(stats.Min), network.FormatSpeed(stats.Avg), network.FormatSpeed(stats.Max))
+		if baseline := history.SpeedtestBaseline(results, now, baselineWindow); baseline.Valid() {
+			fmt.Fprintf(&b, "  usually %s down / %s up\n", network.FormatSpeed(baseline.Download), network.FormatSpeed(baseline.Upload))
+		}
+	}
+
+	b.WriteString("\nOutages and events\n")
+	if len(entries) == 0 {
+		b.WriteString("  none\n")
+	}
+	for _, e := range entries {
+		fmt.Fprintf(&b, "  %s  %s\n", e.Time.In(now.Location()).Format("Mon 15:04"), e.Title)
+	}
+
+	b.WriteString("\nHealth\n")
+	transitions, err := healthHistory(opts.StateDir).All()
+	if err != nil {
+		fmt.Fprintf(&b, "  cannot read the history: %v\n", err)
+	} else {
+		summary := history.SummarizeHealth(transitions, now, digestWindow)
+		fmt.Fprintf(&b, "  now %s, %s in warning, %s in critical, %d incidents\n", healthState(),
+			summary.Warning.Round(time.Minute), summary.Critical.Round(time.Minute), summary.Incidents)
+		for _, t := range transitions {
+			if t.Time.After(start) {
+				fmt.Fprintf(&b, "  %s  %s\n", t.Time.In(now.Location()).Format("Mon 15:04"), t.State)
+			}
+		}
+	}
+	return b.String()
+}
+
+// startDigest collects notable events and mails a summary of the last day every day
+// at EmailDigestTime. Only the leader mails, but every instance collects.
+func startDigest(opts CmdLineOpts) {
+	email := emailTarget(opts)
+	if email == nil || !opts.EmailDigest {
+		return
+	}
+	// Validated at startup
+	at, _ := parseClock(opts.EmailDigestTime)
+	loc := location(opts)
+
+	ch := events.Subscribe()
+	go func() {
+		defer recoverCrash()
+		for e := range ch {
+			if _, isHealth := e.Data.(healthEvent); isHealth {
+				continue
+			}
+			if n, ok := eventNotification(e, ""); ok {
+				digestMutex.Lock()
+				digestEntries = append(digestEntries, digestEntry{Time: e.Time, Title: n.Title})
+				digestMutex.Unlock()
+			}
+		}
+	}()
+
+	go func() {
+		defer recoverCrash()
+		for {
+			next := nextClock(time.Now().In(loc), at)
+			time.Sleep(time.Until(next))
+
+			digestMutex.Lock()
+			entries := digestEntries
+			digestEntries = nil
+			digestMutex.Unlock()
+
+			if !leader.IsLeader() {
+				continue
+			}
+			now := time.Now().In(loc)
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			err := email.Mail(ctx, "cloudkey daily digest for "+now.Add(-digestWindow).Format("Mon 2 Jan"), digestBody(opts, now, entries))
+			cancel()
+			if err != nil {
+				fmt.Printf("Error sending the daily digest: %v\n", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,154 @@
+package display
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/draw"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/llajas/cloudkey/src/network"
+
+	"cloudkey/images"
+	"cloudkey/src/events"
+	"cloudkey/src/history"
+	"cloudkey/src/metrics"
+)
+
+const (
+	// discoveryWindow is how long answers are collected, longer than the 2s SSDP devices may wait
+	discoveryWindow = 3 * time.Second
+	// discoveryNew is how long a device counts as new after it was first seen
+	discoveryNew = 24 * time.Hour
+)
+
+// seenDevice records when a device first answered a discovery search
+type seenDevice struct {
+	Key       string `json:"key"`
+	IP        string `json:"ip"`
+	Name      string `json:"name,omitempty"`
+	FirstSeen int64  `json:"first_seen"`        // milliseconds since epoch
+	Initial   bool   `json:"initial,omitempty"` // found by the very first search, so never new
+}
+
+// discoveryLines summarizes a search, naming the newest device that appeared since yesterday
+func discoveryLines(devices []network.DiscoveredDevice, seen map[string]seenDevice, now time.Time) (string, string, string) {
+	mdns, ssdp := 0, 0
+	for _, d := range devices {
+		if slices.Contains(d.Protocols, "mdns") {
+			mdns++
+		}
+		if slices.Contains(d.Protocols, "ssdp") {
+			ssdp++
+		}
+	}
+
+	var newest *seenDevice
+	fresh := 0
+	for _, d := range devices {
+		s, ok := seen[d.Key()]
+		if !ok || s.Initial || now.Sub(time.UnixMilli(s.FirstSeen)) > discoveryNew {
+			continue
+		}
+		fresh++
+		if newest == nil || s.FirstSeen > newest.FirstSeen {
+			newest = &s
+		}
+	}
+
+	line3 := "No new devices"
+	if newest != nil {
+		name := newest.Name
+		if name == "" {
+			name = newest.IP
+		}
+		line3 = fmt.Sprintf("%d new: %s", fresh, name)
+	}
+	return fmt.Sprintf("LAN: %d devices", len(devices)), fmt.Sprintf("mDNS %d  SSDP %d", mdns, ssdp), line3
+}
+
+func buildDiscovery(i int, demo bool, opts CmdLineOpts) {
+	screen := setupImage(i)
+
+	draw.Draw(screen, screen.Bounds(), image.Black, image.ZP, draw.Src)
+	drawIcon(screen, 0, images.Load("network"))
+
+	if demo {
+		writeRow(screen, 0, "LAN: 37 devices")
+		writeRow(screen, 1, "mDNS 31  SSDP 12")
+		writeRow(screen, 2, "2 new: Sonos-Kitchen")
+		return
+	}
+
+	store := history.Open[seenDevice](filepath.Join(opts.StateDir, "discovery.jsonl"))
+
+	run := pipelineContext(i)
+	go func() {
+		defer recoverCrash()
+		seen := map[string]seenDevice{}
+		records, err := store.All()
+		if err != nil {
+			fmt.Printf("Error reading discovered devices: %v\n", err)
+		}
+		for _, s := range records {
+			seen[s.Key] = s
+		}
+		initial := len(records) == 0
+
+		for {
+			line1, line2, line3 := "LAN discovery", "failed", "check logs"
+
+			traced, done := collect(run, "discovery")
+			ctx, cancel := context.WithTimeout(traced, 2*discoveryWindow)
+			devices, err := network.Discover(ctx, discoveryWindow)
+			cancel()
+			done(err)
+			if run.Err() != nil {
+				return
+			}
+
+			if err != nil {
+				fmt.Printf("Error discovering LAN devices: %v\n", err)
+			} else {
+				activity("discovery")
+				now := time.Now()
+				for _, d := range devices {
+					if _, ok := seen[d.Key()]; ok {
+						continue
+					}
+					s := seenDevice{Key: d.Key(), IP: d.IP, Name: d.Name, FirstSeen: now.UnixMilli(), Initial: initial}
+					seen[s.Key] = s
+					if err := store.Append(s); err != nil {
+						fmt.Printf("Error saving discovered device: %v\n", err)
+					}
+					if !initial {
+						fmt.Printf("New LAN device: %s %s (%s)\n", d.IP, d.MAC, d.Name)
+						events.Publish("device_discovered", d)
+					}
+				}
+				// Only devices of a search that found something are the baseline, not an empty first search
+				initial = initial && len(devices) == 0
+
+				line1, line2, line3 = discoveryLines(devices, seen, now)
+				setRelevant("discovery", slices.ContainsFunc(devices, func(d network.DiscoveredDevice) bool {
+					s := seen[d.Key()]
+					return !s.Initial && now.Sub(time.UnixMilli(s.FirstSeen)) <= discoveryNew
+				}))
+				metrics.Set("cloudkey_lan_devices", "Devices that answered the last mDNS or SSDP search.", float64(len(devices)))
+			}
+
+			redraw(i, func(screen draw.Image) {
+				clearText(screen)
+				writeRow(screen, 0, line1)
+				writeRow(screen, 1, line2)
+				writeRow(screen, 2, line3)
+			})
+
+			if !pause(run, refreshInterval("discovery")) {
+				return
+			}
+		}
+	}()
+}
@@ -5,40 +5,206 @@ import (
 	"image"
 	"image/draw"
 	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	build "github.com/jnovack/go-version"
+	"github.com/llajas/cloudkey/src/network"
+	"github.com/llajas/cloudkey/src/panel"
 
 	"cloudkey/images"
-	"cloudkey/src/framebuffer"
 	"cloudkey/src/leds"
+	"cloudkey/src/ratelimit"
 )
 
-var screens [6]draw.Image
-var activeScreenCount int
+// started is when the screens were built, to log how long each took to get its first data
+var started time.Time
+
+// screens are the screens of the registry in carousel order, as collectors refer to them by index
+var screens []*panel.Screen
+
+// registry holds the screens and coalesces their redraws
+var registry = panel.Registry{
+	MinRedrawInterval: minRedrawInterval,
+	OnReady:           screenReady,
+	OnCoalesce:        redrawCoalesced,
+	Recover:           recoverCrash,
+}
+
+var screensBuilt atomic.Bool // screens is complete and no longer appended to
 var myLeds leds.LEDS
 var fb draw.Image
+var fbMutex sync.Mutex // serializes the carousel and renderer writing to fb
 var width, height int
 
+// headless runs the collectors, API and metrics without touching the framebuffer or LEDs
+var headless bool
+
 // CmdLineOpts structure for the command line options
 type CmdLineOpts struct {
-	Delay         float64
-	Reset         bool
-	Demo          bool
-	Version       bool
-	Pidfile       string
-	UDMBaseURL    string
-	UDMUsername   string
-	UDMPassword   string
-	UDMSite       string
-	UDMVersion    string
-	K8sEnabled    bool
-	K8sKubeconfig string
+	Delay                   Delay
+	ScreenWeights           ScreenWeights
+	WarningDwell            float64
+	ScreenLayouts           ScreenLayouts
+	RefreshIntervals        RefreshIntervals
+	Reset                   bool
+	Demo                    bool
+	Headless                bool
+	Framebuffer             string
+	PanelSize               string
+	LEDsDir                 string
+	Version                 bool
+	Pidfile                 string
+	TracingEndpoint         string
+	ChaosRate               float64
+	ChaosFaults             StringList
+	OutboundRate            int
+	OutboundRates           OutboundRates
+	UDMBaseURL              string
+	UDMUsername             string
+	UDMPassword             string
+	UDMSite                 string
+	UDMVersion              string
+	UDMDetectTimeout        time.Duration
+	UDMLoginTimeout         time.Duration
+	UDMQueryTimeout         time.Duration
+	UDMLoginRetry           time.Duration
+	UDMMaxRequests          int
+	UDMWriteEnabled         bool
+	CaptureAPIDir           string
+	DualWANEnabled          bool
+	VPNEnabled              bool
+	ThreatsEnabled          bool
+	TickerEnabled           bool
+	UpdatesEnabled          bool
+	PortWatchEnabled        bool
+	NewDeviceWatchEnabled   bool
+	NewDeviceAllowlist      StringList
+	WiFiEnabled             bool
+	AirtimeEnabled          bool
+	AirtimeThreshold        int
+	DHCPEnabled             bool
+	DHCPThreshold           int
+	QuotaEnabled            bool
+	QuotaCap                int
+	QuotaResetDay           int
+	DiscoveryEnabled        bool
+	SpeedtestSummaryEnabled bool
+	SpeedtestSchedule       string
+	Timezone                string
+	LatencyHeatmapEnabled   bool
+	PingTarget              string
+	DiagnosticsEnabled      bool
+	VersionScreenEnabled    bool
+	Features                StringList
+	CrashRestart            bool
+	MemoryLimit             int
+	GoroutineLimit          int
+	HealthRules             HealthRules
+	HealthWindow            int
+	HealthLEDs              HealthLEDs
+	LEDProfile              string
+	GPIOLEDs                GPIOLEDs
+	LEDDryRun               bool
+	ActivityIndicator       string
+	FreshnessIndicator      string
+	ScreenSchedule          ScreenSchedule
+	ConditionalScreens      StringList
+	ConditionalLinger       time.Duration
+	LogoBrightness          int
+	LogoBreathing           bool
+	BeeperPath              string
+	BeeperQuietHours        string
+	TopProcessesEnabled     bool
+	TopProcessesSort        string
+	FirmwareChannel         string
+	K8sEnabled              bool
+	K8sKubeconfig           string
+	K8sAuthWarningDays      int
+	K8sLatencyWarning       int
+	K8sHelmEnabled          bool
+	K8sHelmDriver           string
+	GitOpsEnabled           bool
+	LeaderElection          string
+	LeaderLease             string
+	LeaderLockFile          string
+	Upstream                string
+	UpstreamToken           string
+	K8sMessagesEnabled      bool
+	K8sMessagesNamespace    string
+	AlertmanagerEnabled     bool
+	AlertmanagerURL         string
+	AlertmanagerFilter      StringList
+	AlertmanagerHealth      bool
+	DevicesEnabled          bool
+	ButtonDevice            string
+	StateDir                string
+	StatusPageEnabled       bool
+	StatusPageDir           string
+	APIListen               string
+	APIReadTokens           StringList
+	APICtlTokens            StringList
+	APITLS                  bool
+	APITLSCert              string
+	APITLSKey               string
+	APIDisable              StringList
+	OutageProbes            StringList
+	WANIPMask               string
+	WANEnrich               bool
+	WANIPProviders          StringList
+	WANIPAgree              int
+	ExternalLookups         bool
+	NtfyURL                 string
+	NtfyToken               string
+	GotifyURL               string
+	GotifyToken             string
+	NotifyMinSeverity       string
+	TelegramToken           string
+	TelegramChats           StringList
+	SMTPHost                string
+	SMTPPort                int
+	SMTPUsername            string
+	SMTPPassword            string
+	SMTPTLS                 string
+	SMTPFrom                string
+	SMTPTo                  StringList
+	EmailDigest             bool
+	EmailDigestTime         string
 }
 
-func init() {
+// Init takes over the LEDs and framebuffer and shows the boot splash. It must be called before New.
+func Init(opts CmdLineOpts) {
+	leds.SetDir(opts.LEDsDir)
+	if size, err := parsePanelSize(opts.PanelSize); err == nil {
+		panelBounds = size
+	}
+	if !opts.Headless && inContainer() {
+		if _, err := os.Stat(opts.Framebuffer); err != nil {
+			fmt.Printf("Running in a container without %s, continuing headless (mount the device to use the screen)\n", opts.Framebuffer)
+			opts.Headless = true
+		}
+	}
+	if opts.Headless {
+		// Screens still render into memory, so collectors and the API work unchanged
+		headless = true
+		fb = image.NewRGBA(panelBounds)
+		width, height = panelBounds.Dx(), panelBounds.Dy()
+		setScale(fb.Bounds())
+		fmt.Println("Headless: not using the framebuffer or LEDs")
+		return
+	}
+
+	leds.SetDryRun(opts.LEDDryRun || simulated())
+	registerGPIOLEDs(opts.GPIOLEDs)
 	myLeds = leds.LEDS{}
 	leds.PrintDiscoveredLEDs()
+	if inContainer() && len(leds.DiscoverLEDs()) == 0 {
+		fmt.Printf("Running in a container without LEDs in %s (mount /sys/class/leds to use them)\n", opts.LEDsDir)
+	}
 
 	myLeds.AllOff()
 	myLeds.LED("white").On()
@@ -47,36 +213,35 @@ func init() {
 	// Framebuffer has global scope
 	// therefore err must have local scope to prevent redefining
 	var err error
-	fb, err = framebuffer.Open("/dev/fb0")
+	fb, err = openFramebuffer(opts.Framebuffer)
 	if err != nil {
-		panic(err)
+		SignalFatal(CodeNoFramebuffer, fmt.Errorf("failed to open framebuffer: %v", err))
 	}
 
 	width = fb.Bounds().Max.X
 	height = fb.Bounds().Max.Y
 
 	fmt.Printf("Resolution: %dx%d pixels\n", width, height)
-	clearScreen()
-
-	// Set up additional screens
-	for x := range screens {
-		screens[x] = image.NewRGBA(fb.Bounds())
+	setScale(fb.Bounds())
+	if scale != 1 {
+		fmt.Printf("Scaling the screens %.2fx\n", scale)
 	}
+	clearScreen()
 
-	draw.Draw(fb, image.Rect(64, 4, 64+32, 4+32), images.Load("logo"), image.ZP, draw.Src)
+	drawImage(fb, image.Rect(64, 4, 64+32, 4+32), images.Load("logo"))
 
-	center(fb, build.Version, 80, 40, 8, "lato-regular")
+	canvas(fb).Center(build.Version, 40, 8, "lato-regular")
 
 	// Outline the loader line
 	for i := 0; i < 100; i++ {
-		fb.Set(30+i, 56, colors[3])
+		fill(fb, image.Rect(30+i, 56, 31+i, 57), image.NewUniform(colors[3]))
 	}
 
 	// Fill the loader line
 	// This is just a delay right now, do your checks here!
 	r := rand.New(rand.NewSource(time.Now().UnixNano()))
 	for i := 0; i < 100; i++ {
-		fb.Set(30+i, 56, colors[15])
+		fill(fb, image.Rect(30+i, 56, 31+i, 57), image.NewUniform(colors[15]))
 		// mathmatically, the average sleep time is about half of the seed number
 		time.Sleep(time.Duration(r.Intn(50)) * time.Millisecond)
 	}
@@ -87,30 +252,210 @@ func init() {
 
 // New initializes the screens
 func New(opts CmdLineOpts) {
-	buildCPUStats(0, opts.Demo)
-	buildRAMStats(1, opts.Demo)
-	buildSwapStats(2, opts.Demo)
-	buildNetwork(3, opts.Demo)
-	buildSpeedTest(4, opts.Demo, opts)
+	started = time.Now()
+	crashOpts = opts
+	defer recoverCrash()
+	startLeaderElection(opts)
+	startActivity(opts)
+	freshnessMode = opts.FreshnessIndicator
+	screenSchedule, scheduleZone = opts.ScreenSchedule, location(opts)
+	hiddenWhenIdle, relevanceLinger = opts.ConditionalScreens, opts.ConditionalLinger
+	screenWeights, warningDwell = opts.ScreenWeights, opts.WarningDwell
+	screenLayouts = opts.ScreenLayouts
+	refreshIntervals = opts.RefreshIntervals
+	network.LookupCache.Path = filepath.Join(opts.StateDir, "lookups.json")
+	network.LookupCache.Client = &http.Client{Timeout: 15 * time.Second, Transport: ratelimit.Transport(nil)}
+	if opts.Demo {
+		// The demo screens all have something to report
+		for _, name := range conditionalScreens {
+			setRelevant(name, true)
+		}
+		setDemoReadings()
+	}
+	startCPUSampler()
+
+	if opts.Upstream != "" {
+		buildUpstreamScreens(opts)
+	} else {
+		buildScreens(opts)
+	}
+	screensBuilt.Store(true)
+	checkScreenSchedule()
+	applyLayouts()
+	printReport(opts)
+	announceFeatures(opts)
+	announceCrash(opts)
+
+	if !opts.Demo && opts.Upstream == "" {
+		startWatchdog(opts)
+	}
+	startSelfMonitor(opts)
+	startReleaseCheck(opts)
+	startButton(opts)
+	startBeeper(opts)
+	startHealthMonitor(opts)
+	startNotifier(opts)
+	startTelegramBot(opts)
+	startDigest(opts)
+
+	if opts.StatusPageDir != "" {
+		startStatusPageWriter(opts)
+	}
+
+	if headless && !recording {
+		select {}
+	}
+
+	showLoading()
+
+	startRenderer()
+	startFadeCarousel(time.Duration(opts.Delay))
+}
+
+// buildScreens adds the screens enabled in opts, each collecting its own data
+func buildScreens(opts CmdLineOpts) {
+	buildCPUStats(addScreen("cpu"), opts.Demo)
+	buildRAMStats(addScreen("ram"), opts.Demo)
+	buildSwapStats(addScreen("swap"), opts.Demo)
+	buildNetwork(addScreen("network"), opts.Demo, opts)
+	buildSpeedTest(addScreen("speedtest"), opts.Demo, opts)
+
+	if opts.SpeedtestSummaryEnabled {
+		buildSpeedtestSummary(addScreen("speedsummary"), opts.Demo, opts)
+	}
+
+	if opts.LatencyHeatmapEnabled {
+		buildLatencyHeatmap(addScreen("heatmap"), opts.Demo, opts)
+	}
+
+	if opts.DualWANEnabled {
+		buildDualWAN(addScreen("dualwan"), opts.Demo, opts)
+	}
+
+	if opts.VPNEnabled {
+		buildVPNSessions(addScreen("vpn"), opts.Demo, opts)
+	}
+
+	if opts.ThreatsEnabled {
+		buildThreats(addScreen("threats"), opts.Demo, opts)
+	}
+
+	if opts.UpdatesEnabled {
+		buildUpdates(addScreen("updates"), opts.Demo, opts)
+	}
+
+	if opts.WiFiEnabled {
+		buildWiFiExperience(addScreen("wifi"), opts.Demo, opts)
+	}
+
+	if opts.AirtimeEnabled {
+		buildAirtime(addScreen("airtime"), opts.Demo, opts)
+	}
+
+	if opts.DHCPEnabled {
+		buildDHCP(addScreen("dhcp"), opts.Demo, opts)
+	}
+
+	if opts.DevicesEnabled {
+		buildDevices(addScreen("devices"), opts.Demo, opts)
+	}
+
+	if opts.QuotaEnabled {
+		buildQuota(addScreen("quota"), opts.Demo, opts)
+	}
+
+	if opts.DiscoveryEnabled {
+		buildDiscovery(addScreen("discovery"), opts.Demo, opts)
+	}
 
-	activeScreenCount = 5
 	if opts.K8sEnabled {
-		buildKubernetes(5, opts.Demo, opts)
-		activeScreenCount = 6
+		buildKubernetes(addScreen("kubernetes"), opts.Demo, opts)
+	}
+
+	if opts.GitOpsEnabled {
+		buildGitOps(addScreen("gitops"), opts.Demo, opts)
+	}
+
+	if opts.K8sMessagesEnabled {
+		buildClusterMessages(addScreen("k8smessages"), opts.Demo, opts)
 	}
 
-	startHealthMonitor()
+	if opts.AlertmanagerEnabled {
+		buildAlertmanager(addScreen("alertmanager"), opts.Demo, opts)
+	}
+
+	if opts.TopProcessesEnabled {
+		buildTopProcesses(addScreen("processes"), opts.Demo, opts)
+	}
+
+	if opts.DiagnosticsEnabled {
+		buildDiagnostics(addScreen("diagnostics"), opts.Demo, opts)
+	}
 
-	startFadeCarousel(opts.Delay)
+	if opts.VersionScreenEnabled {
+		buildVersion(addScreen("version"), opts.Demo, opts)
+	}
+
+	if opts.TickerEnabled {
+		buildTicker(addScreen("ticker"), opts.Demo)
+	}
+
+	if opts.PortWatchEnabled && !opts.Demo {
+		startPortWatcher(opts)
+	}
+
+	if opts.SpeedtestSchedule != "" && !opts.Demo {
+		startSpeedtestScheduler(opts)
+	}
+
+	if opts.NewDeviceWatchEnabled && !opts.Demo {
+		startClientWatcher(opts)
+	}
+}
+
+// addScreen registers a new blank screen in the carousel and returns its index
+func addScreen(name string) int {
+	screens = append(screens, registry.Add(name, fb.Bounds()))
+	return len(screens) - 1
 }
 
 // Shutdown the LEDs
 func Shutdown() {
+	if headless {
+		return
+	}
 	myLeds.AllOff()
 }
 
+// Snapshot returns a copy of what is currently shown on the panel
+func Snapshot() image.Image {
+	fbMutex.Lock()
+	defer fbMutex.Unlock()
+
+	capture := image.NewRGBA(fb.Bounds())
+	draw.Draw(capture, capture.Bounds(), fb, fb.Bounds().Min, draw.Src)
+	return capture
+}
+
+// ScreenImage returns a copy of the named screen as last drawn, visible or not
+func ScreenImage(name string) (image.Image, bool) {
+	if !screensBuilt.Load() {
+		return nil, false
+	}
+	for i, s := range screens {
+		if s.Name() != name {
+			continue
+		}
+		capture := image.NewRGBA(s.Image().Bounds())
+		frame(i, func(img *image.RGBA) {
+			copy(capture.Pix, img.Pix)
+		})
+		return capture, true
+	}
+	return nil, false
+}
+
 // Output the screen/image immediately to the framebuffer
 func Output(i int) {
-	screen := screens[i]
-	draw.Draw(fb, fb.Bounds(), screen, image.ZP, draw.Over)
+	show(i)
 }
@@ -0,0 +1,95 @@
+package display
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// warningScreens maps the warning conditions raised by setWarning to the screen showing them
+var warningScreens = map[string]string{
+	"wan_backup":   "dualwan",
+	"airtime":      "airtime",
+	"dhcp":         "dhcp",
+	"quota":        "quota",
+	"gitops":       "gitops",
+	"goroutines":   "diagnostics",
+	"helm":         "kubernetes",
+	"k8s-auth":     "kubernetes",
+	"k8s-latency":  "kubernetes",
+	"k8s-pressure": "kubernetes",
+	"watchdog":     "diagnostics",
+	"alertmanager": "alertmanager",
+}
+
+// ScreenWeights scale how long each screen is shown, usable with flag.Var. Screens that are
+// not listed have a weight of 1.
+type ScreenWeights map[string]float64
+
+// String joins the weights back into their flag form
+func (w *ScreenWeights) String() string {
+	var parts []string
+	for name, weight := range *w {
+		parts = append(parts, name+"="+strconv.FormatFloat(weight, 'f', -1, 64))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+// Set replaces the weights, e.g. "speedtest=2,kubernetes=1.5,swap=0.5"
+func (w *ScreenWeights) Set(value string) error {
+	weights := make(ScreenWeights)
+
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		name, number, ok := strings.Cut(item, "=")
+		if !ok {
+			return fmt.Errorf("screen weight %q: expected screen=weight", item)
+		}
+		weight, err := strconv.ParseFloat(number, 64)
+		if err != nil || weight <= 0 {
+			return fmt.Errorf("screen weight %q: weight must be a positive number", item)
+		}
+		weights[name] = weight
+	}
+
+	*w = weights
+	return nil
+}
+
+var (
+	screenWeights ScreenWeights // from -screen-weights
+	warningDwell  = 1.0         // from -warning-dwell
+)
+
+// screenWarning reports whether a warning shown by the named screen is active
+func screenWarning(name string) bool {
+	warningsMutex.Lock()
+	defer warningsMutex.Unlock()
+
+	for warning := range warnings {
+		if warningScreens[warning] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// dwell returns how long the carousel shows the named screen, delay scaled by its weight and,
+// while it shows a warning, by warningDwell
+func dwell(name string, delay time.Duration) time.Duration {
+	scale := 1.0
+	if weight, ok := screenWeights[name]; ok {
+		scale *= weight
+	}
+	if screenWarning(name) {
+		scale *= warningDwell
+	}
+	return time.Duration(float64(delay) * scale)
+}
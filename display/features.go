@@ -0,0 +1,38 @@
+package display
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// featureFlags are the integrations that ship before they are finished, off unless named in
+// -features, with what each one turns on. Their behaviour may change or go away between
+// releases; a flag is removed once its integration is on by default or dropped. Names start
+// with "experimental.", and each integration registers its flag in the change adding it.
+var featureFlags = map[string]string{
+	"experimental.websocket": "live panel mirror at /mirror and /ws/framebuffer on the API",
+}
+
+// FeatureNames lists the known feature flags in order
+func FeatureNames() []string {
+	names := make([]string, 0, len(featureFlags))
+	for name := range featureFlags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Feature reports whether the feature flag name is turned on
+func (o CmdLineOpts) Feature(name string) bool {
+	return slices.Contains(o.Features, name)
+}
+
+// announceFeatures logs the feature flags turned on, so a report from a user testing one
+// says so
+func announceFeatures(opts CmdLineOpts) {
+	for _, name := range opts.Features {
+		fmt.Printf("Experimental feature %s on: %s\n", name, featureFlags[name])
+	}
+}
@@ -0,0 +1,115 @@
+package display
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"sync"
+	"time"
+)
+
+const (
+	// freshnessFade is the age at which the freshness dot reaches its dimmest level
+	freshnessFade = time.Hour
+	// freshnessDimmest is the gray level of a dot whose data is freshnessFade old or older
+	freshnessDimmest = 48
+	// freshnessTextSize is the font size of the age shown in the corner
+	freshnessTextSize = 7
+)
+
+var (
+	// freshnessDotRect is where the freshness dot is drawn, in the bottom right corner
+	freshnessDotRect = image.Rect(155, 55, 159, 59)
+	// freshnessRect is the corner the indicator may cover, restored from the screen when it changes
+	freshnessRect = image.Rect(136, 50, 160, 60)
+)
+
+var (
+	freshnessMode  string // none, dot or age, from -freshness-indicator
+	freshnessShown string // what the indicator on the framebuffer shows, empty if nothing
+
+	refreshedMutex sync.Mutex
+	refreshed      = map[string]time.Time{} // screen name to its last successful refresh
+)
+
+// markRefreshed records that the data of a screen was just fetched
+func markRefreshed(screen string) {
+	refreshedMutex.Lock()
+	defer refreshedMutex.Unlock()
+	refreshed[screen] = time.Now()
+}
+
+// dataAge returns how old the data of a screen is, false for screens that show live
+// values or have not fetched anything yet
+func dataAge(screen string) (time.Duration, bool) {
+	refreshedMutex.Lock()
+	defer refreshedMutex.Unlock()
+
+	at, ok := refreshed[screen]
+	if !ok {
+		return 0, false
+	}
+	return time.Since(at), true
+}
+
+// formatAge shortens an age to fit the corner of the panel
+func formatAge(age time.Duration) string {
+	switch {
+	case age < time.Minute:
+		return "now"
+	case age < time.Hour:
+		return fmt.Sprintf("%dm", int(age.Minutes()))
+	case age < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(age.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(age.Hours()/24))
+	}
+}
+
+// freshnessLevel is the gray level of the dot, dimming from white to freshnessDimmest over freshnessFade
+func freshnessLevel(age time.Duration) uint8 {
+	if age >= freshnessFade {
+		return freshnessDimmest
+	}
+	return uint8(255 - float64(255-freshnessDimmest)*float64(age)/float64(freshnessFade))
+}
+
+// drawFreshness shows how old the data of the visible screen is in its bottom right corner.
+// It is called by the renderer with fbMutex held and only draws when the indicator changes.
+func drawFreshness() {
+	if freshnessMode == "" || freshnessMode == "none" || visible < 0 {
+		return
+	}
+
+	age, ok := dataAge(screens[visible].Name())
+	state := ""
+	if ok {
+		switch freshnessMode {
+		case "dot":
+			state = fmt.Sprintf("dot %d", freshnessLevel(age))
+		case "age":
+			state = formatAge(age)
+		}
+	}
+	if state == freshnessShown {
+		return
+	}
+
+	draw.Draw(fb, scaled(freshnessRect), presented, scaled(freshnessRect).Min, draw.Src)
+	freshnessShown = state
+	if !ok {
+		return
+	}
+
+	switch freshnessMode {
+	case "dot":
+		fill(fb, freshnessDotRect, image.NewUniform(color.Gray{Y: freshnessLevel(age)}))
+	case "age":
+		// Black out just behind the text, so it stays readable over the screen
+		text := formatAge(age)
+		x := freshnessRect.Max.X - 1 - textWidth(text, freshnessTextSize, "lato-regular")
+		fill(fb, image.Rect(x-1, freshnessRect.Min.Y, freshnessRect.Max.X, freshnessRect.Max.Y), image.Black)
+		write(fb, text, x, freshnessRect.Min.Y-1, freshnessTextSize, "lato-regular")
+	}
+}
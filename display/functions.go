@@ -5,13 +5,11 @@ import (
 	"image"
 	"image/color"
 	"image/draw"
-	"log"
-	"math"
+	"slices"
+	"strings"
 	"time"
 
-	"github.com/golang/freetype"
-	"github.com/golang/freetype/truetype"
-	"github.com/jnovack/cloudkey/fonts"
+	"cloudkey/src/events"
 )
 
 // Colors from Black to White
@@ -97,120 +95,195 @@ func fade(inverse bool) {
 	}
 }
 
+// screenEvent is published whenever the carousel shows a new screen
+type screenEvent struct {
+	Index int    `json:"index"`
+	Name  string `json:"name"`
+}
+
+// jumpTo holds the index of a screen to show next, cutting the current one short
+var jumpTo = make(chan int, 1)
+
+// stayOn restarts the dwell of the screen shown, while someone is using it
+var stayOn = make(chan struct{}, 1)
+
+// keepScreen keeps the screen shown up for another full dwell
+func keepScreen() {
+	select {
+	case stayOn <- struct{}{}:
+	default:
+	}
+}
+
+// screenAliases are the names people use for screens named otherwise
+var screenAliases = map[string]string{
+	"k8s":     "kubernetes",
+	"ips":     "threats",
+	"latency": "heatmap",
+	"alerts":  "alertmanager",
+}
+
+// ShowScreen makes the carousel switch to the named screen right away, after which it
+// carries on from there. name may also be an alias or the start of a single screen's name;
+// the name of the screen shown is returned.
+func ShowScreen(name string) (string, error) {
+	if headless {
+		return "", fmt.Errorf("no display in headless mode")
+	}
+	if !screensBuilt.Load() {
+		return "", fmt.Errorf("screens are still being built")
+	}
+	i, err := findScreen(name)
+	if err != nil {
+		return "", err
+	}
+
+	// Replace a jump that has not been taken yet
+	select {
+	case <-jumpTo:
+	default:
+	}
+	jumpTo <- i
+	return screens[i].Name(), nil
+}
+
+// findScreen returns the index of the screen with name, its alias, or the only one whose name
+// starts with it
+func findScreen(name string) (int, error) {
+	name = strings.ToLower(name)
+	if alias, ok := screenAliases[name]; ok && slices.Contains(ScreenNames(), alias) {
+		name = alias
+	}
+
+	var matches []int
+	for i, s := range screens {
+		if s.Name() == name {
+			return i, nil
+		}
+		if strings.HasPrefix(s.Name(), name) {
+			matches = append(matches, i)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return 0, fmt.Errorf("no screen named %q", name)
+	case 1:
+		return matches[0], nil
+	default:
+		names := make([]string, len(matches))
+		for n, i := range matches {
+			names[n] = screens[i].Name()
+		}
+		return 0, fmt.Errorf("%q could be %s", name, strings.Join(names, " or "))
+	}
+}
+
+// ScreenNames returns the names of the screens in carousel order, none while they are being built
+func ScreenNames() []string {
+	if !screensBuilt.Load() {
+		return nil
+	}
+	names := make([]string, len(screens))
+	for i, s := range screens {
+		names[i] = s.Name()
+	}
+	return names
+}
+
 // startFadeCarousel Fast and smooth (default)
-func startFadeCarousel(delay float64) {
-	for {
-		for s := 0; s < activeScreenCount; s++ {
-			capture := image.NewGray(fb.Bounds())
-			draw.Draw(capture, capture.Bounds(), fb, image.ZP, draw.Src)
-			// Fade Old Screen Out
-			for x := range fades {
-				bg := image.NewGray(fb.Bounds())
-				draw.Draw(bg, bg.Bounds(), image.NewUniform(color.Gray{0}), image.ZP, draw.Src)
-				draw.DrawMask(bg, bg.Bounds(), capture, image.ZP, image.NewUniform(fades[x]), image.ZP, draw.Over)
-				draw.Draw(fb, fb.Bounds(), bg, image.ZP, draw.Over)
-				time.Sleep(8 * time.Millisecond)
-			}
+func startFadeCarousel(delay time.Duration) {
+	for s := nextScreen(-1, time.Now()); ; {
+		events.Publish("screen", screenEvent{Index: s, Name: screens[s].Name()})
 
-			// Fade New Screen In
-			for x := len(fades) - 1; x >= 0; x-- {
-				bg := image.NewGray(fb.Bounds())
-				draw.Draw(bg, bg.Bounds(), image.NewUniform(color.Gray{0}), image.ZP, draw.Src)
-				draw.DrawMask(bg, bg.Bounds(), screens[s], image.ZP, image.NewUniform(fades[x]), image.ZP, draw.Over)
-				draw.Draw(fb, fb.Bounds(), bg, image.ZP, draw.Over)
-				time.Sleep(8 * time.Millisecond)
-			}
-			time.Sleep(time.Duration(delay) * time.Millisecond)
+		// Take the panel back from the renderer for the transition
+		show(-1)
+
+		capture := image.NewGray(fb.Bounds())
+		fbMutex.Lock()
+		draw.Draw(capture, capture.Bounds(), fb, image.ZP, draw.Src)
+		fbMutex.Unlock()
+		// Fade Old Screen Out
+		for x := range fades {
+			bg := image.NewGray(fb.Bounds())
+			draw.Draw(bg, bg.Bounds(), image.NewUniform(color.Gray{0}), image.ZP, draw.Src)
+			draw.DrawMask(bg, bg.Bounds(), capture, image.ZP, image.NewUniform(fades[x]), image.ZP, draw.Over)
+			fbMutex.Lock()
+			draw.Draw(fb, fb.Bounds(), bg, image.ZP, draw.Over)
+			fbMutex.Unlock()
+			time.Sleep(8 * time.Millisecond)
+		}
+
+		// Fade New Screen In
+		for x := len(fades) - 1; x > 0; x-- {
+			bg := image.NewGray(fb.Bounds())
+			draw.Draw(bg, bg.Bounds(), image.NewUniform(color.Gray{0}), image.ZP, draw.Src)
+			frame(s, func(img *image.RGBA) {
+				draw.DrawMask(bg, bg.Bounds(), img, image.ZP, image.NewUniform(fades[x]), image.ZP, draw.Over)
+			})
+			fbMutex.Lock()
+			draw.Draw(fb, fb.Bounds(), bg, image.ZP, draw.Over)
+			fbMutex.Unlock()
+			time.Sleep(8 * time.Millisecond)
+		}
+
+		// The final, fully opaque frame is drawn by the renderer, which keeps it up to date
+		show(s)
+		s = waitDwell(s, delay)
+	}
+}
+
+// waitDwell shows screen s for its dwell and returns the screen to show next
+func waitDwell(s int, delay time.Duration) int {
+	timer := time.NewTimer(dwell(screens[s].Name(), delay))
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			return nextScreen(s, time.Now())
+		case next := <-jumpTo:
+			return next
+		case <-stayOn:
+			timer.Reset(dwell(screens[s].Name(), delay))
 		}
 	}
 }
 
 // startXCarousel Very slow and CPU intensive on arm
-func startXCarousel(delay float64) {
+func startXCarousel(delay time.Duration) {
 	capture := image.NewGray(fb.Bounds())
 	for i := 0; i < 2; i++ {
 		for s := range screens {
 			for x := fb.Bounds().Max.X; x > -1; x-- {
 				// Offset current framebuffer 1 pixel to the left (slide out)
-				draw.Draw(capture, image.Rect(-1, 0, -1+screens[s].Bounds().Max.X, screens[s].Bounds().Max.Y), fb, image.ZP, draw.Src)
+				draw.Draw(capture, image.Rect(-1, 0, -1+screens[s].Image().Bounds().Max.X, screens[s].Image().Bounds().Max.Y), fb, image.ZP, draw.Src)
 
 				// Print new screen directly on the capture as it slides out
-				draw.Draw(capture, image.Rect(x, 0, x+screens[s].Bounds().Max.X, screens[s].Bounds().Max.Y), screens[s], image.ZP, draw.Src)
+				draw.Draw(capture, image.Rect(x, 0, x+screens[s].Image().Bounds().Max.X, screens[s].Image().Bounds().Max.Y), screens[s].Image(), image.ZP, draw.Src)
 
 				// Send it all to the framebuffer
 				draw.Draw(fb, fb.Bounds(), capture, image.ZP, draw.Over)
 			}
-			time.Sleep(time.Duration(delay) * time.Millisecond)
+			time.Sleep(delay)
 		}
 	}
 }
 
 // startYCarousel slow and cpu intensive in bursts on arm
-func startYCarousel(delay float64) {
+func startYCarousel(delay time.Duration) {
 	capture := image.NewGray(fb.Bounds())
 	for i := 0; i < 2; i++ {
 		for s := range screens {
 			for y := fb.Bounds().Max.Y; y > -1; y-- {
 				// Offset current framebuffer 1 pixel to the left (slide out)
-				draw.Draw(capture, image.Rect(0, -1, screens[s].Bounds().Max.X, -1+screens[s].Bounds().Max.Y), fb, image.ZP, draw.Src)
+				draw.Draw(capture, image.Rect(0, -1, screens[s].Image().Bounds().Max.X, -1+screens[s].Image().Bounds().Max.Y), fb, image.ZP, draw.Src)
 
 				// Print new screen directly on the capture as it slides out
-				draw.Draw(capture, image.Rect(0, y, screens[s].Bounds().Max.X, y+screens[s].Bounds().Max.Y), screens[s], image.ZP, draw.Src)
+				draw.Draw(capture, image.Rect(0, y, screens[s].Image().Bounds().Max.X, y+screens[s].Image().Bounds().Max.Y), screens[s].Image(), image.ZP, draw.Src)
 
 				// Send it all to the framebuffer
 				draw.Draw(fb, fb.Bounds(), capture, image.ZP, draw.Over)
 			}
-			time.Sleep(time.Duration(delay) * time.Millisecond)
-		}
-	}
-}
-
-// Write draws text to a x,y coordinate on the image
-func write(screen draw.Image, text string, x, y int, size float64, fontname string) {
-	font := fonts.Load(fontname)
-	// Setup new context
-	c := freetype.NewContext()
-	c.SetFont(font)            // Set the font
-	c.SetFontSize(size)        // Set font size
-	c.SetDPI(72)               // Fixed DPI
-	c.SetClip(screen.Bounds()) // Clip the text?
-	c.SetDst(screen)           // Send it where?
-	c.SetSrc(image.White)      // Color of Foreground
-
-	_, err := c.DrawString(text, freetype.Pt(x, y+int(c.PointToFixed(math.Round(float64(size)+1))>>6))) // y is center of line, shift to top of line
-	if err != nil {
-		log.Println(err)
-		return
-	}
-}
-
-func center(screen draw.Image, text string, x, y int, size float64, fontname string) {
-	font := fonts.Load(fontname)
-	// Setup new context
-	c := freetype.NewContext()
-	c.SetFont(font)        // Set the font
-	c.SetFontSize(size)    // Set font size
-	c.SetDPI(72)           // Fixed DPI
-	c.SetClip(fb.Bounds()) // Clip the text?
-	c.SetDst(fb)           // Send it where?
-	c.SetSrc(image.White)  // Color of Foreground
-
-	// Truetype stuff
-	opts := truetype.Options{}
-	opts.DPI = 72
-	opts.Size = size + 1
-	face := truetype.NewFace(font, &opts)
-
-	var widths int
-	// Calculate the widths and print to image
-
-	for _, t := range text {
-		awidth, ok := face.GlyphAdvance(rune(t))
-		if ok != true {
-			return
+			time.Sleep(delay)
 		}
-		iwidthf := int(float64(awidth) / 64)
-		widths = widths + iwidthf
 	}
-	write(fb, text, width/2-widths/2, y, size, fontname)
 }
@@ -0,0 +1,91 @@
+package display
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/draw"
+	"time"
+
+	"cloudkey/images"
+	"cloudkey/src/kubernetes"
+)
+
+// gitOpsLines summarizes the GitOps resources, naming the first one that is not synced
+func gitOpsLines(apps []kubernetes.GitOpsApp) (string, string, string) {
+	if len(apps) == 0 {
+		return "GitOps: none", "no Flux or Argo", "resources found"
+	}
+
+	synced := 0
+	for _, app := range apps {
+		if app.Synced {
+			synced++
+		}
+	}
+	summary := fmt.Sprintf("GitOps: %d/%d synced", synced, len(apps))
+	if synced == len(apps) {
+		return summary, "All healthy", ""
+	}
+	// Unsynced apps are listed first
+	return summary, apps[0].Namespace + "/" + apps[0].Name, apps[0].Reason
+}
+
+func buildGitOps(i int, demo bool, opts CmdLineOpts) {
+	screen := setupImage(i)
+
+	draw.Draw(screen, screen.Bounds(), image.Black, image.ZP, draw.Src)
+	drawIcon(screen, 0, images.Load("kubernetes"))
+
+	if demo {
+		writeRow(screen, 0, "GitOps: 12/13 synced")
+		writeRow(screen, 1, "apps/podinfo")
+		writeRow(screen, 2, "HealthCheckFailed")
+		return
+	}
+
+	run := pipelineContext(i)
+	go func() {
+		defer recoverCrash()
+		client, err := kubernetes.NewClient(opts.K8sKubeconfig)
+		if err != nil {
+			fmt.Printf("GitOps client init error: %v\n", err)
+		}
+
+		for {
+			line1, line2, line3 := "GitOps offline", "config error", "check kubeconfig"
+
+			if client != nil {
+				traced, done := collect(run, "gitops")
+				ctx, cancel := context.WithTimeout(traced, 15*time.Second)
+				apps, err := client.GitOpsStatus(ctx)
+				cancel()
+				done(err)
+				if run.Err() != nil {
+					return
+				}
+
+				if err != nil {
+					fmt.Printf("GitOps status error: %v\n", err)
+					line2, line3 = "unreachable", "check config"
+				} else {
+					activity("gitops")
+					line1, line2, line3 = gitOpsLines(apps)
+					setWarning("gitops", len(apps) > 0 && !apps[0].Synced)
+					setRelevant("gitops", len(apps) > 0 && !apps[0].Synced)
+				}
+			}
+
+			redraw(i, func(screen draw.Image) {
+				clearText(screen)
+				writeRow(screen, 0, line1)
+				writeRow(screen, 1, line2)
+				writeRow(screen, 2, line3)
+			})
+
+			if !pause(run, refreshInterval("gitops")) {
+				return
+			}
+		}
+	}()
+}
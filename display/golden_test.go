@@ -0,0 +1,173 @@
+package display
+
+import (
+	"flag"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/llajas/cloudkey/src/panel"
+)
+
+// update rewrites the golden images instead of comparing against them:
+//
+//	go test ./display -run TestGolden -update
+var update = flag.Bool("update", false, "rewrite the golden images in testdata/golden")
+
+const (
+	// goldenChannelTolerance is how far a color channel may drift before a pixel counts as changed,
+	// absorbing anti-aliasing differences between freetype versions
+	goldenChannelTolerance = 24
+	// goldenPixelTolerance is how many changed pixels a screen may have before the test fails
+	goldenPixelTolerance = 8
+)
+
+// goldenScreens renders every screen from its fixed demo data
+var goldenScreens = []struct {
+	name  string
+	build func(i int, opts CmdLineOpts)
+}{
+	{"cpu", func(i int, opts CmdLineOpts) { buildCPUStats(i, true) }},
+	{"ram", func(i int, opts CmdLineOpts) { buildRAMStats(i, true) }},
+	{"swap", func(i int, opts CmdLineOpts) { buildSwapStats(i, true) }},
+	{"network", func(i int, opts CmdLineOpts) { buildNetwork(i, true, opts) }},
+	{"speedtest", func(i int, opts CmdLineOpts) { buildSpeedTest(i, true, opts) }},
+	{"speedsummary", func(i int, opts CmdLineOpts) { buildSpeedtestSummary(i, true, opts) }},
+	{"heatmap", func(i int, opts CmdLineOpts) { buildLatencyHeatmap(i, true, opts) }},
+	{"dualwan", func(i int, opts CmdLineOpts) { buildDualWAN(i, true, opts) }},
+	{"vpn", func(i int, opts CmdLineOpts) { buildVPNSessions(i, true, opts) }},
+	{"threats", func(i int, opts CmdLineOpts) { buildThreats(i, true, opts) }},
+	{"updates", func(i int, opts CmdLineOpts) { buildUpdates(i, true, opts) }},
+	{"wifi", func(i int, opts CmdLineOpts) { buildWiFiExperience(i, true, opts) }},
+	{"airtime", func(i int, opts CmdLineOpts) { buildAirtime(i, true, opts) }},
+	{"dhcp", func(i int, opts CmdLineOpts) { buildDHCP(i, true, opts) }},
+	{"quota", func(i int, opts CmdLineOpts) { buildQuota(i, true, opts) }},
+	{"discovery", func(i int, opts CmdLineOpts) { buildDiscovery(i, true, opts) }},
+	{"kubernetes", func(i int, opts CmdLineOpts) { buildKubernetes(i, true, opts) }},
+	{"gitops", func(i int, opts CmdLineOpts) { buildGitOps(i, true, opts) }},
+	{"processes", func(i int, opts CmdLineOpts) { buildTopProcesses(i, true, opts) }},
+	{"diagnostics", func(i int, opts CmdLineOpts) { buildDiagnostics(i, true, opts) }},
+	{"devices", func(i int, opts CmdLineOpts) { buildDevices(i, true, opts) }},
+	{"k8smessages", func(i int, opts CmdLineOpts) { buildClusterMessages(i, true, opts) }},
+	{"alertmanager", func(i int, opts CmdLineOpts) { buildAlertmanager(i, true, opts) }},
+	{"version", func(i int, opts CmdLineOpts) { buildVersion(i, true, opts) }},
+	{"ticker", func(i int, opts CmdLineOpts) { buildTicker(i, true) }},
+	{"bigdigits", func(i int, opts CmdLineOpts) { redraw(i, bigDigits("download", "1.2 Gb/s")) }},
+}
+
+func TestGolden(t *testing.T) {
+	fb = image.NewRGBA(panelBounds)
+	width, height = panelBounds.Dx(), panelBounds.Dy()
+
+	// Every case gets its own screen, added before any is built: the goroutines of the
+	// screens built before keep redrawing theirs, and must not see screens change
+	screens = nil
+	for _, tc := range goldenScreens {
+		addScreen(tc.name)
+	}
+
+	for i, tc := range goldenScreens {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.build(i, CmdLineOpts{StateDir: t.TempDir()})
+			got := settled(t, i)
+
+			path := filepath.Join("testdata", "golden", tc.name+".png")
+			if *update {
+				if err := writePNG(path, got); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			want, err := readPNG(path)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			if changed := diffPixels(got, want); changed > goldenPixelTolerance {
+				actual := filepath.Join(os.TempDir(), "cloudkey-golden", tc.name+".png")
+				if err := writePNG(actual, got); err != nil {
+					t.Logf("saving the rendered screen: %v", err)
+				}
+				t.Errorf("%d pixels differ from %s (tolerance %d), rendered screen saved to %s", changed, path, goldenPixelTolerance, actual)
+			}
+		})
+	}
+}
+
+// settled waits for screen i to stop changing, as screens drawing from a goroutine
+// land a moment after being built, and returns a copy of it
+func settled(t *testing.T, i int) *image.RGBA {
+	t.Helper()
+
+	var last *image.RGBA
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		var current *image.RGBA
+		frame(i, func(img *image.RGBA) {
+			current = image.NewRGBA(img.Bounds())
+			copy(current.Pix, img.Pix)
+		})
+		if last != nil && diffPixels(current, last) == 0 && !isBlack(current.SubImage(panel.TextArea).(*image.RGBA)) {
+			return current
+		}
+		last = current
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("screen %s did not settle", screens[i].Name())
+	return nil
+}
+
+// diffPixels counts the pixels of a and b whose color differs by more than goldenChannelTolerance
+func diffPixels(a, b image.Image) int {
+	if a.Bounds() != b.Bounds() {
+		return a.Bounds().Dx() * a.Bounds().Dy()
+	}
+
+	changed := 0
+	bounds := a.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r1, g1, b1, _ := a.At(x, y).RGBA()
+			r2, g2, b2, _ := b.At(x, y).RGBA()
+			if channelDiff(r1, r2) > goldenChannelTolerance || channelDiff(g1, g2) > goldenChannelTolerance || channelDiff(b1, b2) > goldenChannelTolerance {
+				changed++
+			}
+		}
+	}
+	return changed
+}
+
+// channelDiff returns the 8-bit distance between two 16-bit color channels
+func channelDiff(a, b uint32) uint32 {
+	if a > b {
+		return (a - b) >> 8
+	}
+	return (b - a) >> 8
+}
+
+func readPNG(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return png.Decode(f)
+}
+
+func writePNG(path string, img image.Image) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
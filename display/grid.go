@@ -0,0 +1,69 @@
+package display
+
+import (
+	"image"
+	"image/draw"
+
+	"github.com/llajas/cloudkey/src/panel"
+)
+
+// Screens are laid out on the logical panel of the panel package and scaled to the actual one,
+// so a screen defined once fits any panel. The helpers below draw at the panel's scale.
+
+// scale is how many panel pixels a logical pixel covers
+var scale = 1.0
+
+// setScale fits the logical panel into bounds
+func setScale(bounds image.Rectangle) {
+	scale = panel.ScaleFor(bounds)
+}
+
+// canvas draws on screen at the panel's scale
+func canvas(screen draw.Image) panel.Canvas {
+	return panel.Canvas{Image: screen, Scale: scale}
+}
+
+// px converts a logical coordinate to panel pixels
+func px(v int) int {
+	return canvas(nil).Px(v)
+}
+
+// scaled converts a logical rectangle to panel pixels
+func scaled(r image.Rectangle) image.Rectangle {
+	return canvas(nil).Scaled(r)
+}
+
+// write draws text to a logical x,y coordinate on the image, scaled to the panel
+func write(screen draw.Image, text string, x, y int, size float64, fontname string) {
+	canvas(screen).Write(text, x, y, size, fontname)
+}
+
+// textWidth measures text drawn by write, in logical pixels
+func textWidth(text string, size float64, fontname string) int {
+	return canvas(nil).TextWidth(text, size, fontname)
+}
+
+// writeRow writes text on a row, right of the icons
+func writeRow(screen draw.Image, row int, text string) {
+	canvas(screen).Row(row, text)
+}
+
+// drawIcon draws an icon in front of a row
+func drawIcon(screen draw.Image, row int, icon image.Image) {
+	canvas(screen).Icon(row, icon)
+}
+
+// drawImage draws img over the logical rectangle r, scaled to fit it
+func drawImage(screen draw.Image, r image.Rectangle, img image.Image) {
+	canvas(screen).DrawImage(r, img)
+}
+
+// fill paints the logical rectangle r
+func fill(screen draw.Image, r image.Rectangle, src image.Image) {
+	canvas(screen).Fill(r, src)
+}
+
+// clearText blacks out the text area, right of the icons
+func clearText(screen draw.Image) {
+	canvas(screen).ClearText()
+}
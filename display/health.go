@@ -2,16 +2,19 @@ package display
 
 import (
 	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/shirou/gopsutil/v4/mem"
 
+	"cloudkey/src/events"
+	"cloudkey/src/history"
 	"cloudkey/src/leds"
-)
-
-const (
-	ThresholdWarning  = 80.0
-	ThresholdCritical = 95.0
+	"cloudkey/src/metrics"
+	"cloudkey/src/pressure"
 )
 
 type HealthState int
@@ -22,71 +25,290 @@ const (
 	HealthCritical
 )
 
+// String returns the lowercase name of the health state
+func (h HealthState) String() string {
+	switch h {
+	case HealthWarning:
+		return "warning"
+	case HealthCritical:
+		return "critical"
+	default:
+		return "ok"
+	}
+}
+
+// healthEvent is published whenever the health state changes
+type healthEvent struct {
+	State    string  `json:"state"`
+	CPU      float64 `json:"cpu_percent"`
+	RAM      float64 `json:"ram_percent"`
+	Swap     float64 `json:"swap_percent"`
+	Pressure float64 `json:"memory_pressure"`
+	UDMError bool    `json:"udm_error"`
+}
+
 var (
-	currentHealth HealthState = HealthOK
-	hasUDMError   bool
+	currentHealth atomic.Int32 // the HealthState shown, written by the health monitor only
+	hasUDMError   atomic.Bool
 	healthMonitor *leds.LEDS
+
+	// warnings are conditions reported by screens that raise the health to at least their level,
+	// WARNING for most of them
+	warnings      = make(map[string]HealthState)
+	warningsMutex sync.Mutex
 )
 
 func SetUDMError(hasError bool) {
-	hasUDMError = hasError
+	hasUDMError.Store(hasError)
+}
+
+// healthState is the health state currently shown
+func healthState() HealthState {
+	return HealthState(currentHealth.Load())
+}
+
+// SetWANBackup raises the health to at least WARNING while running on the backup WAN
+func SetWANBackup(onBackup bool) {
+	setWarning("wan_backup", onBackup)
+}
+
+// setWarning raises or clears a named warning condition
+func setWarning(name string, active bool) {
+	level := HealthOK
+	if active {
+		level = HealthWarning
+	}
+	raiseHealth(name, level)
+}
+
+// raiseHealth holds the health at level or above while the named condition lasts, or clears
+// the condition with HealthOK
+func raiseHealth(name string, level HealthState) {
+	warningsMutex.Lock()
+	defer warningsMutex.Unlock()
+
+	if level > HealthOK {
+		warnings[name] = level
+	} else {
+		delete(warnings, name)
+	}
+}
+
+// raisedHealth returns the highest level a condition holds the health at, HealthOK if none
+func raisedHealth() HealthState {
+	warningsMutex.Lock()
+	defer warningsMutex.Unlock()
+
+	raised := HealthOK
+	for _, level := range warnings {
+		raised = max(raised, level)
+	}
+	return raised
+}
+
+const (
+	// healthSummaryWindow is the period summarized on the diagnostics screen and API
+	healthSummaryWindow = 7 * 24 * time.Hour
+	// healthRetention is how long health transitions are kept in the state directory
+	healthRetention = 30 * 24 * time.Hour
+)
+
+var (
+	healthStore     *history.Store[history.Transition]
+	healthStoreOnce sync.Once
+)
+
+// healthHistory returns the store of health transitions in the state directory
+func healthHistory(stateDir string) *history.Store[history.Transition] {
+	healthStoreOnce.Do(func() {
+		healthStore = history.Open[history.Transition](filepath.Join(stateDir, "health.jsonl"))
+	})
+	return healthStore
+}
+
+// HealthSummary is the health history served by the API
+type HealthSummary struct {
+	State               string               `json:"state"`
+	WindowHours         float64              `json:"window_hours"`
+	WarningSeconds      float64              `json:"warning_seconds"`
+	CriticalSeconds     float64              `json:"critical_seconds"`
+	Incidents           int                  `json:"incidents"`
+	LastIncidentSeconds float64              `json:"last_incident_seconds"`
+	Ongoing             bool                 `json:"ongoing"`
+	MTTRSeconds         float64              `json:"mttr_seconds"`
+	Transitions         []history.Transition `json:"transitions"`
+}
+
+// HealthHistory summarizes the health transitions of the last 7 days
+func HealthHistory(opts CmdLineOpts) (HealthSummary, error) {
+	transitions, err := healthHistory(opts.StateDir).All()
+	if err != nil {
+		return HealthSummary{}, err
+	}
+
+	now := time.Now()
+	summary := history.SummarizeHealth(transitions, now, healthSummaryWindow)
+	var recent []history.Transition
+	for _, t := range transitions {
+		if now.Sub(t.Time) <= healthSummaryWindow {
+			recent = append(recent, t)
+		}
+	}
+
+	return HealthSummary{
+		State:               healthState().String(),
+		WindowHours:         summary.Window.Hours(),
+		WarningSeconds:      summary.Warning.Seconds(),
+		CriticalSeconds:     summary.Critical.Seconds(),
+		Incidents:           summary.Incidents,
+		LastIncidentSeconds: summary.LastIncident.Seconds(),
+		Ongoing:             summary.Ongoing,
+		MTTRSeconds:         summary.MTTR.Seconds(),
+		Transitions:         recent,
+	}, nil
 }
 
-func startHealthMonitor() {
+// startHealthMonitor evaluates the health rules every 5 seconds and shows the result on the LEDs.
+// A new state must be seen HealthWindow times in a row before it is shown.
+func startHealthMonitor(opts CmdLineOpts) {
 	healthMonitor = &myLeds
+	rules := opts.HealthRules
+	window := max(opts.HealthWindow, 1)
+
+	profile := ledProfile(opts)
+	overrides := opts.HealthLEDs
+	if headless {
+		overrides = nil
+	}
+	mapping := healthIndications(profile, overrides)
+	startLogo(opts, profile)
+	fmt.Printf("LED profile: %s\n", profile.Name)
+
+	store := healthHistory(opts.StateDir)
+	retention := time.Now().Add(-healthRetention)
+	if err := store.Prune(func(t history.Transition) bool { return t.Time.After(retention) }); err != nil {
+		fmt.Printf("Error pruning health history: %v\n", err)
+	}
+	// Record a transition whenever the state differs from the last one stored
+	recorded := HealthOK.String()
+	if past, err := store.All(); err != nil {
+		fmt.Printf("Error reading health history: %v\n", err)
+	} else if len(past) > 0 {
+		recorded = past[len(past)-1].State
+	}
 
 	go func() {
+		defer recoverCrash()
+		current := healthState()
+		pending, pendingCount := current, 0
+		shownUDMError := false
+
 		for {
-			cpuPercent, _ := getCPUUsagePerCore()
+			lastHealthCheck.Store(time.Now().UnixNano())
+			cpuPercent := cpuSampler.Latest().Total
 			memInfo, _ := mem.VirtualMemory()
 			memPercent := memInfo.UsedPercent
+			var swapPercent float64
+			if swapInfo, err := mem.SwapMemory(); err == nil && swapInfo.Total > 0 {
+				swapPercent = swapInfo.UsedPercent
+			}
+			// PSI is missing on older kernels, which leaves pressure at zero
+			psi, _ := pressure.Memory()
+			memPressure := psi.Some.Avg60
+
+			metrics.Set("cloudkey_ram_percent", "System memory in use.", memPercent)
+			metrics.Set("cloudkey_swap_percent", "System swap in use.", swapPercent)
+			metrics.Set("cloudkey_memory_pressure_percent", "Share of time tasks stalled on memory over 60s.", psi.Some.Avg60, "kind", "some")
+			metrics.Set("cloudkey_memory_pressure_percent", "Share of time tasks stalled on memory over 60s.", psi.Full.Avg60, "kind", "full")
+
+			newHealth := rules.Evaluate(map[string]float64{
+				"cpu":      cpuPercent,
+				"ram":      memPercent,
+				"swap":     swapPercent,
+				"pressure": memPressure,
+			})
+			newHealth = max(newHealth, raisedHealth())
+
+			// Hold the current state until the new one has lasted the whole window
+			if newHealth != pending {
+				pending, pendingCount = newHealth, 0
+			}
+			pendingCount++
+			if pendingCount < window {
+				newHealth = current
+			}
 
-			newHealth := evaluateHealth(cpuPercent, memPercent)
+			udmError := hasUDMError.Load()
+			if newHealth != current || udmError != shownUDMError {
+				if newHealth != current {
+					events.Publish("health", healthEvent{
+						State:    newHealth.String(),
+						CPU:      cpuPercent,
+						RAM:      memPercent,
+						Swap:     swapPercent,
+						Pressure: memPressure,
+						UDMError: udmError,
+					})
+				}
+				current = newHealth
+				currentHealth.Store(int32(newHealth))
+				shownUDMError = udmError
+				updateHealthLEDs(newHealth, udmError, mapping)
+			}
 
-			if newHealth != currentHealth || hasUDMError {
-				currentHealth = newHealth
-				updateRackLEDs(newHealth, hasUDMError)
+			if state := current.String(); state != recorded {
+				if err := store.Append(history.Transition{Time: time.Now(), State: state}); err != nil {
+					fmt.Printf("Error saving health history: %v\n", err)
+				}
+				recorded = state
 			}
+			alertHealth(current)
 
-			time.Sleep(5 * time.Second)
+			time.Sleep(refreshInterval("health"))
 		}
 	}()
 
-	fmt.Println("Health monitor started (CPU/RAM -> rack LED)")
+	fmt.Printf("Health monitor started (%s -> LEDs)\n", rules.String())
 }
 
-func evaluateHealth(cpu, ram float64) HealthState {
-	maxUsage := cpu
-	if ram > maxUsage {
-		maxUsage = ram
+// updateHealthLEDs shows the health state using the LED mapped to each state
+func updateHealthLEDs(health HealthState, udmError bool, mapping map[string]leds.Indication) {
+	if udmError {
+		health = HealthCritical
 	}
 
-	if maxUsage >= ThresholdCritical {
-		return HealthCritical
-	} else if maxUsage >= ThresholdWarning {
-		return HealthWarning
+	// Only the LEDs used for health are touched
+	clearBlinkCode()
+	for _, led := range mapping {
+		if led.LED != "" {
+			myLeds.LED(led.LED).Off()
+		}
 	}
-	return HealthOK
-}
-
-func updateRackLEDs(health HealthState, udmError bool) {
-	rackBlue := myLeds.LED("rack:blue")
-	rackWhite := myLeds.LED("rack:white")
-	ulogo := myLeds.LED("ulogo_ctrl")
 
-	rackBlue.Off()
-	rackWhite.Off()
-
-	if udmError || health == HealthCritical {
-		rackWhite.Blink(255, 500, 500)
-		fmt.Printf("Health: CRITICAL (blink white) - CPU/RAM > %.0f%% or UDM error\n", ThresholdCritical)
-	} else if health == HealthWarning {
-		rackWhite.On()
-		fmt.Printf("Health: WARNING (solid white) - CPU/RAM > %.0f%%\n", ThresholdWarning)
-	} else {
-		rackBlue.On()
-		fmt.Printf("Health: OK (solid blue)\n")
+	led := mapping[health.String()]
+	if led.LED != "" {
+		if udmError {
+			// Tell an unreachable controller apart from the system itself being in trouble
+			showBlinkCode(led.LED, CodeControllerUnreachable)
+		} else if led.Blink {
+			myLeds.LED(led.LED).Blink(255, 500, 500)
+		} else {
+			myLeds.LED(led.LED).On()
+		}
 	}
 
-	ulogo.On()
+	mode := "solid"
+	if udmError {
+		mode = fmt.Sprintf("blink code %d", CodeControllerUnreachable)
+	} else if led.Blink {
+		mode = "blink"
+	}
+	if led.LED == "" {
+		mode, led.LED = "no", "LED"
+	}
+	reason := ""
+	if udmError {
+		reason = " - UDM error"
+	}
+	fmt.Printf("Health: %s (%s %s)%s\n", strings.ToUpper(health.String()), mode, led.LED, reason)
 }
@@ -0,0 +1,167 @@
+package display
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"cloudkey/src/leds"
+)
+
+// healthMetrics are the measurements a health rule can refer to
+var healthMetrics = []string{"cpu", "ram", "swap", "pressure"}
+
+// HealthRule raises the health to WARNING or CRITICAL when a metric reaches a threshold
+type HealthRule struct {
+	Metric   string
+	Warning  float64
+	Critical float64
+}
+
+// HealthRules is a comma-separated list of metric:warning:critical rules, usable with flag.Var
+type HealthRules []HealthRule
+
+// DefaultHealthRules are the thresholds used when none are configured
+var DefaultHealthRules = HealthRules{
+	{Metric: "cpu", Warning: 80, Critical: 95},
+	{Metric: "ram", Warning: 80, Critical: 95},
+	{Metric: "swap", Warning: 80, Critical: 95},
+	{Metric: "pressure", Warning: 10, Critical: 40},
+}
+
+// String joins the rules back into their flag form
+func (r *HealthRules) String() string {
+	var parts []string
+	for _, rule := range *r {
+		parts = append(parts, fmt.Sprintf("%s:%s:%s", rule.Metric,
+			strconv.FormatFloat(rule.Warning, 'g', -1, 64), strconv.FormatFloat(rule.Critical, 'g', -1, 64)))
+	}
+	return strings.Join(parts, ",")
+}
+
+// Set replaces the rules with the comma-separated list. "none" leaves only the
+// warnings raised by screens and controller errors.
+func (r *HealthRules) Set(value string) error {
+	var rules HealthRules
+	if value == "none" {
+		*r = rules
+		return nil
+	}
+
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		fields := strings.Split(item, ":")
+		if len(fields) != 3 {
+			return fmt.Errorf("health rule %q: expected metric:warning:critical", item)
+		}
+		if !slices.Contains(healthMetrics, fields[0]) {
+			return fmt.Errorf("health rule %q: unknown metric %q (one of %s)", item, fields[0], strings.Join(healthMetrics, ", "))
+		}
+		warning, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return fmt.Errorf("health rule %q: invalid warning threshold: %v", item, err)
+		}
+		critical, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			return fmt.Errorf("health rule %q: invalid critical threshold: %v", item, err)
+		}
+		if warning > critical {
+			return fmt.Errorf("health rule %q: warning threshold is above the critical threshold", item)
+		}
+		for _, existing := range rules {
+			if existing.Metric == fields[0] {
+				return fmt.Errorf("health rule %q: %s already has a rule", item, fields[0])
+			}
+		}
+
+		rules = append(rules, HealthRule{Metric: fields[0], Warning: warning, Critical: critical})
+	}
+
+	*r = rules
+	return nil
+}
+
+// Evaluate returns the worst state any rule reports for the given metric values
+func (r HealthRules) Evaluate(values map[string]float64) HealthState {
+	state := HealthOK
+	for _, rule := range r {
+		value := values[rule.Metric]
+		if value >= rule.Critical {
+			return HealthCritical
+		} else if value >= rule.Warning {
+			state = HealthWarning
+		}
+	}
+	return state
+}
+
+// HealthLEDs overrides the LED profile for some health states (ok, warning, critical), usable with flag.Var
+type HealthLEDs map[string]leds.Indication
+
+// healthStates are the states a health LED can be configured for
+var healthStates = []string{"ok", "warning", "critical"}
+
+// String joins the overrides back into their flag form
+func (l *HealthLEDs) String() string {
+	var parts []string
+	for state, led := range *l {
+		part := state + "=" + led.LED
+		if led.Blink {
+			part += ":blink"
+		}
+		parts = append(parts, part)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+// Set replaces the overrides, e.g. "warning=rack:white,critical=white:blink".
+// States that are not listed use the LED profile.
+func (l *HealthLEDs) Set(value string) error {
+	overrides := make(HealthLEDs)
+
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		state, name, ok := strings.Cut(item, "=")
+		if !ok {
+			return fmt.Errorf("health LED %q: expected state=led[:blink]", item)
+		}
+		if !slices.Contains(healthStates, state) {
+			return fmt.Errorf("health LED %q: unknown state %q (one of %s)", item, state, strings.Join(healthStates, ", "))
+		}
+
+		led := leds.Indication{LED: name}
+		if trimmed, found := strings.CutSuffix(name, ":blink"); found {
+			led = leds.Indication{LED: trimmed, Blink: true}
+		}
+		if !slices.Contains(leds.KnownLEDs, led.LED) {
+			return fmt.Errorf("health LED %q: unknown LED %q (one of %s)", item, led.LED, strings.Join(leds.KnownLEDs, ", "))
+		}
+		overrides[state] = led
+	}
+
+	*l = overrides
+	return nil
+}
+
+// healthIndications applies the overrides on top of the health LEDs of a profile
+func healthIndications(profile leds.Profile, overrides HealthLEDs) map[string]leds.Indication {
+	mapping := make(map[string]leds.Indication)
+	for state, led := range profile.Health {
+		mapping[state] = led
+	}
+	for state, led := range overrides {
+		mapping[state] = led
+	}
+	return mapping
+}
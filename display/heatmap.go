@@ -0,0 +1,179 @@
+package display
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math/rand"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/llajas/cloudkey/src/network"
+
+	"cloudkey/images"
+	"cloudkey/src/history"
+	"cloudkey/src/metrics"
+)
+
+const (
+	// pingInterval is how often the ping monitor samples the latency
+	pingInterval = time.Minute
+	// pingRetention is how long ping results are kept, a little over the heatmap's week
+	pingRetention = 8 * 24 * time.Hour
+
+	// Latencies at or below heatmapGoodMs are green, at or above heatmapBadMs red
+	heatmapGoodMs = 20
+	heatmapBadMs  = 150
+
+	// Each hour is a heatmapWidth x heatmapHeight block, one pixel of which is the gap to the next
+	heatmapX      = 22
+	heatmapY      = 18
+	heatmapWidth  = 5
+	heatmapHeight = 6
+)
+
+// startPingMonitor pings target every pingInterval and appends the results to store
+func startPingMonitor(target string, store *history.Store[network.PingResult]) {
+	retention := time.Now().Add(-pingRetention).UnixMilli()
+	if err := store.Prune(func(r network.PingResult) bool { return r.Timestamp >= retention }); err != nil {
+		fmt.Printf("Error pruning ping history: %v\n", err)
+	}
+
+	go func() {
+		defer recoverCrash()
+		pruned := time.Now()
+		for {
+			result := network.Ping(target, 5*time.Second)
+			if result.Lost {
+				metrics.Add("cloudkey_ping_lost_total", "Pings of the ping monitor that timed out.", 1)
+			} else {
+				activity("heatmap")
+				metrics.Set("cloudkey_ping_latency_seconds", "Latest round trip of the ping monitor.", result.LatencyMs/1000)
+			}
+			if err := store.Append(result); err != nil {
+				fmt.Printf("Error saving ping history: %v\n", err)
+			}
+
+			// Keep the file to about a week on the Cloud Key's small flash
+			if time.Since(pruned) >= 24*time.Hour {
+				retention := time.Now().Add(-pingRetention).UnixMilli()
+				if err := store.Prune(func(r network.PingResult) bool { return r.Timestamp >= retention }); err != nil {
+					fmt.Printf("Error pruning ping history: %v\n", err)
+				}
+				pruned = time.Now()
+			}
+
+			time.Sleep(pingInterval)
+		}
+	}()
+}
+
+// heatmapColor maps an hour's latency to green through yellow to red. Hours without any samples
+// are dark grey and hours where every ping was lost are bright red.
+func heatmapColor(cell history.HeatmapCell) color.RGBA {
+	if cell.Samples == 0 {
+		if cell.Lost > 0 {
+			return color.RGBA{255, 0, 0, 255}
+		}
+		return color.RGBA{32, 32, 32, 255}
+	}
+
+	f := (cell.MedianMs - heatmapGoodMs) / (heatmapBadMs - heatmapGoodMs)
+	f = max(0, min(1, f))
+	// Lost pings push an otherwise fine hour towards red
+	f = max(f, float64(cell.Lost)/float64(cell.Samples+cell.Lost))
+
+	if f < 0.5 {
+		return color.RGBA{uint8(f * 2 * 255), 200, 0, 255}
+	}
+	return color.RGBA{255, uint8((1 - f) * 2 * 200), 0, 255}
+}
+
+// drawHeatmap draws one row per day, today at the bottom, of one cell per hour
+func drawHeatmap(screen draw.Image, h history.Heatmap) {
+	for day := range h {
+		for hour, cell := range h[day] {
+			x := heatmapX + hour*heatmapWidth
+			y := heatmapY + day*heatmapHeight
+			rect := image.Rect(x, y, x+heatmapWidth-1, y+heatmapHeight-1)
+			fill(screen, rect, image.NewUniform(heatmapColor(cell)))
+		}
+	}
+}
+
+// heatmapTitle reports the median of the hourly medians of the heatmap
+func heatmapTitle(h history.Heatmap) string {
+	var medians []float64
+	for day := range h {
+		for _, cell := range h[day] {
+			if cell.Samples > 0 {
+				medians = append(medians, cell.MedianMs)
+			}
+		}
+	}
+	if len(medians) == 0 {
+		return "Ping: no data yet"
+	}
+	sort.Float64s(medians)
+	return fmt.Sprintf("Ping 7d: %.0f ms", medians[len(medians)/2])
+}
+
+// demoHeatmap is a week with slow evenings and one bad night
+func demoHeatmap() history.Heatmap {
+	var h history.Heatmap
+	r := rand.New(rand.NewSource(1))
+	for day := range h {
+		for hour := range h[day] {
+			ms := 12 + r.Float64()*6
+			if hour >= 19 && hour <= 22 {
+				ms += 40 + r.Float64()*40
+			}
+			cell := history.HeatmapCell{MedianMs: ms, Samples: 60}
+			if day == 4 && hour >= 1 && hour <= 3 {
+				cell = history.HeatmapCell{Lost: 60}
+			}
+			h[day][hour] = cell
+		}
+	}
+	return h
+}
+
+func buildLatencyHeatmap(i int, demo bool, opts CmdLineOpts) {
+	screen := setupImage(i)
+
+	draw.Draw(screen, screen.Bounds(), image.Black, image.ZP, draw.Src)
+	drawIcon(screen, 0, images.Load("internet"))
+
+	if demo {
+		h := demoHeatmap()
+		writeRow(screen, 0, heatmapTitle(h))
+		drawHeatmap(screen, h)
+		return
+	}
+
+	store := history.Open[network.PingResult](filepath.Join(opts.StateDir, "pings.jsonl"))
+	startPingMonitor(opts.PingTarget, store)
+
+	go func() {
+		defer recoverCrash()
+		loc := location(opts)
+
+		for {
+			results, err := store.All()
+			if err != nil {
+				fmt.Printf("Error reading ping history: %v\n", err)
+			}
+			h := history.LatencyHeatmap(results, time.Now().In(loc))
+
+			redraw(i, func(screen draw.Image) {
+				clearText(screen)
+				writeRow(screen, 0, heatmapTitle(h))
+				drawHeatmap(screen, h)
+			})
+
+			time.Sleep(refreshInterval("heatmap"))
+		}
+	}()
+}
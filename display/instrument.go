@@ -0,0 +1,83 @@
+package display
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/llajas/cloudkey/src/network"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"cloudkey/src/chaos"
+	"cloudkey/src/metrics"
+	"cloudkey/src/ratelimit"
+	"cloudkey/src/tracing"
+)
+
+// objectID matches the controller's object IDs in API paths
+var objectID = regexp.MustCompile(`^[0-9a-f]{24}$`)
+
+// instrument adds tracing, fault injection, metrics and, if configured, response captures to
+// a controller client
+func instrument(client *network.UDMProClient, opts CmdLineOpts) {
+	client.HTTPClient.Transport = tracing.Transport(chaos.Transport(ratelimit.Transport(client.HTTPClient.Transport)))
+
+	client.AddHooks(network.Hooks{
+		OnRequest: func(ctx context.Context, call network.Call) context.Context {
+			ctx, _ = tracing.Span(ctx, "unifi."+call.Kind, attribute.String("unifi.path", call.Path))
+			return ctx
+		},
+		OnResponse: func(ctx context.Context, call network.Call, result network.Result) {
+			span := trace.SpanFromContext(ctx)
+			if result.Status != 0 {
+				span.SetAttributes(attribute.Int("unifi.status", result.Status))
+			}
+			tracing.End(span, result.Err)
+		},
+	})
+
+	client.AddHooks(network.Hooks{
+		OnResponse: func(_ context.Context, call network.Call, result network.Result) {
+			endpoint := endpointName(call)
+			status := "error"
+			if result.Status != 0 {
+				status = strconv.Itoa(result.Status)
+			}
+			metrics.Add("cloudkey_controller_requests_total", "Controller API calls by endpoint and answer.", 1, "endpoint", endpoint, "status", status)
+			metrics.Set("cloudkey_controller_request_duration_seconds", "How long the last controller API call to an endpoint took.", result.Elapsed.Seconds(), "endpoint", endpoint)
+		},
+		OnRetry: func(context.Context, network.Call) {
+			metrics.Add("cloudkey_controller_retries_total", "Controller queries sent again after their session expired.", 1)
+		},
+		OnAuthRefresh: func(_ context.Context, err error) {
+			metrics.Add("cloudkey_controller_relogins_total", "Logins to the controller after the session expired.", 1)
+		},
+	})
+
+	if opts.CaptureAPIDir != "" {
+		client.AddHooks(client.CaptureHooks(opts.CaptureAPIDir))
+	}
+}
+
+// endpointName is the API path of a query without the site, query or object IDs, so it can
+// label a metric: /api/s/default/stat/sta?x=1 is stat/sta. Other calls go by their kind.
+func endpointName(call network.Call) string {
+	if call.Kind != "query" {
+		return call.Kind
+	}
+	path, _, _ := strings.Cut(call.Path, "?")
+	path = strings.TrimPrefix(path, "/api/")
+	if rest, ok := strings.CutPrefix(path, "s/"); ok {
+		_, path, _ = strings.Cut(rest, "/")
+	}
+
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		if objectID.MatchString(s) {
+			segments[i] = ":id"
+		}
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,131 @@
+package display
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultDelay is how long the carousel shows a screen unless -delay says otherwise
+const DefaultDelay = Delay(7500 * time.Millisecond)
+
+// Delay is how long the carousel shows a screen, usable with flag.Var. It takes a duration
+// such as 7.5s, or a number of milliseconds as the flag did before durations.
+type Delay time.Duration
+
+// String is the delay as a duration
+func (d *Delay) String() string {
+	return time.Duration(*d).String()
+}
+
+// Set parses a duration, or a plain number of milliseconds
+func (d *Delay) Set(value string) error {
+	if ms, err := strconv.ParseFloat(value, 64); err == nil {
+		*d = Delay(ms * float64(time.Millisecond))
+		return nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("delay %q: expected a duration such as 7.5s, or milliseconds", value)
+	}
+	*d = Delay(duration)
+	return nil
+}
+
+// defaultRefreshIntervals is how often the collectors fetch their data, by the screen they draw
+// or, for watchers without a screen, by what they watch. The watchdog expects a redraw from
+// each screen within a few of its intervals.
+var defaultRefreshIntervals = map[string]time.Duration{
+	"cpu":          5 * time.Second,
+	"ram":          5 * time.Second,
+	"swap":         5 * time.Second,
+	"network":      59 * time.Minute,
+	"speedtest":    5 * time.Minute,
+	"speedsummary": 15 * time.Minute,
+	"heatmap":      5 * time.Minute,
+	"dualwan":      time.Minute,
+	"vpn":          time.Minute,
+	"threats":      time.Hour,
+	"updates":      6 * time.Hour,
+	"wifi":         5 * time.Minute,
+	"airtime":      3 * time.Minute,
+	"dhcp":         3 * time.Minute,
+	"quota":        5 * time.Minute,
+	"discovery":    5 * time.Minute,
+	"kubernetes":   30 * time.Second,
+	"gitops":       time.Minute,
+	"processes":    10 * time.Second,
+	"diagnostics":  10 * time.Second,
+	"version":      time.Minute,
+	"alertmanager": 30 * time.Second,
+	"devices":      30 * time.Second,
+	"health":       5 * time.Second,
+	"ports":        time.Minute,
+	"newdevices":   time.Minute,
+}
+
+// RefreshIntervals override how often collectors fetch their data, usable with flag.Var.
+// Collectors that are not listed keep their default interval.
+type RefreshIntervals map[string]time.Duration
+
+// String joins the intervals back into their flag form
+func (r *RefreshIntervals) String() string {
+	var parts []string
+	for name, interval := range *r {
+		parts = append(parts, name+"="+interval.String())
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+// Set replaces the intervals, e.g. "network=10m,kubernetes=1m"
+func (r *RefreshIntervals) Set(value string) error {
+	intervals := make(RefreshIntervals)
+
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		name, text, ok := strings.Cut(item, "=")
+		if !ok {
+			return fmt.Errorf("refresh interval %q: expected collector=duration", item)
+		}
+		if _, known := defaultRefreshIntervals[name]; !known {
+			return fmt.Errorf("refresh interval %q: unknown collector %q (one of %s)", item, name, strings.Join(refreshCollectors(), ", "))
+		}
+		interval, err := time.ParseDuration(text)
+		if err != nil || interval <= 0 {
+			return fmt.Errorf("refresh interval %q: interval must be a positive duration such as 5m", item)
+		}
+		intervals[name] = interval
+	}
+
+	*r = intervals
+	return nil
+}
+
+// refreshCollectors lists the collectors whose interval can be set
+func refreshCollectors() []string {
+	var names []string
+	for name := range defaultRefreshIntervals {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// refreshIntervals is from -refresh-intervals
+var refreshIntervals RefreshIntervals
+
+// refreshInterval is how often the named collector fetches its data
+func refreshInterval(name string) time.Duration {
+	if interval, ok := refreshIntervals[name]; ok {
+		return interval
+	}
+	return defaultRefreshIntervals[name]
+}
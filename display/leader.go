@@ -0,0 +1,57 @@
+package display
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"cloudkey/src/kubernetes"
+	"cloudkey/src/leader"
+	"cloudkey/src/metrics"
+)
+
+// leaderMetricInterval is how often the leadership is exported as a metric
+const leaderMetricInterval = 5 * time.Second
+
+// startLeaderElection lets one of several instances publish metrics and notifications.
+// Without an election mode every instance is the leader.
+func startLeaderElection(opts CmdLineOpts) {
+	var elector leader.Elector
+	switch opts.LeaderElection {
+	case "file":
+		elector = leader.FileLock{Path: opts.LeaderLockFile}
+	case "lease":
+		client, err := kubernetes.NewClient(opts.K8sKubeconfig)
+		if err != nil {
+			fmt.Printf("Leader election: K8s client init error: %v, staying a follower\n", err)
+			leader.Start(context.Background(), noElection{})
+			return
+		}
+		identity, _ := os.Hostname()
+		namespace, name, _ := strings.Cut(opts.LeaderLease, "/")
+		elector = kubernetes.LeaseElector{Client: client, Namespace: namespace, Name: name, Identity: identity}
+	default:
+		return
+	}
+
+	leader.Start(context.Background(), elector)
+
+	go func() {
+		defer recoverCrash()
+		for {
+			value := 0.0
+			if leader.IsLeader() {
+				value = 1
+			}
+			metrics.Set("cloudkey_leader", "Whether this instance publishes metrics and notifications.", value)
+			time.Sleep(leaderMetricInterval)
+		}
+	}()
+}
+
+// noElection never elects this instance
+type noElection struct{}
+
+func (noElection) Run(ctx context.Context, onChange func(bool)) {}
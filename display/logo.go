@@ -0,0 +1,37 @@
+package display
+
+import (
+	"fmt"
+	"time"
+
+	"cloudkey/src/leds"
+)
+
+// logoBreathPeriod is how long one breath of the idle animation takes
+const logoBreathPeriod = 4 * time.Second
+
+// logoLevel is the configured brightness of the running LED, from 0 to 1
+var logoLevel = 1.0
+
+// startLogo lights the profile's running LED (the Ubiquiti logo on the rack mount) at the
+// configured brightness, or starts the breathing animation
+func startLogo(opts CmdLineOpts, profile leds.Profile) {
+	if profile.Running == "" {
+		return
+	}
+
+	logoLevel = float64(opts.LogoBrightness) / 100
+	led := myLeds.LED(profile.Running)
+	if opts.LogoBreathing {
+		go led.Play(leds.Breathing(logoBreathPeriod, logoLevel/10, logoLevel), nil)
+		fmt.Printf("Logo LED %s breathing (max %d%%)\n", profile.Running, opts.LogoBrightness)
+		return
+	}
+
+	led.Level(logoLevel)
+}
+
+// restoreLogo puts the running LED back to its configured brightness
+func restoreLogo(name string) {
+	myLeds.LED(name).Level(logoLevel)
+}
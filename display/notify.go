@@ -0,0 +1,153 @@
+package display
+
+import (
+	"fmt"
+
+	"github.com/llajas/cloudkey/src/network"
+
+	"cloudkey/src/events"
+	"cloudkey/src/leader"
+	"cloudkey/src/notify"
+)
+
+// notifyTargets returns the push services configured in opts
+func notifyTargets(opts CmdLineOpts) []notify.Target {
+	var targets []notify.Target
+	if opts.NtfyURL != "" {
+		targets = append(targets, &notify.Ntfy{URL: opts.NtfyURL, Token: opts.NtfyToken})
+	}
+	if opts.GotifyURL != "" {
+		targets = append(targets, &notify.Gotify{URL: opts.GotifyURL, Token: opts.GotifyToken})
+	}
+	if bot := telegramBot(opts); bot != nil {
+		targets = append(targets, bot)
+	}
+	if email := emailTarget(opts); email != nil {
+		// Anything less than critical waits for the digest
+		targets = append(targets, notify.AtLeast(email, notify.Critical))
+	}
+	return targets
+}
+
+// eventNotification turns an event into a notification, reporting false for events
+// that are not worth a push. previous is the last health state seen.
+func eventNotification(e events.Event, previous string) (notify.Notification, bool) {
+	switch data := e.Data.(type) {
+	case healthEvent:
+		n := notify.Notification{
+			Message: fmt.Sprintf("CPU %.0f%%, RAM %.0f%%, swap %.0f%%", data.CPU, data.RAM, data.Swap),
+		}
+		switch data.State {
+		case "critical":
+			n.Title, n.Severity, n.Tags = "Rack health critical", notify.Critical, []string{"rotating_light"}
+		case "warning":
+			n.Title, n.Severity, n.Tags = "Rack health warning", notify.Warning, []string{"warning"}
+		default:
+			if previous == "" || previous == "ok" {
+				return n, false
+			}
+			n.Title, n.Severity, n.Tags = "Rack health back to OK", notify.Info, []string{"white_check_mark"}
+		}
+		if data.UDMError {
+			n.Message += ", controller unreachable"
+		}
+		return n, true
+
+	case newDeviceEvent:
+		return notify.Notification{
+			Title:    "New device on the network",
+			Message:  fmt.Sprintf("%s (%s) got %s on %s", data.Name, data.MAC, data.IP, data.Network),
+			Severity: notify.Warning,
+			Tags:     []string{"new"},
+		}, true
+
+	case map[string]string:
+		if e.Type != "outage" {
+			return notify.Notification{}, false
+		}
+		if data["status"] == network.WANDown.String() {
+			return notify.Notification{
+				Title:    "Internet down",
+				Message:  "No probe host answered: " + data["error"],
+				Severity: notify.Critical,
+				Tags:     []string{"rotating_light"},
+			}, true
+		}
+		return notify.Notification{
+			Title:    "WAN check failed",
+			Message:  fmt.Sprintf("%s (%s)", data["error"], data["status"]),
+			Severity: notify.Warning,
+			Tags:     []string{"warning"},
+		}, true
+
+	case map[string]int:
+		if e.Type != "wan_failover" {
+			return notify.Notification{}, false
+		}
+		n := notify.Notification{
+			Title:    fmt.Sprintf("Failover to WAN%d", data["to"]),
+			Message:  fmt.Sprintf("Traffic moved from WAN%d to WAN%d", data["from"], data["to"]),
+			Severity: notify.Warning,
+			Tags:     []string{"twisted_rightwards_arrows"},
+		}
+		if data["to"] == 1 {
+			n.Title, n.Severity = "Back on WAN1", notify.Info
+		}
+		return n, true
+
+	case map[string]any:
+		if e.Type != "speedtest_degraded" {
+			return notify.Notification{}, false
+		}
+		result, _ := data["result"].(*network.SpeedtestResult)
+		download, _ := data["download"].(float64)
+		upload, _ := data["upload"].(float64)
+		if result == nil {
+			return notify.Notification{}, false
+		}
+		return notify.Notification{
+			Title: "Internet slower than usual",
+			Message: fmt.Sprintf("%s down / %s up, usually %s / %s",
+				network.FormatSpeed(result.DownloadMbps), network.FormatSpeed(result.UploadMbps),
+				network.FormatSpeed(download), network.FormatSpeed(upload)),
+			Severity: notify.Warning,
+			Tags:     []string{"turtle"},
+		}, true
+	}
+	return notify.Notification{}, false
+}
+
+// startNotifier pushes notable events to ntfy, Gotify, Telegram and email. Only the leader sends, so
+// redundant instances do not notify twice.
+func startNotifier(opts CmdLineOpts) {
+	targets := notifyTargets(opts)
+	if len(targets) == 0 {
+		return
+	}
+	// Validated at startup
+	minimum, _ := notify.ParseSeverity(opts.NotifyMinSeverity)
+
+	ch := events.Subscribe()
+	go func() {
+		defer recoverCrash()
+		previous := ""
+		for e := range ch {
+			n, ok := eventNotification(e, previous)
+			if health, isHealth := e.Data.(healthEvent); isHealth {
+				previous = health.State
+			}
+			if !ok || n.Severity < minimum || !leader.IsLeader() {
+				continue
+			}
+			if udmUpdating() {
+				// A rebooting UDM takes the network down with it, which is no news
+				fmt.Printf("Notification held back during the controller update: %s\n", n.Title)
+				continue
+			}
+			if opts.UDMUsername != "" {
+				n.Link, n.LinkName = opts.UDMBaseURL, "Open controller"
+			}
+			notify.Send(targets, n)
+		}
+	}()
+}
@@ -0,0 +1,420 @@
+package display
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	// Timezones must resolve in containers without a zoneinfo database
+	_ "time/tzdata"
+
+	"github.com/llajas/cloudkey/src/network"
+
+	"cloudkey/src/chaos"
+	"cloudkey/src/leds"
+	"cloudkey/src/notify"
+	"cloudkey/src/ratelimit"
+)
+
+// StringList is a comma-separated list option, usable with flag.Var and CLOUDKEY_* variables
+type StringList []string
+
+// String joins the list back into its flag form
+func (l *StringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+// Set replaces the list with the comma-separated values, dropping empty entries.
+// "none" clears the list, since empty CLOUDKEY_* variables are ignored.
+func (l *StringList) Set(value string) error {
+	*l = nil
+	if value == "none" {
+		return nil
+	}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+// GPIOLEDs maps LED names to GPIO lines (chip:offset), usable with flag.Var
+type GPIOLEDs map[string]string
+
+// String joins the mapping back into its flag form
+func (g *GPIOLEDs) String() string {
+	var parts []string
+	for name, line := range *g {
+		parts = append(parts, name+"="+line)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+// Set replaces the mapping, e.g. "rack:blue=gpiochip0:17,rack:white=gpiochip0:27"
+func (g *GPIOLEDs) Set(value string) error {
+	mapping := make(GPIOLEDs)
+
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		name, line, ok := strings.Cut(item, "=")
+		if !ok {
+			return fmt.Errorf("GPIO LED %q: expected led=chip:offset", item)
+		}
+		if !slices.Contains(leds.KnownLEDs, name) {
+			return fmt.Errorf("GPIO LED %q: unknown LED %q (one of %s)", item, name, strings.Join(leds.KnownLEDs, ", "))
+		}
+		if _, _, err := leds.ParseGPIOSpec(line); err != nil {
+			return err
+		}
+		mapping[name] = line
+	}
+
+	*g = mapping
+	return nil
+}
+
+// OutboundRates are the requests a minute allowed to single destinations, by host name,
+// usable with flag.Var
+type OutboundRates map[string]int
+
+// String joins the rates back into their flag form
+func (r *OutboundRates) String() string {
+	var parts []string
+	for host, n := range *r {
+		parts = append(parts, host+"="+strconv.Itoa(n))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+// Set replaces the rates, e.g. "192.168.1.1=600,api.ipify.org=5"
+func (r *OutboundRates) Set(value string) error {
+	rates := make(OutboundRates)
+
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		host, number, ok := strings.Cut(item, "=")
+		if !ok {
+			return fmt.Errorf("outbound rate %q: expected host=requests", item)
+		}
+		n, err := strconv.Atoi(number)
+		if err != nil || n < 0 {
+			return fmt.Errorf("outbound rate %q: requests a minute must be 0 (unlimited) or more", item)
+		}
+		rates[host] = n
+	}
+
+	*r = rates
+	return nil
+}
+
+// registerGPIOLEDs switches the configured LEDs to their GPIO lines
+func registerGPIOLEDs(mapping GPIOLEDs) {
+	for name, line := range mapping {
+		if err := leds.RegisterGPIO(name, line); err != nil {
+			fmt.Printf("GPIO LED: %v\n", err)
+		}
+	}
+}
+
+// UDMTimeouts returns the controller request timeouts
+func (o CmdLineOpts) UDMTimeouts() network.Timeouts {
+	return network.Timeouts{
+		Detect: o.UDMDetectTimeout,
+		Login:  o.UDMLoginTimeout,
+		Query:  o.UDMQueryTimeout,
+	}
+}
+
+// OptionProblem is what is wrong with an option
+type OptionProblem int
+
+const (
+	// OptionInvalid is a value that does not parse or is out of range
+	OptionInvalid OptionProblem = iota
+	// OptionMissing is an option that another one needs
+	OptionMissing
+	// OptionConflict is an option that cannot be combined with another one
+	OptionConflict
+)
+
+// OptionError is a problem Validate found with an option, named by its flag
+type OptionError struct {
+	Option  string
+	Problem OptionProblem
+	Message string
+}
+
+// Error says what is wrong and what is expected instead
+func (e *OptionError) Error() string {
+	return e.Message
+}
+
+// ExitCode is the status cloudkey exits with over the problem, telling them apart to scripts
+// and systemd
+func (e *OptionError) ExitCode() int {
+	switch e.Problem {
+	case OptionMissing:
+		return ExitMissingOption
+	case OptionConflict:
+		return ExitConflictingOptions
+	default:
+		return CodeConfigError
+	}
+}
+
+// invalidOption reports a value of option that does not parse or is out of range
+func invalidOption(option, format string, a ...any) error {
+	return &OptionError{Option: option, Problem: OptionInvalid, Message: fmt.Sprintf(format, a...)}
+}
+
+// missingOption reports that option must be set for the options given
+func missingOption(option, format string, a ...any) error {
+	return &OptionError{Option: option, Problem: OptionMissing, Message: fmt.Sprintf(format, a...)}
+}
+
+// conflictingOption reports that option cannot be combined with another one that is set
+func conflictingOption(option, format string, a ...any) error {
+	return &OptionError{Option: option, Problem: OptionConflict, Message: fmt.Sprintf(format, a...)}
+}
+
+// Validate checks option values that flag parsing alone cannot, returning every problem found
+// as an *OptionError, so cloudkey refuses to start instead of a collector failing later
+func (o CmdLineOpts) Validate() []error {
+	var errs []error
+
+	if o.Delay <= 0 {
+		errs = append(errs, invalidOption("delay", "delay must be positive, e.g. 7.5s, got %s", time.Duration(o.Delay)))
+	}
+	if _, err := parsePanelSize(o.PanelSize); err != nil {
+		errs = append(errs, invalidOption("panel-size", "%v", err))
+	}
+	if o.WarningDwell < 1 {
+		errs = append(errs, invalidOption("warning-dwell", "warning-dwell must be at least 1, got %v", o.WarningDwell))
+	}
+	for _, t := range []struct {
+		name    string
+		timeout time.Duration
+	}{
+		{"udm-detect-timeout", o.UDMDetectTimeout},
+		{"udm-login-timeout", o.UDMLoginTimeout},
+		{"udm-query-timeout", o.UDMQueryTimeout},
+	} {
+		if t.timeout <= 0 {
+			errs = append(errs, invalidOption(t.name, "%s must be positive, got %s", t.name, t.timeout))
+		}
+	}
+	if o.UDMLoginRetry < 0 {
+		errs = append(errs, invalidOption("udm-login-retry", "udm-login-retry must not be negative, got %s", o.UDMLoginRetry))
+	}
+	if !isHTTPURL(o.UDMBaseURL) {
+		errs = append(errs, invalidOption("udm-baseurl", "udm-baseurl must be an http:// or https:// URL such as https://192.168.1.1, got %q", o.UDMBaseURL))
+	}
+	if o.UDMUsername != "" || o.UDMPassword != "" {
+		switch {
+		case o.UDMUsername == "":
+			errs = append(errs, missingOption("udm-username", "udm-username is required with udm-password"))
+		case o.UDMPassword == "":
+			errs = append(errs, missingOption("udm-password", "udm-password is required with udm-username"))
+		}
+		if strings.TrimSpace(o.UDMSite) == "" {
+			errs = append(errs, missingOption("udm-site", "udm-site is required with the controller credentials, e.g. default"))
+		}
+	}
+	if o.UDMMaxRequests < 1 {
+		errs = append(errs, invalidOption("udm-max-requests", "udm-max-requests must be at least 1, got %d", o.UDMMaxRequests))
+	}
+	if o.HealthWindow < 1 {
+		errs = append(errs, invalidOption("health-window", "health-window must be at least 1 sample, got %d", o.HealthWindow))
+	}
+	if _, err := leds.SelectProfile(o.LEDProfile); err != nil {
+		errs = append(errs, invalidOption("led-profile", "led-profile must be auto or one of %s, got %q", strings.Join(leds.ProfileNames(), ", "), o.LEDProfile))
+	}
+	switch o.ActivityIndicator {
+	case "none", "led", "glyph", "both":
+	default:
+		errs = append(errs, invalidOption("activity-indicator", "activity-indicator must be none, led, glyph or both, got %q", o.ActivityIndicator))
+	}
+	switch o.WANIPMask {
+	case "none", "partial", "asn":
+	default:
+		errs = append(errs, invalidOption("wan-ip-mask", "wan-ip-mask must be none, partial or asn, got %q", o.WANIPMask))
+	}
+	for _, name := range o.WANIPProviders {
+		if _, ok := wanIPProviders[name]; !ok && name != "controller" {
+			errs = append(errs, invalidOption("wan-ip-providers", "wan-ip-providers must list ipify, icanhazip, cloudflare or controller, got %q", name))
+		}
+	}
+	if o.WANIPAgree < 1 || o.WANIPAgree > len(o.WANIPProviders) {
+		errs = append(errs, invalidOption("wan-ip-agree", "wan-ip-agree must be between 1 and the %d WAN IP providers, got %d", len(o.WANIPProviders), o.WANIPAgree))
+	}
+	if !o.ExternalLookups {
+		if o.WANEnrich {
+			errs = append(errs, conflictingOption("wan-enrich", "wan-enrich looks the provider up externally, unset it or enable external-lookups"))
+		}
+		if o.WANIPMask == "asn" {
+			errs = append(errs, conflictingOption("wan-ip-mask", "wan-ip-mask asn looks the provider up externally, use partial or enable external-lookups"))
+		}
+	}
+	for _, name := range o.Features {
+		switch _, ok := featureFlags[name]; {
+		case ok:
+		case len(featureFlags) == 0:
+			errs = append(errs, invalidOption("features", "features has no experimental features to turn on in this release, got %q", name))
+		default:
+			errs = append(errs, invalidOption("features", "features must list %s, got %q", strings.Join(FeatureNames(), " or "), name))
+		}
+	}
+	switch o.FreshnessIndicator {
+	case "none", "dot", "age":
+	default:
+		errs = append(errs, invalidOption("freshness-indicator", "freshness-indicator must be none, dot or age, got %q", o.FreshnessIndicator))
+	}
+	if o.LogoBrightness < 0 || o.LogoBrightness > 100 {
+		errs = append(errs, invalidOption("logo-brightness", "logo-brightness must be a percentage, got %d", o.LogoBrightness))
+	}
+	if _, err := parseQuietHours(o.BeeperQuietHours); err != nil {
+		errs = append(errs, invalidOption("beeper-quiet-hours", "beeper-quiet-hours: %v", err))
+	}
+	if o.K8sHelmDriver != "secret" && o.K8sHelmDriver != "configmap" {
+		errs = append(errs, invalidOption("k8s-helm-driver", "k8s-helm-driver must be secret or configmap, got %q", o.K8sHelmDriver))
+	}
+	switch o.LeaderElection {
+	case "none", "file":
+	case "lease":
+		if namespace, name, ok := strings.Cut(o.LeaderLease, "/"); !ok || namespace == "" || name == "" {
+			errs = append(errs, invalidOption("leader-lease", "leader-lease must be namespace/name, got %q", o.LeaderLease))
+		}
+	default:
+		errs = append(errs, invalidOption("leader-election", "leader-election must be none, lease or file, got %q", o.LeaderElection))
+	}
+	if o.AlertmanagerEnabled && !isHTTPURL(o.AlertmanagerURL) {
+		errs = append(errs, invalidOption("alertmanager-url", "alertmanager-url must be an http:// or https:// URL such as http://alertmanager:9093, got %q", o.AlertmanagerURL))
+	}
+	if o.TopProcessesSort != "memory" && o.TopProcessesSort != "cpu" {
+		errs = append(errs, invalidOption("top-processes-sort", "top-processes-sort must be memory or cpu, got %q", o.TopProcessesSort))
+	}
+	if o.AirtimeThreshold < 0 || o.AirtimeThreshold > 100 {
+		errs = append(errs, invalidOption("airtime-threshold", "airtime-threshold must be a percentage, got %d", o.AirtimeThreshold))
+	}
+	if o.Upstream != "" {
+		if !isHTTPURL(o.Upstream) {
+			errs = append(errs, invalidOption("upstream", "upstream must be an http:// or https:// URL, got %q", o.Upstream))
+		}
+		if o.UDMUsername != "" {
+			errs = append(errs, conflictingOption("udm-username", "upstream and udm-username cannot be combined: a mirror does not query the controller, unset udm-username"))
+		}
+		if o.Demo {
+			errs = append(errs, conflictingOption("demo", "upstream and demo cannot be combined: a mirror shows the upstream's screens, unset demo"))
+		}
+		if len(o.ScreenLayouts) > 0 {
+			errs = append(errs, conflictingOption("screen-layouts", "screen-layouts are set on the upstream, not on a mirror"))
+		}
+	}
+	if o.SpeedtestSchedule != "" {
+		if _, err := network.ParseSpeedtestSchedule(o.SpeedtestSchedule); err != nil {
+			errs = append(errs, invalidOption("speedtest-schedule", "speedtest-schedule: %v", err))
+		} else if !o.UDMWriteEnabled {
+			errs = append(errs, missingOption("udm-write-enabled", "speedtest-schedule needs udm-write-enabled to change the controller setting"))
+		}
+	}
+	if o.DHCPThreshold < 1 || o.DHCPThreshold > 100 {
+		errs = append(errs, invalidOption("dhcp-threshold", "dhcp-threshold must be a percentage between 1 and 100, got %d", o.DHCPThreshold))
+	}
+	if _, err := notify.ParseSeverity(o.NotifyMinSeverity); err != nil {
+		errs = append(errs, invalidOption("notify-min-severity", "notify-min-severity: %v", err))
+	}
+	for _, u := range []struct{ name, value string }{{"ntfy-url", o.NtfyURL}, {"gotify-url", o.GotifyURL}} {
+		if u.value != "" && !isHTTPURL(u.value) {
+			errs = append(errs, invalidOption(u.name, "%s must be an http:// or https:// URL, got %q", u.name, u.value))
+		}
+	}
+	if o.GotifyURL != "" && o.GotifyToken == "" {
+		errs = append(errs, missingOption("gotify-token", "gotify-token is required with gotify-url"))
+	}
+	if o.TelegramToken != "" && len(o.TelegramChats) == 0 {
+		errs = append(errs, missingOption("telegram-chats", "telegram-chats is required with telegram-token"))
+	}
+	for _, chat := range o.TelegramChats {
+		if _, err := strconv.ParseInt(chat, 10, 64); err != nil {
+			errs = append(errs, invalidOption("telegram-chats", "telegram-chats: %q is not a chat ID", chat))
+		}
+	}
+	if o.QuotaCap < 0 {
+		errs = append(errs, invalidOption("quota-cap", "quota-cap must not be negative, got %d", o.QuotaCap))
+	}
+	if o.QuotaResetDay < 1 || o.QuotaResetDay > 28 {
+		errs = append(errs, invalidOption("quota-reset-day", "quota-reset-day must be between 1 and 28, got %d", o.QuotaResetDay))
+	}
+	if err := validateConditionalScreens(o.ConditionalScreens); err != nil {
+		errs = append(errs, invalidOption("conditional-screens", "conditional-screens: %v", err))
+	}
+	if o.ConditionalLinger < 0 {
+		errs = append(errs, invalidOption("conditional-linger", "conditional-linger must not be negative, got %s", o.ConditionalLinger))
+	}
+	for _, mac := range o.NewDeviceAllowlist {
+		if !isMACPrefix(mac) {
+			errs = append(errs, invalidOption("new-device-allowlist", "new-device-allowlist: %q is not a MAC address or prefix", mac))
+		}
+	}
+	if o.SMTPHost != "" {
+		switch o.SMTPTLS {
+		case "starttls", "tls", "none":
+		default:
+			errs = append(errs, invalidOption("smtp-tls", "smtp-tls must be starttls, tls or none, got %q", o.SMTPTLS))
+		}
+		if o.SMTPFrom == "" || len(o.SMTPTo) == 0 {
+			errs = append(errs, missingOption("smtp-from", "smtp-from and smtp-to are required with smtp-host"))
+		}
+	}
+	if o.EmailDigest && o.SMTPHost == "" {
+		errs = append(errs, missingOption("smtp-host", "smtp-host is required with email-digest, which mails the digest"))
+	}
+	if _, err := parseClock(o.EmailDigestTime); err != nil {
+		errs = append(errs, invalidOption("email-digest-time", "email-digest-time: %v", err))
+	}
+	if _, err := time.LoadLocation(o.Timezone); err != nil {
+		errs = append(errs, invalidOption("timezone", "timezone: %v", err))
+	}
+	if err := chaos.Validate(o.ChaosRate, o.ChaosFaults); err != nil {
+		errs = append(errs, invalidOption("chaos-faults", "chaos: %v", err))
+	}
+	if err := ratelimit.Validate(o.OutboundRate, o.OutboundRates); err != nil {
+		errs = append(errs, invalidOption("outbound-rate", "outbound-rate: %v", err))
+	}
+
+	return errs
+}
+
+// isHTTPURL reports whether s is an absolute http:// or https:// URL
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// isMACPrefix reports whether s is a MAC address or its first octets, e.g. 3c:22:fb
+func isMACPrefix(s string) bool {
+	octets := strings.Split(s, ":")
+	if len(octets) > 6 {
+		return false
+	}
+	for _, octet := range octets {
+		if _, err := strconv.ParseUint(octet, 16, 8); err != nil || len(octet) != 2 {
+			return false
+		}
+	}
+	return true
+}
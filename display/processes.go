@@ -0,0 +1,139 @@
+package display
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"sort"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/process"
+
+	"cloudkey/images"
+)
+
+// procUsage is the resource usage of a single process
+type procUsage struct {
+	PID  int32
+	Name string
+	RSS  uint64  // resident memory in bytes
+	CPU  float64 // percent of one core since the previous poll
+}
+
+// procSampler turns cumulative process CPU times into usage between polls
+type procSampler struct {
+	prev     map[int32]float64 // pid -> user+system seconds
+	prevTime time.Time
+}
+
+// sample lists all processes with their memory and CPU usage
+func (s *procSampler) sample() ([]procUsage, error) {
+	procs, err := process.Processes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list processes: %v", err)
+	}
+
+	now := time.Now()
+	elapsed := now.Sub(s.prevTime).Seconds()
+	current := make(map[int32]float64, len(procs))
+
+	var usage []procUsage
+	for _, p := range procs {
+		// Processes can exit while being inspected
+		name, err := p.Name()
+		if err != nil {
+			continue
+		}
+		mem, err := p.MemoryInfo()
+		if err != nil {
+			continue
+		}
+
+		u := procUsage{PID: p.Pid, Name: name, RSS: mem.RSS}
+		if times, err := p.Times(); err == nil {
+			cpuTime := times.User + times.System
+			current[p.Pid] = cpuTime
+			if prev, ok := s.prev[p.Pid]; ok && elapsed > 0 {
+				u.CPU = (cpuTime - prev) / elapsed * 100
+			}
+		}
+		usage = append(usage, u)
+	}
+
+	s.prev = current
+	s.prevTime = now
+	return usage, nil
+}
+
+// topProcesses returns the n processes using the most memory, or the most CPU if byCPU is set
+func topProcesses(usage []procUsage, n int, byCPU bool) []procUsage {
+	sort.Slice(usage, func(a, b int) bool {
+		if byCPU {
+			return usage[a].CPU > usage[b].CPU
+		}
+		return usage[a].RSS > usage[b].RSS
+	})
+	return usage[:min(n, len(usage))]
+}
+
+// formatProcess fits a process onto one line of the screen
+func formatProcess(p procUsage, byCPU bool) string {
+	name := p.Name
+	if len(name) > 10 {
+		name = name[:10]
+	}
+	if byCPU {
+		return fmt.Sprintf("%s %.0f%% %s", name, p.CPU, formatMB(p.RSS))
+	}
+	return fmt.Sprintf("%s %s", name, formatMB(p.RSS))
+}
+
+func buildTopProcesses(i int, demo bool, opts CmdLineOpts) {
+	byCPU := opts.TopProcessesSort == "cpu"
+	screen := setupImage(i)
+
+	draw.Draw(screen, screen.Bounds(), image.Black, image.ZP, draw.Src)
+	drawIcon(screen, 0, images.Load("ram"))
+	drawIcon(screen, 1, images.Load("ram"))
+	drawIcon(screen, 2, images.Load("ram"))
+
+	if demo {
+		writeRow(screen, 0, "mongod 812.4MB")
+		writeRow(screen, 1, "java 604.1MB")
+		writeRow(screen, 2, "cloudkey 9.8MB")
+		return
+	}
+
+	run := pipelineContext(i)
+	go func() {
+		defer recoverCrash()
+		var sampler procSampler
+
+		for {
+			lines := make([]string, 3)
+
+			usage, err := sampler.sample()
+			if err != nil {
+				fmt.Printf("Error reading processes: %v\n", err)
+				lines[0] = "Processes"
+				lines[1] = "unavailable"
+				lines[2] = "check logs"
+			} else {
+				for n, p := range topProcesses(usage, len(lines), byCPU) {
+					lines[n] = formatProcess(p, byCPU)
+				}
+			}
+
+			redraw(i, func(screen draw.Image) {
+				clearText(screen)
+				writeRow(screen, 0, lines[0])
+				writeRow(screen, 1, lines[1])
+				writeRow(screen, 2, lines[2])
+			})
+
+			if !pause(run, refreshInterval("processes")) {
+				return
+			}
+		}
+	}()
+}
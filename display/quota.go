@@ -0,0 +1,167 @@
+package display
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"path/filepath"
+	"time"
+
+	"cloudkey/images"
+	"cloudkey/src/history"
+	"cloudkey/src/metrics"
+)
+
+const (
+	// quotaRetention is how long usage samples are kept, covering the previous cycle too
+	quotaRetention = 62 * 24 * time.Hour
+	// quotaSettle is how much of a cycle must pass before its usage is projected
+	quotaSettle = 24 * time.Hour
+)
+
+// formatGB formats a byte count in decimal gigabytes, the unit ISPs state data caps in
+func formatGB(b int64) string {
+	gb := float64(b) / 1e9
+	if gb < 10 {
+		return fmt.Sprintf("%.1f GB", gb)
+	}
+	return fmt.Sprintf("%.0f GB", gb)
+}
+
+// quotaLines formats the usage of the billing cycle against the cap, and its projection
+func quotaLines(samples []history.UsageSample, now time.Time, opts CmdLineOpts) (string, string, string, bool) {
+	start, end := history.BillingCycle(now, opts.QuotaResetDay)
+	used := history.Usage(samples, start, end)
+	today := history.Usage(samples, history.Today(now), end)
+	capBytes := int64(opts.QuotaCap) * 1e9
+
+	line1 := "Month: " + formatGB(used)
+	if capBytes > 0 {
+		line1 = fmt.Sprintf("Month: %.0f/%d GB", float64(used)/1e9, opts.QuotaCap)
+	}
+	line2 := "Today: " + formatGB(today)
+
+	// When cloudkey started during the cycle, the rate since then stands for the whole cycle
+	since := start
+	if len(samples) > 0 && time.UnixMilli(samples[0].Timestamp).After(since) {
+		since = time.UnixMilli(samples[0].Timestamp)
+	}
+	if now.Sub(since) < quotaSettle {
+		return line1, line2, "Projection tomorrow", false
+	}
+	projected := history.ProjectUsage(used, now.Sub(since), end.Sub(start))
+	line3 := "Proj. " + formatGB(projected)
+	over := capBytes > 0 && projected >= capBytes
+	if capBytes > 0 {
+		line3 += fmt.Sprintf("  %d%%", projected*100/capBytes)
+	}
+	if over {
+		line3 += " !"
+	}
+	return line1, line2, line3, over
+}
+
+func buildQuota(i int, demo bool, opts CmdLineOpts) {
+	screen := setupImage(i)
+
+	draw.Draw(screen, screen.Bounds(), image.Black, image.ZP, draw.Src)
+	drawIcon(screen, 0, images.Load("internet"))
+	drawIcon(screen, 1, images.Load("download"))
+	drawIcon(screen, 2, images.Load("clock"))
+
+	if demo {
+		writeRow(screen, 0, "Month: 412/1200 GB")
+		writeRow(screen, 1, "Today: 14.2 GB")
+		writeRow(screen, 2, "Proj. 1105 GB  92%")
+		return
+	}
+
+	store := history.Open[history.UsageSample](filepath.Join(opts.StateDir, "usage.jsonl"))
+
+	run := pipelineContext(i)
+	go func() {
+		defer recoverCrash()
+		loc := location(opts)
+		var pruned time.Time
+		warned := false
+
+		samples, err := store.All()
+		if err != nil {
+			fmt.Printf("Error reading WAN usage: %v\n", err)
+		}
+		var previous history.UsageSample
+		if len(samples) > 0 {
+			previous = samples[len(samples)-1]
+		}
+
+		for {
+			if time.Since(pruned) >= 24*time.Hour {
+				retention := time.Now().Add(-quotaRetention).UnixMilli()
+				if err := store.Prune(func(s history.UsageSample) bool { return s.Timestamp >= retention }); err != nil {
+					fmt.Printf("Error pruning WAN usage: %v\n", err)
+				}
+				pruned = time.Now()
+			}
+
+			line1, line2, line3 := "WAN usage", "unavailable", "check logs"
+
+			ctx, done := collect(run, "quota")
+			client, err := udmClient(ctx, opts)
+			var rx, tx int64
+			if err == nil {
+				rx, tx, err = client.GetWANTraffic(ctx)
+			}
+			done(err)
+			if run.Err() != nil {
+				return
+			}
+
+			if err != nil {
+				fmt.Printf("Error fetching WAN counters: %v\n", err)
+			} else {
+				activity("quota")
+				sample := history.NextUsage(previous, rx, tx, time.Now())
+				// Without an earlier reading, the counters hold traffic from before cloudkey started
+				if previous.Timestamp == 0 {
+					sample.RxBytes, sample.TxBytes = 0, 0
+				}
+				if err := store.Append(sample); err != nil {
+					fmt.Printf("Error saving WAN usage: %v\n", err)
+				}
+				previous = sample
+				samples = append(samples, sample)
+			}
+
+			now := time.Now().In(loc)
+			start, _ := history.BillingCycle(now, opts.QuotaResetDay)
+			retention := now.Add(-quotaRetention).UnixMilli()
+			for len(samples) > 0 && samples[0].Timestamp < retention {
+				samples = samples[1:]
+			}
+
+			if err == nil {
+				var over bool
+				line1, line2, line3, over = quotaLines(samples, now, opts)
+				metrics.Set("cloudkey_wan_usage_bytes", "WAN traffic of the current billing cycle.", float64(history.Usage(samples, start, now.Add(time.Second))), "site", opts.UDMSite)
+				setWarning("quota", over)
+				setRelevant("quota", over)
+				if over && !warned {
+					fmt.Printf("Data cap warning: %s\n", line3)
+					pushTicker("Data cap: " + line3)
+				}
+				warned = over
+			}
+
+			redraw(i, func(screen draw.Image) {
+				clearText(screen)
+				writeRow(screen, 0, line1)
+				writeRow(screen, 1, line2)
+				writeRow(screen, 2, line3)
+			})
+
+			if !pause(run, refreshInterval("quota")) {
+				return
+			}
+		}
+	}()
+}
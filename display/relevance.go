@@ -0,0 +1,88 @@
+package display
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// conditionalScreens are the screens that report whether they have something worth showing,
+// and so can leave the rotation while everything is fine
+var conditionalScreens = []string{
+	"network",      // the WAN IP lookup fails
+	"dualwan",      // on the backup WAN, or a WAN is down
+	"vpn",          // VPN sessions are active
+	"threats",      // threats were seen in the last 24h
+	"updates",      // controller or device updates are pending
+	"airtime",      // the busiest access point is busy
+	"dhcp",         // a DHCP pool is nearly full
+	"quota",        // the usage is projected over the data cap
+	"gitops",       // a GitOps resource is not synced
+	"kubernetes",   // nodes under pressure, a degraded API, failed Helm releases or expiring credentials
+	"k8smessages",  // cluster messages are active
+	"discovery",    // new LAN devices appeared since yesterday
+	"alertmanager", // alerts are firing
+	"ticker",       // a ticker entry was added
+}
+
+// relevanceState is whether a conditional screen is relevant, and until when it stays in the
+// rotation after it stopped being relevant
+type relevanceState struct {
+	active bool
+	until  time.Time
+}
+
+var (
+	hiddenWhenIdle  []string      // conditional screens enabled by -conditional-screens
+	relevanceLinger time.Duration // from -conditional-linger
+
+	relevanceMutex sync.Mutex
+	relevance      = map[string]relevanceState{}
+)
+
+// setRelevant records whether a screen has something worth showing. It stays in the rotation
+// for relevanceLinger after it stops, so an outage that just ended can still be read.
+func setRelevant(screen string, active bool) {
+	relevanceMutex.Lock()
+	defer relevanceMutex.Unlock()
+
+	state := relevance[screen]
+	if state.active && !active {
+		state.until = time.Now().Add(relevanceLinger)
+	}
+	state.active = active
+	relevance[screen] = state
+}
+
+// markRelevant keeps a screen in the rotation for relevanceLinger, for screens showing events
+// rather than a condition
+func markRelevant(screen string) {
+	setRelevant(screen, true)
+	setRelevant(screen, false)
+}
+
+// relevant reports whether a screen belongs in the rotation at now. Only the screens listed in
+// -conditional-screens are ever left out.
+func relevant(screen string, now time.Time) bool {
+	if !slices.Contains(hiddenWhenIdle, screen) {
+		return true
+	}
+
+	relevanceMutex.Lock()
+	defer relevanceMutex.Unlock()
+
+	state := relevance[screen]
+	return state.active || now.Before(state.until)
+}
+
+// validateConditionalScreens checks that every conditional screen can report its relevance
+func validateConditionalScreens(names []string) error {
+	for _, name := range names {
+		if !slices.Contains(conditionalScreens, name) {
+			return fmt.Errorf("%q cannot be conditional (one of %s)", name, strings.Join(conditionalScreens, ", "))
+		}
+	}
+	return nil
+}
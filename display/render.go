@@ -0,0 +1,145 @@
+package display
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/draw"
+	"time"
+
+	"github.com/llajas/cloudkey/src/panel"
+
+	"cloudkey/src/metrics"
+	"cloudkey/src/tracing"
+)
+
+// maxFPS caps how often the renderer pushes changes to the framebuffer
+const maxFPS = 4
+
+var (
+	// visible is the screen the renderer keeps up to date, -1 while the carousel is transitioning
+	visible = -1
+	// presented is a copy of what the renderer last put on the framebuffer
+	presented *image.RGBA
+)
+
+// minRedrawInterval is the shortest time between two redraws of a screen. Redraws asked for
+// sooner are coalesced, so a burst of updates costs one redraw instead of flicker and CPU spikes.
+const minRedrawInterval = time.Second
+
+// redraw runs fn on an off-screen copy of screen i and then swaps it in, see panel.Screen.Redraw.
+// Screens with a big layout always draw their reading instead.
+func redraw(i int, fn func(screen draw.Image)) {
+	s := screens[i]
+	if name, ok := screenLayouts[s.Name()]; ok {
+		fn = func(screen draw.Image) { bigDigits(name, reading(name))(screen) }
+	}
+
+	s.Redraw(func(screen draw.Image) {
+		_, span := tracing.Span(context.Background(), "render "+s.Name())
+		defer span.End()
+
+		fn(screen)
+	})
+}
+
+// redrawCoalesced counts redraws replaced before being drawn
+func redrawCoalesced(s *panel.Screen) {
+	metrics.Add("cloudkey_redraws_coalesced_total", "Screen redraws replaced by a later one before being drawn.", 1, "screen", s.Name())
+}
+
+// screenReady logs how long a screen took to get its first data
+func screenReady(s *panel.Screen) {
+	fmt.Printf("Screen %s ready after %s\n", s.Name(), time.Since(started).Round(time.Millisecond))
+}
+
+// showLoading puts a placeholder on every screen whose collector has not drawn anything yet.
+// Screens are built concurrently, so each replaces its placeholder as soon as its data arrives.
+func showLoading() {
+	for _, s := range screens {
+		s.Placeholder(func(img *image.RGBA) {
+			// Screens that drew static or demo text while being built are already complete
+			if isBlack(img.SubImage(scaled(panel.TextArea)).(*image.RGBA)) {
+				writeRow(img, 1, "Loading...")
+			}
+		})
+	}
+}
+
+// isBlack reports whether every pixel of img is black
+func isBlack(img *image.RGBA) bool {
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		row := img.Pix[img.PixOffset(bounds.Min.X, y):img.PixOffset(bounds.Max.X, y)]
+		for x := 0; x < len(row); x += 4 {
+			if row[x] != 0 || row[x+1] != 0 || row[x+2] != 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// frame runs fn with the current front buffer of screen i, which is not swapped until fn returns
+func frame(i int, fn func(img *image.RGBA)) {
+	screens[i].Frame(fn)
+}
+
+// startRenderer copies changes on the visible screen to the framebuffer, at most maxFPS times a second
+func startRenderer() {
+	presented = image.NewRGBA(fb.Bounds())
+
+	go func() {
+		defer recoverCrash()
+		ticker := time.NewTicker(time.Second / maxFPS)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			render()
+		}
+	}()
+}
+
+// render blits the region of the visible screen that changed since the last frame
+func render() {
+	fbMutex.Lock()
+	defer fbMutex.Unlock()
+
+	if visible < 0 {
+		return
+	}
+
+	frame(visible, func(img *image.RGBA) {
+		dirty := panel.DirtyRect(img, presented)
+		if dirty.Empty() {
+			return
+		}
+		draw.Draw(fb, dirty, img, dirty.Min, draw.Src)
+		draw.Draw(presented, dirty, img, dirty.Min, draw.Src)
+		if dirty.Overlaps(scaled(freshnessRect)) {
+			freshnessShown = ""
+		}
+	})
+	drawActivityGlyph()
+	drawFreshness()
+}
+
+// show puts screen i on the framebuffer and hands it to the renderer; -1 pauses the renderer
+// so the carousel can draw its transition
+func show(i int) {
+	fbMutex.Lock()
+	defer fbMutex.Unlock()
+
+	visible = i
+	if i < 0 {
+		return
+	}
+	lastScreen.Store(screens[i].Name())
+
+	frame(i, func(img *image.RGBA) {
+		draw.Draw(fb, fb.Bounds(), img, image.ZP, draw.Src)
+		draw.Draw(presented, presented.Bounds(), img, image.ZP, draw.Src)
+	})
+	activityGlyphUp = false
+	freshnessShown = ""
+}
@@ -0,0 +1,67 @@
+package display
+
+import (
+	"image"
+	"image/draw"
+	"testing"
+
+	"cloudkey/images"
+)
+
+// Run on the Cloud Key itself to see what a redraw costs there:
+//
+//	GOARCH=arm64 go test -c ./display && ./display.test -test.run '^$' -test.bench . -test.benchmem
+
+func BenchmarkWrite(b *testing.B) {
+	img := image.NewRGBA(panelBounds)
+	b.ReportAllocs()
+	for b.Loop() {
+		write(img, "203.0.113.32", 22, 21, 12, "lato-regular")
+	}
+}
+
+func BenchmarkIconBlit(b *testing.B) {
+	img := image.NewRGBA(panelBounds)
+	b.ReportAllocs()
+	for b.Loop() {
+		draw.Draw(img, image.Rect(2, 22, 2+16, 22+16), images.Load("network"), image.ZP, draw.Src)
+	}
+}
+
+// BenchmarkComposeScreen draws a whole three-line screen the way the collectors do
+func BenchmarkComposeScreen(b *testing.B) {
+	img := image.NewRGBA(panelBounds)
+	b.ReportAllocs()
+	for b.Loop() {
+		draw.Draw(img, img.Bounds(), image.Black, image.ZP, draw.Src)
+		draw.Draw(img, image.Rect(2, 2, 2+16, 2+16), images.Load("download"), image.ZP, draw.Src)
+		draw.Draw(img, image.Rect(2, 22, 2+16, 22+16), images.Load("upload"), image.ZP, draw.Src)
+		draw.Draw(img, image.Rect(2, 42, 2+16, 42+16), images.Load("clock"), image.ZP, draw.Src)
+		write(img, "1.2 Gb/s", 22, 1, 12, "lato-regular")
+		write(img, "43.9 Mb/s", 22, 21, 12, "lato-regular")
+		write(img, "25 minutes ago", 22, 41, 12, "lato-regular")
+	}
+}
+
+// BenchmarkRender measures the renderer finding and blitting a one-line change
+func BenchmarkRender(b *testing.B) {
+	fb = image.NewRGBA(panelBounds)
+	screens = nil
+	i := addScreen("bench")
+	write(screens[i].Image(), "25 minutes ago", 22, 41, 12, "lato-regular")
+	presented = image.NewRGBA(panelBounds)
+	show(i)
+
+	b.ReportAllocs()
+	for n := 0; b.Loop(); n++ {
+		frame(i, func(img *image.RGBA) {
+			draw.Draw(img, image.Rect(20, 40, 160, 60), image.Black, image.ZP, draw.Src)
+			if n%2 == 0 {
+				write(img, "26 minutes ago", 22, 41, 12, "lato-regular")
+			} else {
+				write(img, "25 minutes ago", 22, 41, 12, "lato-regular")
+			}
+		})
+		render()
+	}
+}
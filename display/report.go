@@ -0,0 +1,242 @@
+package display
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/llajas/cloudkey/src/panel/framebuffer"
+
+	"cloudkey/src/leds"
+)
+
+// IgnoredKey is an environment variable that has no effect, with the reason
+type IgnoredKey struct {
+	Key    string `json:"key"`
+	Reason string `json:"reason"`
+}
+
+// ConfigReport is the effective configuration, printed at startup and served by the API so a
+// support request can start from what cloudkey actually runs with. It holds no credentials.
+type ConfigReport struct {
+	Mode        string            `json:"mode"`  // display, headless, demo or mirror
+	Panel       string            `json:"panel"` // resolution the screens are drawn at
+	Screens     []string          `json:"screens"`
+	Features    []string          `json:"features"`   // experimental features turned on
+	Collectors  map[string]string `json:"collectors"` // background data sources and where they read from
+	Notifiers   []string          `json:"notifiers"`
+	LEDs        []string          `json:"leds"`
+	Controller  string            `json:"controller"`
+	IgnoredKeys []IgnoredKey      `json:"ignored_keys"`
+}
+
+var (
+	ignoredMutex sync.Mutex
+	ignoredKeys  []IgnoredKey
+)
+
+// SetIgnoredKeys records the environment variables found to have no effect, for the report
+func SetIgnoredKeys(keys []IgnoredKey) {
+	ignoredMutex.Lock()
+	defer ignoredMutex.Unlock()
+	ignoredKeys = keys
+}
+
+// Report describes the configuration in effect. The controller is detected in the background,
+// so it is only known in reports made after startup.
+func Report(opts CmdLineOpts) ConfigReport {
+	report := ConfigReport{
+		Mode:       "display",
+		Panel:      fmt.Sprintf("%dx%d", width, height),
+		Screens:    ScreenNames(),
+		Features:   append([]string{}, opts.Features...),
+		Collectors: collectors(opts),
+		Notifiers:  notifiers(opts),
+		LEDs:       []string{},
+		Controller: controllerStatus(opts),
+	}
+	switch {
+	case opts.Demo:
+		report.Mode = "demo"
+	case opts.Upstream != "":
+		report.Mode = "mirror"
+	case headless:
+		report.Mode = "headless"
+	}
+	if !headless {
+		report.LEDs = append(report.LEDs, leds.DiscoverLEDs()...)
+	}
+
+	ignoredMutex.Lock()
+	report.IgnoredKeys = append([]IgnoredKey{}, ignoredKeys...)
+	ignoredMutex.Unlock()
+	return report
+}
+
+// HardwareReport is what cloudkey finds of the panel and LEDs, for diagnostics
+type HardwareReport struct {
+	Framebuffer string   `json:"framebuffer"` // the device and its size, or why it cannot be used
+	Simulated   bool     `json:"simulated"`   // no fbdev or /sys/class/leds on this platform
+	Container   bool     `json:"container"`
+	LEDs        []string `json:"leds"`
+	GPIOLEDs    []string `json:"gpio_leds"`
+	LEDProfile  string   `json:"led_profile"`
+}
+
+// DetectHardware looks for the framebuffer and LEDs as Init would, without drawing on the
+// panel or changing an LED
+func DetectHardware(opts CmdLineOpts) HardwareReport {
+	leds.SetDir(opts.LEDsDir)
+	report := HardwareReport{
+		Simulated: simulated(),
+		Container: inContainer(),
+		LEDs:      append([]string{}, leds.DiscoverLEDs()...),
+		GPIOLEDs:  []string{},
+	}
+	for name, line := range opts.GPIOLEDs {
+		report.GPIOLEDs = append(report.GPIOLEDs, name+"="+line)
+	}
+	sort.Strings(report.GPIOLEDs)
+
+	if profile, err := leds.SelectProfile(opts.LEDProfile); err == nil {
+		report.LEDProfile = profile.Name
+	} else {
+		report.LEDProfile = err.Error()
+	}
+
+	switch fb, err := framebuffer.Open(opts.Framebuffer); {
+	case report.Simulated:
+		report.Framebuffer = "simulated"
+	case err != nil:
+		report.Framebuffer = fmt.Sprintf("%s: %v", opts.Framebuffer, err)
+	default:
+		report.Framebuffer = fmt.Sprintf("%s: %dx%d", opts.Framebuffer, fb.Bounds().Dx(), fb.Bounds().Dy())
+	}
+	return report
+}
+
+// collectors lists the data sources polled in the background besides the local system
+func collectors(opts CmdLineOpts) map[string]string {
+	found := map[string]string{}
+	if opts.Demo {
+		return found
+	}
+	if opts.Upstream != "" {
+		found["upstream"] = redactedURL(opts.Upstream)
+		return found
+	}
+
+	found["controller"] = fmt.Sprintf("%s, site %s", redactedURL(opts.UDMBaseURL), opts.UDMSite)
+	if opts.K8sEnabled || opts.GitOpsEnabled || opts.K8sMessagesEnabled {
+		found["kubernetes"] = opts.K8sKubeconfig
+		if found["kubernetes"] == "" {
+			found["kubernetes"] = "in-cluster"
+		}
+	}
+	if opts.AlertmanagerEnabled {
+		found["alertmanager"] = redactedURL(opts.AlertmanagerURL)
+	}
+	if opts.LatencyHeatmapEnabled {
+		found["ping"] = opts.PingTarget
+	}
+	if opts.DiscoveryEnabled {
+		found["discovery"] = "mDNS and SSDP"
+	}
+	if opts.PortWatchEnabled {
+		found["port-watch"] = "controller"
+	}
+	if opts.NewDeviceWatchEnabled {
+		found["new-device-watch"] = "controller"
+	}
+	if opts.SpeedtestSchedule != "" {
+		found["speedtest-schedule"] = opts.SpeedtestSchedule
+	}
+	if opts.TracingEndpoint != "" {
+		found["tracing"] = redactedURL(opts.TracingEndpoint)
+	}
+	if opts.LeaderElection != "" && opts.LeaderElection != "none" {
+		found["leader-election"] = opts.LeaderElection
+	}
+	return found
+}
+
+// notifiers lists where alerts are pushed
+func notifiers(opts CmdLineOpts) []string {
+	found := []string{}
+	if opts.NtfyURL != "" {
+		found = append(found, "ntfy")
+	}
+	if opts.GotifyURL != "" {
+		found = append(found, "gotify")
+	}
+	if opts.TelegramToken != "" {
+		found = append(found, "telegram")
+	}
+	if opts.SMTPHost != "" {
+		found = append(found, "email")
+	}
+	if opts.BeeperPath != "" {
+		found = append(found, "beeper")
+	}
+	return found
+}
+
+// controllerStatus describes the controller type as detected so far
+func controllerStatus(opts CmdLineOpts) string {
+	if opts.Demo || opts.Upstream != "" {
+		return "not used"
+	}
+
+	udmMutex.Lock()
+	client := udm
+	udmMutex.Unlock()
+
+	switch {
+	case client == nil:
+		return "not contacted yet"
+	case !client.Detected():
+		return "not reachable yet"
+	default:
+		version, caps := client.Controller()
+		return fmt.Sprintf("%s (version %s)", caps.Family, version)
+	}
+}
+
+// printReport logs the configuration report at startup
+func printReport(opts CmdLineOpts) {
+	r := Report(opts)
+
+	list := func(items []string) string {
+		if len(items) == 0 {
+			return "none"
+		}
+		return strings.Join(items, ", ")
+	}
+	var sources []string
+	for name, from := range r.Collectors {
+		sources = append(sources, fmt.Sprintf("%s (%s)", name, from))
+	}
+	sort.Strings(sources)
+
+	fmt.Println("Configuration report:")
+	fmt.Printf("  Mode: %s, %s panel\n", r.Mode, r.Panel)
+	fmt.Printf("  Screens: %s\n", list(r.Screens))
+	fmt.Printf("  Features: %s\n", list(r.Features))
+	fmt.Printf("  Collectors: %s\n", list(sources))
+	fmt.Printf("  Notifiers: %s\n", list(r.Notifiers))
+	fmt.Printf("  LEDs: %s\n", list(r.LEDs))
+	fmt.Printf("  Controller: %s\n", r.Controller)
+	for _, k := range r.IgnoredKeys {
+		fmt.Printf("  Ignored %s: %s\n", k.Key, k.Reason)
+	}
+}
+
+// redactedURL hides the password of a URL, so it can be reported
+func redactedURL(s string) string {
+	if u, err := url.Parse(s); err == nil {
+		return u.Redacted()
+	}
+	return s
+}
@@ -0,0 +1,26 @@
+//go:build !windows
+
+package display
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// reexec restarts cloudkey by executing its binary again in the running process (execve),
+// keeping the PID so systemd and the pidfile, which the new image keeps as it is, stay valid. It is a full restart, not one of the
+// display loop: all state is lost and everything is set up again from the options. It only
+// returns if the exec failed.
+func reexec(reason string) {
+	executable, err := os.Executable()
+	if err != nil {
+		fmt.Printf("Re-exec failed: %v\n", err)
+		return
+	}
+
+	fmt.Printf("Restarting (%s)\n", reason)
+	if err := syscall.Exec(executable, os.Args, os.Environ()); err != nil {
+		fmt.Printf("Re-exec failed: %v\n", err)
+	}
+}
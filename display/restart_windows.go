@@ -0,0 +1,13 @@
+package display
+
+import (
+	"fmt"
+	"os"
+)
+
+// reexec exits and leaves the restart to the service manager, since Windows cannot
+// replace a running process
+func reexec(reason string) {
+	fmt.Printf("Exiting to be restarted (%s)\n", reason)
+	os.Exit(1)
+}
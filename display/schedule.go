@@ -0,0 +1,216 @@
+package display
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/llajas/cloudkey/src/panel"
+)
+
+// cronField is the set of values one field of a cron expression matches
+type cronField map[int]bool
+
+// cronExpr is a five field cron expression: minute, hour, day of month, month and day of week
+type cronExpr struct {
+	source                        string
+	minute, hour, dom, month, dow cronField
+	domRestricted, dowRestricted  bool
+}
+
+// cronBounds are the allowed values of each field; a day of week of 7 is also Sunday
+var cronBounds = [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+
+// parseCron parses an expression made of *, values, ranges (9-17), steps (*/15, 0-30/10) and lists of them
+func parseCron(expr string) (cronExpr, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return cronExpr{}, fmt.Errorf("cron expression %q: expected 5 fields (minute hour day month weekday)", expr)
+	}
+
+	var parsed [5]cronField
+	for n, field := range fields {
+		set, err := parseCronField(field, cronBounds[n][0], cronBounds[n][1])
+		if err != nil {
+			return cronExpr{}, fmt.Errorf("cron expression %q: %v", expr, err)
+		}
+		parsed[n] = set
+	}
+	if parsed[4][7] {
+		parsed[4][0] = true
+	}
+
+	return cronExpr{
+		source: strings.Join(fields, " "),
+		minute: parsed[0], hour: parsed[1], dom: parsed[2], month: parsed[3], dow: parsed[4],
+		domRestricted: fields[2] != "*",
+		dowRestricted: fields[4] != "*",
+	}, nil
+}
+
+// parseCronField returns the values between min and max that a single field matches
+func parseCronField(field string, min, max int) (cronField, error) {
+	set := cronField{}
+	for _, part := range strings.Split(field, ",") {
+		spec, step := part, 1
+		if s, n, ok := strings.Cut(part, "/"); ok {
+			var err error
+			if step, err = strconv.Atoi(n); err != nil || step < 1 {
+				return nil, fmt.Errorf("invalid step in %q", part)
+			}
+			spec = s
+		}
+
+		lo, hi := min, max
+		if spec != "*" {
+			from, to, isRange := strings.Cut(spec, "-")
+			var err error
+			if lo, err = strconv.Atoi(from); err != nil {
+				return nil, fmt.Errorf("invalid value in %q", part)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(to); err != nil {
+					return nil, fmt.Errorf("invalid range in %q", part)
+				}
+			} else if step > 1 {
+				// 5/15 means from 5 to the end in steps of 15
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return nil, fmt.Errorf("%q is outside %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			set[v] = true
+		}
+	}
+	return set, nil
+}
+
+// matches reports whether the minute of t is matched. Like cron, a day matches if either the day
+// of month or the day of week does when both are restricted.
+func (c cronExpr) matches(t time.Time) bool {
+	if !c.minute[t.Minute()] || !c.hour[t.Hour()] || !c.month[int(t.Month())] {
+		return false
+	}
+	dom, dow := c.dom[t.Day()], c.dow[int(t.Weekday())]
+	if c.domRestricted && c.dowRestricted {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+// ScreenWindow shows some screens only while a cron expression matches the current minute
+type ScreenWindow struct {
+	Screens []string
+	When    cronExpr
+}
+
+// ScreenSchedule is a semicolon-separated list of screens=cron windows, usable with flag.Var.
+// Screens without a window are always shown.
+type ScreenSchedule []ScreenWindow
+
+// String joins the windows back into their flag form
+func (s *ScreenSchedule) String() string {
+	var parts []string
+	for _, w := range *s {
+		parts = append(parts, strings.Join(w.Screens, ",")+"="+w.When.source)
+	}
+	return strings.Join(parts, ";")
+}
+
+// Set replaces the schedule, e.g. "kubernetes,diagnostics=* 9-17 * * 1-5;speedsummary=* 6-9 * * *"
+func (s *ScreenSchedule) Set(value string) error {
+	var schedule ScreenSchedule
+	for _, item := range strings.Split(value, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		names, expr, ok := strings.Cut(item, "=")
+		if !ok {
+			return fmt.Errorf("screen schedule %q: expected screens=cron", item)
+		}
+		when, err := parseCron(expr)
+		if err != nil {
+			return fmt.Errorf("screen schedule %q: %v", item, err)
+		}
+		var window ScreenWindow
+		for _, name := range strings.Split(names, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				window.Screens = append(window.Screens, name)
+			}
+		}
+		if len(window.Screens) == 0 {
+			return fmt.Errorf("screen schedule %q: no screens", item)
+		}
+		window.When = when
+		schedule = append(schedule, window)
+	}
+
+	*s = schedule
+	return nil
+}
+
+// active reports whether the named screen should be in the rotation at t
+func (s ScreenSchedule) active(name string, t time.Time) bool {
+	scheduled := false
+	for _, w := range s {
+		if !slices.Contains(w.Screens, name) {
+			continue
+		}
+		if w.When.matches(t) {
+			return true
+		}
+		scheduled = true
+	}
+	return !scheduled
+}
+
+var (
+	screenSchedule ScreenSchedule // from -screen-schedule
+	scheduleZone   = time.Local   // timezone the schedule is read in
+)
+
+// nextScreen returns the screen the carousel shows after current, skipping the screens that
+// are out of their window or have nothing to report. When no screen qualifies, the rotation
+// carries on as usual.
+func nextScreen(current int, now time.Time) int {
+	now = now.In(scheduleZone)
+	for step := 1; step <= len(screens); step++ {
+		i := (current + step) % len(screens)
+		if screenSchedule.active(screens[i].Name(), now) && relevant(screens[i].Name(), now) {
+			return i
+		}
+	}
+	return (current + 1) % len(screens)
+}
+
+// checkScreenSchedule logs windows, weights and layouts naming screens that are not enabled,
+// which are likely typos
+func checkScreenSchedule() {
+	enabled := func(name string) bool {
+		return slices.ContainsFunc(screens, func(s *panel.Screen) bool { return s.Name() == name })
+	}
+	for _, w := range screenSchedule {
+		for _, name := range w.Screens {
+			if !enabled(name) {
+				fmt.Printf("Screen schedule: no screen named %q\n", name)
+			}
+		}
+	}
+	for name := range screenWeights {
+		if !enabled(name) {
+			fmt.Printf("Screen weights: no screen named %q\n", name)
+		}
+	}
+	for name := range screenLayouts {
+		if !enabled(name) {
+			fmt.Printf("Screen layouts: no screen named %q\n", name)
+		}
+	}
+}
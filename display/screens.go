@@ -2,52 +2,170 @@ package display
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"image"
 	"image/draw"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/llajas/cloudkey/src/network"
 	"github.com/shirou/gopsutil/v4/mem"
-
-	linuxproc "github.com/c9s/goprocinfo/linux"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 
 	"cloudkey/images"
+	"cloudkey/src/cpu"
+	"cloudkey/src/events"
+	"cloudkey/src/history"
 	"cloudkey/src/kubernetes"
-	"cloudkey/src/network"
+	"cloudkey/src/metrics"
+	"cloudkey/src/pressure"
 )
 
-func buildNetwork(i int, demo bool) {
-	screen := screens[i]
+func buildNetwork(i int, demo bool, opts CmdLineOpts) {
+	screen := setupImage(i)
 	hostname := "Simons cloudkey"
 	lan := "192.168.11.13"
 	wan := "203.0.113.32"
 
 	draw.Draw(screen, screen.Bounds(), image.Black, image.ZP, draw.Src)
-	draw.Draw(screen, image.Rect(2, 2, 2+16, 2+16), images.Load("host"), image.ZP, draw.Src)
-	draw.Draw(screen, image.Rect(2, 22, 2+16, 22+16), images.Load("network"), image.ZP, draw.Src)
-	draw.Draw(screen, image.Rect(2, 42, 2+16, 42+16), images.Load("internet"), image.ZP, draw.Src)
+	drawIcon(screen, 0, images.Load("host"))
+	drawIcon(screen, 1, images.Load("network"))
+	drawIcon(screen, 2, images.Load("internet"))
+
+	var provider []string
+	var badge string // on the internet icon
+	if demo {
+		if opts.WANEnrich {
+			provider = []string{"Comcast (AS7922)", "AS7922"}
+		}
+		wan = wanLabel(opts.WANIPMask, wan)
+	}
+
+	var chain *network.WANIPChain
+	if !demo {
+		chain = wanIPChain(opts)
+	}
+	run := pipelineContext(i)
+
+	// Refresh as soon as an address changes, e.g. on a DHCP renewal, and on a timer for
+	// platforms that cannot watch
+	var changes <-chan struct{}
+	if !demo {
+		var err error
+		changes, err = network.WatchAddresses(run)
+		if err != nil {
+			fmt.Printf("Not watching address changes (%v), refreshing the network screen every %s\n", err, refreshInterval("network"))
+		}
+	}
 
-	// Loop Every Hour
 	go func() {
+		defer recoverCrash()
 		for {
 			if !demo {
 				hostname, _ = os.Hostname()
-			}
-			write(screen, hostname, 22, 1, 12, "lato-regular")
-
-			if !demo {
 				lan, _ = network.LANIP()
-			}
-			write(screen, lan, 22, 21, 12, "lato-regular")
 
-			if !demo {
-				wan, _ = network.WANIP()
+				var err error
+				var disagreement *network.WANIPDisagreement
+				status := network.WANUnknown
+				badge = ""
+				if chain == nil {
+					wan, err = "lookups off", nil
+				} else {
+					ctx, cancel := context.WithTimeout(run, time.Minute)
+					wan, err = chain.WANIP(ctx)
+					cancel()
+					// Probe on every refresh, since a cached address hides a dead WAN
+					status = network.CheckWAN(opts.OutageProbes, outageProbeTimeout)
+				}
+				if run.Err() != nil {
+					return
+				}
+				if errors.As(err, &disagreement) {
+					// The address is known, just not for sure: show the first answer
+					fmt.Printf("WAN IP lookup: %v\n", err)
+					badge = "warning"
+					setRelevant("network", true)
+					wan = wanLabel(opts.WANIPMask, disagreement.Answers[0].IP)
+					provider = nil
+				} else if err != nil {
+					badge = "warning"
+					// The probes tell a dead WAN apart from the lookup service being down
+					fmt.Printf("WAN IP lookup failed (%v), probes report: %s\n", err, status)
+					events.Publish("outage", map[string]string{
+						"check":  "wan_ip",
+						"status": status.String(),
+						"error":  err.Error(),
+					})
+					setRelevant("network", true)
+					switch status {
+					case network.WANDown:
+						wan = "WAN down"
+					case network.WANUp:
+						wan = "IP lookup down"
+					default:
+						wan = "WAN unknown"
+					}
+				} else if status == network.WANDown {
+					badge = "warning"
+					fmt.Printf("WAN IP lookup answered but no probe host did, WAN down\n")
+					events.Publish("outage", map[string]string{
+						"check":  "probes",
+						"status": status.String(),
+						"error":  "no probe host answered",
+					})
+					setRelevant("network", true)
+					wan = "WAN down"
+					provider = nil
+				} else if chain != nil {
+					activity("network")
+					setRelevant("network", false)
+					provider = nil
+					if opts.WANEnrich && opts.WANIPMask != "asn" {
+						provider = wanProvider(wan)
+					}
+					wan = wanLabel(opts.WANIPMask, wan)
+				}
 			}
-			write(screen, wan, 22, 41, 12, "lato-regular")
 
-			time.Sleep(59 * time.Minute)
+			redraw(i, func(screen draw.Image) {
+				drawIcon(screen, 2, images.Badged("internet", badge))
+				clearText(screen)
+				writeRow(screen, 0, hostname)
+				writeRow(screen, 1, lan)
+				writeWAN(screen, wan, provider)
+			})
+
+			timer := time.NewTimer(refreshInterval("network"))
+			select {
+			case _, ok := <-changes:
+				if !ok {
+					// The watch ends with the run when the watchdog restarts the screen
+					if run.Err() != nil {
+						timer.Stop()
+						return
+					}
+					fmt.Printf("Stopped watching address changes, refreshing the network screen every %s\n", refreshInterval("network"))
+					changes = nil
+					break
+				}
+				// A renewal or a flap comes as a burst of changes, and the new address
+				// needs a moment before the WAN can be reached through it
+				time.Sleep(addressSettle)
+				select {
+				case <-changes:
+				default:
+				}
+				network.ExpireWANIP()
+			case <-timer.C:
+			case <-run.Done():
+				timer.Stop()
+				return
+			}
+			timer.Stop()
 		}
 	}()
 }
@@ -57,27 +175,53 @@ func buildSpeedTest(i int, demo bool, opts CmdLineOpts) {
 	umsg := "fetching..."
 	tmsg := "from UDM Pro"
 
-	screen := screens[i]
+	screen := setupImage(i)
 
 	draw.Draw(screen, screen.Bounds(), image.Black, image.ZP, draw.Src)
-	draw.Draw(screen, image.Rect(2, 2, 2+16, 2+16), images.Load("download"), image.ZP, draw.Src)
-	draw.Draw(screen, image.Rect(2, 22, 2+16, 22+16), images.Load("upload"), image.ZP, draw.Src)
-	draw.Draw(screen, image.Rect(2, 42, 2+16, 42+16), images.Load("clock"), image.ZP, draw.Src)
+	drawIcon(screen, 0, images.Load("download"))
+	drawIcon(screen, 1, images.Load("upload"))
+	drawIcon(screen, 2, images.Load("clock"))
 
 	if demo {
 		dmsg = "1.2 Gb/s" // Show Gbps example in demo
 		umsg = "43.9 Mb/s"
 		tmsg = "25 minutes ago"
-		write(screen, dmsg, 22, 1, 12, "lato-regular")
-		write(screen, umsg, 22, 21, 12, "lato-regular")
-		write(screen, tmsg, 22, 41, 12, "lato-regular")
+		writeRow(screen, 0, dmsg)
+		writeRow(screen, 1, umsg)
+		writeRow(screen, 2, tmsg)
 	} else {
 		// Smart speedtest fetching - check for new results every 5 minutes
+		run := pipelineContext(i)
 		go func() {
+			defer recoverCrash()
 			var lastResult *network.SpeedtestResult
 			var lastFetchTime time.Time
 			var lastKnownTimestamp int64
 			var hasErrorState bool // Track if we're in an error state
+			var degradedRuns int   // Consecutive results significantly below the baseline
+
+			store := history.Open[network.SpeedtestResult](filepath.Join(opts.StateDir, "speedtests.jsonl"))
+			retention := time.Now().Add(-historyRetention).UnixMilli()
+			if err := store.Prune(func(r network.SpeedtestResult) bool { return r.Timestamp >= retention }); err != nil {
+				fmt.Printf("Error pruning speedtest history: %v\n", err)
+			}
+			past, err := store.All()
+			if err != nil {
+				fmt.Printf("Error reading speedtest history: %v\n", err)
+			}
+			var lastStoredTimestamp int64
+			for _, r := range past {
+				lastStoredTimestamp = max(lastStoredTimestamp, r.Timestamp)
+			}
+			baseline := history.SpeedtestBaseline(past, time.Now(), baselineWindow)
+			if !baseline.Valid() {
+				// Without enough local history, seed the baseline from the controller archive
+				past = backfillSpeedtests(opts, store, past)
+				for _, r := range past {
+					lastStoredTimestamp = max(lastStoredTimestamp, r.Timestamp)
+				}
+				baseline = history.SpeedtestBaseline(past, time.Now(), baselineWindow)
+			}
 
 			// Initial fetch immediately at startup
 			fmt.Println("Fetching initial speedtest data immediately...")
@@ -85,30 +229,45 @@ func buildSpeedTest(i int, demo bool, opts CmdLineOpts) {
 			for {
 				now := time.Now()
 
-				// Always check every 5 minutes, but respect minimum interval
+				// Check at the refresh interval, but respect minimum interval
 				shouldFetch := false
 
 				if lastResult == nil {
 					shouldFetch = true
 					fmt.Println("No cached speedtest data - fetching initial data")
-				} else if time.Since(lastFetchTime) >= 5*time.Minute {
+				} else if time.Since(lastFetchTime) >= refreshInterval("speedtest") {
 					shouldFetch = true
-					fmt.Printf("5 minutes elapsed - checking for new speedtest results\n")
+					fmt.Printf("%s elapsed - checking for new speedtest results\n", refreshInterval("speedtest"))
 				}
 
 				if shouldFetch {
-					result, err := network.GetUDMProSpeedtest(
-						opts.UDMBaseURL,
-						opts.UDMUsername,
-						opts.UDMPassword,
-						opts.UDMSite,
-						opts.UDMVersion,
-					)
+					// Query the last 24 hours through the shared client, bypassing its result cache
+					ctx, done := collect(run, "speedtest")
+					client, err := udmClient(ctx, opts)
+					var result *network.SpeedtestResult
+					if err == nil {
+						end := time.Now().UnixMilli()
+						result, err = client.GetSpeedtestResultsInRange(ctx, end-24*60*60*1000, end)
+					}
+					done(err)
+					if run.Err() != nil {
+						return
+					}
 					if err != nil {
 						fmt.Printf("Error fetching UDM Pro speedtest: %v\n", err)
+						err = udmFailure(ctx, err)
 						hasErrorState = true
-						SetUDMError(true)
-						if strings.Contains(err.Error(), "timeout") || strings.Contains(err.Error(), "cannot reach") {
+						updating := udmUpdating()
+						SetUDMError(!updating)
+						if updating {
+							dmsg = "controller"
+							umsg = "updating..."
+							tmsg = "back shortly"
+						} else if strings.Contains(err.Error(), "is restarting") {
+							dmsg = "controller down"
+							umsg = "app not running"
+							tmsg = "check UniFi OS"
+						} else if strings.Contains(err.Error(), "timeout") || strings.Contains(err.Error(), "cannot reach") {
 							dmsg = "network error"
 							umsg = "check UDM IP"
 							tmsg = "verify connectivity"
@@ -116,6 +275,22 @@ func buildSpeedTest(i int, demo bool, opts CmdLineOpts) {
 							dmsg = "UDM offline"
 							umsg = "check device"
 							tmsg = "verify running"
+						} else if strings.Contains(err.Error(), "not reachable at") {
+							dmsg = "UDM offline"
+							umsg = "no answer"
+							tmsg = "retrying"
+						} else if strings.Contains(err.Error(), "cloud-only") {
+							dmsg = "auth error"
+							umsg = "cloud account"
+							tmsg = "use a local account"
+						} else if strings.Contains(err.Error(), "lacks read access") {
+							dmsg = "auth error"
+							umsg = "no access to site"
+							tmsg = opts.UDMSite
+						} else if strings.Contains(err.Error(), "does not exist on the controller") {
+							dmsg = "unknown site"
+							umsg = opts.UDMSite
+							tmsg = "check UDM site"
 						} else if strings.Contains(err.Error(), "login failed") || strings.Contains(err.Error(), "403") {
 							dmsg = "auth error"
 							umsg = "403 forbidden"
@@ -130,6 +305,7 @@ func buildSpeedTest(i int, demo bool, opts CmdLineOpts) {
 							tmsg = "see UDM_SETUP"
 						}
 					} else {
+						activity("speedtest")
 						hasErrorState = false
 						SetUDMError(false)
 						isNewer := lastKnownTimestamp == 0 || result.Timestamp > lastKnownTimestamp
@@ -138,6 +314,32 @@ func buildSpeedTest(i int, demo bool, opts CmdLineOpts) {
 							fmt.Printf("Found newer speedtest data (timestamp: %d)\n", result.Timestamp)
 							lastResult = result
 							lastKnownTimestamp = result.Timestamp
+							events.Publish("speedtest", result)
+
+							if result.Timestamp > lastStoredTimestamp {
+								// Compare against the baseline before this result joins it
+								baseline = history.SpeedtestBaseline(past, time.Now(), baselineWindow)
+								if baseline.Degraded(*result) {
+									degradedRuns++
+								} else {
+									degradedRuns = 0
+								}
+								if degradedRuns == 2 {
+									fmt.Printf("Speedtest degraded: %.1f/%.1f Mb/s vs usual %.1f/%.1f Mb/s\n",
+										result.DownloadMbps, result.UploadMbps, baseline.Download, baseline.Upload)
+									events.Publish("speedtest_degraded", map[string]any{
+										"result":   result,
+										"download": baseline.Download,
+										"upload":   baseline.Upload,
+									})
+								}
+
+								if err := store.Append(*result); err != nil {
+									fmt.Printf("Error saving speedtest history: %v\n", err)
+								}
+								past = append(past, *result)
+								lastStoredTimestamp = result.Timestamp
+							}
 							fmt.Printf("UDM Pro Speedtest - Download: %.1f Mb/s, Upload: %.1f Mb/s, Latency: %.1f ms\n",
 								result.DownloadMbps, result.UploadMbps, result.LatencyMs)
 						} else {
@@ -145,9 +347,10 @@ func buildSpeedTest(i int, demo bool, opts CmdLineOpts) {
 						}
 
 						// ALWAYS update display messages on successful response (clears any error state)
-						dmsg = network.FormatSpeed(result.DownloadMbps)
-						umsg = network.FormatSpeed(result.UploadMbps)
-						tmsg = network.GetRelativeTime(result.Timestamp)
+						dmsg, umsg, tmsg = speedtestLines(result, baseline)
+						setReading("download", network.FormatSpeed(result.DownloadMbps))
+						setReading("upload", network.FormatSpeed(result.UploadMbps))
+						setReading("latency", fmt.Sprintf("%.0f ms", result.LatencyMs))
 
 						// Always update fetch time regardless of whether data is new
 						lastFetchTime = now
@@ -155,16 +358,12 @@ func buildSpeedTest(i int, demo bool, opts CmdLineOpts) {
 				} else {
 					// Use cached data - but only if we're not in an error state
 					if lastResult != nil && !hasErrorState {
-						dmsg = network.FormatSpeed(lastResult.DownloadMbps)
-						umsg = network.FormatSpeed(lastResult.UploadMbps)
-						tmsg = network.GetRelativeTime(lastResult.Timestamp)
+						dmsg, umsg, tmsg = speedtestLines(lastResult, baseline)
 					} else if lastResult != nil && hasErrorState {
 						// We have cached data but were in error state - clear error and use cached data
 						fmt.Printf("Clearing error state and using cached speedtest data\n")
 						hasErrorState = false
-						dmsg = network.FormatSpeed(lastResult.DownloadMbps)
-						umsg = network.FormatSpeed(lastResult.UploadMbps)
-						tmsg = network.GetRelativeTime(lastResult.Timestamp)
+						dmsg, umsg, tmsg = speedtestLines(lastResult, baseline)
 					} else {
 						// No data yet, show waiting message
 						cst := now.Add(-6 * time.Hour)
@@ -180,121 +379,245 @@ func buildSpeedTest(i int, demo bool, opts CmdLineOpts) {
 					}
 				}
 
-				// Clear and redraw the screen
-				draw.Draw(screen, image.Rect(20, 0, 160, 60), image.Black, image.ZP, draw.Src)
-				write(screen, dmsg, 22, 1, 12, "lato-regular")
-				write(screen, umsg, 22, 21, 12, "lato-regular")
-				write(screen, tmsg, 22, 41, 12, "lato-regular")
+				// Arrows on the icons when the speed is well off the usual
+				var downBadge, upBadge string
+				if lastResult != nil && !hasErrorState && baseline.Valid() {
+					downBadge = deltaBadge(baseline.DownloadDelta(lastResult.DownloadMbps))
+					upBadge = deltaBadge(baseline.UploadDelta(lastResult.UploadMbps))
+				}
 
-				// Check for updates every 5 minutes
-				time.Sleep(5 * time.Minute)
+				// Clear and redraw the screen
+				redraw(i, func(screen draw.Image) {
+					drawIcon(screen, 0, images.Badged("download", downBadge))
+					drawIcon(screen, 1, images.Badged("upload", upBadge))
+					clearText(screen)
+					writeRow(screen, 0, dmsg)
+					writeRow(screen, 1, umsg)
+					writeRow(screen, 2, tmsg)
+				})
+
+				// Check for updates at the refresh interval, or as soon as an unreachable controller
+				// comes up. A controller updating is checked on more often, to show results again soon after.
+				wait := refreshInterval("speedtest")
+				if hasErrorState && udmUpdating() {
+					wait = udmProbeInterval
+				}
+				select {
+				case <-time.After(wait):
+				case <-udmRecovered():
+				case <-run.Done():
+					return
+				}
 			}
 		}()
 	}
 }
 
-func buildCPUStats(i int, demo bool) {
-	screen := screens[i]
+const (
+	// baselineWindow is how far back speedtest results count towards the usual speed
+	baselineWindow = 30 * 24 * time.Hour
+	// historyRetention is how long speedtest results are kept in the state directory
+	historyRetention = 90 * 24 * time.Hour
+	// outageProbeTimeout is how long an outage probe may take to connect
+	outageProbeTimeout = 5 * time.Second
+	// addressSettle is how long the network screen waits after an address changes before
+	// reading the addresses again
+	addressSettle = 2 * time.Second
+)
 
-	go func() {
-		var prevActive, prevTotal uint64
-		first := true
+// backfillSpeedtests adds the results of the last baselineWindow from the controller archive
+// that are missing from the local history, fetching them a chunk at a time, and returns the
+// updated history
+func backfillSpeedtests(opts CmdLineOpts, store *history.Store[network.SpeedtestResult], past []network.SpeedtestResult) []network.SpeedtestResult {
+	ctx, done := collect(context.Background(), "speedtest backfill")
+	client, err := udmClient(ctx, opts)
+	defer func() { done(err) }()
+	if err != nil {
+		fmt.Printf("Skipping speedtest backfill: %v\n", err)
+		return past
+	}
 
-		for {
-			stat, err := linuxproc.ReadStat("/proc/stat")
-			if err != nil {
-				time.Sleep(5 * time.Second)
+	known := make(map[int64]bool, len(past))
+	for _, r := range past {
+		known[r.Timestamp] = true
+	}
+
+	added := 0
+	end := time.Now()
+	err = client.WalkSpeedtestArchive(ctx, end.Add(-baselineWindow).UnixMilli(), end.UnixMilli(), func(results []network.SpeedtestResult) error {
+		for _, r := range results {
+			if known[r.Timestamp] {
 				continue
 			}
+			if err := store.Append(r); err != nil {
+				return fmt.Errorf("failed to save speedtest history: %v", err)
+			}
+			past = append(past, r)
+			known[r.Timestamp] = true
+			added++
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Printf("Error backfilling speedtest history: %v\n", err)
+	}
+	fmt.Printf("Backfilled %d speedtest results from the controller archive\n", added)
+	return past
+}
 
-			var currActive, currTotal uint64
-			for _, stats := range stat.CPUStats {
-				user := stats.User
-				system := stats.System
-				idle := stats.Idle
-				iowait := stats.IOWait
+// speedDeltaBadge is the difference from the usual speed, in percent, that earns an arrow
+const speedDeltaBadge = 10
+
+// deltaBadge returns the badge for a percentage difference from the usual speed
+func deltaBadge(percent float64) string {
+	switch {
+	case percent <= -speedDeltaBadge:
+		return "down"
+	case percent >= speedDeltaBadge:
+		return "up"
+	default:
+		return ""
+	}
+}
 
-				currTotal += user + system + idle + iowait
-				currActive += user + system + iowait
-			}
+// speedtestLines formats a result for the speedtest screen, with the difference
+// from the usual speed once there is enough history for a baseline
+func speedtestLines(result *network.SpeedtestResult, baseline history.Baseline) (string, string, string) {
+	dmsg := network.FormatSpeed(result.DownloadMbps)
+	umsg := network.FormatSpeed(result.UploadMbps)
+	if baseline.Valid() {
+		dmsg += " " + history.FormatDelta(baseline.DownloadDelta(result.DownloadMbps))
+		umsg += " " + history.FormatDelta(baseline.UploadDelta(result.UploadMbps))
+	}
+	return dmsg, umsg, network.GetRelativeTime(result.Timestamp)
+}
 
-			var cpuUsage float64
-			if first {
-				first = false
-			} else {
-				deltaActive := currActive - prevActive
-				deltaTotal := currTotal - prevTotal
-				if deltaTotal > 0 {
-					cpuUsage = (float64(deltaActive) / float64(deltaTotal)) * 100
-				}
+func buildCPUStats(i int, demo bool) {
+	if demo {
+		redraw(i, cpuScreen(cpu.Sample{Total: 23.4, Steal: 3.2}))
+		return
+	}
+
+	run := pipelineContext(i)
+	go func() {
+		defer recoverCrash()
+		for {
+			sample := cpuSampler.Latest()
+			setReading("cpu", fmt.Sprintf("%.0f%%", sample.Total))
+			if t, ok := cpuTemperature(); ok {
+				setReading("temperature", fmt.Sprintf("%.0f °C", t))
 			}
 
-			prevActive = currActive
-			prevTotal = currTotal
+			redraw(i, cpuScreen(sample))
 
-			draw.Draw(screen, screen.Bounds(), image.Black, image.ZP, draw.Src)
-			draw.Draw(screen, image.Rect(2, 2, 2+16, 2+16), images.Load("cpu"), image.ZP, draw.Src)
+			if !pause(run, refreshInterval("cpu")) {
+				return
+			}
+		}
+	}()
+}
 
-			write(screen, "CPU", 22, 1, 12, "lato-regular")
-			write(screen, fmt.Sprintf("%.1f%%", cpuUsage), 22, 21, 18, "lato-regular")
+// cpuScreen draws the CPU usage of sample, with the hypervisor's share once it is noticeable
+func cpuScreen(sample cpu.Sample) func(screen draw.Image) {
+	return func(screen draw.Image) {
+		draw.Draw(screen, screen.Bounds(), image.Black, image.ZP, draw.Src)
+		drawIcon(screen, 0, images.Load("cpu"))
 
-			time.Sleep(5 * time.Second)
+		writeRow(screen, 0, "CPU")
+		write(screen, fmt.Sprintf("%.1f%%", sample.Total), 22, 21, 18, "lato-regular")
+		if sample.Steal >= 1 {
+			write(screen, fmt.Sprintf("steal %.0f%%", sample.Steal), 90, 1, 12, "lato-regular")
 		}
-	}()
+	}
 }
 
 func buildRAMStats(i int, demo bool) {
-	screen := screens[i]
+	if demo {
+		redraw(i, ramScreen(&mem.VirtualMemoryStat{Total: 4 << 30, Used: 1536 << 20, UsedPercent: 37.5}))
+		return
+	}
 
+	run := pipelineContext(i)
 	go func() {
+		defer recoverCrash()
 		for {
 			v, _ := mem.VirtualMemory()
-			usedGB := float64(v.Used) / (1024 * 1024 * 1024)
-			totalGB := float64(v.Total) / (1024 * 1024 * 1024)
+			setReading("ram", fmt.Sprintf("%.0f%%", v.UsedPercent))
 
-			draw.Draw(screen, screen.Bounds(), image.Black, image.ZP, draw.Src)
-			draw.Draw(screen, image.Rect(2, 2, 2+16, 2+16), images.Load("ram"), image.ZP, draw.Src)
+			redraw(i, ramScreen(v))
 
-			write(screen, "RAM", 22, 1, 12, "lato-regular")
-			write(screen, fmt.Sprintf("%.1f/%.1fGB", usedGB, totalGB), 22, 21, 12, "lato-regular")
-			write(screen, fmt.Sprintf("%.1f%%", v.UsedPercent), 22, 41, 12, "lato-regular")
-
-			time.Sleep(5 * time.Second)
+			if !pause(run, refreshInterval("ram")) {
+				return
+			}
 		}
 	}()
 }
 
+// ramScreen draws the memory use of v
+func ramScreen(v *mem.VirtualMemoryStat) func(screen draw.Image) {
+	usedGB := float64(v.Used) / (1024 * 1024 * 1024)
+	totalGB := float64(v.Total) / (1024 * 1024 * 1024)
+	return func(screen draw.Image) {
+		draw.Draw(screen, screen.Bounds(), image.Black, image.ZP, draw.Src)
+		drawIcon(screen, 0, images.Load("ram"))
+
+		writeRow(screen, 0, "RAM")
+		writeRow(screen, 1, fmt.Sprintf("%.1f/%.1fGB", usedGB, totalGB))
+		writeRow(screen, 2, fmt.Sprintf("%.1f%%", v.UsedPercent))
+	}
+}
+
 func buildSwapStats(i int, demo bool) {
-	screen := screens[i]
+	if demo {
+		redraw(i, swapScreen(&mem.SwapMemoryStat{Total: 1 << 30, Used: 128 << 20, UsedPercent: 12.5}, "PSI 2%"))
+		return
+	}
 
+	run := pipelineContext(i)
 	go func() {
+		defer recoverCrash()
 		for {
 			s, _ := mem.SwapMemory()
-			usedGB := float64(s.Used) / (1024 * 1024 * 1024)
-			totalGB := float64(s.Total) / (1024 * 1024 * 1024)
 
-			draw.Draw(screen, screen.Bounds(), image.Black, image.ZP, draw.Src)
-			draw.Draw(screen, image.Rect(2, 2, 2+16, 2+16), images.Load("ram"), image.ZP, draw.Src)
-
-			write(screen, "SWAP", 22, 1, 12, "lato-regular")
-			if s.Total == 0 {
-				write(screen, "Not configured", 22, 21, 12, "lato-regular")
-			} else {
-				write(screen, fmt.Sprintf("%.1f/%.1fGB", usedGB, totalGB), 22, 21, 12, "lato-regular")
-				write(screen, fmt.Sprintf("%.1f%%", s.UsedPercent), 22, 41, 12, "lato-regular")
+			// Memory pressure shows swapping hurting before the percentages look scary
+			var psiMsg string
+			if psi, err := pressure.Memory(); err == nil {
+				psiMsg = fmt.Sprintf("PSI %.0f%%", psi.Some.Avg60)
 			}
 
-			time.Sleep(5 * time.Second)
+			redraw(i, swapScreen(s, psiMsg))
+
+			if !pause(run, refreshInterval("swap")) {
+				return
+			}
 		}
 	}()
 }
 
-func buildSystemStats(i int, demo bool) {
+// swapScreen draws the swap use of s with the memory pressure
+func swapScreen(s *mem.SwapMemoryStat, psiMsg string) func(screen draw.Image) {
+	usedGB := float64(s.Used) / (1024 * 1024 * 1024)
+	totalGB := float64(s.Total) / (1024 * 1024 * 1024)
+	return func(screen draw.Image) {
+		draw.Draw(screen, screen.Bounds(), image.Black, image.ZP, draw.Src)
+		drawIcon(screen, 0, images.Load("ram"))
+
+		writeRow(screen, 0, "SWAP")
+		if s.Total == 0 {
+			writeRow(screen, 1, "Not configured")
+		} else {
+			writeRow(screen, 1, fmt.Sprintf("%.1f/%.1fGB", usedGB, totalGB))
+			writeRow(screen, 2, fmt.Sprintf("%.1f%%", s.UsedPercent))
+		}
+		write(screen, psiMsg, 100, 41, 12, "lato-regular")
+	}
+}
 
-	screen := screens[i]
+func buildSystemStats(i int, demo bool) {
 
 	// Loop to update stats periodically
 	go func() {
+		defer recoverCrash()
 		for {
 			v, _ := mem.VirtualMemory()
 			used := float64(v.Used) / (1024 * 1024 * 1024)
@@ -303,7 +626,7 @@ func buildSystemStats(i int, demo bool) {
 
 			ramInfo := fmt.Sprintf(" %.1f/%.1fGB %.1f%%", used, total, usedPercent)
 
-			cpuUsage, _ := getCPUUsagePerCore()
+			cpuUsage := cpuSampler.Latest().Total
 			cpuInfo := fmt.Sprintf(" %.1f%%", cpuUsage)
 
 			// fmt.Println("Used:", used)
@@ -311,74 +634,141 @@ func buildSystemStats(i int, demo bool) {
 			// fmt.Println("CPU Usage:", cpuInfo)
 
 			// Clear the screen
-			draw.Draw(screen, screen.Bounds(), image.Black, image.ZP, draw.Src)
+			redraw(i, func(screen draw.Image) {
+				draw.Draw(screen, screen.Bounds(), image.Black, image.ZP, draw.Src)
 
-			// Draw static labels for CPU and RAM
-			draw.Draw(screen, image.Rect(2, 2, 2+16, 22+16), images.Load("ram"), image.ZP, draw.Src)
-			draw.Draw(screen, image.Rect(2, 22, 2+16, 22+16), images.Load("cpu"), image.ZP, draw.Src)
+				// Draw static labels for CPU and RAM
+				drawIcon(screen, 0, images.Load("ram"))
+				drawIcon(screen, 1, images.Load("cpu"))
 
-			// Clear the screen
-			write(screen, ramInfo, 22, 1, 12, "lato-regular")
-			write(screen, cpuInfo, 22, 21, 12, "lato-regular")
+				// Clear the screen
+				writeRow(screen, 0, ramInfo)
+				writeRow(screen, 1, cpuInfo)
+			})
 
-			time.Sleep(5 * time.Second)
+			time.Sleep(refreshInterval("ram"))
 		}
 	}()
 }
 
-func getCPUUsagePerCore() (float64, error) {
-	// Read CPU stats
-	stat, err := linuxproc.ReadStat("/proc/stat")
+// k8sLatencyMinSamples is how many API round trips are needed before latency can raise a warning
+const k8sLatencyMinSamples = 5
+
+// k8sErrorRateWarning is the share of failed API round trips that raises a warning
+const k8sErrorRateWarning = 0.2
+
+// k8sLatencyDegraded reports whether the API server has become slow or unreliable.
+// A warning of 0 disables the latency check.
+func k8sLatencyDegraded(latency kubernetes.LatencyStats, warningMs int) bool {
+	if latency.Samples < k8sLatencyMinSamples {
+		return false
+	}
+	if warningMs > 0 && latency.P95 > time.Duration(warningMs)*time.Millisecond {
+		return true
+	}
+	return latency.ErrorRate >= k8sErrorRateWarning
+}
+
+// k8sHealthMessage adds the API latency to the cluster health, or explains why it is degraded
+func k8sHealthMessage(health string, latency kubernetes.LatencyStats, degraded bool) string {
+	switch {
+	case degraded && latency.ErrorRate >= k8sErrorRateWarning:
+		return fmt.Sprintf("API errors %.0f%%", latency.ErrorRate*100)
+	case degraded:
+		return fmt.Sprintf("Slow API %dms", latency.P95.Milliseconds())
+	case latency.P95 > 0:
+		return fmt.Sprintf("%s %dms", health, latency.P95.Milliseconds())
+	default:
+		return health
+	}
+}
+
+// nodePressureAbbreviations shorten the node conditions to fit the screen
+var nodePressureAbbreviations = map[string]string{
+	"MemoryPressure":     "mem",
+	"DiskPressure":       "disk",
+	"PIDPressure":        "pid",
+	"NetworkUnavailable": "net",
+}
+
+// nodePressureMessage names the first node under pressure, e.g. "node3 disk,mem +1"
+func nodePressureMessage(nodes []kubernetes.NodePressure) string {
+	var conditions []string
+	for _, condition := range nodes[0].Conditions {
+		conditions = append(conditions, nodePressureAbbreviations[condition])
+	}
+	msg := nodes[0].Name + " " + strings.Join(conditions, ",")
+	if len(nodes) > 1 {
+		msg += fmt.Sprintf(" +%d", len(nodes)-1)
+	}
+	return msg
+}
+
+// k8sAuthMessage explains credentials that expire within warningDays or were rejected by the
+// API server, reporting whether they no longer work. It returns "" while they are fine.
+func k8sAuthMessage(client *kubernetes.Client, statusErr error, now time.Time, warningDays int) (string, bool) {
+	expiry, kind, err := client.AuthExpiry()
 	if err != nil {
-		return 0, err
+		fmt.Printf("K8s auth error: %v\n", err)
+	}
+	if !expiry.IsZero() {
+		metrics.Set("cloudkey_k8s_auth_expiry_timestamp_seconds", "When the Kubernetes client certificate or token expires.", float64(expiry.Unix()), "kind", kind)
 	}
 
-	// Loop through all cores and calculate the usage
-	var totalCPUUsage uint64
-	var totalCPUTime uint64
-	for _, stats := range stat.CPUStats {
-		// Extract stats for each core
-		user := stats.User
-		system := stats.System
-		idle := stats.Idle
-		IOWait := stats.IOWait
-
-		// Calculate total time spent (user + system + idle + IOWait)
-		total := user + system + idle + IOWait
-
-		// Calculate the total active time (user + system + IOWait)
-		active := user + system + IOWait
-
-		// Accumulate totals
-		totalCPUUsage += active
-		totalCPUTime += total
+	switch {
+	case !expiry.IsZero() && !now.Before(expiry):
+		return "expired " + shortDuration(now.Sub(expiry)) + " ago", true
+	case apierrors.IsUnauthorized(statusErr):
+		return "auth rejected", true
+	case !expiry.IsZero() && expiry.Sub(now) < time.Duration(warningDays)*24*time.Hour:
+		return "auth expiring " + shortDuration(expiry.Sub(now)), false
+	default:
+		return "", false
 	}
+}
 
-	// Calculate the total CPU usage as a percentage
-	if totalCPUTime == 0 {
-		return 0, nil // Avoid division by zero
+// helmProblems returns the Helm releases that failed or are stuck pending
+func helmProblems(releases []kubernetes.HelmRelease, now time.Time) []kubernetes.HelmRelease {
+	var problems []kubernetes.HelmRelease
+	for _, release := range releases {
+		if release.Failed() || release.Stuck(now) {
+			problems = append(problems, release)
+		}
 	}
+	return problems
+}
 
-	usagePercentage := (float64(totalCPUUsage) / float64(totalCPUTime)) * 100
-	return usagePercentage, nil
+// helmMessage names the first problem release, e.g. "traefik failed +1"
+func helmMessage(problems []kubernetes.HelmRelease, now time.Time) string {
+	state := "failed"
+	if problems[0].Stuck(now) {
+		state = "stuck"
+	}
+	msg := fmt.Sprintf("%s %s", problems[0].Name, state)
+	if len(problems) > 1 {
+		msg += fmt.Sprintf(" +%d", len(problems)-1)
+	}
+	return msg
 }
 
 func buildKubernetes(i int, demo bool, opts CmdLineOpts) {
-	screen := screens[i]
+	screen := setupImage(i)
 
 	draw.Draw(screen, screen.Bounds(), image.Black, image.ZP, draw.Src)
-	draw.Draw(screen, image.Rect(2, 2, 2+16, 2+16), images.Load("kubernetes"), image.ZP, draw.Src)
-	draw.Draw(screen, image.Rect(2, 22, 2+16, 22+16), images.Load("kubernetes"), image.ZP, draw.Src)
-	draw.Draw(screen, image.Rect(2, 42, 2+16, 42+16), images.Load("kubernetes"), image.ZP, draw.Src)
+	drawIcon(screen, 0, images.Load("kubernetes"))
+	drawIcon(screen, 1, images.Load("kubernetes"))
+	drawIcon(screen, 2, images.Load("kubernetes"))
 
 	if demo {
-		write(screen, "8/8 nodes", 22, 1, 12, "lato-regular")
-		write(screen, "Healthy", 22, 21, 12, "lato-regular")
-		write(screen, "195 pods (312)", 22, 41, 12, "lato-regular")
+		writeRow(screen, 0, "8/8 nodes")
+		writeRow(screen, 1, "Healthy")
+		writeRow(screen, 2, "195 pods (312)")
 		return
 	}
 
+	run := pipelineContext(i)
 	go func() {
+		defer recoverCrash()
 		var client *kubernetes.Client
 		var lastGoodStatus *kubernetes.ClusterStatus
 		var initError bool
@@ -391,21 +781,29 @@ func buildKubernetes(i int, demo bool, opts CmdLineOpts) {
 
 		for {
 			var nodesMsg, healthMsg, podsMsg string
+			var badge string // on the health line's icon
 
 			if initError {
 				nodesMsg = "K8s offline"
 				healthMsg = "config error"
 				podsMsg = "check kubeconfig"
 			} else {
-				ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+				traced, done := collect(run, "kubernetes")
+				ctx, cancel := context.WithTimeout(traced, 15*time.Second)
 				status, err := client.GetClusterStatus(ctx)
 				cancel()
+				if run.Err() != nil {
+					done(err)
+					return
+				}
+				troubled := err != nil
 
 				if err != nil {
 					fmt.Printf("K8s status error: %v\n", err)
 					if lastGoodStatus != nil {
 						nodesMsg = fmt.Sprintf("%d/%d nodes*", lastGoodStatus.NodesReady, lastGoodStatus.NodesTotal)
 						healthMsg = "Offline*"
+						badge = "stale"
 						podsMsg = fmt.Sprintf("%d pods (%d)*", lastGoodStatus.PodsRunning, lastGoodStatus.ContainerCount)
 					} else {
 						nodesMsg = "K8s offline"
@@ -413,8 +811,14 @@ func buildKubernetes(i int, demo bool, opts CmdLineOpts) {
 						podsMsg = "check config"
 					}
 				} else {
+					activity("kubernetes")
 					lastGoodStatus = status
 					nodesMsg = fmt.Sprintf("%d/%d nodes", status.NodesReady, status.NodesTotal)
+					if len(status.NodesPressure) > 0 {
+						nodesMsg = fmt.Sprintf("%d/%d %s", status.NodesReady, status.NodesTotal, nodePressureMessage(status.NodesPressure))
+					}
+					setWarning("k8s-pressure", len(status.NodesPressure) > 0)
+					troubled = len(status.NodesPressure) > 0 || !status.Healthy
 					if status.Healthy {
 						healthMsg = "Healthy"
 					} else {
@@ -422,14 +826,58 @@ func buildKubernetes(i int, demo bool, opts CmdLineOpts) {
 					}
 					podsMsg = fmt.Sprintf("%d pods (%d)", status.PodsRunning, status.ContainerCount)
 				}
+
+				latency := client.Latency()
+				metrics.Set("cloudkey_k8s_api_latency_seconds", "95th percentile Kubernetes API round trip over recent checks.", latency.P95.Seconds())
+				metrics.Set("cloudkey_k8s_api_error_ratio", "Share of recent Kubernetes API checks that failed.", latency.ErrorRate)
+				degraded := k8sLatencyDegraded(latency, opts.K8sLatencyWarning)
+				setWarning("k8s-latency", degraded)
+				troubled = troubled || degraded
+				if err == nil {
+					healthMsg = k8sHealthMessage(healthMsg, latency, degraded)
+				}
+
+				if opts.K8sHelmEnabled && err == nil {
+					ctx, cancel := context.WithTimeout(traced, 15*time.Second)
+					releases, err := client.HelmReleases(ctx, opts.K8sHelmDriver)
+					cancel()
+					if err != nil {
+						fmt.Printf("K8s Helm error: %v\n", err)
+					} else {
+						problems := helmProblems(releases, time.Now())
+						setWarning("helm", len(problems) > 0)
+						troubled = troubled || len(problems) > 0
+						if len(problems) > 0 && !degraded {
+							healthMsg = helmMessage(problems, time.Now())
+						}
+					}
+				}
+
+				authMsg, authFailed := k8sAuthMessage(client, err, time.Now(), opts.K8sAuthWarningDays)
+				setWarning("k8s-auth", authMsg != "")
+				setRelevant("kubernetes", troubled || authMsg != "")
+				if badge == "" && (troubled || authMsg != "") {
+					badge = "warning"
+				}
+				if authFailed {
+					nodesMsg, healthMsg, podsMsg = "K8s auth", authMsg, "renew kubeconfig"
+				} else if authMsg != "" {
+					healthMsg = authMsg
+				}
+				done(err)
 			}
 
-			draw.Draw(screen, image.Rect(20, 0, 160, 60), image.Black, image.ZP, draw.Src)
-			write(screen, nodesMsg, 22, 1, 12, "lato-regular")
-			write(screen, healthMsg, 22, 21, 12, "lato-regular")
-			write(screen, podsMsg, 22, 41, 12, "lato-regular")
+			redraw(i, func(screen draw.Image) {
+				drawIcon(screen, 1, images.Badged("kubernetes", badge))
+				clearText(screen)
+				writeRow(screen, 0, nodesMsg)
+				writeRow(screen, 1, healthMsg)
+				writeRow(screen, 2, podsMsg)
+			})
 
-			time.Sleep(30 * time.Second)
+			if !pause(run, refreshInterval("kubernetes")) {
+				return
+			}
 		}
 	}()
 }
@@ -0,0 +1,222 @@
+package display
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/llajas/cloudkey/src/panel"
+
+	"cloudkey/images"
+	"cloudkey/src/events"
+	"cloudkey/src/metrics"
+)
+
+// selfInterval is how often the process samples its own resource usage
+const selfInterval = 10 * time.Second
+
+// selfOverLimit is how many consecutive samples above the memory cap trigger a restart
+const selfOverLimit = 3
+
+// selfStats is the latest sample of the cloudkey process itself
+type selfStats struct {
+	Heap       uint64 // bytes in live heap objects
+	Resident   uint64 // bytes held from the OS, excluding memory returned to it
+	Goroutines int
+	GCPause    time.Duration // most recent stop-the-world pause
+	NumGC      uint32
+}
+
+var (
+	lastSelf      selfStats
+	lastSelfMutex sync.Mutex
+)
+
+// readSelfStats samples the Go runtime
+func readSelfStats() selfStats {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	return selfStats{
+		Heap:       m.HeapAlloc,
+		Resident:   m.Sys - m.HeapReleased,
+		Goroutines: runtime.NumGoroutine(),
+		GCPause:    time.Duration(m.PauseNs[(m.NumGC+255)%256]),
+		NumGC:      m.NumGC,
+	}
+}
+
+// startSelfMonitor samples the process every selfInterval, exports the values as metrics and
+// enforces the memory and goroutine budgets. The Cloud Key shares its RAM with the controller,
+// so a leak here must not be allowed to starve it.
+func startSelfMonitor(opts CmdLineOpts) {
+	limit := uint64(opts.MemoryLimit) * 1024 * 1024
+	if limit > 0 {
+		// Make the GC work harder as the cap approaches before resorting to a restart
+		debug.SetMemoryLimit(int64(limit))
+	}
+
+	go func() {
+		defer recoverCrash()
+		var overPolls int
+
+		for {
+			s := readSelfStats()
+			lastSelfMutex.Lock()
+			lastSelf = s
+			lastSelfMutex.Unlock()
+
+			metrics.Set("cloudkey_heap_bytes", "Bytes in live heap objects.", float64(s.Heap))
+			metrics.Set("cloudkey_resident_bytes", "Bytes held from the OS by the Go runtime.", float64(s.Resident))
+			metrics.Set("cloudkey_goroutines", "Number of goroutines.", float64(s.Goroutines))
+			metrics.Set("cloudkey_gc_pause_seconds", "Duration of the most recent GC pause.", s.GCPause.Seconds())
+			metrics.Set("cloudkey_gc_cycles", "Number of completed GC cycles.", float64(s.NumGC))
+
+			setWarning("goroutines", opts.GoroutineLimit > 0 && s.Goroutines > opts.GoroutineLimit)
+
+			if limit > 0 && s.Resident > limit {
+				overPolls++
+				fmt.Printf("Memory above limit: %s of %s (%d/%d)\n", formatMB(s.Resident), formatMB(limit), overPolls, selfOverLimit)
+				debug.FreeOSMemory()
+			} else {
+				overPolls = 0
+			}
+
+			if overPolls >= selfOverLimit {
+				fmt.Println("Memory limit exceeded")
+				pushTicker("cloudkey restarted (memory)")
+				reexec("memory")
+			}
+
+			time.Sleep(selfInterval)
+		}
+	}()
+}
+
+func formatMB(bytes uint64) string {
+	return fmt.Sprintf("%.1fMB", float64(bytes)/(1024*1024))
+}
+
+// shortDuration formats a duration in at most two units, e.g. 3d4h, 2h05m or 12m
+func shortDuration(d time.Duration) string {
+	switch {
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%dd%dh", int(d.Hours())/24, int(d.Hours())%24)
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+	default:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	}
+}
+
+// selfRows lists the process stats for the compact layout
+func selfRows(s selfStats, uptime time.Duration) []panel.Pair {
+	return []panel.Pair{
+		{Label: "Heap", Value: formatMB(s.Heap)},
+		{Label: "Resident", Value: formatMB(s.Resident)},
+		{Label: "Goroutines", Value: fmt.Sprint(s.Goroutines)},
+		{Label: "GC pause", Value: fmt.Sprintf("%.2fms", float64(s.GCPause.Microseconds())/1000)},
+		{Label: "GC cycles", Value: fmt.Sprint(s.NumGC)},
+		{Label: "Uptime", Value: shortDuration(uptime)},
+	}
+}
+
+// healthLines summarizes the health history on three lines
+func healthLines(opts CmdLineOpts) [3]string {
+	summary, err := HealthHistory(opts)
+	if err != nil {
+		fmt.Printf("Error reading health history: %v\n", err)
+		return [3]string{"Health history", "unavailable", "check logs"}
+	}
+
+	lines := [3]string{fmt.Sprintf("7d W %s C %s",
+		shortDuration(time.Duration(summary.WarningSeconds)*time.Second),
+		shortDuration(time.Duration(summary.CriticalSeconds)*time.Second))}
+	if summary.Incidents == 0 {
+		lines[1] = "No incidents"
+		return lines
+	}
+
+	lines[1] = fmt.Sprintf("%d incidents", summary.Incidents)
+	if summary.MTTRSeconds > 0 {
+		lines[1] += ", MTTR " + shortDuration(time.Duration(summary.MTTRSeconds)*time.Second)
+	}
+	last := shortDuration(time.Duration(summary.LastIncidentSeconds) * time.Second)
+	if summary.Ongoing {
+		lines[2] = fmt.Sprintf("%s for %s", strings.ToUpper(summary.State), last)
+	} else {
+		lines[2] = "Last incident " + last
+	}
+	return lines
+}
+
+// buildDiagnostics shows the process stats and the health history, switching page each
+// time the carousel comes back to the screen
+func buildDiagnostics(i int, demo bool, opts CmdLineOpts) {
+	screen := setupImage(i)
+
+	draw.Draw(screen, screen.Bounds(), image.Black, image.ZP, draw.Src)
+	drawIcon(screen, 0, images.Load("ram"))
+
+	if demo {
+		canvas(screen).Columns(panel.TextArea, selfRows(selfStats{
+			Heap:       6502 * 1024,
+			Resident:   14260 * 1024,
+			Goroutines: 24,
+			GCPause:    210 * time.Microsecond,
+			NumGC:      318,
+		}, 26*time.Hour+14*time.Minute))
+		return
+	}
+
+	go func() {
+		defer recoverCrash()
+		shown := events.Subscribe()
+		ticker := time.NewTicker(refreshInterval("diagnostics"))
+		defer ticker.Stop()
+		healthPage := false
+
+		for {
+			if healthPage {
+				lines := healthLines(opts)
+				redraw(i, func(screen draw.Image) {
+					clearText(screen)
+					writeRow(screen, 0, lines[0])
+					writeRow(screen, 1, lines[1])
+					writeRow(screen, 2, lines[2])
+				})
+			} else {
+				lastSelfMutex.Lock()
+				s := lastSelf
+				lastSelfMutex.Unlock()
+
+				rows := selfRows(s, time.Since(started))
+				if row, ok := watchdogRow(); ok {
+					// In place of the GC cycles, the least telling of the stats
+					rows[4] = row
+				}
+				redraw(i, func(screen draw.Image) {
+					canvas(screen).Columns(panel.TextArea, rows)
+				})
+			}
+
+			// Wait for the next sample, or flip the page when the carousel arrives here
+			for waiting := true; waiting; {
+				select {
+				case <-ticker.C:
+					waiting = false
+				case e := <-shown:
+					if se, ok := e.Data.(screenEvent); ok && e.Type == "screen" && se.Index == i {
+						healthPage = !healthPage
+						waiting = false
+					}
+				}
+			}
+		}
+	}()
+}
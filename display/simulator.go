@@ -0,0 +1,56 @@
+package display
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/llajas/cloudkey/src/panel"
+	"github.com/llajas/cloudkey/src/panel/framebuffer"
+)
+
+// panelBounds is the size of the panel when headless or simulated, the Cloud Key Gen2's
+// unless -panel-size says otherwise
+var panelBounds = image.Rect(0, 0, 160, 60)
+
+// parsePanelSize parses a WIDTHxHEIGHT panel size such as 320x120
+func parsePanelSize(size string) (image.Rectangle, error) {
+	w, h, ok := strings.Cut(size, "x")
+	width, errW := strconv.Atoi(w)
+	height, errH := strconv.Atoi(h)
+	if !ok || errW != nil || errH != nil || width < panel.Logical.Dx() || height < panel.Logical.Dy() {
+		return image.Rectangle{}, fmt.Errorf("panel size %q: expected WIDTHxHEIGHT of at least %dx%d", size, panel.Logical.Dx(), panel.Logical.Dy())
+	}
+	return image.Rect(0, 0, width, height), nil
+}
+
+// recording is set while the carousel runs headless for a recording
+var recording bool
+
+// Record runs the screens and the carousel on an in-memory panel without touching the
+// framebuffer or LEDs, for recordings of the rotation. Capture it with Snapshot. Like New,
+// it never returns.
+func Record(opts CmdLineOpts) {
+	opts.Headless = true
+	recording = true
+	Init(opts)
+	New(opts)
+}
+
+// simulated reports whether the panel and LEDs are simulated, for development on
+// macOS or Windows where there is no fbdev or /sys/class/leds
+func simulated() bool {
+	return runtime.GOOS != "linux"
+}
+
+// openFramebuffer opens the framebuffer device, or an in-memory panel when simulated
+func openFramebuffer(device string) (draw.Image, error) {
+	if simulated() {
+		fmt.Println("Development mode: simulating the panel (view it on the API's /mirror page with -features experimental.websocket) and logging LED changes")
+		return image.NewRGBA(panelBounds), nil
+	}
+	return framebuffer.Open(device)
+}
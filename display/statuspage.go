@@ -0,0 +1,204 @@
+package display
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/llajas/cloudkey/src/network"
+
+	"cloudkey/src/history"
+)
+
+// statusPageInterval is how often the status page is rewritten in the status page directory
+const statusPageInterval = time.Minute
+
+// statusCheck is one line of the service checks on the status page
+type statusCheck struct {
+	Name string
+	OK   bool
+	Note string
+}
+
+// statusPage is what the status page shows. It deliberately leaves out addresses,
+// names of clients and anything else that should not be on an unauthenticated page.
+type statusPage struct {
+	Generated      string
+	InternetUp     bool
+	InternetUptime string // share of answered pings over the last 7 days, empty without the ping monitor
+	HealthUptime   string // share of the last 7 days the rack health was not critical
+	Speedtest      string
+	SpeedtestAge   string
+	Checks         []statusCheck
+}
+
+// warningNames describes the warnings raised by screens on the status page
+var warningNames = map[string]string{
+	"airtime":      "Wi-Fi channels are busy",
+	"alertmanager": "Alerts firing in Alertmanager",
+	"gitops":       "GitOps sync",
+	"goroutines":   "cloudkey itself",
+	"helm":         "Helm releases",
+	"k8s-auth":     "Kubernetes credentials",
+	"k8s-latency":  "Kubernetes API",
+	"k8s-pressure": "Kubernetes nodes",
+	"wan_backup":   "Running on backup internet",
+}
+
+// buildStatusPage gathers the state shown on the status page
+func buildStatusPage(opts CmdLineOpts) statusPage {
+	now := time.Now().In(location(opts))
+	page := statusPage{Generated: now.Format("Mon 2 Jan 15:04 MST")}
+
+	// Ask the probes directly rather than trusting a screen that may refresh hourly
+	wan := network.CheckWAN(opts.OutageProbes, outageProbeTimeout)
+	page.InternetUp = wan != network.WANDown
+	internet := statusCheck{Name: "Internet", OK: page.InternetUp}
+	switch wan {
+	case network.WANDown:
+		internet.Note = "no answer from the outside"
+	case network.WANUnknown:
+		internet.Note = "not checked"
+	}
+	page.Checks = append(page.Checks, internet)
+	if opts.UDMUsername != "" {
+		page.Checks = append(page.Checks, statusCheck{Name: "UniFi controller", OK: !hasUDMError.Load()})
+	}
+
+	if opts.LatencyHeatmapEnabled {
+		pings, err := history.Open[network.PingResult](filepath.Join(opts.StateDir, "pings.jsonl")).All()
+		if err != nil {
+			fmt.Printf("Error reading ping history: %v\n", err)
+		}
+		cutoff := now.Add(-healthSummaryWindow).UnixMilli()
+		var answered, total int
+		for _, p := range pings {
+			if p.Timestamp < cutoff {
+				continue
+			}
+			total++
+			if !p.Lost {
+				answered++
+			}
+		}
+		if total > 0 {
+			page.InternetUptime = fmt.Sprintf("%.2f%%", float64(answered)/float64(total)*100)
+		}
+	}
+
+	if summary, err := HealthHistory(opts); err != nil {
+		fmt.Printf("Error reading health history: %v\n", err)
+	} else {
+		page.HealthUptime = fmt.Sprintf("%.2f%%", (1-summary.CriticalSeconds/(summary.WindowHours*3600))*100)
+	}
+
+	speedtests, err := history.Open[network.SpeedtestResult](filepath.Join(opts.StateDir, "speedtests.jsonl")).All()
+	if err != nil {
+		fmt.Printf("Error reading speedtest history: %v\n", err)
+	}
+	if len(speedtests) > 0 {
+		last := speedtests[0]
+		for _, r := range speedtests {
+			if r.Timestamp > last.Timestamp {
+				last = r
+			}
+		}
+		page.Speedtest = fmt.Sprintf("%s down, %s up, %.0f ms", network.FormatSpeed(last.DownloadMbps), network.FormatSpeed(last.UploadMbps), last.LatencyMs)
+		page.SpeedtestAge = time.UnixMilli(last.Timestamp).In(now.Location()).Format("Mon 2 Jan 15:04")
+	}
+
+	warningsMutex.Lock()
+	var names []string
+	for name := range warnings {
+		names = append(names, name)
+	}
+	warningsMutex.Unlock()
+	sort.Strings(names)
+	for _, name := range names {
+		label, ok := warningNames[name]
+		if !ok {
+			label = name
+		}
+		page.Checks = append(page.Checks, statusCheck{Name: label, OK: false, Note: "needs attention"})
+	}
+
+	return page
+}
+
+// WriteStatusPage renders the status page as a standalone HTML document
+func WriteStatusPage(w io.Writer, opts CmdLineOpts) error {
+	return statusPageTemplate.Execute(w, buildStatusPage(opts))
+}
+
+// startStatusPageWriter rewrites index.html in dir every statusPageInterval, so any web server,
+// or a file share, can publish the status page without the API
+func startStatusPageWriter(opts CmdLineOpts) {
+	if err := os.MkdirAll(opts.StatusPageDir, 0755); err != nil {
+		fmt.Printf("Status page disabled: %v\n", err)
+		return
+	}
+
+	go func() {
+		defer recoverCrash()
+		path := filepath.Join(opts.StatusPageDir, "index.html")
+		var buf bytes.Buffer
+		for {
+			buf.Reset()
+			if err := WriteStatusPage(&buf, opts); err != nil {
+				fmt.Printf("Error rendering status page: %v\n", err)
+			} else if err := writeFileAtomic(path, buf.Bytes()); err != nil {
+				fmt.Printf("Error writing status page: %v\n", err)
+			}
+			time.Sleep(statusPageInterval)
+		}
+	}()
+}
+
+// writeFileAtomic replaces path so readers never see a partly written file
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+var statusPageTemplate = template.Must(template.New("status").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta http-equiv="refresh" content="60">
+<title>{{if .InternetUp}}Internet is up{{else}}Internet is down{{end}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Roboto, sans-serif; background: #f4f4f4; color: #222; margin: 0; padding: 2em 1em; }
+main { max-width: 32em; margin: 0 auto; }
+h1 { padding: 0.8em; border-radius: 8px; color: #fff; text-align: center; }
+.up { background: #2e7d32; }
+.down { background: #c62828; }
+table { width: 100%; border-collapse: collapse; background: #fff; border-radius: 8px; }
+td { padding: 0.6em 0.8em; border-bottom: 1px solid #eee; }
+.ok { color: #2e7d32; }
+.bad { color: #c62828; }
+footer { color: #888; font-size: 0.85em; margin-top: 1em; text-align: center; }
+</style>
+</head>
+<body>
+<main>
+{{if .InternetUp}}<h1 class="up">Internet is up</h1>{{else}}<h1 class="down">Internet is down</h1>{{end}}
+<table>
+{{if .InternetUptime}}<tr><td>Internet uptime, 7 days</td><td>{{.InternetUptime}}</td></tr>{{end}}
+{{if .HealthUptime}}<tr><td>Network rack uptime, 7 days</td><td>{{.HealthUptime}}</td></tr>{{end}}
+{{if .Speedtest}}<tr><td>Last speedtest<br><small>{{.SpeedtestAge}}</small></td><td>{{.Speedtest}}</td></tr>{{end}}
+{{range .Checks}}<tr><td>{{.Name}}</td><td class="{{if .OK}}ok{{else}}bad{{end}}">{{if .OK}}OK{{else}}Problem{{end}}{{if .Note}} <small>({{.Note}})</small>{{end}}</td></tr>
+{{end}}</table>
+<footer>Updated {{.Generated}}</footer>
+</main>
+</body>
+</html>
+`))
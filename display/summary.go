@@ -0,0 +1,96 @@
+package display
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"path/filepath"
+	"time"
+
+	"github.com/llajas/cloudkey/src/network"
+
+	"cloudkey/images"
+	"cloudkey/src/history"
+)
+
+// location returns the timezone days and weeks are counted in, the system zone by default
+func location(opts CmdLineOpts) *time.Location {
+	if opts.Timezone == "" {
+		return time.Local
+	}
+	// Validated at startup
+	loc, err := time.LoadLocation(opts.Timezone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// summaryLines formats yesterday's download range and this week's average against last week's
+func summaryLines(results []network.SpeedtestResult, now time.Time) (string, string, string) {
+	yStart, yEnd := history.Yesterday(now)
+	yesterday := history.DownloadStats(results, yStart, yEnd)
+
+	week := history.ThisWeek(now)
+	thisWeek := history.DownloadStats(results, week, now)
+	lastWeek := history.DownloadStats(results, week.AddDate(0, 0, -7), week)
+
+	line1, line2 := "Yesterday: no tests", ""
+	if yesterday.Samples > 0 {
+		line1 = "Yday avg " + network.FormatSpeed(yesterday.Avg)
+		line2 = fmt.Sprintf("%.0f-%.0f Mb/s", yesterday.Min, yesterday.Max)
+	}
+
+	line3 := "Week: no tests"
+	if thisWeek.Samples > 0 {
+		line3 = "Week " + network.FormatSpeed(thisWeek.Avg)
+		if lastWeek.Samples > 0 && lastWeek.Avg > 0 {
+			line3 += " " + history.FormatDelta((thisWeek.Avg-lastWeek.Avg)/lastWeek.Avg*100)
+		}
+	}
+	return line1, line2, line3
+}
+
+func buildSpeedtestSummary(i int, demo bool, opts CmdLineOpts) {
+	screen := setupImage(i)
+
+	draw.Draw(screen, screen.Bounds(), image.Black, image.ZP, draw.Src)
+	drawIcon(screen, 0, images.Load("download"))
+	drawIcon(screen, 2, images.Load("clock"))
+
+	if demo {
+		writeRow(screen, 0, "Yday avg 468.2 Mb/s")
+		writeRow(screen, 1, "402-511 Mb/s")
+		writeRow(screen, 2, "Week 471.9 Mb/s +2%")
+		return
+	}
+
+	run := pipelineContext(i)
+	go func() {
+		defer recoverCrash()
+		store := history.Open[network.SpeedtestResult](filepath.Join(opts.StateDir, "speedtests.jsonl"))
+		loc := location(opts)
+
+		for {
+			line1, line2, line3 := "History error", "check state dir", ""
+
+			results, err := store.All()
+			if err != nil {
+				fmt.Printf("Error reading speedtest history: %v\n", err)
+			} else {
+				line1, line2, line3 = summaryLines(results, time.Now().In(loc))
+			}
+
+			redraw(i, func(screen draw.Image) {
+				clearText(screen)
+				writeRow(screen, 0, line1)
+				writeRow(screen, 1, line2)
+				writeRow(screen, 2, line3)
+			})
+
+			if !pause(run, refreshInterval("speedsummary")) {
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,158 @@
+package display
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/llajas/cloudkey/src/network"
+
+	"cloudkey/src/history"
+	"cloudkey/src/leader"
+	"cloudkey/src/notify"
+)
+
+const (
+	// telegramPoll is how long a getUpdates request waits for a message
+	telegramPoll = 50 * time.Second
+	// telegramStandby is how often a follower checks whether it became the leader
+	telegramStandby = 15 * time.Second
+	// telegramRetry is how long to wait after getUpdates failed
+	telegramRetry = 30 * time.Second
+)
+
+const telegramHelp = `Commands:
+/status - internet, uptime and checks
+/speedtest - the latest speedtest result
+/screen - list the screens
+/screen <name> - show a screen on the panel now`
+
+// telegramBot returns the Telegram bot configured in opts, or nil
+func telegramBot(opts CmdLineOpts) *notify.Telegram {
+	if opts.TelegramToken == "" {
+		return nil
+	}
+	bot := &notify.Telegram{Token: opts.TelegramToken}
+	for _, chat := range opts.TelegramChats {
+		// Validated at startup
+		id, _ := strconv.ParseInt(chat, 10, 64)
+		bot.Chats = append(bot.Chats, id)
+	}
+	return bot
+}
+
+// telegramCommand answers a command sent to the bot
+func telegramCommand(opts CmdLineOpts, text string) string {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return telegramHelp
+	}
+	// Commands in group chats are addressed as /status@yourbot
+	command, _, _ := strings.Cut(fields[0], "@")
+
+	switch command {
+	case "/status":
+		page := buildStatusPage(opts)
+		lines := []string{"Internet is up"}
+		if !page.InternetUp {
+			lines[0] = "Internet is DOWN"
+		}
+		if page.InternetUptime != "" {
+			lines = append(lines, "Internet uptime (7d): "+page.InternetUptime)
+		}
+		lines = append(lines, "Health: "+healthState().String())
+		for _, check := range page.Checks {
+			state := "OK"
+			if !check.OK {
+				state = "problem"
+			}
+			if check.Note != "" {
+				state += " (" + check.Note + ")"
+			}
+			lines = append(lines, check.Name+": "+state)
+		}
+		return strings.Join(lines, "\n")
+
+	case "/speedtest":
+		results, err := history.Open[network.SpeedtestResult](filepath.Join(opts.StateDir, "speedtests.jsonl")).All()
+		if err != nil {
+			return "Cannot read the speedtest history: " + err.Error()
+		}
+		if len(results) == 0 {
+			return "No speedtest results yet"
+		}
+		last := results[0]
+		for _, r := range results {
+			if r.Timestamp > last.Timestamp {
+				last = r
+			}
+		}
+		reply := fmt.Sprintf("Down %s, up %s, ping %.0f ms\n%s",
+			network.FormatSpeed(last.DownloadMbps), network.FormatSpeed(last.UploadMbps), last.LatencyMs,
+			time.UnixMilli(last.Timestamp).In(location(opts)).Format("Mon 2 Jan 15:04"))
+		if baseline := history.SpeedtestBaseline(results, time.Now(), baselineWindow); baseline.Valid() {
+			reply += fmt.Sprintf("\nUsually %s / %s", network.FormatSpeed(baseline.Download), network.FormatSpeed(baseline.Upload))
+		}
+		return reply
+
+	case "/screen":
+		if len(fields) < 2 {
+			return "Screens: " + strings.Join(ScreenNames(), ", ")
+		}
+		shown, err := ShowScreen(fields[1])
+		if err != nil {
+			return fmt.Sprintf("Cannot show %s: %v\nScreens: %s", fields[1], err, strings.Join(ScreenNames(), ", "))
+		}
+		return "Showing " + shown
+
+	default:
+		return telegramHelp
+	}
+}
+
+// startTelegramBot answers commands from the configured chats. Only the leader polls,
+// since Telegram allows a single getUpdates consumer per bot.
+func startTelegramBot(opts CmdLineOpts) {
+	bot := telegramBot(opts)
+	if bot == nil {
+		return
+	}
+
+	go func() {
+		defer recoverCrash()
+		var offset int64
+		failing := false
+		for {
+			if !leader.IsLeader() {
+				time.Sleep(telegramStandby)
+				continue
+			}
+
+			messages, err := bot.Updates(context.Background(), offset, telegramPoll)
+			if err != nil {
+				if !failing {
+					fmt.Printf("Telegram updates failed: %v\n", err)
+				}
+				failing = true
+				time.Sleep(telegramRetry)
+				continue
+			}
+			failing = false
+
+			for _, m := range messages {
+				offset = m.UpdateID + 1
+				if m.ChatID == 0 {
+					continue
+				}
+				ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+				if err := bot.Reply(ctx, m.ChatID, telegramCommand(opts, m.Text)); err != nil {
+					fmt.Printf("Telegram reply failed: %v\n", err)
+				}
+				cancel()
+			}
+		}
+	}()
+}
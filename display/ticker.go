@@ -0,0 +1,91 @@
+package display
+
+import (
+	"image"
+	"image/draw"
+	"sync"
+	"time"
+
+	"github.com/llajas/cloudkey/src/network"
+
+	"cloudkey/images"
+	"cloudkey/src/events"
+)
+
+const (
+	// tickerSize is how many messages the ticker screen keeps
+	tickerSize = 3
+	// tickerAging is how often the ticker is redrawn to age the relative times
+	tickerAging = 5 * time.Second
+)
+
+// tickerItem is a single line on the ticker screen
+type tickerItem struct {
+	Text string    `json:"text"`
+	Time time.Time `json:"time"`
+}
+
+var (
+	tickerItems []tickerItem
+	tickerMutex sync.Mutex
+
+	// tickerChanged wakes the ticker screen. It holds at most one wakeup, so a burst of
+	// messages is drawn once.
+	tickerChanged = make(chan struct{}, 1)
+)
+
+// pushTicker adds a message to the ticker screen and publishes it as an event
+func pushTicker(text string) {
+	item := tickerItem{Text: text, Time: time.Now()}
+
+	tickerMutex.Lock()
+	tickerItems = append([]tickerItem{item}, tickerItems...)
+	if len(tickerItems) > tickerSize {
+		tickerItems = tickerItems[:tickerSize]
+	}
+	tickerMutex.Unlock()
+	markRelevant("ticker")
+
+	select {
+	case tickerChanged <- struct{}{}:
+	default:
+	}
+	events.Publish("ticker", item)
+}
+
+func buildTicker(i int, demo bool) {
+	if demo {
+		pushTicker("IPS: ET SCAN Nmap")
+		pushTicker("sw-office port 7 down")
+		pushTicker("New VPN: simon")
+	}
+
+	go func() {
+		defer recoverCrash()
+		for {
+			tickerMutex.Lock()
+			items := append([]tickerItem(nil), tickerItems...)
+			tickerMutex.Unlock()
+
+			redraw(i, func(screen draw.Image) {
+				draw.Draw(screen, screen.Bounds(), image.Black, image.ZP, draw.Src)
+				drawIcon(screen, 0, images.Load("clock"))
+
+				if len(items) == 0 {
+					writeRow(screen, 0, "No recent events")
+				}
+				for n, item := range items {
+					y := 1 + n*20
+					write(screen, network.GetRelativeTime(item.Time.UnixMilli()), 22, y, 8, "lato-regular")
+					write(screen, item.Text, 22, y+9, 8, "lato-regular")
+				}
+			})
+
+			// Redraw for new messages, and every tickerAging to age the relative times
+			select {
+			case <-tickerChanged:
+			case <-time.After(tickerAging):
+			}
+		}
+	}()
+}
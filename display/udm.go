@@ -0,0 +1,260 @@
+package display
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/llajas/cloudkey/src/network"
+)
+
+const (
+	// udmDetectRetryMin is the first pause between attempts to reach the controller in the background
+	udmDetectRetryMin = 10 * time.Second
+	// udmDetectRetryMax caps the pause as the attempts back off
+	udmDetectRetryMax = 5 * time.Minute
+	// udmProbeInterval is how often the controller is probed between fetches once reachable
+	udmProbeInterval = 30 * time.Second
+)
+
+var (
+	udm      *network.UDMProClient
+	udmMutex sync.Mutex
+
+	udmLogin       chan struct{} // closed when the login in flight finishes, nil if none
+	udmLoginErr    error         // result of the last login
+	udmLoginFailed time.Time     // when the last login failed, zero if it succeeded
+
+	udmReachable = make(chan struct{}) // closed once the controller type has been detected
+	udmOffline   error                 // why the last probe got no answer, nil while the controller answers
+	udmBack      chan struct{}         // closed when the controller answers again after going offline
+)
+
+// udmClient returns the controller client shared by all controller screens, logged in and ready.
+// Screens asking while a login is in flight wait for its result instead of logging in again,
+// each until its own deadline. For -udm-login-retry after a failed login they get its error
+// instead of trying again, so an unreachable controller does not hold every controller screen
+// in a queue of slow logins.
+func udmClient(ctx context.Context, opts CmdLineOpts) (*network.UDMProClient, error) {
+	udmMutex.Lock()
+
+	if udm == nil {
+		client, err := network.NewUDMProClient(opts.UDMBaseURL, opts.UDMUsername, opts.UDMPassword, opts.UDMSite, opts.UDMVersion, opts.UDMTimeouts())
+		if err != nil {
+			udmMutex.Unlock()
+			return nil, err
+		}
+		client.WritesEnabled = opts.UDMWriteEnabled
+		client.MaxRequests = opts.UDMMaxRequests
+		instrument(client, opts)
+		udm = client
+		go detectController(client)
+	}
+	client := udm
+
+	if !udmLoginFailed.IsZero() && time.Since(udmLoginFailed) < opts.UDMLoginRetry {
+		err := udmLoginErr
+		udmMutex.Unlock()
+		return nil, err
+	}
+
+	if udmLogin == nil {
+		udmLogin = make(chan struct{})
+		go udmLoginShared(ctx, client, udmLogin, udmLoginBudget(opts))
+	}
+	done := udmLogin
+	udmMutex.Unlock()
+
+	wait, cancel := context.WithTimeout(ctx, udmLoginBudget(opts))
+	defer cancel()
+	select {
+	case <-done:
+	case <-wait.Done():
+		return nil, fmt.Errorf("controller login not finished: %v", wait.Err())
+	}
+
+	udmMutex.Lock()
+	err := udmLoginErr
+	udmMutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// udmLoginShared logs in for every screen waiting on done, and closes it with the result in
+// udmLoginErr. The login runs on its own deadline, since the screen that started it may stop
+// waiting first.
+func udmLoginShared(ctx context.Context, client *network.UDMProClient, done chan struct{}, budget time.Duration) {
+	defer recoverCrash()
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), budget)
+	defer cancel()
+
+	err := client.Login(ctx)
+
+	udmMutex.Lock()
+	udmLogin = nil
+	udmLoginErr = err
+	udmLoginFailed = time.Time{}
+	if err != nil {
+		udmLoginFailed = time.Now()
+	}
+	udmMutex.Unlock()
+	close(done)
+}
+
+// udmLoginBudget is the longest a login takes with the configured timeouts: detecting the
+// controller, the login request and the queries checking the version, site and account
+func udmLoginBudget(opts CmdLineOpts) time.Duration {
+	return opts.UDMDetectTimeout + opts.UDMLoginTimeout + 3*opts.UDMQueryTimeout
+}
+
+// detectController keeps trying to reach the controller in the background, backing off,
+// so controller screens recover on their own when the controller comes up after cloudkey
+func detectController(client *network.UDMProClient) {
+	defer recoverCrash()
+	wait := udmDetectRetryMin
+	for {
+		err := client.Detect(context.Background())
+		if err == nil {
+			break
+		}
+		fmt.Printf("Controller not reachable yet, retrying in %s: %v\n", wait, err)
+		time.Sleep(wait)
+		wait = min(wait*2, udmDetectRetryMax)
+	}
+
+	// Let screens waiting out a failed login try again right away
+	udmMutex.Lock()
+	udmLoginFailed = time.Time{}
+	udmMutex.Unlock()
+	close(udmReachable)
+
+	for {
+		time.Sleep(udmProbeInterval)
+		udmProbe(context.Background(), client)
+	}
+}
+
+// udmProbe checks the controller answers and returns why not. Going offline and coming back
+// are logged; coming back also lets screens waiting out a failed login try again at once and
+// wakes the screens waiting on udmRecovered.
+func udmProbe(ctx context.Context, client *network.UDMProClient) error {
+	err := client.Probe(ctx)
+
+	udmMutex.Lock()
+	defer udmMutex.Unlock()
+	switch {
+	case err != nil && udmOffline == nil:
+		fmt.Printf("Controller stopped answering: %v\n", err)
+		udmBack = make(chan struct{})
+		SetUDMError(true)
+	case err == nil && udmOffline != nil:
+		fmt.Println("Controller answers again")
+		udmLoginFailed = time.Time{}
+		close(udmBack)
+	}
+	udmOffline = err
+	return err
+}
+
+// udmFailure tells a failed controller fetch apart: when the controller does not answer a
+// probe either, the probe's error replaces err, so the screen says the controller is offline
+// rather than blaming the login or the data
+func udmFailure(ctx context.Context, err error) error {
+	udmMutex.Lock()
+	client := udm
+	udmMutex.Unlock()
+
+	if client == nil || !client.Detected() {
+		return err
+	}
+	if probeErr := udmProbe(ctx, client); probeErr != nil {
+		return probeErr
+	}
+	return err
+}
+
+// udmUpdating reports whether the controller is in an update or reboot, during which screens
+// say so calmly and notifications are held back
+func udmUpdating() bool {
+	udmMutex.Lock()
+	client := udm
+	udmMutex.Unlock()
+
+	return client != nil && client.Restarting()
+}
+
+// udmRecovered returns a channel closed when the controller becomes reachable, or nil (blocking
+// forever) if it already was. Screens showing a controller error can wait on it to refresh early.
+func udmRecovered() <-chan struct{} {
+	udmMutex.Lock()
+	client, back, offline := udm, udmBack, udmOffline
+	udmMutex.Unlock()
+
+	switch {
+	case client == nil:
+		return nil
+	case !client.Detected():
+		return udmReachable
+	case offline != nil:
+		return back
+	}
+	return nil
+}
+
+// DeviceActions performs device and client write actions through the shared controller client
+type DeviceActions struct {
+	opts CmdLineOpts
+}
+
+// NewDeviceActions creates device actions using the controller settings in opts
+func NewDeviceActions(opts CmdLineOpts) *DeviceActions {
+	return &DeviceActions{opts: opts}
+}
+
+// LocateDevice starts or stops flashing the LED of a device
+func (d *DeviceActions) LocateDevice(mac string, enable bool) error {
+	ctx := context.Background()
+	client, err := udmClient(ctx, d.opts)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Locate device %s: %t\n", mac, enable)
+	return client.LocateDevice(ctx, mac, enable)
+}
+
+// RestartDevice restarts a device
+func (d *DeviceActions) RestartDevice(mac string) error {
+	ctx := context.Background()
+	client, err := udmClient(ctx, d.opts)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Restarting device %s\n", mac)
+	pushTicker("Restarting " + mac)
+	return client.RestartDevice(ctx, mac)
+}
+
+// BlockClient blocks or unblocks a network client
+func (d *DeviceActions) BlockClient(mac string, block bool) error {
+	ctx := context.Background()
+	client, err := udmClient(ctx, d.opts)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Block client %s: %t\n", mac, block)
+	return client.BlockClient(ctx, mac, block)
+}
+
+// KickClient disconnects a network client
+func (d *DeviceActions) KickClient(mac string) error {
+	ctx := context.Background()
+	client, err := udmClient(ctx, d.opts)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Kicking client %s\n", mac)
+	return client.KickClient(ctx, mac)
+}
@@ -0,0 +1,628 @@
+package display
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/draw"
+	"strings"
+	"time"
+
+	"github.com/llajas/cloudkey/src/network"
+
+	"cloudkey/images"
+	"cloudkey/src/events"
+	"cloudkey/src/metrics"
+)
+
+// wanState describes a single WAN interface in a few characters
+func wanState(w *network.WANInterface) string {
+	switch {
+	case w == nil:
+		return "none"
+	case !w.Enable:
+		return "off"
+	case w.Up:
+		return "up"
+	default:
+		return "down"
+	}
+}
+
+func buildDualWAN(i int, demo bool, opts CmdLineOpts) {
+	screen := setupImage(i)
+
+	draw.Draw(screen, screen.Bounds(), image.Black, image.ZP, draw.Src)
+	drawIcon(screen, 0, images.Load("internet"))
+	drawIcon(screen, 1, images.Load("network"))
+	drawIcon(screen, 2, images.Load("download"))
+
+	if demo {
+		writeRow(screen, 0, "WAN1 up  WAN2 up")
+		writeRow(screen, 1, "Active: WAN1")
+		writeRow(screen, 2, "0 failovers")
+		return
+	}
+
+	run := pipelineContext(i)
+	go func() {
+		defer recoverCrash()
+		var lastActive, failovers int
+
+		for {
+			var linksMsg, activeMsg, usageMsg string
+			var badge string // on the internet icon
+
+			ctx, done := collect(run, "dualwan")
+			client, err := udmClient(ctx, opts)
+			var status *network.DualWANStatus
+			if err == nil {
+				status, err = client.GetDualWANStatus(ctx)
+			}
+			done(err)
+			if run.Err() != nil {
+				return
+			}
+
+			if err != nil {
+				fmt.Printf("Error fetching dual WAN status: %v\n", err)
+				linksMsg = "WAN status"
+				activeMsg = "unavailable"
+				usageMsg = "check logs"
+			} else {
+				activity("dualwan")
+				if lastActive != 0 && status.Active != 0 && status.Active != lastActive {
+					failovers++
+					fmt.Printf("WAN failover: WAN%d -> WAN%d\n", lastActive, status.Active)
+					events.Publish("wan_failover", map[string]int{"from": lastActive, "to": status.Active})
+				}
+				if status.Active != 0 {
+					lastActive = status.Active
+				}
+				SetWANBackup(status.OnBackup())
+				setRelevant("dualwan", status.OnBackup() || wanState(status.WAN1) == "down" || wanState(status.WAN2) == "down")
+				if status.OnBackup() {
+					badge = "warning"
+				}
+
+				linksMsg = fmt.Sprintf("WAN1 %s  WAN2 %s", wanState(status.WAN1), wanState(status.WAN2))
+				switch status.Active {
+				case 1:
+					activeMsg = "Active: WAN1"
+				case 2:
+					activeMsg = "BACKUP: WAN2 !"
+				default:
+					activeMsg = "Active: unknown"
+				}
+				if status.HasLTE {
+					usageMsg = "LTE " + network.FormatBytes(status.LTERx+status.LTETx)
+				} else {
+					usageMsg = fmt.Sprintf("%d failovers", failovers)
+				}
+			}
+
+			redraw(i, func(screen draw.Image) {
+				drawIcon(screen, 0, images.Badged("internet", badge))
+				clearText(screen)
+				writeRow(screen, 0, linksMsg)
+				writeRow(screen, 1, activeMsg)
+				writeRow(screen, 2, usageMsg)
+			})
+
+			if !pause(run, refreshInterval("dualwan")) {
+				return
+			}
+		}
+	}()
+}
+
+func buildVPNSessions(i int, demo bool, opts CmdLineOpts) {
+	screen := setupImage(i)
+
+	draw.Draw(screen, screen.Bounds(), image.Black, image.ZP, draw.Src)
+	drawIcon(screen, 0, images.Load("network"))
+	drawIcon(screen, 1, images.Load("host"))
+	drawIcon(screen, 2, images.Load("clock"))
+
+	if demo {
+		writeRow(screen, 0, "2 VPN sessions")
+		writeRow(screen, 1, "simon (WG)")
+		writeRow(screen, 2, "12 minutes ago")
+		return
+	}
+
+	run := pipelineContext(i)
+	go func() {
+		defer recoverCrash()
+		seen := make(map[string]bool)
+		first := true
+
+		for {
+			var countMsg, userMsg, timeMsg string
+
+			ctx, done := collect(run, "vpn")
+			client, err := udmClient(ctx, opts)
+			var sessions []network.VPNSession
+			if err == nil {
+				sessions, err = client.GetVPNSessions(ctx)
+			}
+			done(err)
+			if run.Err() != nil {
+				return
+			}
+
+			if err != nil {
+				fmt.Printf("Error fetching VPN sessions: %v\n", err)
+				countMsg = "VPN sessions"
+				userMsg = "unavailable"
+				timeMsg = "check logs"
+			} else {
+				activity("vpn")
+				current := make(map[string]bool)
+				for _, s := range sessions {
+					key := fmt.Sprintf("%s/%s/%d", s.User, s.RemoteIP, s.Start)
+					current[key] = true
+					if !first && !seen[key] {
+						fmt.Printf("New VPN session: %s (%s) from %s\n", s.User, s.TypeLabel(), s.RemoteIP)
+						events.Publish("vpn_session", s)
+					}
+				}
+				seen = current
+				first = false
+				setRelevant("vpn", len(sessions) > 0)
+
+				switch len(sessions) {
+				case 0:
+					countMsg = "No VPN sessions"
+				case 1:
+					countMsg = "1 VPN session"
+				default:
+					countMsg = fmt.Sprintf("%d VPN sessions", len(sessions))
+				}
+				if len(sessions) > 0 {
+					userMsg = fmt.Sprintf("%s (%s)", sessions[0].User, sessions[0].TypeLabel())
+					timeMsg = network.GetRelativeTime(sessions[0].Start * 1000)
+				}
+			}
+
+			redraw(i, func(screen draw.Image) {
+				clearText(screen)
+				writeRow(screen, 0, countMsg)
+				writeRow(screen, 1, userMsg)
+				writeRow(screen, 2, timeMsg)
+			})
+
+			if !pause(run, refreshInterval("vpn")) {
+				return
+			}
+		}
+	}()
+}
+
+func buildThreats(i int, demo bool, opts CmdLineOpts) {
+	screen := setupImage(i)
+
+	draw.Draw(screen, screen.Bounds(), image.Black, image.ZP, draw.Src)
+	drawIcon(screen, 0, images.Load("internet"))
+	drawIcon(screen, 1, images.Load("host"))
+	drawIcon(screen, 2, images.Load("clock"))
+
+	if demo {
+		writeRow(screen, 0, "17 blocked (24h)")
+		writeRow(screen, 1, "ET SCAN Nmap")
+		writeRow(screen, 2, "3 hours ago")
+		return
+	}
+
+	run := pipelineContext(i)
+	go func() {
+		defer recoverCrash()
+		var lastTimestamp int64
+
+		for {
+			var countMsg, sigMsg, timeMsg string
+
+			ctx, done := collect(run, "threats")
+			client, err := udmClient(ctx, opts)
+			var threats []network.IPSEvent
+			if err == nil {
+				threats, err = client.GetIPSEvents(ctx, 24*time.Hour)
+			}
+			done(err)
+			if run.Err() != nil {
+				return
+			}
+
+			if err != nil {
+				fmt.Printf("Error fetching IPS events: %v\n", err)
+				countMsg = "IPS events"
+				sigMsg = "unavailable"
+				timeMsg = "check logs"
+			} else {
+				activity("threats")
+				blocked := 0
+				for _, t := range threats {
+					if t.Blocked() {
+						blocked++
+					}
+				}
+				countMsg = fmt.Sprintf("%d blocked (24h)", blocked)
+				setRelevant("threats", len(threats) > 0)
+
+				if len(threats) == 0 {
+					sigMsg = "No threats"
+				} else {
+					latest := threats[0]
+					sigMsg = latest.Signature
+					timeMsg = network.GetRelativeTime(latest.Timestamp)
+					if latest.Timestamp > lastTimestamp {
+						if lastTimestamp != 0 {
+							pushTicker("IPS: " + latest.Signature)
+						}
+						lastTimestamp = latest.Timestamp
+					}
+				}
+			}
+
+			redraw(i, func(screen draw.Image) {
+				clearText(screen)
+				writeRow(screen, 0, countMsg)
+				writeRow(screen, 1, sigMsg)
+				writeRow(screen, 2, timeMsg)
+			})
+
+			if !pause(run, refreshInterval("threats")) {
+				return
+			}
+		}
+	}()
+}
+
+// Release metadata used to look up the controller's target version
+const (
+	controllerFirmwareProduct  = "unifi-controller"
+	controllerFirmwarePlatform = "debian"
+)
+
+func buildUpdates(i int, demo bool, opts CmdLineOpts) {
+	screen := setupImage(i)
+
+	draw.Draw(screen, screen.Bounds(), image.Black, image.ZP, draw.Src)
+	drawIcon(screen, 0, images.Load("host"))
+	drawIcon(screen, 1, images.Load("clock"))
+	drawIcon(screen, 2, images.Load("network"))
+
+	if demo {
+		writeRow(screen, 0, "Ctrl -> 9.0.114")
+		writeRow(screen, 1, "released 2025-03-04")
+		writeRow(screen, 2, "2 devices -> 6.6.77")
+		return
+	}
+
+	run := pipelineContext(i)
+	go func() {
+		defer recoverCrash()
+		for {
+			var ctrlMsg, dateMsg, devMsg string
+
+			ctx, done := collect(run, "updates")
+			client, err := udmClient(ctx, opts)
+			var info *network.SysInfo
+			var devices []network.Device
+			if err == nil {
+				err = network.Parallel(ctx, func(ctx context.Context) (err error) {
+					info, err = client.GetSysInfo(ctx)
+					return err
+				}, func(ctx context.Context) (err error) {
+					devices, err = client.GetDevices(ctx)
+					return err
+				})
+			}
+			done(err)
+			if run.Err() != nil {
+				return
+			}
+
+			if err != nil {
+				fmt.Printf("Error fetching update status: %v\n", err)
+				ctrlMsg = "Updates"
+				dateMsg = "unavailable"
+				devMsg = "check logs"
+			} else {
+				activity("updates")
+				ctrlMsg = "Controller " + info.Version
+				if info.UpdateAvailable {
+					ctrlMsg = "Ctrl update"
+					release, err := network.LatestFirmware(controllerFirmwareProduct, controllerFirmwarePlatform, opts.FirmwareChannel)
+					if err != nil {
+						fmt.Printf("Error fetching controller release: %v\n", err)
+					} else {
+						ctrlMsg = "Ctrl -> " + release.ShortVersion()
+						dateMsg = "released " + release.Created.Format("2006-01-02")
+					}
+				}
+
+				var upgradable []network.Device
+				for _, d := range devices {
+					if d.Upgrade {
+						upgradable = append(upgradable, d)
+					}
+				}
+				if len(upgradable) == 0 {
+					devMsg = "Devices up to date"
+				} else {
+					target := upgradable[0].UpgradeTo
+					release, err := network.LatestFirmware("unifi-firmware", upgradable[0].Model, opts.FirmwareChannel)
+					if err != nil {
+						fmt.Printf("Error fetching %s release: %v\n", upgradable[0].Model, err)
+					} else {
+						target = release.ShortVersion()
+						if dateMsg == "" {
+							dateMsg = "released " + release.Created.Format("2006-01-02")
+						}
+					}
+					devMsg = fmt.Sprintf("%d devices -> %s", len(upgradable), target)
+				}
+				setRelevant("updates", info.UpdateAvailable || len(upgradable) > 0)
+			}
+
+			redraw(i, func(screen draw.Image) {
+				clearText(screen)
+				writeRow(screen, 0, ctrlMsg)
+				writeRow(screen, 1, dateMsg)
+				writeRow(screen, 2, devMsg)
+			})
+
+			if !pause(run, refreshInterval("updates")) {
+				return
+			}
+		}
+	}()
+}
+
+func buildWiFiExperience(i int, demo bool, opts CmdLineOpts) {
+	screen := setupImage(i)
+
+	draw.Draw(screen, screen.Bounds(), image.Black, image.ZP, draw.Src)
+	drawIcon(screen, 0, images.Load("network"))
+	drawIcon(screen, 1, images.Load("download"))
+	drawIcon(screen, 2, images.Load("internet"))
+
+	if demo {
+		writeRow(screen, 0, "ap-garage")
+		writeRow(screen, 1, "Score 71% (4)")
+		writeRow(screen, 2, "home 93%")
+		return
+	}
+
+	run := pipelineContext(i)
+	go func() {
+		defer recoverCrash()
+		for {
+			var apMsg, scoreMsg, ssidMsg string
+
+			ctx, done := collect(run, "wifi")
+			client, err := udmClient(ctx, opts)
+			var exp *network.WiFiExperience
+			if err == nil {
+				exp, err = client.GetWiFiExperience(ctx)
+			}
+			done(err)
+			if run.Err() != nil {
+				return
+			}
+
+			if err != nil {
+				fmt.Printf("Error fetching Wi-Fi experience: %v\n", err)
+				apMsg = "Wi-Fi score"
+				scoreMsg = "unavailable"
+				ssidMsg = "check logs"
+			} else if len(exp.APs) == 0 {
+				activity("wifi")
+				apMsg = "Wi-Fi score"
+				scoreMsg = "no clients"
+			} else {
+				activity("wifi")
+				worst := exp.APs[0]
+				apMsg = worst.Name
+				scoreMsg = fmt.Sprintf("Score %d%% (%d)", worst.Score, worst.Clients)
+				if len(exp.SSIDs) > 0 {
+					ssidMsg = fmt.Sprintf("%s %d%%", exp.SSIDs[0].Name, exp.SSIDs[0].Score)
+				}
+			}
+
+			redraw(i, func(screen draw.Image) {
+				clearText(screen)
+				writeRow(screen, 0, apMsg)
+				writeRow(screen, 1, scoreMsg)
+				writeRow(screen, 2, ssidMsg)
+			})
+
+			if !pause(run, refreshInterval("wifi")) {
+				return
+			}
+		}
+	}()
+}
+
+// airtimeSustain is how many consecutive polls must exceed the threshold before warning
+const airtimeSustain = 3
+
+func buildAirtime(i int, demo bool, opts CmdLineOpts) {
+	screen := setupImage(i)
+
+	draw.Draw(screen, screen.Bounds(), image.Black, image.ZP, draw.Src)
+	drawIcon(screen, 0, images.Load("network"))
+	drawIcon(screen, 1, images.Load("upload"))
+	drawIcon(screen, 2, images.Load("uploadIdle"))
+
+	if demo {
+		writeRow(screen, 0, "ap-living")
+		writeRow(screen, 1, "2.4: 64%  5: 23%")
+		writeRow(screen, 2, "6: 4%")
+		return
+	}
+
+	run := pipelineContext(i)
+	go func() {
+		defer recoverCrash()
+		var busyPolls int
+
+		for {
+			var apMsg, bandsMsg, extraMsg string
+
+			ctx, done := collect(run, "airtime")
+			client, err := udmClient(ctx, opts)
+			var airtime *network.Airtime
+			if err == nil {
+				airtime, err = client.GetBusiestAirtime(ctx)
+			}
+			done(err)
+			if run.Err() != nil {
+				return
+			}
+
+			if err != nil {
+				fmt.Printf("Error fetching airtime: %v\n", err)
+				apMsg = "Airtime"
+				bandsMsg = "unavailable"
+				extraMsg = "check logs"
+			} else {
+				activity("airtime")
+				if airtime.Busiest() >= opts.AirtimeThreshold {
+					busyPolls++
+				} else {
+					busyPolls = 0
+				}
+				busy := busyPolls >= airtimeSustain
+				setWarning("airtime", busy)
+				setRelevant("airtime", busy)
+				if busyPolls == airtimeSustain {
+					fmt.Printf("Airtime warning: %s at %d%%\n", airtime.AP, airtime.Busiest())
+					pushTicker(fmt.Sprintf("%s airtime %d%%", airtime.AP, airtime.Busiest()))
+				}
+
+				apMsg = airtime.AP
+				var bands []string
+				for _, r := range airtime.Radios {
+					bands = append(bands, fmt.Sprintf("%s: %d%%", r.Band(), r.CUTotal))
+				}
+				if len(bands) > 0 {
+					bandsMsg = strings.Join(bands[:min(2, len(bands))], "  ")
+				}
+				if len(bands) > 2 {
+					extraMsg = strings.Join(bands[2:], "  ")
+				}
+				if busy {
+					extraMsg = strings.TrimSpace(extraMsg + "  busy!")
+				}
+			}
+
+			redraw(i, func(screen draw.Image) {
+				clearText(screen)
+				writeRow(screen, 0, apMsg)
+				writeRow(screen, 1, bandsMsg)
+				writeRow(screen, 2, extraMsg)
+			})
+
+			if !pause(run, refreshInterval("airtime")) {
+				return
+			}
+		}
+	}()
+}
+
+// dhcpLines summarizes the DHCP pools, fullest first, flagging it when it nears exhaustion
+func dhcpLines(pools []network.DHCPPool, threshold int) (string, string, string) {
+	if len(pools) == 0 {
+		return "DHCP: no pools", "no network serves", "DHCP"
+	}
+
+	fullest := pools[0]
+	usage := fmt.Sprintf("%s  %d%%", fullest.Network, fullest.Utilization())
+	if fullest.Utilization() >= threshold {
+		usage += "  full!"
+	}
+
+	var others string
+	switch len(pools) {
+	case 1:
+	case 2:
+		others = fmt.Sprintf("%s: %d/%d", pools[1].Network, pools[1].Leased, pools[1].Size)
+	default:
+		others = fmt.Sprintf("%d more pools OK", len(pools)-1)
+		if pools[1].Utilization() >= threshold {
+			others = fmt.Sprintf("%s: %d/%d", pools[1].Network, pools[1].Leased, pools[1].Size)
+		}
+	}
+	return fmt.Sprintf("DHCP: %d/%d", fullest.Leased, fullest.Size), usage, others
+}
+
+func buildDHCP(i int, demo bool, opts CmdLineOpts) {
+	screen := setupImage(i)
+
+	draw.Draw(screen, screen.Bounds(), image.Black, image.ZP, draw.Src)
+	drawIcon(screen, 0, images.Load("network"))
+
+	if demo {
+		writeRow(screen, 0, "DHCP: 143/254")
+		writeRow(screen, 1, "Default  56%")
+		writeRow(screen, 2, "IoT: 12/100")
+		return
+	}
+
+	run := pipelineContext(i)
+	go func() {
+		defer recoverCrash()
+		exhausted := map[string]bool{}
+
+		for {
+			line1, line2, line3 := "DHCP pools", "unavailable", "check logs"
+
+			ctx, done := collect(run, "dhcp")
+			client, err := udmClient(ctx, opts)
+			var pools []network.DHCPPool
+			if err == nil {
+				pools, err = client.GetDHCPPools(ctx)
+			}
+			done(err)
+			if run.Err() != nil {
+				return
+			}
+
+			if err != nil {
+				fmt.Printf("Error fetching DHCP pools: %v\n", err)
+			} else {
+				activity("dhcp")
+				full := false
+				for _, p := range pools {
+					metrics.Set("cloudkey_dhcp_leases", "Connected clients holding an address of the DHCP pool.", float64(p.Leased), "site", opts.UDMSite, "network", p.Network)
+					metrics.Set("cloudkey_dhcp_pool_size", "Addresses in the DHCP pool.", float64(p.Size), "site", opts.UDMSite, "network", p.Network)
+
+					nearly := p.Utilization() >= opts.DHCPThreshold
+					full = full || nearly
+					if nearly && !exhausted[p.Network] {
+						fmt.Printf("DHCP warning: %s pool at %d/%d\n", p.Network, p.Leased, p.Size)
+						pushTicker(fmt.Sprintf("DHCP %s %d/%d", p.Network, p.Leased, p.Size))
+					}
+					exhausted[p.Network] = nearly
+				}
+				setWarning("dhcp", full)
+				setRelevant("dhcp", full)
+
+				line1, line2, line3 = dhcpLines(pools, opts.DHCPThreshold)
+			}
+
+			redraw(i, func(screen draw.Image) {
+				clearText(screen)
+				writeRow(screen, 0, line1)
+				writeRow(screen, 1, line2)
+				writeRow(screen, 2, line3)
+			})
+
+			if !pause(run, refreshInterval("dhcp")) {
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,126 @@
+package display
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image/draw"
+	"image/png"
+	"net/url"
+	"slices"
+	"time"
+
+	"cloudkey/src/api"
+)
+
+const (
+	// upstreamInterval is how often the screens of the upstream instance are fetched
+	upstreamInterval = 5 * time.Second
+	// upstreamRetry is how long to wait before asking an unreachable upstream for its screens again
+	upstreamRetry = 10 * time.Second
+	// upstreamStale is how long the upstream may be unreachable before its screens say so
+	upstreamStale = time.Minute
+)
+
+// buildUpstreamScreens mirrors the screens of another instance through its API instead of
+// collecting any data, so a second display costs the controller and cluster nothing. The
+// screen list is read once: restart the mirror after changing the upstream's screens.
+func buildUpstreamScreens(opts CmdLineOpts) {
+	client := api.NewClient(opts.Upstream, opts.UpstreamToken)
+
+	var names []string
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		list, err := client.Screens(ctx)
+		cancel()
+		if err == nil && len(list) > 0 {
+			names = list
+			break
+		}
+		if err == nil {
+			err = fmt.Errorf("no screens yet")
+		}
+		fmt.Printf("Waiting for upstream %s: %v\n", opts.Upstream, err)
+		time.Sleep(upstreamRetry)
+	}
+
+	indexes := make([]int, len(names))
+	for n, name := range names {
+		indexes[n] = addScreen(name)
+	}
+	fmt.Printf("Mirroring %d screens of %s\n", len(names), opts.Upstream)
+
+	go func() {
+		defer recoverCrash()
+		last := make([][]byte, len(names))
+		lastSeen := time.Now()
+		host := upstreamHost(opts.Upstream)
+		unreachable := false
+
+		for {
+			reached := false
+			var failures []error
+			for n, name := range names {
+				ctx, done := collect(context.Background(), "upstream")
+				body, err := client.Screen(ctx, name)
+				done(err)
+				if err != nil {
+					failures = append(failures, fmt.Errorf("screen %s: %v", name, err))
+					continue
+				}
+				reached = true
+				activity(name)
+				if bytes.Equal(body, last[n]) {
+					continue
+				}
+
+				img, err := png.Decode(bytes.NewReader(body))
+				if err != nil {
+					fmt.Printf("Error decoding upstream screen %s: %v\n", name, err)
+					continue
+				}
+				last[n] = body
+				redraw(indexes[n], func(screen draw.Image) {
+					draw.Draw(screen, screen.Bounds(), img, img.Bounds().Min, draw.Src)
+				})
+			}
+
+			// An unreachable upstream is logged once, rather than every screen on every poll
+			if reached || !unreachable {
+				for _, err := range failures {
+					fmt.Printf("Error fetching upstream %v\n", err)
+				}
+			}
+			if reached && unreachable {
+				fmt.Printf("Upstream %s reachable again\n", opts.Upstream)
+			}
+			unreachable = !reached
+
+			if reached {
+				lastSeen = time.Now()
+			} else if time.Since(lastSeen) >= upstreamStale && slices.ContainsFunc(last, func(b []byte) bool { return b != nil }) {
+				for n := range names {
+					// Drawn again in full once the upstream answers
+					last[n] = nil
+					redraw(indexes[n], func(screen draw.Image) {
+						clearText(screen)
+						writeRow(screen, 0, "Upstream")
+						writeRow(screen, 1, "unreachable")
+						writeRow(screen, 2, host)
+					})
+				}
+			}
+
+			time.Sleep(upstreamInterval)
+		}
+	}()
+}
+
+// upstreamHost shortens the upstream URL to its host for the display
+func upstreamHost(upstream string) string {
+	u, err := url.Parse(upstream)
+	if err != nil || u.Hostname() == "" {
+		return upstream
+	}
+	return u.Hostname()
+}
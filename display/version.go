@@ -0,0 +1,210 @@
+package display
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/draw"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	build "github.com/jnovack/go-version"
+	"github.com/llajas/cloudkey/src/network"
+	"github.com/llajas/cloudkey/src/panel"
+
+	"cloudkey/images"
+)
+
+// releaseURL is the latest published release of cloudkey on GitHub, which leaves out
+// prereleases and drafts
+var releaseURL = "https://api.github.com/repos/llajas/cloudkey/releases/latest"
+
+// releaseTTL is how long LookupCache keeps the latest release, well within the hourly budget
+// GitHub gives clients without a token
+const releaseTTL = 6 * time.Hour
+
+// VersionInfo describes the running build, served by the API
+type VersionInfo struct {
+	Version       string  `json:"version"`
+	Commit        string  `json:"commit"`
+	Built         string  `json:"built"`
+	Go            string  `json:"go"`
+	UptimeSeconds float64 `json:"uptime_seconds"`
+	// Latest is the tag of the latest release, empty until the release check succeeded or
+	// while it is off
+	Latest          string `json:"latest,omitempty"`
+	UpdateAvailable bool   `json:"update_available"`
+	ReleaseURL      string `json:"release_url,omitempty"`
+}
+
+// release is the part of a GitHub release the check reads
+type release struct {
+	Tag string `json:"tag_name"`
+	URL string `json:"html_url"`
+}
+
+var (
+	latestMutex   sync.Mutex
+	latestRelease release
+)
+
+// BuildInfo describes the running build and whether a newer release is out
+func BuildInfo() VersionInfo {
+	latestMutex.Lock()
+	latest := latestRelease
+	latestMutex.Unlock()
+
+	uptime := 0.0
+	if !started.IsZero() {
+		uptime = time.Since(started).Seconds()
+	}
+	return VersionInfo{
+		Version:         build.Version,
+		Commit:          build.Commit,
+		Built:           build.BuildRFC3339,
+		Go:              build.GoVersion,
+		UptimeSeconds:   uptime,
+		Latest:          latest.Tag,
+		UpdateAvailable: newerVersion(latest.Tag, build.Version),
+		ReleaseURL:      latest.URL,
+	}
+}
+
+// startReleaseCheck looks for a newer release of cloudkey every releaseTTL, unless
+// -external-lookups is off. Development builds, versioned v0.0.0, are never out of date.
+func startReleaseCheck(opts CmdLineOpts) {
+	if opts.Demo || !opts.ExternalLookups || build.Version == "v0.0.0" {
+		return
+	}
+
+	go func() {
+		defer recoverCrash()
+		for {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			body, err := network.LookupCache.Get(ctx, releaseURL, releaseTTL)
+			cancel()
+
+			var latest release
+			if err == nil {
+				err = json.Unmarshal(body, &latest)
+			}
+			if err != nil {
+				fmt.Printf("Release check failed: %v\n", err)
+			} else {
+				latestMutex.Lock()
+				changed := latest.Tag != latestRelease.Tag
+				latestRelease = latest
+				latestMutex.Unlock()
+				if changed && newerVersion(latest.Tag, build.Version) {
+					fmt.Printf("cloudkey %s is available (running %s): %s\n", latest.Tag, build.Version, latest.URL)
+				}
+			}
+
+			time.Sleep(releaseTTL)
+		}
+	}()
+}
+
+// newerVersion reports whether the tag latest is a later version than current, both like
+// v1.2.3. Anything that is not a plain version is never newer.
+func newerVersion(latest, current string) bool {
+	l, ok := versionParts(latest)
+	if !ok {
+		return false
+	}
+	c, ok := versionParts(current)
+	if !ok {
+		return false
+	}
+	for i := range l {
+		if l[i] != c[i] {
+			return l[i] > c[i]
+		}
+	}
+	return false
+}
+
+// versionParts splits v1.2.3 into its numbers, ignoring a -rc1 style suffix of current builds
+func versionParts(version string) ([3]int, bool) {
+	var parts [3]int
+	version, _, _ = strings.Cut(strings.TrimPrefix(version, "v"), "-")
+	fields := strings.Split(version, ".")
+	if len(fields) != 3 {
+		return parts, false
+	}
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return parts, false
+		}
+		parts[i] = n
+	}
+	return parts, true
+}
+
+// versionRows lays out the build on the compact rows
+func versionRows(v VersionInfo) []panel.Pair {
+	built := v.Built
+	if t, err := time.Parse(time.RFC3339, v.Built); err == nil {
+		built = t.Format(time.DateOnly)
+	}
+	update := "up to date"
+	switch {
+	case v.UpdateAvailable:
+		update = v.Latest + " out"
+	case v.Latest == "":
+		update = "unknown"
+	}
+	return []panel.Pair{
+		{Label: "cloudkey", Value: v.Version},
+		{Label: "Commit", Value: v.Commit},
+		{Label: "Built", Value: built},
+		{Label: "Go", Value: strings.TrimPrefix(v.Go, "go")},
+		{Label: "Uptime", Value: shortDuration(time.Duration(v.UptimeSeconds) * time.Second)},
+		{Label: "Update", Value: update},
+	}
+}
+
+// buildVersion shows the running build and uptime, with a badge when a newer release is out
+func buildVersion(i int, demo bool, opts CmdLineOpts) {
+	screen := setupImage(i)
+
+	draw.Draw(screen, screen.Bounds(), image.Black, image.ZP, draw.Src)
+	drawIcon(screen, 0, images.Load("host"))
+
+	if demo {
+		drawIcon(screen, 0, images.Badged("host", "up"))
+		canvas(screen).Columns(panel.TextArea, versionRows(VersionInfo{
+			Version:         "v1.8.2",
+			Commit:          "4f2c9e1",
+			Built:           "2026-09-14T08:12:00+00:00",
+			Go:              "go1.25.3",
+			UptimeSeconds:   (3*24*time.Hour + 5*time.Hour).Seconds(),
+			Latest:          "v1.9.0",
+			UpdateAvailable: true,
+		}))
+		return
+	}
+
+	run := pipelineContext(i)
+	go func() {
+		defer recoverCrash()
+		for {
+			v := BuildInfo()
+			badge := ""
+			if v.UpdateAvailable {
+				badge = "up"
+			}
+			redraw(i, func(screen draw.Image) {
+				drawIcon(screen, 0, images.Badged("host", badge))
+				canvas(screen).Columns(panel.TextArea, versionRows(v))
+			})
+			if !pause(run, refreshInterval("version")) {
+				return
+			}
+		}
+	}()
+}
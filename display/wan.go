@@ -0,0 +1,160 @@
+package display
+
+import (
+	"context"
+	"fmt"
+	"image/draw"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/llajas/cloudkey/src/network"
+	"github.com/llajas/cloudkey/src/panel"
+)
+
+// wanProviderTTL is how long the provider of a public address is kept before it is looked up again
+const wanProviderTTL = 24 * time.Hour
+
+// wanProviderSize is the font size of the provider written after the WAN IP
+const wanProviderSize = 7
+
+// cachedProvider is the provider of a public address, as labels from longest to shortest
+type cachedProvider struct {
+	labels []string
+	at     time.Time
+}
+
+var (
+	wanProvidersMutex sync.Mutex
+	wanProviders      = map[string]cachedProvider{}
+)
+
+// wanProvider names the network of a public address from its ASN, falling back to the domain
+// of its reverse DNS name, and returns nil if neither is known. Answers are kept for a day.
+func wanProvider(ip string) []string {
+	wanProvidersMutex.Lock()
+	defer wanProvidersMutex.Unlock()
+
+	if p, ok := wanProviders[ip]; ok && time.Since(p.at) < wanProviderTTL {
+		return p.labels
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var labels []string
+	asn, err := network.LookupASN(ctx, ip)
+	if err == nil {
+		labels = []string{asn.Label(), fmt.Sprintf("AS%d", asn.Number)}
+	} else {
+		fmt.Printf("WAN provider lookup: %v\n", err)
+	}
+	if name, err := network.ReverseDNS(ctx, ip); err == nil {
+		fmt.Printf("WAN IP %s is %s\n", ip, name)
+		if labels == nil {
+			labels = []string{registeredDomain(name)}
+		}
+	}
+
+	// Failures are retried on the next refresh instead of being kept for a day
+	if labels != nil {
+		wanProviders[ip] = cachedProvider{labels: labels, at: time.Now()}
+	}
+	return labels
+}
+
+// wanIPProviders are the providers -wan-ip-providers can list, by name
+var wanIPProviders = map[string]network.WANIPProvider{
+	"ipify":      network.Ipify{},
+	"icanhazip":  network.ICanHazIP{},
+	"cloudflare": network.CloudflareTrace{},
+}
+
+// controllerWANIP is the address of the active WAN as the controller reports it
+type controllerWANIP struct {
+	opts CmdLineOpts
+}
+
+// Name identifies the provider in logs
+func (c controllerWANIP) Name() string {
+	return "controller"
+}
+
+// WANIP asks the shared controller client
+func (c controllerWANIP) WANIP(ctx context.Context) (string, error) {
+	client, err := udmClient(ctx, c.opts)
+	if err != nil {
+		return "", err
+	}
+	return client.GetWANIP(ctx)
+}
+
+// wanIPChain builds the chain of -wan-ip-providers, leaving out the external ones when
+// -external-lookups is off and the controller when there is none. It returns nil if no
+// provider is left.
+func wanIPChain(opts CmdLineOpts) *network.WANIPChain {
+	chain := &network.WANIPChain{Agree: opts.WANIPAgree}
+	for _, name := range opts.WANIPProviders {
+		switch {
+		case name == "controller":
+			if opts.UDMUsername == "" {
+				fmt.Println("WAN IP provider controller left out: no controller credentials")
+				continue
+			}
+			chain.Providers = append(chain.Providers, controllerWANIP{opts})
+		case !opts.ExternalLookups:
+			continue
+		default:
+			chain.Providers = append(chain.Providers, wanIPProviders[name])
+		}
+	}
+	if len(chain.Providers) == 0 {
+		return nil
+	}
+	// Fewer providers than the agreement asks for could never agree
+	chain.Agree = min(chain.Agree, len(chain.Providers))
+	return chain
+}
+
+// registeredDomain shortens a host name to the domain of its owner,
+// e.g. c-73-1-2-3.hsd1.ca.comcast.net to comcast.net and host.isp.co.uk to isp.co.uk
+func registeredDomain(host string) string {
+	labels := strings.Split(host, ".")
+	keep := 2
+	if n := len(labels); n >= 3 && len(labels[n-1]) == 2 && len(labels[n-2]) <= 3 {
+		keep = 3
+	}
+	if len(labels) <= keep {
+		return host
+	}
+	return strings.Join(labels[len(labels)-keep:], ".")
+}
+
+// wanLabel hides the public address as configured by -wan-ip-mask, for panels visitors can see
+func wanLabel(mask, ip string) string {
+	switch mask {
+	case "partial":
+		return network.MaskIP(ip)
+	case "asn":
+		if labels := wanProvider(ip); labels != nil {
+			return labels[0]
+		}
+		return network.MaskIP(ip)
+	default:
+		return ip
+	}
+}
+
+// writeWAN writes the WAN line, followed in small print by the longest provider label that fits
+func writeWAN(screen draw.Image, wan string, provider []string) {
+	writeRow(screen, 2, wan)
+
+	x := 22 + textWidth(wan, 12, "lato-regular") + 4
+	for _, label := range provider {
+		if x+textWidth(label, wanProviderSize, "lato-regular") <= panel.Logical.Max.X {
+			// Share the baseline of the address
+			write(screen, label, x, 46, wanProviderSize, "lato-regular")
+			return
+		}
+	}
+}
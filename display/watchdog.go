@@ -0,0 +1,226 @@
+package display
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/draw"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/llajas/cloudkey/src/panel"
+
+	"cloudkey/src/metrics"
+)
+
+const (
+	// watchdogInterval is how often the screens are checked for an overdue redraw
+	watchdogInterval = 5 * time.Second
+	// watchdogMissed is how many redraw intervals a screen may miss before its pipeline is restarted
+	watchdogMissed = 3
+)
+
+// pipeline is how a screen collects its data: how often its loop redraws, its refresh interval,
+// and how to start it
+type pipeline struct {
+	interval time.Duration
+	build    func(i int, demo bool, opts CmdLineOpts)
+}
+
+// pipelines start the screens watched for a collector that stopped redrawing. Screens redrawn
+// on events are left out, as is the heatmap, whose build also starts the ping monitor.
+var pipelines = map[string]func(i int, demo bool, opts CmdLineOpts){
+	"cpu":          func(i int, demo bool, _ CmdLineOpts) { buildCPUStats(i, demo) },
+	"ram":          func(i int, demo bool, _ CmdLineOpts) { buildRAMStats(i, demo) },
+	"swap":         func(i int, demo bool, _ CmdLineOpts) { buildSwapStats(i, demo) },
+	"network":      buildNetwork,
+	"speedtest":    buildSpeedTest,
+	"speedsummary": buildSpeedtestSummary,
+	"dualwan":      buildDualWAN,
+	"vpn":          buildVPNSessions,
+	"threats":      buildThreats,
+	"updates":      buildUpdates,
+	"wifi":         buildWiFiExperience,
+	"airtime":      buildAirtime,
+	"dhcp":         buildDHCP,
+	"quota":        buildQuota,
+	"discovery":    buildDiscovery,
+	"kubernetes":   buildKubernetes,
+	"gitops":       buildGitOps,
+	"processes":    buildTopProcesses,
+	"version":      buildVersion,
+	"alertmanager": buildAlertmanager,
+	"devices":      buildDevices,
+}
+
+var (
+	watchdogMutex sync.Mutex
+	stuckScreens  []string            // screens restarted that have not redrawn since
+	restarts      map[string]int      // screen name to how often its pipeline was restarted
+	runs          map[int]func()      // screen index to the cancel of its pipeline's run
+	setupImages   map[int]*image.RGBA // screen index to the off-screen copy its restart draws on
+)
+
+// pipelineContext starts a run of the pipeline of screen i and stops the run before it. The
+// loop of a stopped run returns at its next wait or once its request fails, as requests are
+// made under the context; a hung one is given up, so no loop polls next to its replacement.
+func pipelineContext(i int) context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	watchdogMutex.Lock()
+	defer watchdogMutex.Unlock()
+	if runs == nil {
+		runs = map[int]func(){}
+	}
+	if stop := runs[i]; stop != nil {
+		stop()
+	}
+	runs[i] = cancel
+	return ctx
+}
+
+// pause waits d before the next refresh of a pipeline, false if its run was stopped meanwhile
+func pause(run context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-run.Done():
+		return false
+	}
+}
+
+// setupImage is what the build of screen i draws the parts that never change on, such as
+// icons: the front buffer while the screens are built and not shown yet, and an off-screen copy
+// while the watchdog restarts the screen, which restartScreen then draws through redraw.
+func setupImage(i int) *image.RGBA {
+	watchdogMutex.Lock()
+	defer watchdogMutex.Unlock()
+	if img, ok := setupImages[i]; ok {
+		return img
+	}
+	return screens[i].Image()
+}
+
+// startWatchdog restarts the pipeline of a screen that has not redrawn for watchdogMissed of
+// its intervals, as when a request hangs without a timeout or its goroutine died. The run
+// restarted stops, so a collector that was only slow does not keep polling.
+func startWatchdog(opts CmdLineOpts) {
+	watched := map[int]pipeline{}
+	for i, s := range screens {
+		if build, ok := pipelines[s.Name()]; ok {
+			watched[i] = pipeline{refreshInterval(s.Name()), build}
+		}
+	}
+
+	go func() {
+		defer recoverCrash()
+		// A restart counts as a redraw, so a screen is only restarted again after another
+		// watchdogMissed intervals
+		since := map[int]time.Time{}
+		for i := range watched {
+			since[i] = started
+		}
+
+		for {
+			time.Sleep(watchdogInterval)
+
+			for i, p := range watched {
+				s := screens[i]
+				last := s.LastDraw()
+
+				if last.After(since[i]) {
+					since[i] = last
+					screenRecovered(s.Name())
+					continue
+				}
+				if overdue := time.Since(since[i]); overdue >= watchdogMissed*p.interval {
+					fmt.Printf("Warning: screen %s has not redrawn for %s, restarting it\n", s.Name(), overdue.Round(time.Second))
+					restartScreen(i, p, opts)
+					since[i] = time.Now()
+				}
+			}
+		}
+	}()
+}
+
+// restartScreen starts the pipeline of screen i again and says so on it until it redraws
+func restartScreen(i int, p pipeline, opts CmdLineOpts) {
+	name := screens[i].Name()
+
+	watchdogMutex.Lock()
+	if restarts == nil {
+		restarts = map[string]int{}
+	}
+	restarts[name]++
+	if !slices.Contains(stuckScreens, name) {
+		stuckScreens = append(stuckScreens, name)
+	}
+	setWarning("watchdog", true)
+	watchdogMutex.Unlock()
+
+	metrics.Add("cloudkey_screen_restarts_total", "Screen pipelines restarted by the watchdog after missing their redraws.", 1, "screen", name)
+	pushTicker("Restarted screen " + name)
+
+	// The screen is shown, so the build draws its icons on a copy that is then redrawn
+	var setup *image.RGBA
+	screens[i].Frame(func(img *image.RGBA) {
+		setup = image.NewRGBA(img.Bounds())
+		copy(setup.Pix, img.Pix)
+	})
+	watchdogMutex.Lock()
+	if setupImages == nil {
+		setupImages = map[int]*image.RGBA{}
+	}
+	setupImages[i] = setup
+	watchdogMutex.Unlock()
+
+	p.build(i, false, opts)
+
+	watchdogMutex.Lock()
+	delete(setupImages, i)
+	watchdogMutex.Unlock()
+	redraw(i, func(screen draw.Image) {
+		draw.Draw(screen, screen.Bounds(), setup, image.Point{}, draw.Src)
+		clearText(screen)
+		writeRow(screen, 1, "Restarting...")
+	})
+}
+
+// screenRecovered clears the watchdog warning once every restarted screen redrew
+func screenRecovered(name string) {
+	watchdogMutex.Lock()
+	defer watchdogMutex.Unlock()
+
+	n := slices.Index(stuckScreens, name)
+	if n < 0 {
+		return
+	}
+	stuckScreens = slices.Delete(stuckScreens, n, n+1)
+	fmt.Printf("Screen %s redraws again after its restart\n", name)
+	setWarning("watchdog", len(stuckScreens) > 0)
+}
+
+// watchdogRow summarizes the restarts for the diagnostics screen: the screens still stuck, or
+// the restarted ones with how often. ok is false when no screen was ever restarted.
+func watchdogRow() (panel.Pair, bool) {
+	watchdogMutex.Lock()
+	defer watchdogMutex.Unlock()
+
+	if len(stuckScreens) > 0 {
+		return panel.Pair{Label: "Stuck", Value: strings.Join(stuckScreens, " ")}, true
+	}
+	if len(restarts) == 0 {
+		return panel.Pair{}, false
+	}
+	var counts []string
+	for name, n := range restarts {
+		counts = append(counts, fmt.Sprintf("%s %d", name, n))
+	}
+	sort.Strings(counts)
+	return panel.Pair{Label: "Restarts", Value: strings.Join(counts, " ")}, true
+}
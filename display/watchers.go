@@ -0,0 +1,234 @@
+package display
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/llajas/cloudkey/src/network"
+
+	"cloudkey/src/cpu"
+	"cloudkey/src/events"
+	"cloudkey/src/history"
+	"cloudkey/src/leader"
+	"cloudkey/src/metrics"
+)
+
+// cpuInterval is how often /proc/stat is sampled
+const cpuInterval = 5 * time.Second
+
+// cpuSampler is shared by the CPU screen, the health monitor and the metrics
+var cpuSampler = cpu.NewSampler("/proc/stat")
+
+// startCPUSampler samples CPU usage every cpuInterval and exports it as metrics
+func startCPUSampler() {
+	go func() {
+		defer recoverCrash()
+		for {
+			sample, err := cpuSampler.Sample()
+			if err != nil {
+				fmt.Printf("Error sampling CPU: %v\n", err)
+			} else {
+				metrics.Set("cloudkey_cpu_percent", "CPU usage over the last sample interval.", sample.Total, "cpu", "all")
+				for n, c := range sample.Cores {
+					metrics.Set("cloudkey_cpu_percent", "CPU usage over the last sample interval.", c, "cpu", strconv.Itoa(n))
+				}
+				metrics.Set("cloudkey_cpu_steal_percent", "CPU time taken by the hypervisor.", sample.Steal)
+				metrics.Set("cloudkey_cpu_irq_percent", "CPU time spent handling interrupts.", sample.IRQ)
+			}
+
+			time.Sleep(cpuInterval)
+		}
+	}()
+}
+
+// portLinkEvent is published when a device port changes link state
+type portLinkEvent struct {
+	Device string `json:"device"`
+	MAC    string `json:"mac"`
+	Port   int    `json:"port"`
+	Name   string `json:"name"`
+	Up     bool   `json:"up"`
+}
+
+// startPortWatcher polls the controller's port tables and reports link up/down transitions
+func startPortWatcher(opts CmdLineOpts) {
+	go func() {
+		defer recoverCrash()
+		links := make(map[string]bool)
+		first := true
+
+		for {
+			ctx, done := collect(context.Background(), "portwatch")
+			client, err := udmClient(ctx, opts)
+			var devices []network.Device
+			if err == nil {
+				devices, err = client.GetDevices(ctx)
+			}
+			done(err)
+
+			if err != nil {
+				fmt.Printf("Error fetching port tables: %v\n", err)
+			} else {
+				activity("portwatch")
+				for _, d := range devices {
+					name := d.Name
+					if name == "" {
+						name = d.MAC
+					}
+					for _, p := range d.PortTable {
+						if !p.Enable {
+							continue
+						}
+						key := fmt.Sprintf("%s/%d", d.MAC, p.Index)
+						was, known := links[key]
+						links[key] = p.Up
+						if first || !known || was == p.Up {
+							continue
+						}
+
+						state := "down"
+						if p.Up {
+							state = "up"
+						}
+						fmt.Printf("Port link change: %s port %d (%s) %s\n", name, p.Index, p.Name, state)
+						pushTicker(fmt.Sprintf("%s port %d %s", name, p.Index, state))
+						events.Publish("port_link", portLinkEvent{Device: name, MAC: d.MAC, Port: p.Index, Name: p.Name, Up: p.Up})
+					}
+				}
+				first = false
+			}
+
+			time.Sleep(refreshInterval("ports"))
+		}
+	}()
+
+	fmt.Println("Port watcher started (controller port tables -> ticker)")
+}
+
+// newDeviceEvent is published when a MAC address never seen before joins the network
+type newDeviceEvent struct {
+	MAC     string `json:"mac"`
+	Name    string `json:"name"`
+	IP      string `json:"ip"`
+	Network string `json:"network"`
+}
+
+// knownClient records when a MAC address was first seen on the network
+type knownClient struct {
+	MAC       string `json:"mac"`
+	Name      string `json:"name,omitempty"`
+	FirstSeen int64  `json:"first_seen"` // milliseconds since epoch
+}
+
+// allowlisted reports whether a MAC address is a known guest, matching whole addresses
+// or prefixes such as a vendor's OUI (aa:bb:cc)
+func allowlisted(allowlist []string, mac string) bool {
+	for _, allowed := range allowlist {
+		if strings.HasPrefix(mac, strings.ToLower(allowed)) {
+			return true
+		}
+	}
+	return false
+}
+
+// startClientWatcher polls the controller's clients, remembers every MAC address in the state
+// directory and reports the ones joining for the first time. The clients connected when it
+// first runs are the baseline and are not reported.
+func startClientWatcher(opts CmdLineOpts) {
+	store := history.Open[knownClient](filepath.Join(opts.StateDir, "clients.jsonl"))
+
+	go func() {
+		defer recoverCrash()
+		known := map[string]bool{}
+		records, err := store.All()
+		if err != nil {
+			fmt.Printf("Error reading known clients: %v\n", err)
+		}
+		for _, r := range records {
+			known[r.MAC] = true
+		}
+		baseline := len(records) == 0
+
+		for {
+			ctx, done := collect(context.Background(), "clientwatch")
+			client, err := udmClient(ctx, opts)
+			var clients []network.Client
+			if err == nil {
+				clients, err = client.GetClients(ctx)
+			}
+			done(err)
+
+			if err != nil {
+				fmt.Printf("Error fetching clients: %v\n", err)
+			} else {
+				activity("clientwatch")
+				setReading("clients", strconv.Itoa(len(clients)))
+				for _, c := range clients {
+					mac := strings.ToLower(c.MAC)
+					if mac == "" || known[mac] {
+						continue
+					}
+					known[mac] = true
+					if err := store.Append(knownClient{MAC: mac, Name: c.DisplayName(), FirstSeen: time.Now().UnixMilli()}); err != nil {
+						fmt.Printf("Error saving known client: %v\n", err)
+					}
+					if baseline || allowlisted(opts.NewDeviceAllowlist, mac) {
+						continue
+					}
+
+					fmt.Printf("New device: %s (%s) at %s on %s\n", c.DisplayName(), mac, c.IP, c.Network)
+					pushTicker("New device: " + c.DisplayName())
+					events.Publish("new_device", newDeviceEvent{MAC: mac, Name: c.DisplayName(), IP: c.IP, Network: c.Network})
+				}
+				if len(clients) > 0 {
+					baseline = false
+				}
+			}
+
+			time.Sleep(refreshInterval("newdevices"))
+		}
+	}()
+
+	fmt.Println("Client watcher started (new MAC addresses -> ticker and notifications)")
+}
+
+// speedtestScheduleInterval is how often the controller's speedtest schedule is checked
+const speedtestScheduleInterval = time.Hour
+
+// startSpeedtestScheduler keeps the controller's speedtest schedule at the one configured,
+// putting it back when it is changed in the controller UI. Only the leader writes it.
+func startSpeedtestScheduler(opts CmdLineOpts) {
+	// Validated at startup
+	want, _ := network.ParseSpeedtestSchedule(opts.SpeedtestSchedule)
+
+	go func() {
+		defer recoverCrash()
+		for {
+			if leader.IsLeader() {
+				ctx, done := collect(context.Background(), "speedtestschedule")
+				client, err := udmClient(ctx, opts)
+				var current *network.SpeedtestSchedule
+				if err == nil {
+					current, err = client.GetSpeedtestSchedule(ctx)
+				}
+				if err == nil && !current.Matches(want) {
+					fmt.Printf("Speedtest schedule is %s, setting it to %s\n", current, want)
+					err = client.SetSpeedtestSchedule(ctx, want)
+				}
+				done(err)
+
+				if err != nil {
+					fmt.Printf("Error enforcing the speedtest schedule: %v\n", err)
+				}
+			}
+
+			time.Sleep(speedtestScheduleInterval)
+		}
+	}()
+
+	fmt.Printf("Speedtest schedule enforced: %s\n", want)
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/llajas/cloudkey/src/network"
+
+	"cloudkey/src/history"
+)
+
+// exportCommand runs `cloudkey export speedtests [flags]` and returns the exit code
+func exportCommand(args []string) int {
+	if len(args) == 0 || args[0] != "speedtests" {
+		fmt.Println("Usage: cloudkey export speedtests [--since 30d] [--format csv|json] [--source history|controller] [--output file]")
+		return 2
+	}
+
+	fs := flag.NewFlagSet("export speedtests", flag.ContinueOnError)
+	since := fs.String("since", "30d", "how far back to export, e.g. 30d or 12h")
+	format := fs.String("format", "csv", "output format: csv or json")
+	source := fs.String("source", "history", "where to read results: history (local) or controller (UDM archive)")
+	output := fs.String("output", "-", "file to write, - for standard output")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	window, err := parseSince(*since)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 2
+	}
+	if *format != "csv" && *format != "json" {
+		fmt.Printf("Error: format must be csv or json, got %q\n", *format)
+		return 2
+	}
+	if *source != "history" && *source != "controller" {
+		fmt.Printf("Error: source must be history or controller, got %q\n", *source)
+		return 2
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "-" {
+		file, err := os.Create(*output)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return 1
+		}
+		defer file.Close()
+		w = file
+	}
+
+	var sw speedtestWriter
+	if *format == "json" {
+		sw = newSpeedtestJSONWriter(w)
+	} else {
+		sw = newSpeedtestCSVWriter(w)
+	}
+
+	start := time.Now().Add(-window)
+	count := 0
+	write := func(results []network.SpeedtestResult) error {
+		count += len(results)
+		return sw.Write(results)
+	}
+	if *source == "controller" {
+		err = controllerSpeedtestsSince(start, write)
+	} else {
+		err = speedtestsSince(start, write)
+	}
+	if err == nil {
+		err = sw.Close()
+	}
+	if err != nil {
+		fmt.Printf("Error: failed to export speedtests: %v\n", err)
+		return 1
+	}
+	if *output != "-" {
+		fmt.Printf("Exported %d speedtests to %s\n", count, *output)
+	}
+	return 0
+}
+
+// parseSince parses a duration, also accepting whole days such as 30d
+func parseSince(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid --since %q", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid --since %q", value)
+	}
+	return d, nil
+}
+
+// speedtestsSince reads the speedtest history recorded by the speedtest screen, oldest first
+func speedtestsSince(start time.Time, each func([]network.SpeedtestResult) error) error {
+	store := history.Open[network.SpeedtestResult](filepath.Join(opts.StateDir, "speedtests.jsonl"))
+	all, err := store.All()
+	if err != nil {
+		return fmt.Errorf("failed to read speedtest history: %v", err)
+	}
+
+	var results []network.SpeedtestResult
+	for _, r := range all {
+		if r.Timestamp >= start.UnixMilli() {
+			results = append(results, r)
+		}
+	}
+	return each(results)
+}
+
+// controllerSpeedtestsSince reads the speedtest archive of the UDM controller in chunks, oldest first
+func controllerSpeedtestsSince(start time.Time, each func([]network.SpeedtestResult) error) error {
+	if opts.UDMBaseURL == "" || opts.UDMUsername == "" || opts.UDMPassword == "" {
+		return fmt.Errorf("the controller source needs the UDM URL, username and password")
+	}
+	client, err := network.NewUDMProClient(opts.UDMBaseURL, opts.UDMUsername, opts.UDMPassword, opts.UDMSite, opts.UDMVersion, opts.UDMTimeouts())
+	if err != nil {
+		return fmt.Errorf("failed to create client: %v", err)
+	}
+	ctx := context.Background()
+	if err := client.Login(ctx); err != nil {
+		return fmt.Errorf("login failed: %v", err)
+	}
+	return client.WalkSpeedtestArchive(ctx, start.UnixMilli(), time.Now().UnixMilli(), each)
+}
+
+// speedtestWriter writes speedtest results as they arrive so long exports are never held in memory
+type speedtestWriter interface {
+	Write(results []network.SpeedtestResult) error
+	Close() error
+}
+
+// exportedSpeedtest adds a readable time to a speedtest result
+type exportedSpeedtest struct {
+	Time string `json:"time"`
+	network.SpeedtestResult
+}
+
+// speedtestJSONWriter writes a JSON array one element at a time
+type speedtestJSONWriter struct {
+	w     io.Writer
+	count int
+}
+
+func newSpeedtestJSONWriter(w io.Writer) *speedtestJSONWriter {
+	return &speedtestJSONWriter{w: w}
+}
+
+func (j *speedtestJSONWriter) Write(results []network.SpeedtestResult) error {
+	for _, r := range results {
+		data, err := json.MarshalIndent(exportedSpeedtest{Time: time.UnixMilli(r.Timestamp).Format(time.RFC3339), SpeedtestResult: r}, "  ", "  ")
+		if err != nil {
+			return err
+		}
+		sep := ",\n  "
+		if j.count == 0 {
+			sep = "[\n  "
+		}
+		if _, err := fmt.Fprintf(j.w, "%s%s", sep, data); err != nil {
+			return err
+		}
+		j.count++
+	}
+	return nil
+}
+
+func (j *speedtestJSONWriter) Close() error {
+	if j.count == 0 {
+		_, err := fmt.Fprintln(j.w, "[]")
+		return err
+	}
+	_, err := fmt.Fprintln(j.w, "\n]")
+	return err
+}
+
+// speedtestCSVWriter writes a header followed by one row per result
+type speedtestCSVWriter struct {
+	cw *csv.Writer
+}
+
+func newSpeedtestCSVWriter(w io.Writer) *speedtestCSVWriter {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"time", "download_mbps", "upload_mbps", "latency_ms"})
+	return &speedtestCSVWriter{cw: cw}
+}
+
+func (c *speedtestCSVWriter) Write(results []network.SpeedtestResult) error {
+	for _, r := range results {
+		c.cw.Write([]string{
+			time.UnixMilli(r.Timestamp).Format(time.RFC3339),
+			strconv.FormatFloat(r.DownloadMbps, 'f', 2, 64),
+			strconv.FormatFloat(r.UploadMbps, 'f', 2, 64),
+			strconv.FormatFloat(r.LatencyMs, 'f', 1, 64),
+		})
+	}
+	c.cw.Flush()
+	return c.cw.Error()
+}
+
+func (c *speedtestCSVWriter) Close() error {
+	c.cw.Flush()
+	return c.cw.Error()
+}
@@ -0,0 +1,101 @@
+package images
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// badges are 7x7 glyphs drawn over the bottom right corner of an icon to show its state
+var badges = map[string][7]string{
+	"warning": {
+		"...#...",
+		"..###..",
+		"..#.#..",
+		".##.##.",
+		".#####.",
+		"###.###",
+		"#######",
+	},
+	"up": {
+		"...#...",
+		"..###..",
+		".#####.",
+		"#######",
+		"..###..",
+		"..###..",
+		"..###..",
+	},
+	"down": {
+		"..###..",
+		"..###..",
+		"..###..",
+		"#######",
+		".#####.",
+		"..###..",
+		"...#...",
+	},
+	"stale": {
+		".#####.",
+		"#..#..#",
+		"#..#..#",
+		"#..##.#",
+		"#.....#",
+		"#.....#",
+		".#####.",
+	},
+}
+
+var badged = map[[2]string]image.Image{}
+
+// Badged returns the named icon with a badge in its bottom right corner, e.g. Badged("internet",
+// "warning"). The badge is white with a black outline so it stands out over any icon. An empty
+// or unknown badge returns the icon unchanged. Like Load, never draw into the result.
+func Badged(name, badge string) image.Image {
+	icon := Load(name)
+	glyph, ok := badges[badge]
+	if !ok {
+		return icon
+	}
+
+	decodedMutex.Lock()
+	defer decodedMutex.Unlock()
+
+	key := [2]string{name, badge}
+	if img, ok := badged[key]; ok {
+		return img
+	}
+
+	b := icon.Bounds()
+	img := image.NewRGBA(b)
+	draw.Draw(img, b, icon, b.Min, draw.Src)
+
+	mask, outline := badgeMasks(glyph)
+	at := image.Pt(b.Max.X-7, b.Max.Y-7)
+	draw.DrawMask(img, outline.Bounds().Add(at), image.Black, image.ZP, outline, outline.Bounds().Min, draw.Over)
+	draw.DrawMask(img, mask.Bounds().Add(at), image.White, image.ZP, mask, mask.Bounds().Min, draw.Over)
+
+	badged[key] = img
+	return img
+}
+
+// badgeMasks returns the alpha mask of a glyph and of its outline, the glyph grown by a
+// pixel in every direction and so one pixel larger on each side
+func badgeMasks(glyph [7]string) (*image.Alpha, *image.Alpha) {
+	mask := image.NewAlpha(image.Rect(0, 0, 7, 7))
+	outline := image.NewAlpha(image.Rect(-1, -1, 8, 8))
+	for y, row := range glyph {
+		for x, c := range row {
+			if c != '#' {
+				continue
+			}
+			mask.SetAlpha(x, y, color.Alpha{A: 255})
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					outline.SetAlpha(x+dx, y+dy, color.Alpha{A: 255})
+				}
+			}
+		}
+	}
+	return mask, outline
+}
@@ -6,18 +6,32 @@ import (
 	"image/png"
 	"log"
 	"strings"
+	"sync"
 )
 
 var assets map[string]string
 
-// Load ...
+var (
+	decodedMutex sync.Mutex
+	decoded      = map[string]image.Image{}
+)
+
+// Load returns the named image. It is decoded once and shared, so draw from it but never into it.
 func Load(name string) image.Image {
+	decodedMutex.Lock()
+	defer decodedMutex.Unlock()
+
+	if img, ok := decoded[name]; ok {
+		return img
+	}
+
 	reader := base64.NewDecoder(base64.StdEncoding, strings.NewReader(assets[name]))
 	img, err := png.Decode(reader)
 	if err != nil {
 		log.Fatal(err)
 		return nil
 	}
+	decoded[name] = img
 	return img
 }
 
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"os"
+	"strings"
+	"time"
+
+	"cloudkey/display"
+	"cloudkey/src/api"
+	"github.com/llajas/cloudkey/src/panel/framebuffer"
+)
+
+// recordWarmup is how long the demo screens get to draw before the first frame is recorded
+const recordWarmup = 2 * time.Second
+
+// recordCommand runs `cloudkey record [flags]` and returns the exit code
+func recordCommand(args []string) int {
+	fs := flag.NewFlagSet("record", flag.ContinueOnError)
+	seconds := fs.Int("seconds", 30, "how long to record")
+	fps := fs.Int("fps", 4, "frames captured per second")
+	source := fs.String("source", "demo", "what to record: demo (simulated demo screens), framebuffer (the live panel) or the URL of an instance's API")
+	token := fs.String("token", "", "API read token, when recording an instance through its API")
+	output := fs.String("output", "cloudkey.gif", "GIF file to write")
+	if err := fs.Parse(args); err != nil || fs.NArg() > 0 {
+		fmt.Println("Usage: cloudkey record [--seconds 30] [--fps 4] [--source demo|framebuffer|URL] [--token token] [--output cloudkey.gif]")
+		return 2
+	}
+	if *seconds <= 0 || *fps <= 0 || *fps > 25 {
+		fmt.Println("Error: seconds must be positive and fps between 1 and 25")
+		return 2
+	}
+
+	capture, err := frameSource(*source, *token)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("Recording %ds from %s...\n", *seconds, *source)
+	anim, err := record(capture, time.Duration(*seconds)*time.Second, *fps)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+
+	file, err := os.Create(*output)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+	if err := gif.EncodeAll(file, anim); err != nil {
+		file.Close()
+		fmt.Printf("Error: failed to write %s: %v\n", *output, err)
+		return 1
+	}
+	if err := file.Close(); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Recorded %d frames to %s\n", len(anim.Image), *output)
+	return 0
+}
+
+// frameSource returns a function capturing the panel of source
+func frameSource(source, token string) (func() (image.Image, error), error) {
+	switch {
+	case source == "demo":
+		// Run the demo screens in memory, rotating like on the panel
+		demo := opts
+		demo.Demo = true
+		demo.APIListen = ""
+		dir, err := os.MkdirTemp("", "cloudkey-record")
+		if err != nil {
+			return nil, err
+		}
+		demo.StateDir = dir
+		go display.Record(demo)
+		// Let the screens draw before the first frame
+		time.Sleep(recordWarmup)
+		return func() (image.Image, error) { return display.Snapshot(), nil }, nil
+
+	case source == "framebuffer":
+		fb, err := framebuffer.Open(opts.Framebuffer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %v", opts.Framebuffer, err)
+		}
+		return func() (image.Image, error) {
+			frame := image.NewRGBA(fb.Bounds())
+			draw.Draw(frame, frame.Bounds(), fb, fb.Bounds().Min, draw.Src)
+			return frame, nil
+		}, nil
+
+	case strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://"):
+		client := api.NewClient(source, token)
+		return func() (image.Image, error) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			body, err := client.Frame(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return png.Decode(bytes.NewReader(body))
+		}, nil
+	}
+	return nil, fmt.Errorf("source must be demo, framebuffer or an http(s) URL, got %q", source)
+}
+
+// record captures fps frames a second for length. A frame identical to the previous one
+// lengthens it instead of being added, so still screens cost almost nothing.
+func record(capture func() (image.Image, error), length time.Duration, fps int) (*gif.GIF, error) {
+	anim := &gif.GIF{}
+	interval := time.Second / time.Duration(fps)
+	delay := 100 / fps // in hundredths of a second
+	var last *image.Paletted
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for end := time.Now().Add(length); time.Now().Before(end); <-ticker.C {
+		img, err := capture()
+		if err != nil {
+			return nil, fmt.Errorf("failed to capture a frame: %v", err)
+		}
+
+		frame := image.NewPaletted(img.Bounds(), gifPalette)
+		draw.Draw(frame, frame.Bounds(), img, img.Bounds().Min, draw.Src)
+		if last != nil && bytes.Equal(frame.Pix, last.Pix) {
+			anim.Delay[len(anim.Delay)-1] += delay
+			continue
+		}
+		anim.Image = append(anim.Image, frame)
+		anim.Delay = append(anim.Delay, delay)
+		last = frame
+	}
+	if len(anim.Image) == 0 {
+		return nil, fmt.Errorf("no frames captured")
+	}
+	return anim, nil
+}
+
+// gifPalette is the web-safe palette, for the colored screens such as the heatmap, completed
+// with grays for the anti-aliased text that makes up most of the panel
+var gifPalette = func() color.Palette {
+	p := append(color.Palette{}, palette.WebSafe...)
+	for level := 0; len(p) < 256; level += 6 {
+		if level%0x33 != 0 {
+			p = append(p, color.Gray{Y: uint8(level)})
+		}
+	}
+	return p
+}()
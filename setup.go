@@ -0,0 +1,342 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/llajas/cloudkey/src/network"
+	"golang.org/x/term"
+
+	"cloudkey/src/envfile"
+	"cloudkey/src/leds"
+)
+
+// setupProbeTimeout bounds each request the wizard sends to a candidate controller
+const setupProbeTimeout = 3 * time.Second
+
+// setupScreens are the optional screens the wizard offers, by flag name
+var setupScreens = []string{
+	"speedtest-summary-enabled",
+	"latency-heatmap-enabled",
+	"dual-wan-enabled",
+	"vpn-enabled",
+	"threats-enabled",
+	"updates-enabled",
+	"wifi-enabled",
+	"airtime-enabled",
+	"dhcp-enabled",
+	"quota-enabled",
+	"discovery-enabled",
+	"ticker-enabled",
+	"top-processes-enabled",
+	"diagnostics-enabled",
+	"k8s-enabled",
+	"gitops-enabled",
+}
+
+// wizard asks questions on a terminal and collects the answers as flag values
+type wizard struct {
+	in      *bufio.Reader
+	out     io.Writer
+	answers map[string]string // flag name to value
+	order   []string          // flag names in the order they were answered
+	eof     bool              // input ended, so every further question takes its default
+}
+
+// setupCommand runs `cloudkey setup [file]` and returns the exit code
+func setupCommand(args []string) int {
+	path := defaultConfigFile
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	w := &wizard{in: bufio.NewReader(os.Stdin), out: os.Stdout, answers: map[string]string{}}
+	fmt.Fprintf(w.out, "cloudkey setup writes %s. Press Enter to keep the [default].\n", path)
+	if _, err := os.Stat(path); err == nil && !w.confirm(fmt.Sprintf("%s exists. Replace it?", path), false) {
+		return 1
+	}
+
+	w.controller()
+	w.hardware()
+	w.screens()
+
+	if errs := w.validate(); len(errs) > 0 {
+		for _, err := range errs {
+			fmt.Fprintf(w.out, "Configuration error: %s\n", optionHint(err))
+		}
+		return 1
+	}
+	if err := w.write(path); err != nil {
+		fmt.Fprintf(w.out, "Error: %v\n", err)
+		return 1
+	}
+
+	fmt.Fprintf(w.out, "\nWrote %s. Start cloudkey with it:\n\n  systemctl restart cloudkey\n", path)
+	return 0
+}
+
+// controller finds the UDM, then tests the credentials against it
+func (w *wizard) controller() {
+	fmt.Fprintln(w.out, "\n== Controller ==")
+
+	baseURL := flag.Lookup("udm-baseurl").Value.String()
+	for _, candidate := range controllerCandidates(baseURL) {
+		fmt.Fprintf(w.out, "Looking for a controller at %s\n", candidate)
+		if family, err := probeController(candidate); err != nil {
+			fmt.Fprintf(w.out, "  not found: %v\n", err)
+		} else {
+			fmt.Fprintf(w.out, "  found a %s controller\n", family)
+			baseURL = candidate
+			break
+		}
+	}
+
+	for {
+		w.set("udm-baseurl", w.ask("Controller URL", baseURL))
+		w.set("udm-username", w.ask("Username (a local, read-only account is enough)", flag.Lookup("udm-username").Value.String()))
+		w.set("udm-password", w.askSecret("Password"))
+
+		fmt.Fprintln(w.out, "Logging in")
+		version, sites, err := testLogin(w.answers["udm-baseurl"], w.answers["udm-username"], w.answers["udm-password"])
+		if err == nil {
+			fmt.Fprintf(w.out, "  OK, Network application %s\n", version)
+			if version != "" {
+				w.set("udm-version", version)
+			}
+			w.site(sites)
+			return
+		}
+		fmt.Fprintf(w.out, "  failed: %v\n", err)
+		if !w.confirm("Try again?", true) || w.eof {
+			return
+		}
+		baseURL = w.answers["udm-baseurl"]
+	}
+}
+
+// site picks the site to show. The account's sites are listed by the name the UniFi interface
+// shows, and the answer is stored as the site ID, so the configuration does not depend on
+// resolving the name at every start.
+func (w *wizard) site(sites []network.Site) {
+	configured := flag.Lookup("udm-site").Value.String()
+	current, found := network.FindSite(sites, configured)
+	if len(sites) == 0 || len(sites) == 1 && found {
+		return
+	}
+
+	fmt.Fprintln(w.out, "Sites:")
+	for _, s := range sites {
+		fmt.Fprintf(w.out, "  %s\n", s)
+	}
+	def := sites[0].Desc
+	if found {
+		def = current.Desc
+	}
+	for {
+		answer := w.ask("Site", def)
+		if s, ok := network.FindSite(sites, answer); ok {
+			w.set("udm-site", s.Name)
+			return
+		}
+		fmt.Fprintf(w.out, "  no site %q\n", answer)
+		if w.eof {
+			return
+		}
+	}
+}
+
+// hardware reports the framebuffer and LEDs, falling back to headless without a panel
+func (w *wizard) hardware() {
+	fmt.Fprintln(w.out, "\n== Hardware ==")
+
+	device := flag.Lookup("framebuffer").Value.String()
+	if _, err := os.Stat(device); err == nil {
+		fmt.Fprintf(w.out, "Framebuffer: %s\n", device)
+	} else {
+		fmt.Fprintf(w.out, "Framebuffer: %s not found\n", device)
+		if w.confirm("Run headless, without screen and LEDs (API and metrics only)?", true) {
+			w.set("headless", "true")
+			return
+		}
+	}
+
+	found := leds.DiscoverLEDs()
+	if len(found) == 0 {
+		fmt.Fprintf(w.out, "LEDs: none found in %s\n", flag.Lookup("leds-dir").Value.String())
+	} else {
+		fmt.Fprintf(w.out, "LEDs: %s (%s profile)\n", strings.Join(found, ", "), leds.DetectProfile().Name)
+	}
+
+	names := leds.ProfileNames()
+	for {
+		profile := w.ask(fmt.Sprintf("LED profile (auto, %s)", strings.Join(names, ", ")), flag.Lookup("led-profile").Value.String())
+		if profile == "auto" || slices.Contains(names, profile) {
+			w.set("led-profile", profile)
+			return
+		}
+		fmt.Fprintf(w.out, "Unknown profile %q\n", profile)
+	}
+}
+
+// screens asks which optional screens to show
+func (w *wizard) screens() {
+	fmt.Fprintln(w.out, "\n== Screens ==")
+	fmt.Fprintln(w.out, "CPU, RAM, swap, network and speedtest are always shown.")
+
+	for _, name := range setupScreens {
+		f := flag.Lookup(name)
+		enabled := w.confirm(strings.ToUpper(f.Usage[:1])+f.Usage[1:]+"?", f.Value.String() == "true")
+		w.set(name, fmt.Sprint(enabled))
+	}
+}
+
+// validate applies the answers to the options and checks them like `cloudkey config validate`
+func (w *wizard) validate() []error {
+	for _, name := range w.order {
+		if err := flag.Set(name, w.answers[name]); err != nil {
+			return []error{fmt.Errorf("%s: %v", name, err)}
+		}
+	}
+	return opts.Validate()
+}
+
+// write saves the answers as an environment file readable only by its owner, as it holds the password
+func (w *wizard) write(path string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Written by cloudkey setup on %s\n", time.Now().Format("2006-01-02"))
+	for _, name := range w.order {
+		fmt.Fprintf(&b, "%s=%s\n", envName(name), envfile.Quote(w.answers[name]))
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// set records the answer for a flag, keeping the order of first answers
+func (w *wizard) set(name, value string) {
+	if _, ok := w.answers[name]; !ok {
+		w.order = append(w.order, name)
+	}
+	w.answers[name] = value
+}
+
+// ask reads a line, returning def when it is empty
+func (w *wizard) ask(question, def string) string {
+	if def != "" {
+		fmt.Fprintf(w.out, "%s [%s]: ", question, def)
+	} else {
+		fmt.Fprintf(w.out, "%s: ", question)
+	}
+	line, err := w.in.ReadString('\n')
+	if err != nil {
+		w.eof = true
+		fmt.Fprintln(w.out)
+	}
+	if line = strings.TrimSpace(line); line != "" {
+		return line
+	}
+	return def
+}
+
+// askSecret reads a line without echoing it when stdin is a terminal
+func (w *wizard) askSecret(question string) string {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return w.ask(question, "")
+	}
+
+	fmt.Fprintf(w.out, "%s: ", question)
+	secret, err := term.ReadPassword(fd)
+	fmt.Fprintln(w.out)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(secret))
+}
+
+// confirm asks a yes/no question
+func (w *wizard) confirm(question string, def bool) bool {
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	for {
+		switch strings.ToLower(w.ask(question+" ("+hint+")", "")) {
+		case "":
+			return def
+		default:
+			if w.eof {
+				return def
+			}
+		case "y", "yes":
+			return true
+		case "n", "no":
+			return false
+		}
+	}
+}
+
+// envName is the environment variable setting a flag
+func envName(flagName string) string {
+	return "CLOUDKEY_" + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// controllerCandidates lists where a controller is likely to be: the configured URL, then
+// the first address of the local network, where a UDM usually routes it
+func controllerCandidates(configured string) []string {
+	candidates := []string{configured}
+	if lan, err := network.LANIP(); err == nil {
+		if i := strings.LastIndex(lan, "."); i > 0 {
+			candidates = append(candidates, "https://"+lan[:i]+".1")
+		}
+	}
+	return slices.Compact(candidates)
+}
+
+// probeController checks whether a controller answers at baseURL and returns its API family
+func probeController(baseURL string) (string, error) {
+	client, err := network.NewUDMProClient(baseURL, "", "", flag.Lookup("udm-site").Value.String(), flag.Lookup("udm-version").Value.String(), network.Timeouts{Detect: setupProbeTimeout})
+	if err != nil {
+		return "", err
+	}
+	if err := client.Detect(context.Background()); err != nil {
+		return "", err
+	}
+	_, caps := client.Controller()
+	return caps.Family, nil
+}
+
+// testLogin logs in to the controller and returns the version it reports with the sites the
+// account can see. A configured site the controller does not know is left for the wizard to
+// correct rather than failing the login.
+func testLogin(baseURL, username, password string) (string, []network.Site, error) {
+	client, err := network.NewUDMProClient(baseURL, username, password, flag.Lookup("udm-site").Value.String(), flag.Lookup("udm-version").Value.String(), network.DefaultTimeouts)
+	if err != nil {
+		return "", nil, err
+	}
+	ctx := context.Background()
+	if err := client.Login(ctx); err != nil && !strings.Contains(err.Error(), "does not exist on the controller") {
+		return "", nil, err
+	}
+	sites, err := client.ListSites(ctx)
+	if err != nil {
+		fmt.Printf("  could not list the sites: %v\n", err)
+	}
+	// Login already switched to the reported version where it differs from the configured one
+	version, _ := client.Controller()
+	return version, sites, nil
+}
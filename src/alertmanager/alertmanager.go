@@ -0,0 +1,107 @@
+// Package alertmanager reads the alerts firing in a Prometheus Alertmanager through its v2 API.
+package alertmanager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"cloudkey/src/chaos"
+	"cloudkey/src/ratelimit"
+	"cloudkey/src/tracing"
+)
+
+// Alert is a group of firing alerts sharing a name and severity
+type Alert struct {
+	Name     string `json:"name"`
+	Severity string `json:"severity"` // the severity label, e.g. critical, warning or info
+	Count    int    `json:"count"`
+}
+
+// Client reads alerts from one Alertmanager. Credentials in the URL are sent as basic auth.
+type Client struct {
+	URL        string
+	HTTPClient *http.Client
+}
+
+// NewClient returns a client of the Alertmanager at baseURL, e.g. http://alertmanager:9093
+func NewClient(baseURL string) *Client {
+	return &Client{
+		URL: strings.TrimRight(baseURL, "/"),
+		HTTPClient: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: tracing.Transport(chaos.Transport(ratelimit.Transport(nil))),
+		},
+	}
+}
+
+// apiAlert is an alert as the v2 API returns it
+type apiAlert struct {
+	Labels map[string]string `json:"labels"`
+}
+
+// Firing returns the alerts that are firing, neither silenced nor inhibited, and match every
+// filter (Alertmanager matchers such as team="infra"), grouped by name and severity. The most
+// severe come first, then the most numerous.
+func (c *Client) Firing(ctx context.Context, filters []string) ([]Alert, error) {
+	query := url.Values{"active": {"true"}, "silenced": {"false"}, "inhibited": {"false"}}
+	for _, f := range filters {
+		query.Add("filter", f)
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", c.URL+"/api/v2/alerts?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("alertmanager: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("alertmanager answered %s", resp.Status)
+	}
+
+	var firing []apiAlert
+	if err := json.NewDecoder(resp.Body).Decode(&firing); err != nil {
+		return nil, fmt.Errorf("failed to parse alerts: %v", err)
+	}
+
+	groups := map[Alert]int{}
+	for _, a := range firing {
+		groups[Alert{Name: a.Labels["alertname"], Severity: a.Labels["severity"]}]++
+	}
+	alerts := make([]Alert, 0, len(groups))
+	for a, n := range groups {
+		a.Count = n
+		alerts = append(alerts, a)
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		a, b := alerts[i], alerts[j]
+		if SeverityRank(a.Severity) != SeverityRank(b.Severity) {
+			return SeverityRank(a.Severity) > SeverityRank(b.Severity)
+		}
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Name < b.Name
+	})
+	return alerts, nil
+}
+
+// SeverityRank orders severity labels: 2 for critical, 1 for warning and 0 for anything else
+func SeverityRank(severity string) int {
+	switch strings.ToLower(severity) {
+	case "critical":
+		return 2
+	case "warning":
+		return 1
+	}
+	return 0
+}
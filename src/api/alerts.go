@@ -0,0 +1,16 @@
+package api
+
+import "net/http"
+
+var alertAcknowledger func()
+
+// SetAlertAcknowledger sets the function that silences the current audible alert
+func SetAlertAcknowledger(acknowledge func()) {
+	alertAcknowledger = acknowledge
+}
+
+// handleAlertAck silences the beeper until the health next becomes critical
+func handleAlertAck(w http.ResponseWriter, r *http.Request) {
+	alertAcknowledger()
+	writeJSON(w, http.StatusOK, map[string]string{"status": "acknowledged"})
+}
@@ -0,0 +1,176 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"cloudkey/src/events"
+)
+
+// Config holds the settings for the local HTTP API
+type Config struct {
+	Addr          string
+	ReadTokens    []string // tokens granting ScopeRead
+	ControlTokens []string // tokens granting ScopeControl
+	TLS           bool
+	TLSCert       string // generated as a self-signed certificate if missing
+	TLSKey        string
+	Disabled      []string // endpoint names to leave unregistered
+	LiveMirror    bool     // serve the experimental WebSocket mirror at /ws/framebuffer and /mirror
+}
+
+// Server is the local HTTP API
+type Server struct {
+	config Config
+	mux    *http.ServeMux
+}
+
+// Start serves the local HTTP API in the background
+func Start(config Config) error {
+	s := &Server{config: config, mux: http.NewServeMux()}
+
+	s.handle("events", "/api/events", ScopeRead, http.HandlerFunc(handleEvents))
+	s.handle("metrics", "GET /metrics", ScopeRead, http.HandlerFunc(handleMetrics))
+	if livenessSource != nil {
+		s.handle("healthz", "GET /healthz", ScopePublic, http.HandlerFunc(handleHealthz))
+	}
+	if statusPageSource != nil {
+		s.handle("status", "GET /status", ScopePublic, http.HandlerFunc(handleStatusPage))
+	}
+	if healthSource != nil {
+		s.handle("health", "GET /api/health", ScopeRead, http.HandlerFunc(handleHealth))
+	}
+	if configSource != nil {
+		s.handle("config", "GET /api/config", ScopeRead, http.HandlerFunc(handleConfig))
+	}
+	if versionSource != nil {
+		s.handle("version", "GET /api/version", ScopeRead, http.HandlerFunc(handleVersion))
+	}
+	if alertAcknowledger != nil {
+		s.handle("alerts", "POST /api/alerts/ack", ScopeControl, http.HandlerFunc(handleAlertAck))
+	}
+	if frameSource != nil {
+		if config.LiveMirror {
+			s.handle("mirror", "/ws/framebuffer", ScopeRead, websocket.Handler(handleMirrorSocket))
+			s.handle("mirror", "/mirror", ScopeRead, http.HandlerFunc(handleMirrorPage))
+		}
+		s.handle("mirror", "GET /api/frame", ScopeRead, http.HandlerFunc(handleFrame))
+	}
+	if screenSource != nil {
+		s.handle("screens", "GET /api/screens", ScopeRead, http.HandlerFunc(handleScreens))
+		s.handle("screens", "GET /api/screens/{name}", ScopeRead, http.HandlerFunc(handleScreen))
+	}
+	// Device and client actions are never exposed on an unauthenticated API
+	if deviceController != nil && len(config.ControlTokens) > 0 {
+		s.handle("devices", "POST /api/devices/{mac}/locate", ScopeControl, http.HandlerFunc(handleLocate))
+		s.handle("devices", "DELETE /api/devices/{mac}/locate", ScopeControl, http.HandlerFunc(handleLocate))
+		s.handle("devices", "POST /api/devices/{mac}/restart", ScopeControl, http.HandlerFunc(handleRestart))
+	} else if deviceController != nil {
+		fmt.Println("Device control endpoints disabled - no API control tokens configured")
+	}
+	if clientController != nil && len(config.ControlTokens) > 0 {
+		s.handle("clients", "POST /api/clients/{mac}/block", ScopeControl, http.HandlerFunc(handleBlock))
+		s.handle("clients", "DELETE /api/clients/{mac}/block", ScopeControl, http.HandlerFunc(handleBlock))
+		s.handle("clients", "POST /api/clients/{mac}/kick", ScopeControl, http.HandlerFunc(handleKick))
+	} else if clientController != nil {
+		fmt.Println("Client control endpoints disabled - no API control tokens configured")
+	}
+
+	if config.TLS {
+		if err := ensureCertificate(config.TLSCert, config.TLSKey); err != nil {
+			return err
+		}
+	}
+
+	server := &http.Server{
+		Addr:              config.Addr,
+		Handler:           s.mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	if !s.authEnabled() {
+		fmt.Println("Warning: API has no tokens configured - all endpoints are unauthenticated")
+	}
+
+	go func() {
+		var err error
+		if config.TLS {
+			fmt.Printf("API listening on %s (TLS)\n", config.Addr)
+			err = server.ListenAndServeTLS(config.TLSCert, config.TLSKey)
+		} else {
+			fmt.Printf("API listening on %s\n", config.Addr)
+			err = server.ListenAndServe()
+		}
+		if err != nil {
+			fmt.Printf("API server error: %v\n", err)
+		}
+	}()
+	return nil
+}
+
+// handle registers an endpoint unless it has been disabled in the config
+func (s *Server) handle(name, pattern string, scope Scope, handler http.Handler) {
+	if slices.Contains(s.config.Disabled, name) {
+		return
+	}
+	s.mux.Handle(pattern, s.require(scope, handler))
+}
+
+// handleEvents streams state changes as Server-Sent Events
+func handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	ch := events.Subscribe()
+	defer events.Unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	// Replay the current state so clients do not wait for the next change
+	for _, e := range events.Latest() {
+		if err := writeEvent(w, e); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	keepalive := time.NewTicker(30 * time.Second)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e := <-ch:
+			if err := writeEvent(w, e); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// writeEvent writes a single event in text/event-stream format
+func writeEvent(w http.ResponseWriter, e events.Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+	return err
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// Scope is the level of access a token grants
+type Scope int
+
+const (
+	// ScopeRead allows reading state, events and the panel mirror
+	ScopeRead Scope = iota
+	// ScopeControl allows everything ScopeRead does, plus actions that change state
+	ScopeControl
+
+	// ScopePublic needs no token, for probes that cannot send one
+	ScopePublic Scope = -1
+)
+
+// authEnabled reports whether any tokens have been configured
+func (s *Server) authEnabled() bool {
+	return len(s.config.ReadTokens) > 0 || len(s.config.ControlTokens) > 0
+}
+
+// tokenScope returns the scope granted by the token, or false if it is unknown
+func (s *Server) tokenScope(token string) (Scope, bool) {
+	if token == "" {
+		return ScopeRead, false
+	}
+	for _, t := range s.config.ControlTokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return ScopeControl, true
+		}
+	}
+	for _, t := range s.config.ReadTokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return ScopeRead, true
+		}
+	}
+	return ScopeRead, false
+}
+
+// requestToken extracts the bearer token, falling back to the token query
+// parameter since browsers cannot set headers on EventSource or WebSocket
+func requestToken(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+	return r.URL.Query().Get("token")
+}
+
+// require wraps a handler so it only runs for tokens granting at least the given scope
+func (s *Server) require(scope Scope, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.authEnabled() || scope == ScopePublic {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		granted, ok := s.tokenScope(requestToken(r))
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="cloudkey"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if granted < scope {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
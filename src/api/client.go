@@ -0,0 +1,94 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"cloudkey/src/ratelimit"
+)
+
+// Client reads the screens of another cloudkey instance through its API
+type Client struct {
+	BaseURL string
+	Token   string // read token, if the instance requires one
+	HTTP    *http.Client
+}
+
+// NewClient returns a client for the API at baseURL, e.g. https://rack.lan:8443. Like the
+// controller, the API usually has a self-signed certificate, so it is not verified.
+func NewClient(baseURL, token string) *Client {
+	return &Client{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		Token:   token,
+		HTTP: &http.Client{
+			Timeout: 10 * time.Second,
+			Transport: ratelimit.Transport(&http.Transport{
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			}),
+		},
+	}
+}
+
+// Screens returns the names of the instance's screens, in carousel order
+func (c *Client) Screens(ctx context.Context) ([]string, error) {
+	body, err := c.get(ctx, "/api/screens")
+	if err != nil {
+		return nil, err
+	}
+
+	var list struct {
+		Screens []string `json:"screens"`
+	}
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse screen list: %v", err)
+	}
+	return list.Screens, nil
+}
+
+// Screen returns the current contents of a screen as a PNG
+func (c *Client) Screen(ctx context.Context, name string) ([]byte, error) {
+	return c.get(ctx, "/api/screens/"+url.PathEscape(name))
+}
+
+// Frame returns what the instance's panel currently shows as a PNG
+func (c *Client) Frame(ctx context.Context) ([]byte, error) {
+	return c.get(ctx, "/api/frame")
+}
+
+// Get fetches any API path the token can read, e.g. /api/health or /metrics
+func (c *Client) Get(ctx context.Context, path string) ([]byte, error) {
+	return c.get(ctx, path)
+}
+
+// get fetches an API path, turning non-200 answers into errors
+func (c *Client) get(ctx context.Context, path string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.BaseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", path, resp.Status)
+	}
+	return body, nil
+}
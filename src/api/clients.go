@@ -0,0 +1,41 @@
+package api
+
+import "net/http"
+
+// ClientController performs write actions on network clients
+type ClientController interface {
+	BlockClient(mac string, block bool) error
+	KickClient(mac string) error
+}
+
+var clientController ClientController
+
+// SetClientController enables the client control endpoints
+func SetClientController(controller ClientController) {
+	clientController = controller
+}
+
+// handleBlock blocks (POST) or unblocks (DELETE) a client
+func handleBlock(w http.ResponseWriter, r *http.Request) {
+	mac := r.PathValue("mac")
+	block := r.Method == http.MethodPost
+	if err := clientController.BlockClient(mac, block); err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+		return
+	}
+	status := "unblocked"
+	if block {
+		status = "blocked"
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": status, "mac": mac})
+}
+
+// handleKick disconnects a client so it has to reconnect
+func handleKick(w http.ResponseWriter, r *http.Request) {
+	mac := r.PathValue("mac")
+	if err := clientController.KickClient(mac); err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "kicked", "mac": mac})
+}
@@ -0,0 +1,28 @@
+package api
+
+import "net/http"
+
+var configSource func() any
+
+// SetConfigSource sets the function describing the configuration in effect
+func SetConfigSource(source func() any) {
+	configSource = source
+}
+
+var versionSource func() any
+
+// SetVersionSource sets the function describing the running build
+func SetVersionSource(source func() any) {
+	versionSource = source
+}
+
+// handleVersion returns the version, build and uptime, and whether a newer release is out
+func handleVersion(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, versionSource())
+}
+
+// handleConfig returns the configuration report: screens, collectors, LEDs, controller and
+// ignored settings
+func handleConfig(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, configSource())
+}
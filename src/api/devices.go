@@ -0,0 +1,108 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// confirmWindow is how long a restart confirmation token stays valid
+const confirmWindow = 30 * time.Second
+
+// DeviceController performs write actions on controller devices
+type DeviceController interface {
+	LocateDevice(mac string, enable bool) error
+	RestartDevice(mac string) error
+}
+
+var deviceController DeviceController
+
+// SetDeviceController enables the device control endpoints
+func SetDeviceController(controller DeviceController) {
+	deviceController = controller
+}
+
+// pendingAction is a destructive action waiting for confirmation
+type pendingAction struct {
+	action  string
+	mac     string
+	expires time.Time
+}
+
+var (
+	pending      = make(map[string]pendingAction)
+	pendingMutex sync.Mutex
+)
+
+// confirm returns true if token confirms the action, otherwise it registers a new
+// pending action and returns the token the client must send back
+func confirm(action, mac, token string) (bool, string, error) {
+	pendingMutex.Lock()
+	defer pendingMutex.Unlock()
+
+	now := time.Now()
+	for t, p := range pending {
+		if now.After(p.expires) {
+			delete(pending, t)
+		}
+	}
+
+	if p, ok := pending[token]; ok && p.action == action && p.mac == mac {
+		delete(pending, token)
+		return true, "", nil
+	}
+
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return false, "", fmt.Errorf("failed to generate confirmation token: %v", err)
+	}
+	newToken := hex.EncodeToString(b)
+	pending[newToken] = pendingAction{action: action, mac: mac, expires: now.Add(confirmWindow)}
+	return false, newToken, nil
+}
+
+// handleLocate starts (POST) or stops (DELETE) flashing a device's LED
+func handleLocate(w http.ResponseWriter, r *http.Request) {
+	mac := r.PathValue("mac")
+	if err := deviceController.LocateDevice(mac, r.Method == http.MethodPost); err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok", "mac": mac})
+}
+
+// handleRestart restarts a device after the request is repeated with the confirmation token
+func handleRestart(w http.ResponseWriter, r *http.Request) {
+	mac := r.PathValue("mac")
+
+	ok, token, err := confirm("restart", mac, r.URL.Query().Get("confirm"))
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if !ok {
+		writeJSON(w, http.StatusAccepted, map[string]any{
+			"status":     "confirmation required",
+			"confirm":    token,
+			"expires_in": int(confirmWindow.Seconds()),
+		})
+		return
+	}
+
+	if err := deviceController.RestartDevice(mac); err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "restarting", "mac": mac})
+}
+
+// writeJSON writes v as a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,20 @@
+package api
+
+import "net/http"
+
+var healthSource func() (any, error)
+
+// SetHealthSource sets the function used to summarize the health history
+func SetHealthSource(source func() (any, error)) {
+	healthSource = source
+}
+
+// handleHealth returns the current health state and how it was spent over the last days
+func handleHealth(w http.ResponseWriter, r *http.Request) {
+	summary, err := healthSource()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, summary)
+}
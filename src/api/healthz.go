@@ -0,0 +1,25 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// livenessSource reports whether cloudkey is still working, nil when not configured
+var livenessSource func() error
+
+// SetLivenessSource enables the /healthz endpoint for container liveness probes
+func SetLivenessSource(source func() error) {
+	livenessSource = source
+}
+
+// handleHealthz answers liveness probes with 200 ok, or 503 and the reason
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	if err := livenessSource(); err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, err)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
@@ -0,0 +1,27 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"cloudkey/src/leader"
+	"cloudkey/src/metrics"
+)
+
+// followerMetrics is all a follower serves, so that scraping every instance does not duplicate series
+const followerMetrics = `# HELP cloudkey_leader Whether this instance publishes metrics and notifications.
+# TYPE cloudkey_leader gauge
+cloudkey_leader 0
+`
+
+// handleMetrics serves the process metrics in the Prometheus text format
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	if !leader.IsLeader() {
+		fmt.Fprint(w, followerMetrics)
+		return
+	}
+	if err := metrics.Write(w); err != nil {
+		fmt.Printf("Error writing metrics: %v\n", err)
+	}
+}
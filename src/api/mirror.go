@@ -0,0 +1,125 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"io"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// mirrorFPS caps how many frames per second are sent to each mirror client
+const mirrorFPS = 2
+
+var frameSource func() image.Image
+
+// SetFrameSource sets the function used to capture the current panel contents
+func SetFrameSource(source func() image.Image) {
+	frameSource = source
+}
+
+// handleMirrorSocket streams the panel as PNG frames, only encoding and sending a frame when
+// the panel changed. It returns once the client goes away, even while the panel stays still.
+func handleMirrorSocket(ws *websocket.Conn) {
+	defer ws.Close()
+
+	// The client sends nothing, so a read only returns once it disconnected
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		io.Copy(io.Discard, ws)
+	}()
+
+	ticker := time.NewTicker(time.Second / mirrorFPS)
+	defer ticker.Stop()
+
+	var last []byte // pixels of the frame sent last
+	var buf bytes.Buffer
+	for {
+		select {
+		case <-gone:
+			return
+		case <-ticker.C:
+		}
+
+		frame := frameSource()
+		pixels := framePixels(frame)
+		if last != nil && bytes.Equal(pixels, last) {
+			continue
+		}
+
+		buf.Reset()
+		if err := png.Encode(&buf, frame); err != nil {
+			fmt.Printf("Mirror encode error: %v\n", err)
+			return
+		}
+		last = append(last[:0], pixels...)
+
+		if err := websocket.Message.Send(ws, buf.Bytes()); err != nil {
+			return
+		}
+	}
+}
+
+// framePixels returns the pixels of a frame, to tell whether it changed since the last one
+func framePixels(img image.Image) []byte {
+	if rgba, ok := img.(*image.RGBA); ok {
+		return rgba.Pix
+	}
+	rgba := image.NewRGBA(img.Bounds())
+	draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	return rgba.Pix
+}
+
+// handleFrame serves what the panel currently shows as a PNG
+func handleFrame(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, frameSource()); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(buf.Bytes())
+}
+
+// handleMirrorPage serves a minimal page rendering the mirror socket
+func handleMirrorPage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, mirrorPage)
+}
+
+const mirrorPage = `<!DOCTYPE html>
+<html>
+<head>
+<title>cloudkey mirror</title>
+<style>
+body { background: #111; margin: 0; display: flex; height: 100vh; align-items: center; justify-content: center; }
+img { width: 640px; image-rendering: pixelated; border: 8px solid #222; border-radius: 8px; }
+</style>
+</head>
+<body>
+<img id="panel" alt="cloudkey panel">
+<script>
+function connect() {
+	var proto = location.protocol === "https:" ? "wss://" : "ws://";
+	var ws = new WebSocket(proto + location.host + "/ws/framebuffer" + location.search);
+	ws.binaryType = "blob";
+	ws.onmessage = function (msg) {
+		var img = document.getElementById("panel");
+		var old = img.src;
+		img.src = URL.createObjectURL(msg.data);
+		if (old) { URL.revokeObjectURL(old); }
+	};
+	ws.onclose = function () { setTimeout(connect, 2000); };
+}
+connect();
+</script>
+</body>
+</html>
+`
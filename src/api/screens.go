@@ -0,0 +1,42 @@
+package api
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"net/http"
+)
+
+var (
+	screenNames  func() []string
+	screenSource func(name string) (image.Image, bool)
+)
+
+// SetScreenSource sets the functions listing the screens and capturing one of them,
+// enabling the screen endpoints that mirroring instances read
+func SetScreenSource(names func() []string, source func(name string) (image.Image, bool)) {
+	screenNames, screenSource = names, source
+}
+
+// handleScreens lists the screens of the carousel in order
+func handleScreens(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string][]string{"screens": screenNames()})
+}
+
+// handleScreen returns the current contents of a screen as a PNG, whether it is visible or not
+func handleScreen(w http.ResponseWriter, r *http.Request) {
+	img, ok := screenSource(r.PathValue("name"))
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown screen"})
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(buf.Bytes())
+}
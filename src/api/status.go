@@ -0,0 +1,29 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// statusPageSource renders the family status page, nil when not configured
+var statusPageSource func(io.Writer) error
+
+// SetStatusPageSource enables the unauthenticated /status page
+func SetStatusPageSource(source func(io.Writer) error) {
+	statusPageSource = source
+}
+
+// handleStatusPage serves the status page, rendered fresh for every request
+func handleStatusPage(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	if err := statusPageSource(&buf); err != nil {
+		fmt.Printf("Status page error: %v\n", err)
+		http.Error(w, "status unavailable", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(buf.Bytes())
+}
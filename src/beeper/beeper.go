@@ -0,0 +1,57 @@
+package beeper
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Beeper drives a buzzer through a sysfs file that turns it on with "1" and off with "0",
+// such as /sys/class/leds/<buzzer>/brightness or /sys/class/gpio/gpio<N>/value
+type Beeper struct {
+	path string
+	mu   sync.Mutex
+}
+
+// New returns a beeper writing to path
+func New(path string) *Beeper {
+	return &Beeper{path: path}
+}
+
+// Available reports whether the buzzer's sysfs file exists
+func (b *Beeper) Available() bool {
+	_, err := os.Stat(b.path)
+	return err == nil
+}
+
+// Beep sounds the buzzer for each on duration, separated by the off duration
+func (b *Beeper) Beep(on []time.Duration, off time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for n, d := range on {
+		if n > 0 {
+			time.Sleep(off)
+		}
+		if err := b.set(true); err != nil {
+			return err
+		}
+		time.Sleep(d)
+		if err := b.set(false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *Beeper) set(on bool) error {
+	value := "0"
+	if on {
+		value = "1"
+	}
+	if err := os.WriteFile(b.path, []byte(value), 0644); err != nil {
+		return fmt.Errorf("failed to write beeper: %v", err)
+	}
+	return nil
+}
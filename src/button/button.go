@@ -0,0 +1,19 @@
+// Package button reads the presses of a hardware button from a Linux input device, such as a
+// GPIO key or a USB button, with how long each press was held.
+package button
+
+import (
+	"context"
+	"time"
+)
+
+// Press is one press of the button, reported when it is released
+type Press struct {
+	Held time.Duration
+}
+
+// Watch reports the presses of any key of the input device at path, e.g. /dev/input/event0,
+// until ctx ends or the device goes away, when the channel is closed
+func Watch(ctx context.Context, path string) (<-chan Press, error) {
+	return watch(ctx, path)
+}
@@ -0,0 +1,77 @@
+package button
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+)
+
+// Event type and key values of linux/input.h
+const (
+	evKey      = 0x01
+	keyRelease = 0
+	keyPress   = 1
+)
+
+// inputEvent is struct input_event, whose timestamp is as wide as a long
+type inputEvent struct {
+	Time  syscall.Timeval
+	Type  uint16
+	Code  uint16
+	Value int32
+}
+
+// watch reads key events from the device, timing each press from the kernel's timestamps
+func watch(ctx context.Context, path string) (<-chan Press, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("button: %v", err)
+	}
+
+	// Closing the device ends the blocking read below
+	go func() {
+		<-ctx.Done()
+		f.Close()
+	}()
+
+	presses := make(chan Press)
+	go func() {
+		defer close(presses)
+		defer f.Close()
+
+		var down time.Time
+		for {
+			var ev inputEvent
+			if err := binary.Read(f, binary.NativeEndian, &ev); err != nil {
+				if ctx.Err() == nil {
+					fmt.Printf("Button %s closed: %v\n", path, err)
+				}
+				return
+			}
+			if ev.Type != evKey {
+				continue
+			}
+
+			at := time.Unix(ev.Time.Unix())
+			switch ev.Value {
+			case keyPress:
+				down = at
+			case keyRelease:
+				if down.IsZero() {
+					continue
+				}
+				press := Press{Held: at.Sub(down)}
+				down = time.Time{}
+				select {
+				case presses <- press:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return presses, nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package button
+
+import (
+	"context"
+	"errors"
+)
+
+// watch is only supported on Linux
+func watch(ctx context.Context, path string) (<-chan Press, error) {
+	return nil, errors.New("button input is only supported on Linux")
+}
@@ -0,0 +1,130 @@
+// Package chaos injects failures into the HTTP calls of the collectors, so degraded-mode
+// rendering, retries and LED alerts can be exercised without breaking a real controller.
+package chaos
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"cloudkey/src/metrics"
+)
+
+// Faults lists the failures that can be injected
+var Faults = []string{"timeout", "unauthorized", "malformed", "refused", "error"}
+
+// HangTimeout bounds an injected timeout for requests without a deadline
+const HangTimeout = 30 * time.Second
+
+var (
+	mu     sync.RWMutex
+	rate   float64
+	faults []string
+)
+
+// Validate checks a rate and fault list before Configure
+func Validate(r float64, f []string) error {
+	if r < 0 || r > 1 {
+		return fmt.Errorf("rate must be between 0 and 1, got %v", r)
+	}
+	for _, fault := range f {
+		if !slices.Contains(Faults, fault) {
+			return fmt.Errorf("unknown fault %q, expected one of %s", fault, strings.Join(Faults, ", "))
+		}
+	}
+	return nil
+}
+
+// Configure injects one of faults (all when empty) into a share r (0 to 1) of requests.
+// A rate of 0 turns injection off.
+func Configure(r float64, f []string) error {
+	if err := Validate(r, f); err != nil {
+		return err
+	}
+	if len(f) == 0 {
+		f = Faults
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	rate, faults = r, f
+	return nil
+}
+
+// pick returns the fault to inject into the next request, or "" for none
+func pick() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	if rate == 0 || rand.Float64() >= rate {
+		return ""
+	}
+	return faults[rand.IntN(len(faults))]
+}
+
+// transport injects faults in front of another transport
+type transport struct {
+	next http.RoundTripper
+}
+
+// Transport wraps next so requests fail as configured. Without Configure it passes
+// every request through.
+func Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &transport{next: next}
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	fault := pick()
+	if fault == "" {
+		return t.next.RoundTrip(req)
+	}
+
+	fmt.Printf("Chaos: injecting %s into %s %s\n", fault, req.Method, req.URL.Path)
+	metrics.Add("cloudkey_chaos_injected_total", "Failures injected into collector requests.", 1, "fault", fault)
+
+	switch fault {
+	case "timeout":
+		// Hang like an unresponsive server until the caller gives up, bounded for callers
+		// without a deadline such as watches
+		select {
+		case <-req.Context().Done():
+		case <-time.After(HangTimeout):
+		}
+		return nil, fmt.Errorf("chaos: %w (Client.Timeout exceeded while awaiting headers)", context.DeadlineExceeded)
+	case "refused":
+		return nil, fmt.Errorf("chaos: dial tcp %s: connect: connection refused", req.URL.Host)
+	case "unauthorized":
+		return fakeResponse(req, http.StatusUnauthorized, `{"meta":{"rc":"error","msg":"api.err.LoginRequired"},"data":[]}`), nil
+	case "malformed":
+		return fakeResponse(req, http.StatusOK, `{"meta":{"rc":"ok"},"data":[{"xput_download":`), nil
+	default:
+		return fakeResponse(req, http.StatusInternalServerError, "Internal Server Error"), nil
+	}
+}
+
+// fakeResponse answers req without sending it
+func fakeResponse(req *http.Request, status int, body string) *http.Response {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(bytes.NewBufferString(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
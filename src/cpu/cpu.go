@@ -0,0 +1,99 @@
+package cpu
+
+import (
+	"sync"
+	"time"
+)
+
+// HistorySize is how many samples a Sampler keeps
+const HistorySize = 60
+
+// Sample is CPU usage over the interval between two reads of /proc/stat, in percent
+type Sample struct {
+	Time  time.Time `json:"time"`
+	Total float64   `json:"total"`
+	Cores []float64 `json:"cores"`
+	Steal float64   `json:"steal"` // time taken by the hypervisor, part of Total
+	IRQ   float64   `json:"irq"`   // hard and soft interrupt handling, part of Total
+}
+
+// counters are the cumulative jiffies of one CPU line in /proc/stat
+type counters struct {
+	busy, irq, steal, total uint64
+}
+
+// percent returns the share of the interval between prev and c spent on part
+func percent(part, prevPart, total, prevTotal uint64) float64 {
+	// Counters can go backwards when a core is hot-unplugged
+	if total <= prevTotal || part < prevPart {
+		return 0
+	}
+	return float64(part-prevPart) / float64(total-prevTotal) * 100
+}
+
+// Sampler computes CPU usage from successive reads of /proc/stat and keeps a rolling history
+type Sampler struct {
+	path string
+
+	mu      sync.Mutex
+	prev    counters
+	prevCPU []counters
+	history []Sample
+}
+
+// NewSampler creates a sampler reading the given stat file (normally /proc/stat)
+func NewSampler(path string) *Sampler {
+	return &Sampler{path: path}
+}
+
+// Sample reads the current counters and records the usage since the previous call.
+// The first call only primes the counters and returns a zero sample.
+func (s *Sampler) Sample() (Sample, error) {
+	all, cores, err := readCounters(s.path)
+	if err != nil {
+		return Sample{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sample := Sample{Time: time.Now(), Cores: make([]float64, len(cores))}
+	if s.prev.total > 0 {
+		sample.Total = percent(all.busy, s.prev.busy, all.total, s.prev.total)
+		sample.Steal = percent(all.steal, s.prev.steal, all.total, s.prev.total)
+		sample.IRQ = percent(all.irq, s.prev.irq, all.total, s.prev.total)
+		for n, c := range cores {
+			if n < len(s.prevCPU) {
+				sample.Cores[n] = percent(c.busy, s.prevCPU[n].busy, c.total, s.prevCPU[n].total)
+			}
+		}
+
+		s.history = append(s.history, sample)
+		if len(s.history) > HistorySize {
+			s.history = s.history[len(s.history)-HistorySize:]
+		}
+	}
+	s.prev = all
+	s.prevCPU = cores
+
+	return sample, nil
+}
+
+// Latest returns the most recent sample, or a zero sample if there is none yet
+func (s *Sampler) Latest() Sample {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.history) == 0 {
+		return Sample{}
+	}
+	return s.history[len(s.history)-1]
+}
+
+// History returns up to HistorySize samples, oldest first
+func (s *Sampler) History() []Sample {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Sample(nil), s.history...)
+}
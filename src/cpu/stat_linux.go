@@ -0,0 +1,33 @@
+package cpu
+
+import (
+	"fmt"
+
+	linuxproc "github.com/c9s/goprocinfo/linux"
+)
+
+func countersOf(s linuxproc.CPUStat) counters {
+	// Guest time is already included in user and nice
+	irq := s.IRQ + s.SoftIRQ
+	busy := s.User + s.Nice + s.System + irq + s.Steal
+	return counters{
+		busy:  busy,
+		irq:   irq,
+		steal: s.Steal,
+		total: busy + s.Idle + s.IOWait,
+	}
+}
+
+// readCounters reads the counters of all CPUs and of each core from a stat file
+func readCounters(path string) (counters, []counters, error) {
+	stat, err := linuxproc.ReadStat(path)
+	if err != nil {
+		return counters{}, nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+
+	cores := make([]counters, len(stat.CPUStats))
+	for n, c := range stat.CPUStats {
+		cores[n] = countersOf(c)
+	}
+	return countersOf(stat.CPUStatAll), cores, nil
+}
@@ -0,0 +1,41 @@
+//go:build !linux
+
+package cpu
+
+import (
+	"fmt"
+
+	gopsutil "github.com/shirou/gopsutil/v4/cpu"
+)
+
+// countersOf converts the CPU times, in seconds, to hundredths like /proc/stat
+func countersOf(t gopsutil.TimesStat) counters {
+	hundredths := func(seconds float64) uint64 { return uint64(seconds * 100) }
+	irq := hundredths(t.Irq + t.Softirq)
+	busy := hundredths(t.User+t.Nice+t.System+t.Steal) + irq
+	return counters{
+		busy:  busy,
+		irq:   irq,
+		steal: hundredths(t.Steal),
+		total: busy + hundredths(t.Idle+t.Iowait),
+	}
+}
+
+// readCounters asks the operating system for the CPU times, since there is no /proc/stat.
+// The path is ignored.
+func readCounters(path string) (counters, []counters, error) {
+	all, err := gopsutil.Times(false)
+	if err != nil || len(all) == 0 {
+		return counters{}, nil, fmt.Errorf("failed to read CPU times: %v", err)
+	}
+	perCore, err := gopsutil.Times(true)
+	if err != nil {
+		return counters{}, nil, fmt.Errorf("failed to read CPU times: %v", err)
+	}
+
+	cores := make([]counters, len(perCore))
+	for n, c := range perCore {
+		cores[n] = countersOf(c)
+	}
+	return countersOf(all[0]), cores, nil
+}
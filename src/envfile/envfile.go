@@ -0,0 +1,83 @@
+// Package envfile reads and writes the KEY=value environment files systemd loads with
+// EnvironmentFile, such as /etc/cloudkey.env, so a value reads back as systemd passes it.
+package envfile
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Load sets the KEY=value pairs of an environment file in the environment
+func Load(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("%s:%d: expected KEY=value", path, n)
+		}
+		value, err := Value(value)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+		if err := os.Setenv(strings.TrimSpace(key), value); err != nil {
+			return fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// Value reads the value after the = as systemd does: in double quotes a backslash escapes the
+// next character, in single quotes nothing is escaped, and either keeps spaces and # as they
+// are. It reads back what Quote writes.
+func Value(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || (value[0] != '"' && value[0] != '\'') {
+		// Trailing comments are not part of the value in systemd files, but are common in examples
+		if i := strings.Index(value, " #"); i >= 0 {
+			value = strings.TrimSpace(value[:i])
+		}
+		return value, nil
+	}
+
+	quote := value[0]
+	var b strings.Builder
+	for i := 1; i < len(value); i++ {
+		switch c := value[i]; {
+		case c == quote:
+			if rest := strings.TrimSpace(value[i+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+				return "", fmt.Errorf("unexpected %q after the closing quote", rest)
+			}
+			return b.String(), nil
+		case c == '\\' && quote == '"' && i+1 < len(value):
+			i++
+			if !strings.ContainsRune("\"\\$`", rune(value[i])) {
+				b.WriteByte(c)
+			}
+			b.WriteByte(value[i])
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", fmt.Errorf("missing the closing quote of %s", value)
+}
+
+// Quote quotes a value where an environment file would otherwise cut or change it
+func Quote(value string) string {
+	if !strings.ContainsAny(value, " \t#\"'\\$`") {
+		return value
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "`", "\\`").Replace(value) + `"`
+}
@@ -0,0 +1,72 @@
+package envfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	values := []string{
+		"plain",
+		"",
+		"with space",
+		"pa$$word",
+		`say "hi"`,
+		`back\slash`,
+		`\"$`,
+		"it's",
+		"tab\there",
+		"not # a comment",
+		"trailing#hash",
+		" padded ",
+		"`tick`",
+	}
+
+	var file strings.Builder
+	for n, value := range values {
+		key := fmt.Sprintf("ENVFILE_ROUND_TRIP_%d", n)
+		t.Setenv(key, "")
+		fmt.Fprintf(&file, "%s=%s\n", key, Quote(value))
+	}
+	path := filepath.Join(t.TempDir(), "cloudkey.env")
+	if err := os.WriteFile(path, []byte(file.String()), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Load(path); err != nil {
+		t.Fatalf("loading %q: %v", file.String(), err)
+	}
+	for n, want := range values {
+		key := fmt.Sprintf("ENVFILE_ROUND_TRIP_%d", n)
+		if got := os.Getenv(key); got != want {
+			t.Errorf("%s written as %s read back as %q, want %q", key, Quote(want), got, want)
+		}
+	}
+}
+
+func TestValue(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+		err   bool
+	}{
+		{"value", "value", false},
+		{"  value  ", "value", false},
+		{"value # comment", "value", false},
+		{`"quoted # kept" # comment`, "quoted # kept", false},
+		{`'single \ $ kept'`, `single \ $ kept`, false},
+		{`"a\"b\\c\$d"`, `a"b\c$d`, false},
+		{`"keeps \n as is"`, `keeps \n as is`, false},
+		{`"unterminated`, "", true},
+		{`"closed" trailing`, "", true},
+	}
+	for _, tt := range tests {
+		got, err := Value(tt.value)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("Value(%s) = %q, %v, want %q (error %v)", tt.value, got, err, tt.want, tt.err)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package events
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Event is a single state change published by the display
+type Event struct {
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	Data any       `json:"data,omitempty"`
+}
+
+// Bus fans out published events to all subscribers
+type Bus struct {
+	mu     sync.RWMutex
+	subs   map[chan Event]struct{}
+	latest map[string]Event
+}
+
+// NewBus creates an empty event bus
+func NewBus() *Bus {
+	return &Bus{
+		subs:   make(map[chan Event]struct{}),
+		latest: make(map[string]Event),
+	}
+}
+
+// Publish sends an event to every subscriber, dropping it for subscribers that are not keeping up
+func (b *Bus) Publish(eventType string, data any) {
+	e := Event{Type: eventType, Time: time.Now(), Data: data}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.latest[eventType] = e
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a channel receiving every event published from now on
+func (b *Bus) Subscribe() chan Event {
+	ch := make(chan Event, 16)
+
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch
+}
+
+// Unsubscribe stops delivery to the channel and closes it
+func (b *Bus) Unsubscribe(ch chan Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subs[ch]; ok {
+		delete(b.subs, ch)
+		close(ch)
+	}
+}
+
+// Latest returns the most recent event of each type, so late subscribers can catch up
+func (b *Bus) Latest() []Event {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	list := make([]Event, 0, len(b.latest))
+	for _, e := range b.latest {
+		list = append(list, e)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Time.Before(list[j].Time) })
+	return list
+}
+
+var defaultBus = NewBus()
+
+// Publish sends an event on the default bus
+func Publish(eventType string, data any) {
+	defaultBus.Publish(eventType, data)
+}
+
+// Subscribe subscribes to the default bus
+func Subscribe() chan Event {
+	return defaultBus.Subscribe()
+}
+
+// Unsubscribe unsubscribes from the default bus
+func Unsubscribe(ch chan Event) {
+	defaultBus.Unsubscribe(ch)
+}
+
+// Latest returns the most recent event of each type on the default bus
+func Latest() []Event {
+	return defaultBus.Latest()
+}
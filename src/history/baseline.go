@@ -0,0 +1,105 @@
+package history
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/llajas/cloudkey/src/network"
+)
+
+// BaselineMinSamples is the number of results needed before a baseline is trusted
+const BaselineMinSamples = 5
+
+// Baseline is the usual speedtest performance over a rolling window
+type Baseline struct {
+	Download    float64 // median download in Mb/s
+	Upload      float64 // median upload in Mb/s
+	DownloadMAD float64 // median absolute deviation of download
+	UploadMAD   float64 // median absolute deviation of upload
+	Samples     int
+}
+
+// SpeedtestBaseline computes the median speeds of the results within window of now
+func SpeedtestBaseline(results []network.SpeedtestResult, now time.Time, window time.Duration) Baseline {
+	cutoff := now.Add(-window).UnixMilli()
+
+	var down, up []float64
+	for _, r := range results {
+		if r.Timestamp < cutoff {
+			continue
+		}
+		down = append(down, r.DownloadMbps)
+		up = append(up, r.UploadMbps)
+	}
+
+	b := Baseline{Samples: len(down)}
+	b.Download, b.DownloadMAD = medianMAD(down)
+	b.Upload, b.UploadMAD = medianMAD(up)
+	return b
+}
+
+// Valid reports whether enough results were seen for the baseline to be meaningful
+func (b Baseline) Valid() bool {
+	return b.Samples >= BaselineMinSamples
+}
+
+// DownloadDelta returns the percentage difference of mbps from the usual download speed
+func (b Baseline) DownloadDelta(mbps float64) float64 {
+	return delta(mbps, b.Download)
+}
+
+// UploadDelta returns the percentage difference of mbps from the usual upload speed
+func (b Baseline) UploadDelta(mbps float64) float64 {
+	return delta(mbps, b.Upload)
+}
+
+// Degraded reports whether the result is significantly below the baseline: more than
+// three scaled MADs and at least 10% below the median on either download or upload
+func (b Baseline) Degraded(r network.SpeedtestResult) bool {
+	if !b.Valid() {
+		return false
+	}
+	return below(r.DownloadMbps, b.Download, b.DownloadMAD) || below(r.UploadMbps, b.Upload, b.UploadMAD)
+}
+
+// FormatDelta formats a percentage difference like "-12%" or "+3%"
+func FormatDelta(percent float64) string {
+	return fmt.Sprintf("%+.0f%%", percent)
+}
+
+func below(value, median, mad float64) bool {
+	// 1.4826 scales the MAD to be comparable with a standard deviation
+	threshold := math.Max(3*1.4826*mad, 0.1*median)
+	return value < median-threshold
+}
+
+func delta(value, median float64) float64 {
+	if median == 0 {
+		return 0
+	}
+	return (value - median) / median * 100
+}
+
+func medianMAD(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	m := median(values)
+	deviations := make([]float64, len(values))
+	for i, v := range values {
+		deviations[i] = math.Abs(v - m)
+	}
+	return m, median(deviations)
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
@@ -0,0 +1,86 @@
+package history
+
+import (
+	"sort"
+	"time"
+)
+
+// Transition is a change of the health state
+type Transition struct {
+	Time  time.Time `json:"time"`
+	State string    `json:"state"`
+}
+
+// HealthSummary is how the health state was spent over a window
+type HealthSummary struct {
+	Window       time.Duration
+	Warning      time.Duration // total time in WARNING
+	Critical     time.Duration // total time in CRITICAL
+	Incidents    int           // periods away from OK that started or ended in the window
+	LastIncident time.Duration // length of the most recent incident, up to now if still ongoing
+	Ongoing      bool          // the health is currently not OK
+	MTTR         time.Duration // mean time to recover over the resolved incidents
+}
+
+// SummarizeHealth totals the time spent in each state over the window ending at now.
+// Anything before the first transition is assumed to be OK.
+func SummarizeHealth(transitions []Transition, now time.Time, window time.Duration) HealthSummary {
+	sorted := append([]Transition(nil), transitions...)
+	sort.Slice(sorted, func(a, b int) bool { return sorted[a].Time.Before(sorted[b].Time) })
+
+	summary := HealthSummary{Window: window}
+	start := now.Add(-window)
+
+	state, since := "ok", start
+	var incidentStart time.Time
+	var resolved int
+	var repair time.Duration
+
+	advance := func(next string, at time.Time) {
+		switch state {
+		case "warning":
+			summary.Warning += at.Sub(since)
+		case "critical":
+			summary.Critical += at.Sub(since)
+		}
+
+		if state == "ok" && next != "ok" {
+			incidentStart = at
+			summary.Incidents++
+		} else if state != "ok" && next == "ok" {
+			summary.LastIncident = at.Sub(incidentStart)
+			resolved++
+			repair += summary.LastIncident
+		}
+		state, since = next, at
+	}
+
+	// Carry the state from before the window into it
+	var n int
+	for ; n < len(sorted) && sorted[n].Time.Before(start); n++ {
+		state = sorted[n].State
+	}
+	if state != "ok" {
+		incidentStart = start
+		summary.Incidents++
+	}
+
+	for _, t := range sorted[n:] {
+		if t.Time.After(now) {
+			break
+		}
+		if t.State != state {
+			advance(t.State, t.Time)
+		}
+	}
+
+	if state != "ok" {
+		advance(state, now)
+		summary.LastIncident = now.Sub(incidentStart)
+		summary.Ongoing = true
+	}
+	if resolved > 0 {
+		summary.MTTR = repair / time.Duration(resolved)
+	}
+	return summary
+}
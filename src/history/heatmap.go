@@ -0,0 +1,55 @@
+package history
+
+import (
+	"time"
+
+	"github.com/llajas/cloudkey/src/network"
+)
+
+// HeatmapDays is the number of days, and rows, of a latency heatmap
+const HeatmapDays = 7
+
+// HeatmapCell is the ping latency during one hour
+type HeatmapCell struct {
+	MedianMs float64 // median latency of the answered pings
+	Samples  int     // pings answered
+	Lost     int     // pings that timed out
+}
+
+// Heatmap holds one row per day, oldest first and ending with the day of now, of 24 hourly cells
+type Heatmap [HeatmapDays][24]HeatmapCell
+
+// LatencyHeatmap buckets the ping results of the last HeatmapDays calendar days by day and hour,
+// in the location of now
+func LatencyHeatmap(results []network.PingResult, now time.Time) Heatmap {
+	first := startOfDay(now).AddDate(0, 0, -(HeatmapDays - 1))
+
+	var latencies [HeatmapDays][24][]float64
+	var h Heatmap
+	for _, r := range results {
+		t := time.UnixMilli(r.Timestamp).In(now.Location())
+		if t.Before(first) || t.After(now) {
+			continue
+		}
+		// Count calendar days rather than 24 hour periods so DST changes do not shift rows
+		day := 0
+		for day < HeatmapDays-1 && !t.Before(first.AddDate(0, 0, day+1)) {
+			day++
+		}
+		if r.Lost {
+			h[day][t.Hour()].Lost++
+			continue
+		}
+		latencies[day][t.Hour()] = append(latencies[day][t.Hour()], r.LatencyMs)
+	}
+
+	for day := range latencies {
+		for hour, values := range latencies[day] {
+			if len(values) > 0 {
+				h[day][hour].MedianMs = median(values)
+				h[day][hour].Samples = len(values)
+			}
+		}
+	}
+	return h
+}
@@ -0,0 +1,57 @@
+package history
+
+import (
+	"math"
+	"time"
+
+	"github.com/llajas/cloudkey/src/network"
+)
+
+// SpeedStats is the download speed of the speedtest results within a period
+type SpeedStats struct {
+	Min     float64 // slowest download in Mb/s
+	Avg     float64 // mean download in Mb/s
+	Max     float64 // fastest download in Mb/s
+	Samples int
+}
+
+// DownloadStats summarizes the download speeds of the results from start up to, but not including, end
+func DownloadStats(results []network.SpeedtestResult, start, end time.Time) SpeedStats {
+	from, to := start.UnixMilli(), end.UnixMilli()
+
+	s := SpeedStats{Min: math.Inf(1)}
+	var sum float64
+	for _, r := range results {
+		if r.Timestamp < from || r.Timestamp >= to {
+			continue
+		}
+		s.Min = math.Min(s.Min, r.DownloadMbps)
+		s.Max = math.Max(s.Max, r.DownloadMbps)
+		sum += r.DownloadMbps
+		s.Samples++
+	}
+	if s.Samples == 0 {
+		return SpeedStats{}
+	}
+	s.Avg = sum / float64(s.Samples)
+	return s
+}
+
+// Yesterday returns the start and end of the calendar day before now, in the location of now
+func Yesterday(now time.Time) (time.Time, time.Time) {
+	today := startOfDay(now)
+	return today.AddDate(0, 0, -1), today
+}
+
+// ThisWeek returns the start of the week of now, beginning on Monday, in the location of now
+func ThisWeek(now time.Time) time.Time {
+	today := startOfDay(now)
+	// Weekday counts from Sunday
+	return today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+}
+
+// startOfDay uses time.Date rather than Truncate so days follow the local calendar across DST changes
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,62 @@
+package history
+
+import "time"
+
+// UsageSample is the WAN traffic since the previous sample
+type UsageSample struct {
+	Timestamp int64 `json:"timestamp"` // milliseconds since epoch
+	RxBytes   int64 `json:"rx_bytes"`
+	TxBytes   int64 `json:"tx_bytes"`
+	RxCounter int64 `json:"rx_counter"` // gateway counters the next sample is taken from
+	TxCounter int64 `json:"tx_counter"`
+}
+
+// NextUsage turns the gateway counters read at now into a sample following previous. Counters
+// lower than before were reset by a gateway restart, so all of the new value is fresh traffic.
+func NextUsage(previous UsageSample, rx, tx int64, now time.Time) UsageSample {
+	sample := UsageSample{Timestamp: now.UnixMilli(), RxBytes: rx, TxBytes: tx, RxCounter: rx, TxCounter: tx}
+	if rx >= previous.RxCounter {
+		sample.RxBytes -= previous.RxCounter
+	}
+	if tx >= previous.TxCounter {
+		sample.TxBytes -= previous.TxCounter
+	}
+	return sample
+}
+
+// Usage totals the traffic of the samples from start up to, but not including, end
+func Usage(samples []UsageSample, start, end time.Time) int64 {
+	from, to := start.UnixMilli(), end.UnixMilli()
+
+	var total int64
+	for _, s := range samples {
+		if s.Timestamp >= from && s.Timestamp < to {
+			total += s.RxBytes + s.TxBytes
+		}
+	}
+	return total
+}
+
+// BillingCycle returns the start and end of the billing cycle of now, which restarts on
+// resetDay (1-28) of every month, in the location of now
+func BillingCycle(now time.Time, resetDay int) (time.Time, time.Time) {
+	y, m, d := now.Date()
+	if d < resetDay {
+		m--
+	}
+	start := time.Date(y, m, resetDay, 0, 0, 0, 0, now.Location())
+	return start, start.AddDate(0, 1, 0)
+}
+
+// ProjectUsage extrapolates the traffic measured over a period to a whole cycle
+func ProjectUsage(used int64, measured, cycle time.Duration) int64 {
+	if measured <= 0 {
+		return used
+	}
+	return int64(float64(used) * float64(cycle) / float64(measured))
+}
+
+// Today returns the start of the calendar day of now, in the location of now
+func Today(now time.Time) time.Time {
+	return startOfDay(now)
+}
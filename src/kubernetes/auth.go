@@ -0,0 +1,78 @@
+package kubernetes
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// AuthExpiry returns when the credentials used for the API server expire, and what they are
+// ("client certificate" or "token"). It returns a zero time for credentials without a known
+// expiry, such as exec plugins or legacy service account tokens.
+func (c *Client) AuthExpiry() (time.Time, string, error) {
+	certData := c.config.CertData
+	if len(certData) == 0 && c.config.CertFile != "" {
+		data, err := os.ReadFile(c.config.CertFile)
+		if err != nil {
+			return time.Time{}, "", fmt.Errorf("failed to read client certificate: %v", err)
+		}
+		certData = data
+	}
+	if len(certData) > 0 {
+		expiry, err := certificateExpiry(certData)
+		return expiry, "client certificate", err
+	}
+
+	// Projected service account tokens are rotated by the kubelet, so read the file every time
+	token := c.config.BearerToken
+	if c.config.BearerTokenFile != "" {
+		data, err := os.ReadFile(c.config.BearerTokenFile)
+		if err != nil {
+			return time.Time{}, "", fmt.Errorf("failed to read token: %v", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	if token != "" {
+		return tokenExpiry(token), "token", nil
+	}
+
+	return time.Time{}, "", nil
+}
+
+// certificateExpiry returns the end of validity of the first certificate in PEM data
+func certificateExpiry(data []byte) (time.Time, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return time.Time{}, fmt.Errorf("client certificate is not PEM encoded")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse client certificate: %v", err)
+	}
+	return cert.NotAfter, nil
+}
+
+// tokenExpiry returns the exp claim of a JWT, or a zero time if it is not a JWT or never expires.
+// The signature is not checked: this only predicts when the API server will reject the token.
+func tokenExpiry(token string) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return time.Time{}
+	}
+	return time.Unix(claims.Exp, 0)
+}
@@ -0,0 +1,97 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// GitOpsApp is the sync state of a Flux Kustomization or HelmRelease, or an Argo CD Application
+type GitOpsApp struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Synced    bool   // applied and healthy
+	Reason    string // why it is not synced, e.g. ReconciliationFailed or OutOfSync
+}
+
+// gitOpsResource is a GitOps custom resource and how to read its state
+type gitOpsResource struct {
+	kind     string
+	resource schema.GroupVersionResource
+	state    func(obj *unstructured.Unstructured) (bool, string)
+}
+
+var gitOpsResources = []gitOpsResource{
+	{"Kustomization", schema.GroupVersionResource{Group: "kustomize.toolkit.fluxcd.io", Version: "v1", Resource: "kustomizations"}, fluxState},
+	{"HelmRelease", schema.GroupVersionResource{Group: "helm.toolkit.fluxcd.io", Version: "v2", Resource: "helmreleases"}, fluxState},
+	{"Application", schema.GroupVersionResource{Group: "argoproj.io", Version: "v1alpha1", Resource: "applications"}, argoState},
+}
+
+// fluxState reads the Ready condition Flux sets once the resource is applied and healthy
+func fluxState(obj *unstructured.Unstructured) (bool, string) {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]any)
+		if !ok || condition["type"] != "Ready" {
+			continue
+		}
+		if condition["status"] == "True" {
+			return true, ""
+		}
+		if reason, ok := condition["reason"].(string); ok && reason != "" {
+			return false, reason
+		}
+		return false, "NotReady"
+	}
+	return false, "Progressing"
+}
+
+// argoState combines the sync and health status of an Argo CD Application
+func argoState(obj *unstructured.Unstructured) (bool, string) {
+	sync, _, _ := unstructured.NestedString(obj.Object, "status", "sync", "status")
+	health, _, _ := unstructured.NestedString(obj.Object, "status", "health", "status")
+	switch {
+	case health != "Healthy" && health != "":
+		return false, health
+	case sync != "Synced":
+		if sync == "" {
+			sync = "Unknown"
+		}
+		return false, sync
+	case health == "":
+		return false, "Unknown"
+	default:
+		return true, ""
+	}
+}
+
+// GitOpsStatus lists the Flux and Argo CD resources in the cluster, unsynced ones first.
+// Kinds whose CRD is not installed are skipped.
+func (c *Client) GitOpsStatus(ctx context.Context) ([]GitOpsApp, error) {
+	var apps []GitOpsApp
+	for _, r := range gitOpsResources {
+		list, err := c.dynamic.Resource(r.resource).Namespace("").List(ctx, metav1.ListOptions{})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %ss: %w", r.kind, err)
+		}
+		for n := range list.Items {
+			obj := &list.Items[n]
+			synced, reason := r.state(obj)
+			apps = append(apps, GitOpsApp{Kind: r.kind, Namespace: obj.GetNamespace(), Name: obj.GetName(), Synced: synced, Reason: reason})
+		}
+	}
+
+	sort.SliceStable(apps, func(a, b int) bool {
+		return !apps[a].Synced && apps[b].Synced
+	})
+	return apps, nil
+}
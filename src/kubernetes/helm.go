@@ -0,0 +1,88 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// HelmStuckAfter is how long a release may stay pending before it counts as stuck
+const HelmStuckAfter = 10 * time.Minute
+
+// HelmRelease is the latest revision of a Helm release
+type HelmRelease struct {
+	Namespace string
+	Name      string
+	Revision  int
+	Status    string // deployed, failed, pending-install, pending-upgrade, pending-rollback, ...
+	Since     time.Time
+}
+
+// Failed reports whether the last install, upgrade or rollback of the release failed
+func (r HelmRelease) Failed() bool {
+	return r.Status == "failed"
+}
+
+// Stuck reports whether the release has been pending for longer than HelmStuckAfter
+func (r HelmRelease) Stuck(now time.Time) bool {
+	return strings.HasPrefix(r.Status, "pending") && now.Sub(r.Since) > HelmStuckAfter
+}
+
+// helmStorage maps the Helm storage drivers to the resources holding the releases
+var helmStorage = map[string]schema.GroupVersionResource{
+	"secret":    {Version: "v1", Resource: "secrets"},
+	"configmap": {Version: "v1", Resource: "configmaps"},
+}
+
+// HelmReleases lists the latest revision of every Helm release stored by the driver
+// (secret or configmap, as in HELM_DRIVER). Only metadata is fetched: Helm keeps the
+// status in labels, and the release bodies would be too large for the Cloud Key.
+func (c *Client) HelmReleases(ctx context.Context, driver string) ([]HelmRelease, error) {
+	resource, ok := helmStorage[driver]
+	if !ok {
+		return nil, fmt.Errorf("unknown Helm driver %q", driver)
+	}
+
+	list, err := c.metadata.Resource(resource).Namespace("").List(ctx, metav1.ListOptions{LabelSelector: "owner=helm"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Helm %ss: %w", driver, err)
+	}
+
+	latest := make(map[string]HelmRelease)
+	for _, item := range list.Items {
+		labels := item.GetLabels()
+		revision, err := strconv.Atoi(labels["version"])
+		if err != nil || labels["name"] == "" {
+			continue
+		}
+		release := HelmRelease{
+			Namespace: item.GetNamespace(),
+			Name:      labels["name"],
+			Revision:  revision,
+			Status:    labels["status"],
+			Since:     item.GetCreationTimestamp().Time,
+		}
+		key := release.Namespace + "/" + release.Name
+		if previous, ok := latest[key]; !ok || revision > previous.Revision {
+			latest[key] = release
+		}
+	}
+
+	releases := make([]HelmRelease, 0, len(latest))
+	for _, release := range latest {
+		releases = append(releases, release)
+	}
+	sort.Slice(releases, func(a, b int) bool {
+		if releases[a].Namespace != releases[b].Namespace {
+			return releases[a].Namespace < releases[b].Namespace
+		}
+		return releases[a].Name < releases[b].Name
+	})
+	return releases, nil
+}
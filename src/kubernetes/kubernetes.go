@@ -3,18 +3,26 @@ package kubernetes
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"slices"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/metadata"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+
+	"cloudkey/src/chaos"
+	"cloudkey/src/tracing"
 )
 
 type ClusterStatus struct {
 	NodesReady     int
 	NodesTotal     int
+	NodesPressure  []NodePressure
 	PodsRunning    int
 	PodsPending    int
 	PodsFailed     int
@@ -23,8 +31,26 @@ type ClusterStatus struct {
 	ErrorMsg       string
 }
 
+// NodePressure lists the conditions a node reports trouble with
+type NodePressure struct {
+	Name       string
+	Conditions []string // e.g. DiskPressure
+}
+
+// pressureConditions are the node conditions that signal trouble when true
+var pressureConditions = []corev1.NodeConditionType{
+	corev1.NodeMemoryPressure,
+	corev1.NodeDiskPressure,
+	corev1.NodePIDPressure,
+	corev1.NodeNetworkUnavailable,
+}
+
 type Client struct {
+	config    *rest.Config
 	clientset *kubernetes.Clientset
+	dynamic   dynamic.Interface
+	metadata  metadata.Interface
+	latency   latencyTracker
 }
 
 func NewClient(kubeconfig string) (*Client, error) {
@@ -42,19 +68,30 @@ func NewClient(kubeconfig string) (*Client, error) {
 	}
 
 	config.Timeout = 10 * time.Second
+	config.Wrap(func(rt http.RoundTripper) http.RoundTripper { return tracing.Transport(chaos.Transport(rt)) })
 
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create clientset: %w", err)
 	}
 
-	return &Client{clientset: clientset}, nil
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+
+	metadataClient, err := metadata.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metadata client: %w", err)
+	}
+
+	return &Client{config: config, clientset: clientset, dynamic: dynamicClient, metadata: metadataClient}, nil
 }
 
 func (c *Client) GetClusterStatus(ctx context.Context) (*ClusterStatus, error) {
 	status := &ClusterStatus{}
 
-	_, err := c.clientset.Discovery().ServerVersion()
+	err := c.ping()
 	if err != nil {
 		status.Healthy = false
 		status.ErrorMsg = "API unreachable"
@@ -70,12 +107,20 @@ func (c *Client) GetClusterStatus(ctx context.Context) (*ClusterStatus, error) {
 
 	status.NodesTotal = len(nodes.Items)
 	for _, node := range nodes.Items {
+		pressure := NodePressure{Name: node.Name}
 		for _, condition := range node.Status.Conditions {
-			if condition.Type == corev1.NodeReady && condition.Status == corev1.ConditionTrue {
+			if condition.Status != corev1.ConditionTrue {
+				continue
+			}
+			if condition.Type == corev1.NodeReady {
 				status.NodesReady++
-				break
+			} else if slices.Contains(pressureConditions, condition.Type) {
+				pressure.Conditions = append(pressure.Conditions, string(condition.Type))
 			}
 		}
+		if len(pressure.Conditions) > 0 {
+			status.NodesPressure = append(status.NodesPressure, pressure)
+		}
 	}
 
 	pods, err := c.clientset.CoreV1().Pods("").List(ctx, metav1.ListOptions{})
@@ -100,6 +145,18 @@ func (c *Client) GetClusterStatus(ctx context.Context) (*ClusterStatus, error) {
 }
 
 func (c *Client) HealthCheck(ctx context.Context) bool {
+	return c.ping() == nil
+}
+
+// ping asks the API server for its version, recording the round trip latency
+func (c *Client) ping() error {
+	start := time.Now()
 	_, err := c.clientset.Discovery().ServerVersion()
-	return err == nil
+	c.latency.record(time.Since(start), err != nil)
+	return err
+}
+
+// Latency returns statistics over the last LatencyHistorySize API server round trips
+func (c *Client) Latency() LatencyStats {
+	return c.latency.stats()
 }
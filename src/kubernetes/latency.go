@@ -0,0 +1,73 @@
+package kubernetes
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// LatencyHistorySize is how many API round trips are kept for latency statistics
+const LatencyHistorySize = 60
+
+// probe is one API server round trip
+type probe struct {
+	time    time.Time
+	latency time.Duration
+	failed  bool
+}
+
+// LatencyStats summarizes the recent API server round trips
+type LatencyStats struct {
+	Samples   int
+	P95       time.Duration // of successful round trips
+	ErrorRate float64       // failed share of all round trips, 0 to 1
+}
+
+// latencyTracker keeps a rolling window of API server round trips
+type latencyTracker struct {
+	mu     sync.Mutex
+	probes []probe
+}
+
+func (t *latencyTracker) record(latency time.Duration, failed bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.probes = append(t.probes, probe{time: time.Now(), latency: latency, failed: failed})
+	if len(t.probes) > LatencyHistorySize {
+		t.probes = t.probes[len(t.probes)-LatencyHistorySize:]
+	}
+}
+
+func (t *latencyTracker) stats() LatencyStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats := LatencyStats{Samples: len(t.probes)}
+	var latencies []time.Duration
+	failed := 0
+	for _, p := range t.probes {
+		if p.failed {
+			failed++
+			continue
+		}
+		latencies = append(latencies, p.latency)
+	}
+	if stats.Samples > 0 {
+		stats.ErrorRate = float64(failed) / float64(stats.Samples)
+	}
+	stats.P95 = percentile(latencies, 95)
+	return stats
+}
+
+// percentile returns the nearest-rank percentile of the durations, 0 if there are none
+func percentile(durations []time.Duration, p float64) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(a, b int) bool { return sorted[a] < sorted[b] })
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[min(max(rank, 1), len(sorted))-1]
+}
@@ -0,0 +1,47 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// LeaseElector elects a leader among cloudkey instances through a coordination.k8s.io Lease
+type LeaseElector struct {
+	Client    *Client
+	Namespace string
+	Name      string
+	Identity  string // unique per instance, e.g. the pod or host name
+}
+
+// Run takes part in the election until ctx is done, standing again after losing the lease
+func (e LeaseElector) Run(ctx context.Context, onChange func(bool)) {
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta:  metav1.ObjectMeta{Namespace: e.Namespace, Name: e.Name},
+		Client:     e.Client.clientset.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: e.Identity},
+	}
+
+	for ctx.Err() == nil {
+		elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+			Lock:            lock,
+			ReleaseOnCancel: true,
+			LeaseDuration:   15 * time.Second,
+			RenewDeadline:   10 * time.Second,
+			RetryPeriod:     2 * time.Second,
+			Callbacks: leaderelection.LeaderCallbacks{
+				OnStartedLeading: func(context.Context) { onChange(true) },
+				OnStoppedLeading: func() { onChange(false) },
+			},
+		})
+		if err != nil {
+			fmt.Printf("Leader election: %v\n", err)
+			return
+		}
+		elector.Run(ctx)
+	}
+}
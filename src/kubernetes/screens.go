@@ -0,0 +1,97 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ScreenResource is the CloudKeyScreen custom resource, see doc/cloudkeyscreen-crd.yaml
+var ScreenResource = schema.GroupVersionResource{Group: "cloudkey.llajas.dev", Version: "v1alpha1", Resource: "cloudkeyscreens"}
+
+// ScreenMessage is the content of a CloudKeyScreen resource
+type ScreenMessage struct {
+	Namespace string
+	Name      string
+	Text      string
+	Priority  int       // higher is shown first
+	Expires   time.Time // zero if the message has no TTL
+}
+
+// Expired reports whether the message's TTL has run out
+func (m ScreenMessage) Expired(now time.Time) bool {
+	return !m.Expires.IsZero() && !now.Before(m.Expires)
+}
+
+// screenMessageOf reads a CloudKeyScreen. The TTL counts from the resource's creation.
+func screenMessageOf(obj *unstructured.Unstructured) (ScreenMessage, error) {
+	message := ScreenMessage{Namespace: obj.GetNamespace(), Name: obj.GetName()}
+
+	text, _, err := unstructured.NestedString(obj.Object, "spec", "message")
+	if err != nil {
+		return message, fmt.Errorf("%s/%s: spec.message: %v", message.Namespace, message.Name, err)
+	}
+	message.Text = text
+
+	priority, _, err := unstructured.NestedInt64(obj.Object, "spec", "priority")
+	if err != nil {
+		return message, fmt.Errorf("%s/%s: spec.priority: %v", message.Namespace, message.Name, err)
+	}
+	message.Priority = int(priority)
+
+	ttl, found, err := unstructured.NestedString(obj.Object, "spec", "ttl")
+	if err != nil {
+		return message, fmt.Errorf("%s/%s: spec.ttl: %v", message.Namespace, message.Name, err)
+	}
+	if found && ttl != "" {
+		duration, err := time.ParseDuration(ttl)
+		if err != nil {
+			return message, fmt.Errorf("%s/%s: spec.ttl: %v", message.Namespace, message.Name, err)
+		}
+		message.Expires = obj.GetCreationTimestamp().Add(duration)
+	}
+
+	return message, nil
+}
+
+// WatchScreens calls onChange with every CloudKeyScreen in the namespace (all namespaces if empty),
+// highest priority first, whenever one is added, changed or deleted. It blocks until ctx is done.
+func (c *Client) WatchScreens(ctx context.Context, namespace string, onChange func([]ScreenMessage)) {
+	informer := dynamicinformer.NewFilteredDynamicInformer(c.dynamic, ScreenResource, namespace, 10*time.Minute, cache.Indexers{}, nil).Informer()
+
+	update := func() {
+		var messages []ScreenMessage
+		for _, item := range informer.GetStore().List() {
+			obj, ok := item.(*unstructured.Unstructured)
+			if !ok {
+				continue
+			}
+			message, err := screenMessageOf(obj)
+			if err != nil {
+				fmt.Printf("CloudKeyScreen %v\n", err)
+				continue
+			}
+			messages = append(messages, message)
+		}
+		sort.SliceStable(messages, func(a, b int) bool {
+			if messages[a].Priority != messages[b].Priority {
+				return messages[a].Priority > messages[b].Priority
+			}
+			return messages[a].Name < messages[b].Name
+		})
+		onChange(messages)
+	}
+
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(any) { update() },
+		UpdateFunc: func(any, any) { update() },
+		DeleteFunc: func(any) { update() },
+	})
+	informer.Run(ctx.Done())
+}
@@ -0,0 +1,49 @@
+package leader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+)
+
+// fileRetry is how often a follower tries to take the lock
+const fileRetry = 5 * time.Second
+
+// FileLock elects the instance holding an exclusive lock on a file, for instances sharing a
+// host or a network filesystem with working locks. The lock is released when the process exits.
+type FileLock struct {
+	Path string
+}
+
+// Run tries to lock the file until it succeeds, then leads until ctx is done
+func (l FileLock) Run(ctx context.Context, onChange func(bool)) {
+	file, err := os.OpenFile(l.Path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		fmt.Printf("Leader election: failed to open %s: %v\n", l.Path, err)
+		return
+	}
+	defer file.Close()
+
+	onChange(false)
+	for {
+		err := tryLock(file)
+		if err == nil {
+			break
+		}
+		if err == errUnsupported {
+			fmt.Printf("Leader election: %v\n", err)
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(fileRetry):
+		}
+	}
+
+	onChange(true)
+	<-ctx.Done()
+	unlock(file)
+	onChange(false)
+}
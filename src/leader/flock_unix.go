@@ -0,0 +1,21 @@
+//go:build !windows
+
+package leader
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+var errUnsupported = errors.New("file locks are not supported on this system")
+
+// tryLock takes an exclusive lock on the file without waiting
+func tryLock(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+}
+
+// unlock releases the lock taken by tryLock
+func unlock(file *os.File) {
+	syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
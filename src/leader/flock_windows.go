@@ -0,0 +1,15 @@
+package leader
+
+import (
+	"errors"
+	"os"
+)
+
+var errUnsupported = errors.New("file locks are not supported on Windows")
+
+// tryLock is not implemented on Windows
+func tryLock(file *os.File) error {
+	return errUnsupported
+}
+
+func unlock(file *os.File) {}
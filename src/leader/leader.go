@@ -0,0 +1,40 @@
+package leader
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+)
+
+// Elector decides which of several cloudkey instances publishes metrics and notifications.
+// Run calls onChange whenever this instance gains or loses leadership, until ctx is done.
+type Elector interface {
+	Run(ctx context.Context, onChange func(leading bool))
+}
+
+// leading starts true so that a single instance without an elector always publishes
+var leading atomic.Bool
+
+func init() {
+	leading.Store(true)
+}
+
+// IsLeader reports whether this instance should publish metrics and notifications.
+// Every instance drives its own display regardless.
+func IsLeader() bool {
+	return leading.Load()
+}
+
+// Start runs the elector in the background. This instance is a follower until elected.
+func Start(ctx context.Context, e Elector) {
+	leading.Store(false)
+	go e.Run(ctx, func(isLeader bool) {
+		if leading.Swap(isLeader) != isLeader {
+			if isLeader {
+				fmt.Println("Leader election: this instance is now the leader")
+			} else {
+				fmt.Println("Leader election: this instance is now a follower")
+			}
+		}
+	})
+}
@@ -0,0 +1,124 @@
+package leds
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// gpioLine is an output line of a GPIO chip
+type gpioLine interface {
+	SetValue(value int) error
+	Close() error
+}
+
+// gpioLED drives an LED through a GPIO line. Lines are either on or off, and blinking is
+// done in software since there is no timer trigger.
+type gpioLED struct {
+	line gpioLine
+
+	mu       sync.Mutex
+	delayOn  int // milliseconds
+	delayOff int
+	stop     chan struct{} // stops the software blink, nil when not blinking
+}
+
+var (
+	gpioLEDs      = make(map[string]*gpioLED)
+	gpioLEDsMutex sync.Mutex
+)
+
+// ParseGPIOSpec splits a chip:offset line spec such as gpiochip0:17
+func ParseGPIOSpec(spec string) (string, int, error) {
+	chip, offset, ok := strings.Cut(spec, ":")
+	if !ok || chip == "" {
+		return "", 0, fmt.Errorf("GPIO line %q: expected chip:offset, e.g. gpiochip0:17", spec)
+	}
+	n, err := strconv.Atoi(offset)
+	if err != nil || n < 0 {
+		return "", 0, fmt.Errorf("GPIO line %q: invalid offset %q", spec, offset)
+	}
+	return chip, n, nil
+}
+
+// RegisterGPIO drives the named LED through a GPIO line instead of /sys/class/leds,
+// so LED profiles work on a Raspberry Pi or other board with LEDs wired to GPIO
+func RegisterGPIO(name, spec string) error {
+	chip, offset, err := ParseGPIOSpec(spec)
+	if err != nil {
+		return err
+	}
+	line, err := requestGPIOLine(chip, offset)
+	if err != nil {
+		return fmt.Errorf("failed to request GPIO line %s for %s: %v", spec, name, err)
+	}
+
+	gpioLEDsMutex.Lock()
+	defer gpioLEDsMutex.Unlock()
+
+	if old, ok := gpioLEDs[name]; ok {
+		old.write("trigger", "none")
+		old.line.Close()
+	}
+	gpioLEDs[name] = &gpioLED{line: line, delayOn: 500, delayOff: 500}
+	return nil
+}
+
+// gpioFor returns the GPIO backend of the named LED, if it has one
+func gpioFor(name string) (*gpioLED, bool) {
+	gpioLEDsMutex.Lock()
+	defer gpioLEDsMutex.Unlock()
+
+	g, ok := gpioLEDs[name]
+	return g, ok
+}
+
+// write maps the sysfs attributes used by LED onto the GPIO line
+func (g *gpioLED) write(where, what string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	switch where {
+	case "brightness":
+		value := 1
+		if what == "0" {
+			value = 0
+		}
+		return g.line.SetValue(value)
+	case "delay_on":
+		g.delayOn, _ = strconv.Atoi(what)
+	case "delay_off":
+		g.delayOff, _ = strconv.Atoi(what)
+	case "trigger":
+		if g.stop != nil {
+			close(g.stop)
+			g.stop = nil
+		}
+		if what == "timer" {
+			g.stop = make(chan struct{})
+			go g.blink(g.stop)
+		}
+	}
+	return nil
+}
+
+// blink toggles the line with the current delays until stop is closed
+func (g *gpioLED) blink(stop chan struct{}) {
+	for value := 1; ; value = 1 - value {
+		g.mu.Lock()
+		delay := g.delayOff
+		if value == 1 {
+			delay = g.delayOn
+		}
+		g.line.SetValue(value)
+		g.mu.Unlock()
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(time.Duration(max(delay, 1)) * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,8 @@
+package leds
+
+import "github.com/warthog618/go-gpiocdev"
+
+// requestGPIOLine claims a GPIO line as an output, initially off
+func requestGPIOLine(chip string, offset int) (gpioLine, error) {
+	return gpiocdev.RequestLine(chip, offset, gpiocdev.AsOutput(0), gpiocdev.WithConsumer("cloudkey"))
+}
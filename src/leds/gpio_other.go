@@ -0,0 +1,10 @@
+//go:build !linux
+
+package leds
+
+import "errors"
+
+// requestGPIOLine is only supported on Linux
+func requestGPIOLine(chip string, offset int) (gpioLine, error) {
+	return nil, errors.New("GPIO LEDs are only supported on Linux")
+}
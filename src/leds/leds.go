@@ -1,10 +1,15 @@
 package leds
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
+	"math"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // https://scene-si.org/2016/07/19/building-your-own-build-status-indicator-with-golang-and-rpi3/
@@ -12,6 +17,14 @@ import (
 // Known Cloud Key LED names (regular + rack mount variants)
 var KnownLEDs = []string{"blue", "white", "rack:blue", "rack:white", "ulogo_ctrl"}
 
+// dir holds the led class devices, normally /sys/class/leds
+var dir = "/sys/class/leds"
+
+// SetDir changes where the leds are looked up, e.g. where /sys/class/leds is mounted in a container
+func SetDir(path string) {
+	dir = path
+}
+
 // LED is an individual led
 type LED struct {
 	name string
@@ -24,16 +37,25 @@ func (r LED) Name() string {
 
 // filename returns the /sys path of the led
 func (r LED) filename() string {
-	return "/sys/class/leds/" + r.name
+	return dir + "/" + r.name
 }
 
-// Exists checks if the LED exists on this system
+// Exists checks if the LED exists on this system. In dry-run mode every known LED exists.
 func (r LED) Exists() bool {
+	if _, ok := gpioFor(r.name); ok {
+		return true
+	}
+	if dryRun.Load() && slices.Contains(KnownLEDs, r.name) {
+		return true
+	}
 	_, err := os.Stat(r.filename())
 	return err == nil
 }
 
 func (r LED) read(where string) ([]byte, error) {
+	if _, ok := gpioFor(r.name); ok && where == "max_brightness" {
+		return []byte("1\n"), nil
+	}
 	filename := r.filename() + "/" + where
 	content, err := os.ReadFile(filename)
 	if err != nil {
@@ -42,13 +64,50 @@ func (r LED) read(where string) ([]byte, error) {
 	return content, nil
 }
 
+// write sets an attribute of the led, logging the first of consecutive failures
 func (r LED) write(where, what string) LED {
+	reportWrite(r.name, where, r.set(where, what))
+	return r
+}
+
+// set writes an attribute of the led to sysfs, its GPIO line, or the log in dry-run mode
+func (r LED) set(where, what string) error {
 	if !r.Exists() {
-		return r
+		return nil
+	}
+	if dryRun.Load() {
+		logDryRun(r.name, where, what)
+		return nil
+	}
+	if g, ok := gpioFor(r.name); ok {
+		return g.write(where, what)
 	}
 	filename := r.filename() + "/" + where
-	os.WriteFile(filename, []byte(what), 0666)
-	return r
+	if err := os.WriteFile(filename, []byte(what), 0666); err != nil {
+		if errors.Is(err, fs.ErrPermission) {
+			return fmt.Errorf("%v (%s)", err, permissionHint)
+		}
+		return err
+	}
+	return nil
+}
+
+// Check reports whether the led's brightness can be written, without changing it
+func (r LED) Check() error {
+	if !r.Exists() || dryRun.Load() {
+		return nil
+	}
+	if _, ok := gpioFor(r.name); ok {
+		return nil
+	}
+	file, err := os.OpenFile(r.filename()+"/brightness", os.O_WRONLY, 0)
+	if err != nil {
+		if errors.Is(err, fs.ErrPermission) {
+			return fmt.Errorf("%v (%s)", err, permissionHint)
+		}
+		return err
+	}
+	return file.Close()
 }
 
 // On turns on the led to maximum brightness, and clears the current running trigger (if any)
@@ -74,6 +133,25 @@ func (r LED) Off() LED {
 	return r.write("brightness", "0")
 }
 
+// MaxBrightness returns the highest brightness the led supports, 255 if unknown
+func (r LED) MaxBrightness() int {
+	maxBytes, err := r.read("max_brightness")
+	if err != nil {
+		return 255
+	}
+	max, err := strconv.Atoi(strings.TrimSpace(string(maxBytes)))
+	if err != nil || max <= 0 {
+		return 255
+	}
+	return max
+}
+
+// Level sets the brightness as a fraction (0 to 1) of the maximum brightness
+func (r LED) Level(level float64) LED {
+	level = min(max(level, 0), 1)
+	return r.Brightness(int(math.Round(level * float64(r.MaxBrightness()))))
+}
+
 // Brightness sets the brightness directly, and clears the current running trigger (if any)
 func (r LED) Brightness(i int) LED {
 	if !r.Exists() {
@@ -128,7 +206,31 @@ func PrintDiscoveredLEDs() {
 	found := DiscoverLEDs()
 	if len(found) > 0 {
 		fmt.Printf("Discovered LEDs: %s\n", strings.Join(found, ", "))
+		for _, name := range found {
+			if err := (LED{name: name}).Check(); err != nil {
+				fmt.Printf("LED %s is not writable: %v\n", name, err)
+			}
+		}
 	} else {
 		fmt.Println("No LEDs discovered")
 	}
 }
+
+// blinkCodeOn, blinkCodeOff and blinkCodePause time the blinks of BlinkCode
+const (
+	blinkCodeOn    = 200 * time.Millisecond
+	blinkCodeOff   = 300 * time.Millisecond
+	blinkCodePause = 1500 * time.Millisecond
+)
+
+// BlinkCode repeats n short blinks followed by a pause until stop is closed, then turns the led off
+func (r LED) BlinkCode(n int, stop <-chan struct{}) {
+	var steps []Step
+	for i := 0; i < n; i++ {
+		steps = append(steps, Step{Level: 1, Duration: blinkCodeOn}, Step{Level: 0, Duration: blinkCodeOff})
+	}
+	steps = append(steps, Step{Level: 0, Duration: blinkCodePause})
+
+	r.Play(steps, stop)
+	r.Off()
+}
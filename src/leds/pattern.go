@@ -0,0 +1,56 @@
+package leds
+
+import (
+	"math"
+	"strconv"
+	"time"
+)
+
+// Step holds an led at a brightness level (0 to 1 of its maximum) for a duration
+type Step struct {
+	Level    float64
+	Duration time.Duration
+}
+
+// Play repeats the steps on the led until stop is closed. A nil stop plays forever.
+func (r LED) Play(steps []Step, stop <-chan struct{}) {
+	if len(steps) == 0 {
+		return
+	}
+
+	// Read once, rather than on every step
+	maxBrightness := float64(r.MaxBrightness())
+	r.write("trigger", "none")
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	<-timer.C
+
+	for {
+		for _, step := range steps {
+			r.write("brightness", strconv.Itoa(int(math.Round(step.Level*maxBrightness))))
+			timer.Reset(step.Duration)
+			select {
+			case <-stop:
+				return
+			case <-timer.C:
+			}
+		}
+	}
+}
+
+// Breathing returns a pattern that fades between the low and high levels and back over period
+func Breathing(period time.Duration, low, high float64) []Step {
+	const steps = 40
+
+	pattern := make([]Step, steps)
+	for i := range pattern {
+		// Cosine easing spends longer near the ends, which looks more natural than a linear fade
+		phase := float64(i) / steps * 2 * math.Pi
+		pattern[i] = Step{
+			Level:    low + (high-low)*(1-math.Cos(phase))/2,
+			Duration: period / steps,
+		}
+	}
+	return pattern
+}
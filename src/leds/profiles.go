@@ -0,0 +1,85 @@
+package leds
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// Indication is how a state is shown on a single LED. An empty LED shows nothing.
+type Indication struct {
+	LED   string
+	Blink bool
+}
+
+// Profile maps health states to the LEDs available on one kind of hardware
+type Profile struct {
+	Name     string
+	Health   map[string]Indication // keyed by health state: ok, warning, critical
+	Running  string                // LED kept on while the service runs, if any
+	Activity string                // LED pulsed when new data is fetched, if any
+}
+
+// Profiles are the built-in LED profiles by name
+var Profiles = map[string]Profile{
+	// Cloud Key in the rack mount accessory
+	"rackmount": {
+		Name: "rackmount",
+		Health: map[string]Indication{
+			"ok":       {LED: "rack:blue"},
+			"warning":  {LED: "rack:white"},
+			"critical": {LED: "rack:white", Blink: true},
+		},
+		Running:  "ulogo_ctrl",
+		Activity: "ulogo_ctrl",
+	},
+	// Desk unit with only the main blue and white LEDs
+	"desk": {
+		Name: "desk",
+		Health: map[string]Indication{
+			"ok":       {LED: "blue"},
+			"warning":  {LED: "white"},
+			"critical": {LED: "white", Blink: true},
+		},
+	},
+	// Hardware without usable LEDs
+	"headless": {
+		Name:   "headless",
+		Health: map[string]Indication{},
+	},
+}
+
+// ProfileNames returns the names of the built-in profiles, sorted
+func ProfileNames() []string {
+	var names []string
+	for name := range Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DetectProfile picks the profile matching the LEDs that exist on this system
+func DetectProfile() Profile {
+	found := DiscoverLEDs()
+	switch {
+	case slices.Contains(found, "rack:blue") && slices.Contains(found, "rack:white"):
+		return Profiles["rackmount"]
+	case slices.Contains(found, "blue") && slices.Contains(found, "white"):
+		return Profiles["desk"]
+	default:
+		return Profiles["headless"]
+	}
+}
+
+// SelectProfile returns the named profile, or the detected one for "auto"
+func SelectProfile(name string) (Profile, error) {
+	if name == "auto" {
+		return DetectProfile(), nil
+	}
+	profile, ok := Profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("unknown LED profile %q", name)
+	}
+	return profile, nil
+}
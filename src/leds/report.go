@@ -0,0 +1,61 @@
+package leds
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// permissionHint explains how to let a non-root cloudkey drive the LEDs
+const permissionHint = `run cloudkey as root, or add a udev rule such as ` +
+	`SUBSYSTEM=="leds", ACTION=="add", RUN+="/bin/chmod 0666 /sys/class/leds/%k/brightness /sys/class/leds/%k/trigger /sys/class/leds/%k/delay_on /sys/class/leds/%k/delay_off"`
+
+var (
+	dryRun atomic.Bool
+
+	// failing holds the led attributes whose last write failed, so a pattern rewriting
+	// the brightness several times a second logs the failure once instead of every step
+	failing      = make(map[string]bool)
+	failingMutex sync.Mutex
+
+	// dryRunState holds the last value logged for each led attribute in dry-run mode
+	dryRunState = make(map[string]string)
+	dryRunMutex sync.Mutex
+)
+
+// SetDryRun logs intended LED changes instead of writing them, for development without LEDs.
+// Every known LED is treated as present.
+func SetDryRun(enabled bool) {
+	dryRun.Store(enabled)
+}
+
+// reportWrite logs a failed write to a led attribute, unless the previous write failed too
+func reportWrite(name, where string, err error) {
+	key := name + "/" + where
+
+	failingMutex.Lock()
+	defer failingMutex.Unlock()
+
+	if err == nil {
+		delete(failing, key)
+		return
+	}
+	if !failing[key] {
+		fmt.Printf("LED %s: failed to write %s: %v\n", name, where, err)
+	}
+	failing[key] = true
+}
+
+// logDryRun logs a led attribute change in dry-run mode, skipping unchanged values
+func logDryRun(name, where, what string) {
+	key := name + "/" + where
+
+	dryRunMutex.Lock()
+	defer dryRunMutex.Unlock()
+
+	if last, ok := dryRunState[key]; ok && last == what {
+		return
+	}
+	dryRunState[key] = what
+	fmt.Printf("LED %s (dry run): %s=%s\n", name, where, what)
+}
@@ -0,0 +1,54 @@
+package metrics
+
+// Definition is the contract of a metric. Dashboards and alerts are built on the name, type
+// and label names, so once released they are only ever added to: a metric is renamed or a
+// label removed only in a major release, listed in the changelog. Help texts may change.
+//
+// Names follow cloudkey_<subsystem>_<quantity>_<unit>, in base units (seconds, bytes, percent
+// or ratio), and counters end in _total. Labels shared between metrics keep the same name:
+// site for the controller site, screen for a screen of the carousel and collector for the
+// loop refreshing a screen or watcher.
+type Definition struct {
+	Name   string
+	Kind   Kind
+	Labels []string // in the order they are rendered
+	Help   string
+}
+
+// Catalog lists every metric cloudkey exposes. The tests check it against the metrics set in
+// the code and against testdata/catalog.txt, the released contract.
+var Catalog = []Definition{
+	{"cloudkey_alertmanager_alerts", Gauge, []string{"severity"}, "Alerts firing in Alertmanager by severity."},
+	{"cloudkey_chaos_injected_total", Counter, []string{"fault"}, "Failures injected into collector requests."},
+	{"cloudkey_collector_duration_seconds", Gauge, []string{"collector"}, "How long the last refresh of a collector took."},
+	{"cloudkey_collector_errors_total", Counter, []string{"collector"}, "Refreshes of a collector that failed."},
+	{"cloudkey_collector_runs_total", Counter, []string{"collector"}, "Refreshes of a collector."},
+	{"cloudkey_controller_relogins_total", Counter, nil, "Logins to the controller after the session expired."},
+	{"cloudkey_controller_request_duration_seconds", Gauge, []string{"endpoint"}, "How long the last controller API call to an endpoint took."},
+	{"cloudkey_controller_requests_total", Counter, []string{"endpoint", "status"}, "Controller API calls by endpoint and answer."},
+	{"cloudkey_controller_retries_total", Counter, nil, "Controller queries sent again after their session expired."},
+	{"cloudkey_cpu_irq_percent", Gauge, nil, "CPU time spent handling interrupts."},
+	{"cloudkey_cpu_percent", Gauge, []string{"cpu"}, "CPU usage over the last sample interval."},
+	{"cloudkey_cpu_steal_percent", Gauge, nil, "CPU time taken by the hypervisor."},
+	{"cloudkey_dhcp_leases", Gauge, []string{"site", "network"}, "Connected clients holding an address of the DHCP pool."},
+	{"cloudkey_dhcp_pool_size", Gauge, []string{"site", "network"}, "Addresses in the DHCP pool."},
+	{"cloudkey_gc_cycles", Gauge, nil, "Number of completed GC cycles."},
+	{"cloudkey_gc_pause_seconds", Gauge, nil, "Duration of the most recent GC pause."},
+	{"cloudkey_goroutines", Gauge, nil, "Number of goroutines."},
+	{"cloudkey_heap_bytes", Gauge, nil, "Bytes in live heap objects."},
+	{"cloudkey_k8s_api_error_ratio", Gauge, nil, "Share of recent Kubernetes API checks that failed."},
+	{"cloudkey_k8s_api_latency_seconds", Gauge, nil, "95th percentile Kubernetes API round trip over recent checks."},
+	{"cloudkey_k8s_auth_expiry_timestamp_seconds", Gauge, []string{"kind"}, "When the Kubernetes client certificate or token expires."},
+	{"cloudkey_lan_devices", Gauge, nil, "Devices that answered the last mDNS or SSDP search."},
+	{"cloudkey_leader", Gauge, nil, "Whether this instance publishes metrics and notifications."},
+	{"cloudkey_memory_pressure_percent", Gauge, []string{"kind"}, "Share of time tasks stalled on memory over 60s."},
+	{"cloudkey_outbound_throttled_total", Counter, []string{"destination"}, "Requests held back by the outbound rate limit of their destination."},
+	{"cloudkey_ping_latency_seconds", Gauge, nil, "Latest round trip of the ping monitor."},
+	{"cloudkey_ping_lost_total", Counter, nil, "Pings of the ping monitor that timed out."},
+	{"cloudkey_ram_percent", Gauge, nil, "System memory in use."},
+	{"cloudkey_redraws_coalesced_total", Counter, []string{"screen"}, "Screen redraws replaced by a later one before being drawn."},
+	{"cloudkey_resident_bytes", Gauge, nil, "Bytes held from the OS by the Go runtime."},
+	{"cloudkey_screen_restarts_total", Counter, []string{"screen"}, "Screen pipelines restarted by the watchdog after missing their redraws."},
+	{"cloudkey_swap_percent", Gauge, nil, "System swap in use."},
+	{"cloudkey_wan_usage_bytes", Gauge, []string{"site"}, "WAN traffic of the current billing cycle."},
+}
@@ -0,0 +1,122 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Kind is the Prometheus metric type
+type Kind string
+
+const (
+	Gauge   Kind = "gauge"
+	Counter Kind = "counter"
+)
+
+// family is a metric name with its help text and labelled samples
+type family struct {
+	help    string
+	kind    Kind
+	samples map[string]float64 // keyed by rendered label set
+}
+
+// Registry holds metric values for text exposition
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]*family)}
+}
+
+// Set records the current value of a gauge. Labels are given as name, value pairs.
+func (r *Registry) Set(name, help string, value float64, labels ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.family(name, help, Gauge).samples[labelSet(labels)] = value
+}
+
+// Add increments a counter. Labels are given as name, value pairs.
+func (r *Registry) Add(name, help string, delta float64, labels ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.family(name, help, Counter).samples[labelSet(labels)] += delta
+}
+
+func (r *Registry) family(name, help string, kind Kind) *family {
+	f, ok := r.families[name]
+	if !ok {
+		f = &family{help: help, kind: kind, samples: make(map[string]float64)}
+		r.families[name] = f
+	}
+	return f
+}
+
+// Write renders all metrics in the Prometheus text exposition format, sorted by name
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		f := r.families[name]
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, f.help, name, f.kind); err != nil {
+			return err
+		}
+
+		keys := make([]string, 0, len(f.samples))
+		for k := range f.samples {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if _, err := fmt.Fprintf(w, "%s%s %g\n", name, k, f.samples[k]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// labelSet renders name, value pairs as {a="1",b="2"}
+func labelSet(labels []string) string {
+	if len(labels) < 2 {
+		return ""
+	}
+
+	var parts []string
+	for i := 0; i+1 < len(labels); i += 2 {
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(labels[i+1])
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, labels[i], value))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+var defaultRegistry = NewRegistry()
+
+// Set records a gauge on the default registry
+func Set(name, help string, value float64, labels ...string) {
+	defaultRegistry.Set(name, help, value, labels...)
+}
+
+// Add increments a counter on the default registry
+func Add(name, help string, delta float64, labels ...string) {
+	defaultRegistry.Add(name, help, delta, labels...)
+}
+
+// Write renders the default registry
+func Write(w io.Writer) error {
+	return defaultRegistry.Write(w)
+}
@@ -0,0 +1,286 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// update rewrites the released contract from the catalog, after a deliberate change:
+//
+//	go test ./src/metrics -run TestCatalogContract -update
+var update = flag.Bool("update", false, "rewrite testdata/catalog.txt from the catalog")
+
+var (
+	// metricName is the naming scheme of Definition
+	metricName = regexp.MustCompile(`^cloudkey_[a-z][a-z0-9]*(_[a-z0-9]+)*$`)
+	// sampleLine is a sample of the text exposition format as Write renders it
+	sampleLine = regexp.MustCompile(`^([a-z_][a-z0-9_]*)(\{[a-z_]+="(?:[^"\\]|\\.)*"(?:,[a-z_]+="(?:[^"\\]|\\.)*")*\})? (\S+)$`)
+)
+
+// sharedLabels must be spelled the same by every metric using them
+var sharedLabels = []string{"site", "screen", "collector"}
+
+func TestWrite(t *testing.T) {
+	r := NewRegistry()
+	r.Set("cloudkey_b", "Second.", 2.5, "site", "default", "network", `Guest "5G"`)
+	r.Add("cloudkey_a_total", "First.", 1)
+	r.Add("cloudkey_a_total", "First.", 2)
+	r.Set("cloudkey_b", "Second.", 1, "site", "default", "network", "LAN\\1\n")
+
+	var out bytes.Buffer
+	if err := r.Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP cloudkey_a_total First.
+# TYPE cloudkey_a_total counter
+cloudkey_a_total 3
+# HELP cloudkey_b Second.
+# TYPE cloudkey_b gauge
+cloudkey_b{site="default",network="Guest \"5G\""} 2.5
+cloudkey_b{site="default",network="LAN\\1\n"} 1
+`
+	if out.String() != want {
+		t.Errorf("exposition:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+// TestExposition renders every metric of the catalog and checks the output parses as the
+// Prometheus text format, with the declared type and labels
+func TestExposition(t *testing.T) {
+	r := NewRegistry()
+	for _, d := range Catalog {
+		var labels []string
+		for _, l := range d.Labels {
+			labels = append(labels, l, "value of "+l)
+		}
+		if d.Kind == Counter {
+			r.Add(d.Name, d.Help, 1, labels...)
+		} else {
+			r.Set(d.Name, d.Help, 0.25, labels...)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := r.Write(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	byName := map[string]Definition{}
+	for _, d := range Catalog {
+		byName[d.Name] = d
+	}
+	typed := map[string]bool{}
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if help, ok := strings.CutPrefix(line, "# HELP "); ok {
+			name, text, _ := strings.Cut(help, " ")
+			if text != byName[name].Help {
+				t.Errorf("%s: help %q, want %q", name, text, byName[name].Help)
+			}
+			continue
+		}
+		if typ, ok := strings.CutPrefix(line, "# TYPE "); ok {
+			name, kind, _ := strings.Cut(typ, " ")
+			if Kind(kind) != byName[name].Kind {
+				t.Errorf("%s: type %s, want %s", name, kind, byName[name].Kind)
+			}
+			typed[name] = true
+			continue
+		}
+
+		m := sampleLine.FindStringSubmatch(line)
+		if m == nil {
+			t.Errorf("not a valid sample line: %q", line)
+			continue
+		}
+		if !typed[m[1]] {
+			t.Errorf("%s: sample before its TYPE line", m[1])
+		}
+		if _, err := strconv.ParseFloat(m[3], 64); err != nil {
+			t.Errorf("%s: value %q: %v", m[1], m[3], err)
+		}
+		var names []string
+		for _, pair := range regexp.MustCompile(`([a-z_]+)="`).FindAllStringSubmatch(m[2], -1) {
+			names = append(names, pair[1])
+		}
+		if !slices.Equal(names, byName[m[1]].Labels) {
+			t.Errorf("%s: labels %v, want %v", m[1], names, byName[m[1]].Labels)
+		}
+	}
+	if len(typed) != len(Catalog) {
+		t.Errorf("%d metrics rendered, %d in the catalog", len(typed), len(Catalog))
+	}
+}
+
+// TestCatalogNaming checks the catalog follows the naming scheme
+func TestCatalogNaming(t *testing.T) {
+	seen := map[string]bool{}
+	for _, d := range Catalog {
+		if seen[d.Name] {
+			t.Errorf("%s: listed twice", d.Name)
+		}
+		seen[d.Name] = true
+
+		if !metricName.MatchString(d.Name) {
+			t.Errorf("%s: does not match %s", d.Name, metricName)
+		}
+		if total := strings.HasSuffix(d.Name, "_total"); total != (d.Kind == Counter) {
+			t.Errorf("%s: counters, and only counters, end in _total", d.Name)
+		}
+		if d.Help == "" || !strings.HasSuffix(d.Help, ".") {
+			t.Errorf("%s: help should be a sentence", d.Name)
+		}
+		for _, l := range d.Labels {
+			if !regexp.MustCompile(`^[a-z][a-z_]*$`).MatchString(l) {
+				t.Errorf("%s: label %q is not snake case", d.Name, l)
+			}
+			for _, shared := range sharedLabels {
+				if l != shared && strings.Contains(l, shared) {
+					t.Errorf("%s: label %q should be %q", d.Name, l, shared)
+				}
+			}
+		}
+	}
+}
+
+// TestCatalogContract compares the catalog with the released contract, so a metric is not
+// renamed, retyped or relabelled by accident. New metrics are added with -update.
+func TestCatalogContract(t *testing.T) {
+	var current bytes.Buffer
+	for _, d := range Catalog {
+		labels := strings.Join(d.Labels, ",")
+		if labels == "" {
+			labels = "-"
+		}
+		fmt.Fprintf(&current, "%s %s %s\n", d.Name, d.Kind, labels)
+	}
+
+	path := filepath.Join("testdata", "catalog.txt")
+	if *update {
+		if err := os.WriteFile(path, current.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	released, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run with -update to create it)", err)
+	}
+
+	have := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(current.String()), "\n") {
+		name, rest, _ := strings.Cut(line, " ")
+		have[name] = rest
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(released)), "\n") {
+		name, rest, _ := strings.Cut(line, " ")
+		switch now, ok := have[name]; {
+		case !ok:
+			t.Errorf("%s was released and is missing from the catalog", name)
+		case now != rest:
+			t.Errorf("%s was released as %q and is now %q", name, rest, now)
+		}
+		delete(have, name)
+	}
+	for name := range have {
+		t.Errorf("%s is new: add it to the contract with -update", name)
+	}
+}
+
+// TestCallSites checks every metric set in the code is in the catalog, with its type, labels
+// and help text
+func TestCallSites(t *testing.T) {
+	byName := map[string]Definition{}
+	for _, d := range Catalog {
+		byName[d.Name] = d
+	}
+	used := map[string]bool{}
+
+	root := filepath.Join("..", "..")
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return err
+		}
+		file, err := parser.ParseFile(token.NewFileSet(), path, nil, 0)
+		if err != nil {
+			return err
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok || (sel.Sel.Name != "Set" && sel.Sel.Name != "Add") || len(call.Args) < 3 {
+				return true
+			}
+			if pkg, ok := sel.X.(*ast.Ident); !ok || pkg.Name != "metrics" {
+				return true
+			}
+
+			name, ok := stringLiteral(call.Args[0])
+			if !ok {
+				t.Errorf("%s: metric name is not a literal", path)
+				return true
+			}
+			used[name] = true
+			d, ok := byName[name]
+			if !ok {
+				t.Errorf("%s: %s is not in the catalog", path, name)
+				return true
+			}
+			if kind := map[string]Kind{"Set": Gauge, "Add": Counter}[sel.Sel.Name]; kind != d.Kind {
+				t.Errorf("%s: %s is used as a %s, the catalog says %s", path, name, kind, d.Kind)
+			}
+			if help, _ := stringLiteral(call.Args[1]); help != d.Help {
+				t.Errorf("%s: %s has help %q, the catalog says %q", path, name, help, d.Help)
+			}
+			var labels []string
+			for i := 3; i < len(call.Args); i += 2 {
+				label, ok := stringLiteral(call.Args[i])
+				if !ok {
+					t.Errorf("%s: %s has a label name that is not a literal", path, name)
+				}
+				labels = append(labels, label)
+			}
+			if !slices.Equal(labels, d.Labels) {
+				t.Errorf("%s: %s has labels %v, the catalog says %v", path, name, labels, d.Labels)
+			}
+			return true
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, d := range Catalog {
+		if !used[d.Name] {
+			t.Errorf("%s is in the catalog but never set", d.Name)
+		}
+	}
+}
+
+// stringLiteral returns the value of a string literal expression
+func stringLiteral(expr ast.Expr) (string, bool) {
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	s, err := strconv.Unquote(lit.Value)
+	return s, err == nil
+}
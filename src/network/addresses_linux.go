@@ -0,0 +1,65 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"syscall"
+)
+
+// Multicast groups of rtnetlink, from linux/rtnetlink.h, which syscall does not define
+const (
+	rtmgrpLink       = 0x1
+	rtmgrpIPv4IfAddr = 0x10
+)
+
+// watchAddresses listens on a netlink socket for IPv4 addresses and links changing
+func watchAddresses(ctx context.Context) (<-chan struct{}, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_ROUTE)
+	if err != nil {
+		return nil, fmt.Errorf("netlink socket: %v", err)
+	}
+	groups := uint32(rtmgrpIPv4IfAddr | rtmgrpLink)
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: groups}); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("netlink bind: %v", err)
+	}
+
+	// Closing the socket ends the blocking read below
+	go func() {
+		<-ctx.Done()
+		syscall.Shutdown(fd, syscall.SHUT_RDWR)
+		syscall.Close(fd)
+	}()
+
+	changes := make(chan struct{}, 1)
+	go func() {
+		defer close(changes)
+		buf := make([]byte, 1<<16)
+		for {
+			n, _, err := syscall.Recvfrom(fd, buf, 0)
+			if ctx.Err() != nil {
+				return
+			}
+			if err == syscall.EINTR || err == syscall.ENOBUFS {
+				// ENOBUFS means events were dropped, which is a change all the same
+				notify(changes)
+				continue
+			}
+			if err != nil {
+				return
+			}
+
+			messages, err := syscall.ParseNetlinkMessage(buf[:n])
+			if err != nil {
+				continue
+			}
+			for _, m := range messages {
+				switch m.Header.Type {
+				case syscall.RTM_NEWADDR, syscall.RTM_DELADDR, syscall.RTM_NEWLINK, syscall.RTM_DELLINK:
+					notify(changes)
+				}
+			}
+		}
+	}()
+	return changes, nil
+}
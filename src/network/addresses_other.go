@@ -0,0 +1,13 @@
+//go:build !linux
+
+package network
+
+import (
+	"context"
+	"errors"
+)
+
+// watchAddresses is only supported on Linux
+func watchAddresses(ctx context.Context) (<-chan struct{}, error) {
+	return nil, errors.New("watching address changes is only supported on Linux")
+}
@@ -0,0 +1,58 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	// ArchiveChunk is the time range fetched per archive request. The controller answers a
+	// single request for months of results slowly and with a response too large for the Cloud Key.
+	ArchiveChunk = 7 * 24 * time.Hour
+	// ArchiveRequestInterval spaces out the chunk requests so a long range does not load the controller
+	ArchiveRequestInterval = 500 * time.Millisecond
+)
+
+// WalkSpeedtestArchive walks the speedtest archive from start to end (unix milliseconds) one
+// ArchiveChunk at a time, calling each with the valid results of every chunk, oldest first.
+// Only one chunk is held in memory. It stops at the first error from the controller or each.
+func (c *UDMProClient) WalkSpeedtestArchive(ctx context.Context, start, end int64, each func([]SpeedtestResult) error) error {
+	chunk := ArchiveChunk.Milliseconds()
+
+	for from := start; from < end; from += chunk {
+		if from > start {
+			time.Sleep(ArchiveRequestInterval)
+		}
+
+		to := from + chunk
+		if to > end {
+			to = end
+		}
+		rows, err := c.speedtestRows(ctx, from, to)
+		if err != nil {
+			return fmt.Errorf("archive %s to %s: %v",
+				time.UnixMilli(from).Format(time.DateOnly), time.UnixMilli(to).Format(time.DateOnly), err)
+		}
+
+		var results []SpeedtestResult
+		for n := range rows {
+			// Chunk boundaries are inclusive on the controller, so skip results of the next chunk
+			if rows[n].Time < from || rows[n].Time >= to {
+				continue
+			}
+			if rows[n].XputDownload > 0 || rows[n].XputUpload > 0 {
+				results = append(results, *c.convertSpeedtestResult(&rows[n]))
+			}
+		}
+		sort.Slice(results, func(a, b int) bool { return results[a].Timestamp < results[b].Timestamp })
+
+		if len(results) > 0 {
+			if err := each(results); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package network
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ResponseFormat is the shape of a controller's JSON responses
+type ResponseFormat int
+
+const (
+	// FormatMeta is the classic {"meta": {"rc": "ok"}, "data": [...]} envelope
+	FormatMeta ResponseFormat = iota
+	// FormatArray is a bare JSON array without an envelope
+	FormatArray
+	// FormatV2 is the v2 API {"errorCode": 0, "data": [...]} envelope
+	FormatV2
+)
+
+// String returns the name of the format for logs
+func (f ResponseFormat) String() string {
+	switch f {
+	case FormatArray:
+		return "array"
+	case FormatV2:
+		return "v2"
+	default:
+		return "meta"
+	}
+}
+
+// Capabilities describes how a controller version family speaks the API
+type Capabilities struct {
+	Family      string
+	UniFiOS     bool
+	MinVersion  string // lowest Network application version of the family
+	PathPrefix  string // prepended to every /api path
+	LoginPath   string
+	CookieName  string // cookie carrying the session token
+	CSRFFromJWT bool   // the CSRF token is embedded in the session JWT
+	Format      ResponseFormat
+	// Guessed is set when the version was unknown, so the family is only the platform's oldest
+	Guessed bool
+}
+
+// capabilityTable is the support matrix, ordered from newest to oldest within each platform
+var capabilityTable = []Capabilities{
+	{
+		Family:      "unifi-os",
+		UniFiOS:     true,
+		MinVersion:  "7.0.0",
+		PathPrefix:  "/proxy/network",
+		LoginPath:   "/api/auth/login",
+		CookieName:  "TOKEN",
+		CSRFFromJWT: true,
+		Format:      FormatMeta,
+	},
+	{
+		Family:      "unifi-os-legacy",
+		UniFiOS:     true,
+		MinVersion:  "0",
+		PathPrefix:  "/proxy/network",
+		LoginPath:   "/api/auth/login",
+		CookieName:  "TOKEN",
+		CSRFFromJWT: true,
+		Format:      FormatArray,
+	},
+	{
+		Family:      "classic",
+		UniFiOS:     false,
+		MinVersion:  "0",
+		PathPrefix:  "",
+		LoginPath:   "/api/login",
+		CookieName:  "unifises",
+		CSRFFromJWT: false,
+		Format:      FormatMeta,
+	},
+}
+
+// CapabilitiesFor returns the API behaviour of a Network application version on the given platform
+func CapabilitiesFor(version string, unifiOS bool) Capabilities {
+	for _, caps := range capabilityTable {
+		if caps.UniFiOS == unifiOS && CompareVersions(version, caps.MinVersion) >= 0 {
+			caps.Guessed = !versionKnown(version)
+			return caps
+		}
+	}
+	return capabilityTable[len(capabilityTable)-1]
+}
+
+// versionKnown reports whether version starts with a number a family can be picked by
+func versionKnown(version string) bool {
+	major, _, _ := strings.Cut(strings.TrimPrefix(version, "v"), ".")
+	_, err := strconv.Atoi(major)
+	return err == nil
+}
+
+// formats returns the response formats to decode with: the family's own, followed by the
+// other known ones only when the family was guessed
+func (c Capabilities) formats() []ResponseFormat {
+	list := []ResponseFormat{c.Format}
+	if !c.Guessed {
+		return list
+	}
+	for _, f := range []ResponseFormat{FormatMeta, FormatArray, FormatV2} {
+		if f != c.Format {
+			list = append(list, f)
+		}
+	}
+	return list
+}
+
+// CompareVersions compares dotted version strings numerically, returning -1, 0 or 1.
+// Missing or non-numeric components count as zero, so "8.0" == "8.0.0".
+func CompareVersions(a, b string) int {
+	pa := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	pb := strings.Split(strings.TrimPrefix(b, "v"), ".")
+	for i := 0; i < max(len(pa), len(pb)); i++ {
+		var na, nb int
+		if i < len(pa) {
+			na, _ = strconv.Atoi(pa[i])
+		}
+		if i < len(pb) {
+			nb, _ = strconv.Atoi(pb[i])
+		}
+		if na != nb {
+			if na < nb {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,96 @@
+package network
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCapabilitiesFor(t *testing.T) {
+	tests := []struct {
+		version string
+		unifiOS bool
+		family  string
+		format  ResponseFormat
+		guessed bool
+	}{
+		{"9.0.114", true, "unifi-os", FormatMeta, false},
+		{"8.0.28", true, "unifi-os", FormatMeta, false},
+		{"v8.1", true, "unifi-os", FormatMeta, false},
+		{"7.0.0", true, "unifi-os", FormatMeta, false},
+		{"7.5.187", true, "unifi-os", FormatMeta, false},
+		{"6.5.55", true, "unifi-os-legacy", FormatArray, false},
+		{"6.99.99", true, "unifi-os-legacy", FormatArray, false},
+		{"", true, "unifi-os-legacy", FormatArray, true},
+		{"unknown", true, "unifi-os-legacy", FormatArray, true},
+		{"9.0.114", false, "classic", FormatMeta, false},
+		{"5.14.23", false, "classic", FormatMeta, false},
+		{"", false, "classic", FormatMeta, true},
+	}
+	for _, tt := range tests {
+		caps := CapabilitiesFor(tt.version, tt.unifiOS)
+		if caps.Family != tt.family || caps.Format != tt.format || caps.Guessed != tt.guessed {
+			t.Errorf("CapabilitiesFor(%q, %v) = %s %s guessed %v, want %s %s guessed %v",
+				tt.version, tt.unifiOS, caps.Family, caps.Format, caps.Guessed, tt.family, tt.format, tt.guessed)
+		}
+		if caps.UniFiOS != tt.unifiOS {
+			t.Errorf("CapabilitiesFor(%q, %v) picked the %s family of the other platform", tt.version, tt.unifiOS, caps.Family)
+		}
+	}
+}
+
+func TestCapabilityTableFamilies(t *testing.T) {
+	// Rows that only differ by name or version range belong in one family
+	for i, a := range capabilityTable {
+		for _, b := range capabilityTable[i+1:] {
+			a.Family, a.MinVersion, b.Family, b.MinVersion = "", "", "", ""
+			if a == b {
+				t.Errorf("families %s and %s have the same capabilities", capabilityTable[i].Family, b.Family)
+			}
+		}
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"8.0.28", "8.0.28", 0},
+		{"8.0", "8.0.0", 0},
+		{"v8.0.0", "8.0.0", 0},
+		{"8.0.28", "8.0.7", 1},
+		{"7.5.187", "8.0.0", -1},
+		{"10.0.1", "9.9.9", 1},
+		{"6.5.55", "7.0.0", -1},
+		{"7.0.0", "0", 1},
+		{"", "0", 0},
+		{"8.x.1", "8.0.1", 0},
+	}
+	for _, tt := range tests {
+		if got := CompareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := CompareVersions(tt.b, tt.a); got != -tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
+
+func TestCapabilitiesFormats(t *testing.T) {
+	tests := []struct {
+		version string
+		unifiOS bool
+		want    []ResponseFormat
+	}{
+		{"9.0.114", true, []ResponseFormat{FormatMeta}},
+		{"6.5.55", true, []ResponseFormat{FormatArray}},
+		{"9.0.114", false, []ResponseFormat{FormatMeta}},
+		{"", true, []ResponseFormat{FormatArray, FormatMeta, FormatV2}},
+		{"", false, []ResponseFormat{FormatMeta, FormatArray, FormatV2}},
+	}
+	for _, tt := range tests {
+		if got := CapabilitiesFor(tt.version, tt.unifiOS).formats(); !slices.Equal(got, tt.want) {
+			t.Errorf("formats for %q (UniFi OS %v) = %v, want %v", tt.version, tt.unifiOS, got, tt.want)
+		}
+	}
+}
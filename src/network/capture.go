@@ -0,0 +1,110 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// sensitiveKeys are JSON keys whose values are replaced in captured responses
+var sensitiveKeys = []string{"token", "password", "passphrase", "secret", "csrf", "cookie", "x_shadow", "key", "auth"}
+
+// captures numbers the captured responses, so two in the same millisecond keep their own file
+var captures atomic.Uint64
+
+// jwtPattern matches JSON Web Tokens embedded in string values
+var jwtPattern = regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+
+// CaptureHooks write a sanitized copy of every controller response to dir, as fixtures for
+// tests or to attach to a bug report
+func (c *UDMProClient) CaptureHooks(dir string) Hooks {
+	return Hooks{OnResponse: func(_ context.Context, call Call, result Result) {
+		if result.Status != 0 && (call.Kind == "query" || call.Kind == "login") {
+			c.capture(dir, call.Method, call.Path, result.Status, result.Body)
+		}
+	}}
+}
+
+// capture writes a sanitized copy of a controller response to dir
+func (c *UDMProClient) capture(dir, method, path string, status int, body []byte) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		fmt.Printf("Capture error: %v\n", err)
+		return
+	}
+
+	name := strings.Trim(strings.NewReplacer("/", "_", ".", "_", "?", "_").Replace(path), "_")
+	stamp := time.Now().Format("20060102-150405.000")
+	filename := filepath.Join(dir, fmt.Sprintf("%s-%d-%s-%s.json", stamp, captures.Add(1), method, name))
+
+	version, caps := c.Controller()
+	record := map[string]any{
+		"method":  method,
+		"path":    path,
+		"status":  status,
+		"family":  caps.Family,
+		"version": version,
+		"body":    json.RawMessage(SanitizeResponse(body)),
+	}
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		fmt.Printf("Capture error: %v\n", err)
+		return
+	}
+	if err := os.WriteFile(filename, data, 0600); err != nil {
+		fmt.Printf("Capture error: %v\n", err)
+	}
+}
+
+// SanitizeResponse strips credentials and tokens from a controller response so it can be shared.
+// Non-JSON bodies are returned as a JSON string with any JWTs removed.
+func SanitizeResponse(body []byte) []byte {
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		out, _ := json.Marshal(jwtPattern.ReplaceAllString(string(body), "REDACTED"))
+		return out
+	}
+
+	out, err := json.Marshal(sanitizeValue(v))
+	if err != nil {
+		return []byte(`"REDACTED"`)
+	}
+	return out
+}
+
+func sanitizeValue(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, val := range t {
+			if isSensitiveKey(k) {
+				t[k] = "REDACTED"
+			} else {
+				t[k] = sanitizeValue(val)
+			}
+		}
+		return t
+	case []any:
+		for i := range t {
+			t[i] = sanitizeValue(t[i])
+		}
+		return t
+	case string:
+		return jwtPattern.ReplaceAllString(t, "REDACTED")
+	}
+	return v
+}
+
+func isSensitiveKey(key string) bool {
+	lower := strings.ToLower(key)
+	for _, s := range sensitiveKeys {
+		if strings.Contains(lower, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,140 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// metaResponse is the classic {"meta": {"rc": "ok"}, "data": [...]} envelope
+type metaResponse struct {
+	Meta struct {
+		RC  string `json:"rc"`
+		Msg string `json:"msg,omitempty"`
+	} `json:"meta"`
+	Data json.RawMessage `json:"data"`
+}
+
+// v2Response is the v2 API {"errorCode": 0, "message": "", "data": [...]} envelope
+type v2Response struct {
+	ErrorCode int             `json:"errorCode"`
+	Message   string          `json:"message"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// decoder extracts the data payload of a response in one format;
+// matched is false if the body is not in that format at all
+type decoder func(body []byte) (data json.RawMessage, matched bool, err error)
+
+var decoders = map[ResponseFormat]decoder{
+	FormatMeta:  decodeMeta,
+	FormatArray: decodeArray,
+	FormatV2:    decodeV2,
+}
+
+// decodeMeta unwraps the classic envelope, turning rc != "ok" into an error
+func decodeMeta(body []byte) (json.RawMessage, bool, error) {
+	var resp metaResponse
+	if err := json.Unmarshal(body, &resp); err != nil || resp.Meta.RC == "" {
+		return nil, false, nil
+	}
+
+	if resp.Meta.RC != "ok" {
+		if resp.Meta.RC == "error" {
+			errorMsg := "Unknown error from controller"
+			if resp.Meta.Msg != "" {
+				errorMsg = clip(resp.Meta.Msg)
+			}
+			return nil, true, fmt.Errorf("API error: %s", errorMsg)
+		}
+		return nil, true, fmt.Errorf("API returned status: %s", clip(resp.Meta.RC))
+	}
+	return resp.Data, true, nil
+}
+
+// decodeArray accepts a bare JSON array as the payload
+func decodeArray(body []byte) (json.RawMessage, bool, error) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 || trimmed[0] != '[' {
+		return nil, false, nil
+	}
+	return trimmed, true, nil
+}
+
+// decodeV2 unwraps the v2 envelope, turning a non-zero errorCode into an error
+func decodeV2(body []byte) (json.RawMessage, bool, error) {
+	var resp v2Response
+	if err := json.Unmarshal(body, &resp); err != nil || (resp.ErrorCode == 0 && resp.Data == nil) {
+		return nil, false, nil
+	}
+
+	if resp.ErrorCode != 0 {
+		errorMsg := "Unknown error from v2 API"
+		if resp.Message != "" {
+			errorMsg = clip(resp.Message)
+		}
+		return nil, true, fmt.Errorf("v2 API error (code %d): %s", resp.ErrorCode, errorMsg)
+	}
+	return resp.Data, true, nil
+}
+
+// decodeResponse unwraps a response in the format of the controller family, or in the first
+// known format that matches while the family is guessed, and unmarshals its payload into v
+func (c *UDMProClient) decodeResponse(ctx context.Context, answer reply, v any) error {
+	// A response the controller reported unchanged was unwrapped before
+	if data, format, ok := c.responses.payload(answer); ok {
+		if err := json.Unmarshal(data, v); err != nil {
+			return fmt.Errorf("failed to parse %s response data: %v", format, err)
+		}
+		return nil
+	}
+
+	body := answer.body
+	caps, _ := c.api()
+	formats := caps.formats()
+	for _, format := range formats {
+		data, matched, err := decoders[format](body)
+		if !matched {
+			continue
+		}
+		if format != formats[0] {
+			fmt.Printf("Warning: response used %s format, expected %s for %s\n", format, formats[0], caps.Family)
+		}
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, v); err != nil {
+			return fmt.Errorf("failed to parse %s response data: %v", format, err)
+		}
+		c.responses.setPayload(answer, data, format)
+		return nil
+	}
+
+	// If all parsing attempts fail, return the start of the raw response for debugging
+	if len(formats) == 1 {
+		return fmt.Errorf("failed to parse response in the %s format of %s controllers. Raw response: %s", formats[0], caps.Family, excerpt(body))
+	}
+	return fmt.Errorf("failed to parse response in any known format. Raw response: %s", excerpt(body))
+}
+
+// maxExcerpt is how much of a response body excerpt quotes
+const maxExcerpt = 200
+
+// excerpt quotes the start of a response body for an error message, so a huge or binary
+// body cannot flood the logs or smuggle control characters into them
+func excerpt(body []byte) string {
+	if len(body) <= maxExcerpt {
+		return strconv.Quote(string(body))
+	}
+	return fmt.Sprintf("%s... (%d bytes)", strconv.Quote(string(body[:maxExcerpt])), len(body))
+}
+
+// clip shortens a message taken from a response body for an error message
+func clip(msg string) string {
+	if len(msg) <= maxExcerpt && strconv.CanBackquote(msg) {
+		return msg
+	}
+	return excerpt([]byte(msg))
+}
@@ -0,0 +1,64 @@
+package network
+
+import (
+	"context"
+	"testing"
+)
+
+// FuzzDecodeResponse feeds arbitrary bodies through the decoder chain of every controller
+// family, as a broken or hostile controller could send
+func FuzzDecodeResponse(f *testing.F) {
+	f.Add([]byte(`{"meta":{"rc":"ok"},"data":[{"xput_download":512.3,"xput_upload":41.2,"latency":9,"time":1735689600000}]}`))
+	f.Add([]byte(`{"meta":{"rc":"error","msg":"api.err.LoginRequired"},"data":[]}`))
+	f.Add([]byte(`{"errorCode":0,"message":"","data":[{"xput_download":1.5e3}]}`))
+	f.Add([]byte(`{"errorCode":401,"message":"unauthorized"}`))
+	f.Add([]byte(`[{"xput_download":"fast","time":-1}]`))
+	f.Add([]byte(`{"meta":{"rc":"ok"},"data":[{"xput_download":`))
+	f.Add([]byte("\x00\xff<html>502 Bad Gateway</html>"))
+
+	families := []Capabilities{
+		CapabilitiesFor("9.0.114", true),
+		CapabilitiesFor("7.5.187", true),
+		CapabilitiesFor("6.5.55", true),
+		CapabilitiesFor("6.5.55", false),
+	}
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		for _, caps := range families {
+			c := &UDMProClient{Caps: caps}
+
+			var rows []speedtestRow
+			err := c.decodeResponse(context.Background(), reply{body: body}, &rows)
+			if err != nil {
+				if len(err.Error()) > 8*maxExcerpt+200 {
+					t.Fatalf("%s: error quotes too much of a %d byte body: %d bytes", caps.Family, len(body), len(err.Error()))
+				}
+				continue
+			}
+			for i := range rows {
+				c.convertSpeedtestResult(&rows[i])
+			}
+		}
+	})
+}
+
+// FuzzExtractCSRFToken feeds arbitrary session cookies through the JWT payload parsing
+func FuzzExtractCSRFToken(f *testing.F) {
+	f.Add("eyJhbGciOiJIUzI1NiJ9.eyJjc3JmVG9rZW4iOiJhYmMxMjMifQ.c2ln")
+	f.Add("eyJhbGciOiJIUzI1NiJ9.eyJ0b2tlbiI6MTJ9.c2ln")
+	f.Add("a.b")
+	f.Add("..")
+	f.Add("x.eyJjc3JmVG9rZW4iOiIifQ.y")
+	f.Add("x.-_-_.y")
+
+	caps := CapabilitiesFor("9.0.114", true)
+
+	f.Fuzz(func(t *testing.T, token string) {
+		c := &UDMProClient{Caps: caps, AuthToken: token}
+		if err := c.extractCSRFToken(); err != nil {
+			if c.CSRFToken != "" {
+				t.Fatalf("CSRF token %q set despite error %v", c.CSRFToken, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,86 @@
+package network
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDecodeResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		caps    Capabilities
+		rows    int
+		latency float64 // of the first row
+		err     string  // part of the error, empty when the response decodes
+	}{
+		{"meta", "speedtest-meta.json", CapabilitiesFor("9.0.114", true), 2, 9, ""},
+		{"meta classic", "speedtest-meta.json", CapabilitiesFor("9.0.114", false), 2, 9, ""},
+		{"array", "speedtest-array.json", CapabilitiesFor("6.5.55", true), 2, 23, ""},
+		{"meta guessed", "speedtest-meta.json", CapabilitiesFor("", true), 2, 9, ""},
+		{"v2 guessed", "speedtest-v2.json", CapabilitiesFor("", true), 1, 4, ""},
+		{"array guessed", "speedtest-array.json", CapabilitiesFor("", false), 2, 23, ""},
+
+		{"array from meta family", "speedtest-array.json", CapabilitiesFor("9.0.114", true), 0, 0, "the meta format of unifi-os controllers"},
+		{"meta from array family", "speedtest-meta.json", CapabilitiesFor("6.5.55", true), 0, 0, "the array format of unifi-os-legacy controllers"},
+		{"v2 from meta family", "speedtest-v2.json", CapabilitiesFor("9.0.114", false), 0, 0, "the meta format of classic controllers"},
+		{"meta error", "error-meta.json", CapabilitiesFor("9.0.114", true), 0, 0, "API error: api.err.LoginRequired"},
+		{"meta error guessed", "error-meta-nosite.json", CapabilitiesFor("", true), 0, 0, "API error: api.err.NoSiteContext"},
+		{"v2 error guessed", "error-v2.json", CapabilitiesFor("", false), 0, 0, "v2 API error (code 401): unauthorized"},
+		{"html", "bad-gateway.html", CapabilitiesFor("9.0.114", true), 0, 0, `Raw response: "<html>\n<head><title>502 Bad Gateway`},
+		{"html guessed", "bad-gateway.html", CapabilitiesFor("", true), 0, 0, "failed to parse response in any known format"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := os.ReadFile(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			c := &UDMProClient{Caps: tt.caps}
+
+			var rows []speedtestRow
+			err = c.decodeResponse(context.Background(), reply{body: body}, &rows)
+			switch {
+			case tt.err == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tt.err != "" && err == nil:
+				t.Fatalf("decoded %d rows, want an error containing %q", len(rows), tt.err)
+			case tt.err != "" && !strings.Contains(err.Error(), tt.err):
+				t.Fatalf("error %q does not contain %q", err, tt.err)
+			case tt.err != "":
+				return
+			}
+			if len(rows) != tt.rows || rows[0].Latency != tt.latency {
+				t.Errorf("decoded %+v, want %d rows with a first latency of %v", rows, tt.rows, tt.latency)
+			}
+		})
+	}
+}
+
+func TestDecodeResponseMalformed(t *testing.T) {
+	c := &UDMProClient{Caps: CapabilitiesFor("9.0.114", true)}
+	tests := []struct {
+		name string
+		body string
+		err  string
+	}{
+		{"unknown rc", `{"meta":{"rc":"busy"},"data":[]}`, "API returned status: busy"},
+		{"error without message", `{"meta":{"rc":"error"},"data":[]}`, "API error: Unknown error from controller"},
+		{"data of the wrong type", `{"meta":{"rc":"ok"},"data":[{"latency":"fast"}]}`, "failed to parse meta response data"},
+		{"truncated", `{"meta":{"rc":"ok"},"data":[{"latency":`, "failed to parse response in the meta format"},
+		{"empty", ``, "failed to parse response in the meta format"},
+		{"long message", `{"meta":{"rc":"error","msg":"` + strings.Repeat("x", 2*maxExcerpt) + `"}}`, "... (400 bytes)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rows []speedtestRow
+			err := c.decodeResponse(context.Background(), reply{body: []byte(tt.body)}, &rows)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got error %v, want one containing %q", err, tt.err)
+			}
+		})
+	}
+}
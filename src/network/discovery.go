@@ -0,0 +1,235 @@
+package network
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+var (
+	mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+	ssdpGroup = &net.UDPAddr{IP: net.IPv4(239, 255, 255, 250), Port: 1900}
+)
+
+// DiscoveredDevice is a LAN host that answered an mDNS or SSDP search
+type DiscoveredDevice struct {
+	IP        string   `json:"ip"`
+	MAC       string   `json:"mac,omitempty"`  // from the kernel ARP table, when the host is in it
+	Name      string   `json:"name,omitempty"` // mDNS host name or SSDP server string
+	Protocols []string `json:"protocols"`      // mdns, ssdp or both
+}
+
+// Key identifies the device across scans, by MAC where known as addresses move with DHCP
+func (d DiscoveredDevice) Key() string {
+	if d.MAC != "" {
+		return d.MAC
+	}
+	return d.IP
+}
+
+// Discover searches the LAN with mDNS and SSDP for window and returns the hosts that answered,
+// sorted by address. Answers are sent straight back to the searching socket, so no multicast
+// group needs to be joined. Only a protocol that failed entirely is reported as an error.
+func Discover(ctx context.Context, window time.Duration) ([]DiscoveredDevice, error) {
+	devices := map[string]*DiscoveredDevice{}
+	found := func(ip, name, protocol string) {
+		d, ok := devices[ip]
+		if !ok {
+			d = &DiscoveredDevice{IP: ip}
+			devices[ip] = d
+		}
+		if d.Name == "" || protocol == "mdns" && name != "" {
+			d.Name = name
+		}
+		if !slices.Contains(d.Protocols, protocol) {
+			d.Protocols = append(d.Protocols, protocol)
+		}
+	}
+
+	type answer struct {
+		ip, name, protocol string
+	}
+	answers := make(chan answer)
+	errs := make(chan error, 2)
+
+	ctx, cancel := context.WithTimeout(ctx, window)
+	defer cancel()
+
+	search := func(protocol string, group *net.UDPAddr, query []byte, parse func([]byte) string) {
+		errs <- listen(ctx, group, query, func(ip string, packet []byte) {
+			answers <- answer{ip, parse(packet), protocol}
+		})
+	}
+	go search("mdns", mdnsGroup, mdnsQuery(), mdnsName)
+	go search("ssdp", ssdpGroup, ssdpQuery(), ssdpServer)
+
+	var failed []error
+	for pending := 2; pending > 0; {
+		select {
+		case a := <-answers:
+			found(a.ip, a.name, a.protocol)
+		case err := <-errs:
+			pending--
+			if err != nil {
+				failed = append(failed, err)
+			}
+		}
+	}
+	if len(failed) == 2 {
+		return nil, fmt.Errorf("discovery failed: %v; %v", failed[0], failed[1])
+	}
+
+	macs := arpTable()
+	result := make([]DiscoveredDevice, 0, len(devices))
+	for _, d := range devices {
+		d.MAC = macs[d.IP]
+		result = append(result, *d)
+	}
+	sort.Slice(result, func(a, b int) bool {
+		return compareIP(result[a].IP, result[b].IP) < 0
+	})
+	return result, nil
+}
+
+// listen sends query to a multicast group from a fresh socket, then hands every reply to
+// answer until ctx is done
+func listen(ctx context.Context, group *net.UDPAddr, query []byte, answer func(ip string, packet []byte)) error {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	if _, err := conn.WriteToUDP(query, group); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 9000)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				return nil
+			}
+			return err
+		}
+		answer(from.IP.String(), buf[:n])
+	}
+}
+
+// mdnsQuery asks every responder for the services it offers (RFC 6763 section 9)
+func mdnsQuery() []byte {
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{})
+	b.StartQuestions()
+	b.Question(dnsmessage.Question{
+		Name:  dnsmessage.MustNewName("_services._dns-sd._udp.local."),
+		Type:  dnsmessage.TypePTR,
+		Class: dnsmessage.ClassINET,
+	})
+	query, _ := b.Finish()
+	return query
+}
+
+// mdnsName returns the host name in an mDNS answer, without .local, or an empty string
+func mdnsName(packet []byte) string {
+	var p dnsmessage.Parser
+	if _, err := p.Start(packet); err != nil {
+		return ""
+	}
+	p.SkipAllQuestions()
+
+	for {
+		h, err := p.AnswerHeader()
+		if err != nil {
+			break
+		}
+		if h.Type == dnsmessage.TypeA || h.Type == dnsmessage.TypeAAAA || h.Type == dnsmessage.TypeSRV {
+			name := h.Name.String()
+			if h.Type == dnsmessage.TypeSRV {
+				srv, err := p.SRVResource()
+				if err != nil {
+					break
+				}
+				name = srv.Target.String()
+			}
+			return strings.TrimSuffix(strings.TrimSuffix(name, "."), ".local")
+		}
+		if err := p.SkipAnswer(); err != nil {
+			break
+		}
+	}
+
+	// Hosts often only send the address records in the additional section
+	p.SkipAllAnswers()
+	p.SkipAllAuthorities()
+	for {
+		h, err := p.AdditionalHeader()
+		if err != nil {
+			return ""
+		}
+		if h.Type == dnsmessage.TypeA || h.Type == dnsmessage.TypeAAAA {
+			return strings.TrimSuffix(strings.TrimSuffix(h.Name.String(), "."), ".local")
+		}
+		if err := p.SkipAdditional(); err != nil {
+			return ""
+		}
+	}
+}
+
+// ssdpQuery searches for every UPnP device, asking for answers within 2 seconds
+func ssdpQuery() []byte {
+	return []byte("M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: 239.255.255.250:1900\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		"MX: 2\r\n" +
+		"ST: ssdp:all\r\n\r\n")
+}
+
+// ssdpServer returns the SERVER header of an SSDP answer, e.g. Linux/5.4 UPnP/1.0 Sonos/70.3
+func ssdpServer(packet []byte) string {
+	resp, err := http.ReadResponse(bufio.NewReader(strings.NewReader(string(packet))), nil)
+	if err != nil {
+		return ""
+	}
+	resp.Body.Close()
+	return resp.Header.Get("Server")
+}
+
+// arpTable maps the addresses in /proc/net/arp to their MAC address
+func arpTable() map[string]string {
+	data, err := os.ReadFile("/proc/net/arp")
+	if err != nil {
+		return nil
+	}
+
+	macs := map[string]string{}
+	for _, line := range strings.Split(string(data), "\n")[1:] {
+		fields := strings.Fields(line)
+		// IP address, HW type, flags, HW address, mask, device; flags 0x0 is an incomplete entry
+		if len(fields) < 4 || fields[2] == "0x0" {
+			continue
+		}
+		macs[fields[0]] = fields[3]
+	}
+	return macs
+}
+
+// compareIP orders addresses numerically, so 192.168.1.9 comes before 192.168.1.10
+func compareIP(a, b string) int {
+	ipA, ipB := net.ParseIP(a).To16(), net.ParseIP(b).To16()
+	if ipA == nil || ipB == nil {
+		return strings.Compare(a, b)
+	}
+	return slices.Compare(ipA, ipB)
+}
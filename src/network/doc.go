@@ -0,0 +1,20 @@
+// Package network is a client for the UniFi Network application, on a UDM, Cloud Key or a
+// self-hosted controller, together with the local network checks cloudkey shows: LAN and
+// WAN addresses, ping, mDNS and SSDP discovery, and firmware releases.
+//
+// It is a module of its own, github.com/llajas/cloudkey/src/network, depending on neither
+// the display nor the rest of cloudkey, so other tools can use the client alone:
+//
+//	client, err := network.NewUDMProClient("https://192.168.1.1", "user", "password", "default", "9.0.114", network.DefaultTimeouts)
+//	if err != nil { ... }
+//	if err := client.Login(ctx); err != nil { ... }
+//	devices, err := client.GetDevices(ctx)
+//
+// The controller type is detected on first use, the session is shared by concurrent calls
+// and renewed when it expires, and the site may be given by its ID or by the name the UniFi
+// interface shows. Metrics, tracing and captures are added through Hooks.
+//
+// The exported identifiers are a stable API: releases only add to them, and a breaking change
+// comes with a new major version of the module. Actions that change the controller state,
+// such as RestartDevice, need WritesEnabled.
+package network
@@ -0,0 +1,85 @@
+package network_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/llajas/cloudkey/src/network"
+)
+
+func ExampleNewUDMProClient() {
+	client, err := network.NewUDMProClient("https://192.168.1.1", "readonly", "secret", "Home", "9.0.114", network.DefaultTimeouts)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if err := client.Login(ctx); err != nil {
+		log.Fatal(err)
+	}
+	devices, err := client.GetDevices(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, d := range devices {
+		fmt.Println(d.Name, d.Version)
+	}
+}
+
+func ExampleUDMProClient_ListSites() {
+	client, err := network.NewUDMProClient("https://192.168.1.1", "readonly", "secret", "default", "9.0.114", network.DefaultTimeouts)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if err := client.Login(ctx); err != nil {
+		log.Fatal(err)
+	}
+	sites, err := client.ListSites(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, s := range sites {
+		fmt.Println(s) // e.g. "Home (x7k2p9q1)"
+	}
+}
+
+func ExampleUDMProClient_AddHooks() {
+	client, err := network.NewUDMProClient("https://192.168.1.1", "readonly", "secret", "default", "9.0.114", network.DefaultTimeouts)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Log every call with its status and duration
+	client.AddHooks(network.Hooks{
+		OnResponse: func(_ context.Context, call network.Call, result network.Result) {
+			log.Printf("%s %s: %d in %s", call.Method, call.Path, result.Status, result.Elapsed.Round(time.Millisecond))
+		},
+	})
+}
+
+func ExampleParallel() {
+	client, err := network.NewUDMProClient("https://192.168.1.1", "readonly", "secret", "default", "9.0.114", network.DefaultTimeouts)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Both requests share one login and go out together
+	ctx := context.Background()
+	var info *network.SysInfo
+	var clients []network.Client
+	err = network.Parallel(ctx, func(ctx context.Context) (err error) {
+		info, err = client.GetSysInfo(ctx)
+		return err
+	}, func(ctx context.Context) (err error) {
+		clients, err = client.GetClients(ctx)
+		return err
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Network %s, %d clients\n", info.Version, len(clients))
+}
@@ -0,0 +1,75 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// FirmwareUpdateURL is Ubiquiti's release channel metadata API
+var FirmwareUpdateURL = "https://fw-update.ui.com/api/firmware-latest"
+
+// FirmwareTTL is how long LookupCache keeps the release channel metadata
+const FirmwareTTL = time.Hour
+
+// FirmwareRelease is the latest published firmware for a product and platform
+type FirmwareRelease struct {
+	Version   string    `json:"version"`
+	Channel   string    `json:"channel"`
+	Platform  string    `json:"platform"`
+	Product   string    `json:"product"`
+	Created   time.Time `json:"created"`
+	Changelog string    `json:"-"`
+}
+
+// ShortVersion strips the leading "v" and build suffix, e.g. v6.6.77.15402 -> 6.6.77
+func (r FirmwareRelease) ShortVersion() string {
+	parts := strings.Split(strings.TrimPrefix(r.Version, "v"), ".")
+	if len(parts) > 3 {
+		parts = parts[:3]
+	}
+	return strings.Join(parts, ".")
+}
+
+// LatestFirmware fetches the latest firmware on a release channel ("release", "release-candidate", ...)
+func LatestFirmware(product, platform, channel string) (*FirmwareRelease, error) {
+	query := url.Values{}
+	query.Add("filter", "eq~~product~~"+product)
+	query.Add("filter", "eq~~platform~~"+platform)
+	query.Add("filter", "eq~~channel~~"+channel)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	data, err := LookupCache.Get(ctx, FirmwareUpdateURL+"?"+query.Encode(), FirmwareTTL)
+	if err != nil {
+		return nil, fmt.Errorf("firmware lookup failed: %v", err)
+	}
+
+	var body struct {
+		Embedded struct {
+			Firmware []struct {
+				FirmwareRelease
+				Links struct {
+					Changelog struct {
+						Href string `json:"href"`
+					} `json:"changelog"`
+				} `json:"_links"`
+			} `json:"firmware"`
+		} `json:"_embedded"`
+	}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, fmt.Errorf("failed to parse firmware metadata: %v", err)
+	}
+
+	if len(body.Embedded.Firmware) == 0 {
+		return nil, fmt.Errorf("no %s firmware published for %s/%s", channel, product, platform)
+	}
+
+	latest := body.Embedded.Firmware[0]
+	release := latest.FirmwareRelease
+	release.Changelog = latest.Links.Changelog.Href
+	return &release, nil
+}
@@ -0,0 +1,5 @@
+module github.com/llajas/cloudkey/src/network
+
+go 1.25.0
+
+require golang.org/x/net v0.58.0
@@ -0,0 +1,84 @@
+package network
+
+import (
+	"context"
+	"time"
+)
+
+// Call is a request to the controller as the hooks see it
+type Call struct {
+	// Kind is detect, probe, login or query
+	Kind   string
+	Method string
+	// Path is the API path without the UniFi OS prefix, with the query
+	Path string
+}
+
+// Result is how a call ended
+type Result struct {
+	// Status is the HTTP status, 0 when no answer arrived
+	Status  int
+	Body    []byte
+	Elapsed time.Duration
+	Err     error
+}
+
+// Hooks observe a client, so metrics, tracing or captures can be added by its user without
+// the client depending on them. Every hook is optional and runs on the goroutine making the
+// call; a hook must not call the client.
+type Hooks struct {
+	// OnRequest runs before a call is sent. The context it returns is the call's, so a span
+	// started there is the parent of the HTTP request and ends in OnResponse.
+	OnRequest func(ctx context.Context, call Call) context.Context
+	// OnResponse runs once a call finished, answered or not, with the context of OnRequest
+	OnResponse func(ctx context.Context, call Call, result Result)
+	// OnRetry runs when a query is sent again because its session expired
+	OnRetry func(ctx context.Context, call Call)
+	// OnAuthRefresh runs after logging in again for an expired session, with the login error
+	OnAuthRefresh func(ctx context.Context, err error)
+}
+
+// AddHooks adds hooks to the client. Hooks added earlier run first, and see the context
+// before later hooks derive theirs from it. Add them before the client is used.
+func (c *UDMProClient) AddHooks(h Hooks) {
+	c.hooks = append(c.hooks, h)
+}
+
+// begin runs the OnRequest hooks and returns the call's context with a function running the
+// OnResponse hooks, in reverse order
+func (c *UDMProClient) begin(ctx context.Context, call Call) (context.Context, func(status int, body []byte, err error)) {
+	start := time.Now()
+	contexts := make([]context.Context, len(c.hooks))
+	for i, h := range c.hooks {
+		if h.OnRequest != nil {
+			ctx = h.OnRequest(ctx, call)
+		}
+		contexts[i] = ctx
+	}
+	return ctx, func(status int, body []byte, err error) {
+		result := Result{Status: status, Body: body, Elapsed: time.Since(start), Err: err}
+		for i := len(c.hooks) - 1; i >= 0; i-- {
+			if h := c.hooks[i]; h.OnResponse != nil {
+				h.OnResponse(contexts[i], call, result)
+			}
+		}
+	}
+}
+
+// retried runs the OnRetry hooks
+func (c *UDMProClient) retried(ctx context.Context, call Call) {
+	for _, h := range c.hooks {
+		if h.OnRetry != nil {
+			h.OnRetry(ctx, call)
+		}
+	}
+}
+
+// authRefreshed runs the OnAuthRefresh hooks
+func (c *UDMProClient) authRefreshed(ctx context.Context, err error) {
+	for _, h := range c.hooks {
+		if h.OnAuthRefresh != nil {
+			h.OnAuthRefresh(ctx, err)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// cacheMaxBody is the largest answer kept, far more than any lookup returns
+	cacheMaxBody = 1 << 20
+	// cacheMinBackoff is how long a failing URL is left alone after its first failure,
+	// doubling with each failure after it up to cacheMaxBackoff
+	cacheMinBackoff = time.Minute
+	cacheMaxBackoff = time.Hour
+)
+
+// LookupCache keeps the answers of the third-party services cloudkey looks things up with,
+// shared by the WAN IP providers and the firmware lookup. Set its Path to keep them across
+// restarts.
+var LookupCache = &HTTPCache{}
+
+// HTTPCache answers GET requests from earlier answers while they are fresh, and leaves a URL
+// alone for a while after it failed, so restarts and retries do not hammer external services.
+// The zero value keeps answers in memory.
+type HTTPCache struct {
+	// Path is the file answers are kept in across restarts, empty to keep them in memory
+	Path string
+	// Client makes the requests, nil for one giving up after 15 seconds
+	Client *http.Client
+
+	mutex   sync.Mutex
+	loaded  bool
+	entries map[string]*cacheEntry
+}
+
+// cacheEntry is what the cache knows about a URL
+type cacheEntry struct {
+	Body    []byte    `json:"body,omitempty"`
+	Expires time.Time `json:"expires"`
+	// Failures counts the failed requests since the last answer
+	Failures int       `json:"failures,omitempty"`
+	RetryAt  time.Time `json:"retry_at,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// BackoffError is returned instead of asking a URL that failed recently
+type BackoffError struct {
+	URL     string
+	RetryAt time.Time
+	// Err is why the last request failed
+	Err string
+}
+
+func (e *BackoffError) Error() string {
+	return fmt.Sprintf("%s failed (%s), not asking again before %s", e.URL, e.Err, e.RetryAt.Format(time.TimeOnly))
+}
+
+// Get returns the body of a successful GET of url, fetched within ttl or, when the service
+// sent a longer Cache-Control max-age, within that. A URL that failed is not asked again until
+// its backoff ends, or the time a Retry-After header asked for, and returns a *BackoffError.
+func (c *HTTPCache) Get(ctx context.Context, url string, ttl time.Duration) ([]byte, error) {
+	c.mutex.Lock()
+	c.load()
+	entry := c.entries[url]
+	now := time.Now()
+	if entry != nil && entry.Body != nil && now.Before(entry.Expires) {
+		body := entry.Body
+		c.mutex.Unlock()
+		return body, nil
+	}
+	if entry != nil && now.Before(entry.RetryAt) {
+		err := &BackoffError{URL: url, RetryAt: entry.RetryAt, Err: entry.Error}
+		c.mutex.Unlock()
+		return nil, err
+	}
+	c.mutex.Unlock()
+
+	body, maxAge, retryAfter, err := c.fetch(ctx, url)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	entry = c.entries[url]
+	if entry == nil {
+		entry = &cacheEntry{}
+		c.entries[url] = entry
+	}
+	now = time.Now()
+	if err != nil {
+		if ctx.Err() != nil {
+			// Given up on by the caller, which says nothing about the service
+			return nil, err
+		}
+		entry.Failures++
+		backoff := cacheMinBackoff
+		for i := 1; i < entry.Failures && backoff < cacheMaxBackoff; i++ {
+			backoff *= 2
+		}
+		if backoff > cacheMaxBackoff {
+			backoff = cacheMaxBackoff
+		}
+		entry.RetryAt = now.Add(max(backoff, retryAfter))
+		entry.Error = err.Error()
+		c.save()
+		return nil, err
+	}
+
+	*entry = cacheEntry{Body: body, Expires: now.Add(max(ttl, maxAge))}
+	c.save()
+	return body, nil
+}
+
+// Expire makes the next Get of url ask the service again, unless it is backing off
+func (c *HTTPCache) Expire(url string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.load()
+	if entry, ok := c.entries[url]; ok {
+		entry.Expires = time.Time{}
+	}
+}
+
+// fetch makes the request, returning how long the service asked for its answer to be kept or,
+// when it failed, to be left alone
+func (c *HTTPCache) fetch(ctx context.Context, url string) (body []byte, maxAge, retryAfter time.Duration, err error) {
+	client := c.Client
+	if client == nil {
+		client = &http.Client{Timeout: 15 * time.Second}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, headerSeconds(resp.Header.Get("Retry-After")), fmt.Errorf("%s", resp.Status)
+	}
+	body, err = io.ReadAll(io.LimitReader(resp.Body, cacheMaxBody))
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if body == nil {
+		body = []byte{}
+	}
+
+	for _, directive := range strings.Split(resp.Header.Get("Cache-Control"), ",") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(directive), "max-age="); ok {
+			maxAge = headerSeconds(value)
+		}
+	}
+	return body, maxAge, 0, nil
+}
+
+// headerSeconds parses a number of seconds from a header, zero if it holds something else
+func headerSeconds(value string) time.Duration {
+	seconds, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// load reads the answers kept by an earlier run, once. It must be called with mutex held.
+func (c *HTTPCache) load() {
+	if c.loaded {
+		return
+	}
+	c.loaded = true
+	c.entries = map[string]*cacheEntry{}
+	if c.Path == "" {
+		return
+	}
+
+	data, err := os.ReadFile(c.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &c.entries)
+	}
+	if err != nil {
+		fmt.Printf("Lookup cache %s ignored: %v\n", c.Path, err)
+		c.entries = map[string]*cacheEntry{}
+	}
+}
+
+// save keeps the answers for the next run. It must be called with mutex held.
+func (c *HTTPCache) save() {
+	if c.Path == "" {
+		return
+	}
+
+	// Answers that went stale a day ago are not worth keeping
+	for url, entry := range c.entries {
+		if time.Since(entry.Expires) > 24*time.Hour && time.Since(entry.RetryAt) > 24*time.Hour {
+			delete(c.entries, url)
+		}
+	}
+
+	data, err := json.Marshal(c.entries)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(c.Path), 0755)
+	}
+	tmp := c.Path + ".tmp"
+	if err == nil {
+		err = os.WriteFile(tmp, data, 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp, c.Path)
+	}
+	if err != nil {
+		fmt.Printf("Lookup cache %s not saved: %v\n", c.Path, err)
+	}
+}
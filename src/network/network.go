@@ -1,12 +1,12 @@
 package network
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net"
+	"strings"
 	"time"
-
-	ipify "github.com/rdegges/go-ipify"
 )
 
 // LANIP gives you the first non-loopback IP address
@@ -47,9 +47,38 @@ func LANIP() (string, error) {
 	return "", errors.New("network not found")
 }
 
-// WANIP gives you your WAN IP of the device
+// WatchAddresses reports when an address of the host or the state of a link changes, e.g. on a
+// DHCP renewal or an interface flap, until ctx is done or watching fails, when the channel is
+// closed. Changes arriving while one is pending are merged into it, so a receiver sees at most
+// one. It fails where the platform or its permissions do not allow watching.
+func WatchAddresses(ctx context.Context) (<-chan struct{}, error) {
+	return watchAddresses(ctx)
+}
+
+// notify signals a change without blocking, unless one is already pending
+func notify(changes chan<- struct{}) {
+	select {
+	case changes <- struct{}{}:
+	default:
+	}
+}
+
+// WANIP gives you your WAN IP of the device, asking ipify, icanhazip and Cloudflare in turn
 func WANIP() (string, error) {
-	return ipify.GetIp()
+	chain := WANIPChain{Providers: []WANIPProvider{Ipify{}, ICanHazIP{}, CloudflareTrace{}}}
+	return chain.WANIP(context.Background())
+}
+
+// ReverseDNS returns the host name an address resolves back to, without the trailing dot
+func ReverseDNS(ctx context.Context, ip string) (string, error) {
+	names, err := net.DefaultResolver.LookupAddr(ctx, ip)
+	if err != nil {
+		return "", err
+	}
+	if len(names) == 0 {
+		return "", fmt.Errorf("no reverse DNS name for %s", ip)
+	}
+	return strings.TrimSuffix(names[0], "."), nil
 }
 
 // GetRelativeTime returns a human-readable relative time string
@@ -0,0 +1,63 @@
+package network
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// WANStatus is the result of probing external hosts after a local check failed
+type WANStatus int
+
+const (
+	// WANUnknown means no probe hosts are configured
+	WANUnknown WANStatus = iota
+	// WANUp means a probe host answered, so only the checked service is down
+	WANUp
+	// WANDown means no probe host answered
+	WANDown
+)
+
+// String returns a short description for the display
+func (s WANStatus) String() string {
+	switch s {
+	case WANUp:
+		return "service down"
+	case WANDown:
+		return "WAN down"
+	default:
+		return "unknown"
+	}
+}
+
+// CheckWAN dials each probe host (host:port) concurrently and reports whether any answered
+func CheckWAN(probes []string, timeout time.Duration) WANStatus {
+	if len(probes) == 0 {
+		return WANUnknown
+	}
+
+	var wg sync.WaitGroup
+	reachable := make(chan bool, len(probes))
+	for _, probe := range probes {
+		wg.Add(1)
+		go func(addr string) {
+			defer wg.Done()
+			conn, err := net.DialTimeout("tcp", addr, timeout)
+			if err != nil {
+				reachable <- false
+				return
+			}
+			conn.Close()
+			reachable <- true
+		}(probe)
+	}
+	wg.Wait()
+	close(reachable)
+
+	for ok := range reachable {
+		if ok {
+			return WANUp
+		}
+	}
+	return WANDown
+}
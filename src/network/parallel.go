@@ -0,0 +1,30 @@
+package network
+
+import (
+	"context"
+	"sync"
+)
+
+// Parallel runs fetches of the same client concurrently and returns the first error. The
+// fetches share the session, so a login happens at most once, and the client's MaxRequests
+// bounds how many are on the wire at once.
+func Parallel(ctx context.Context, fetches ...func(ctx context.Context) error) error {
+	var wg sync.WaitGroup
+	errs := make(chan error, len(fetches))
+	for _, fetch := range fetches {
+		wg.Add(1)
+		go func(fetch func(ctx context.Context) error) {
+			defer wg.Done()
+			errs <- fetch(ctx)
+		}(fetch)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
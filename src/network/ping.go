@@ -0,0 +1,29 @@
+package network
+
+import (
+	"net"
+	"time"
+)
+
+// PingResult is one latency sample of the ping monitor
+type PingResult struct {
+	Timestamp int64   `json:"timestamp"` // milliseconds since epoch
+	LatencyMs float64 `json:"latency_ms"`
+	Lost      bool    `json:"lost,omitempty"`
+}
+
+// Ping measures the time to open a TCP connection to addr (host:port). A TCP handshake
+// needs no raw socket privileges, unlike ICMP, and takes one round trip.
+func Ping(addr string, timeout time.Duration) PingResult {
+	start := time.Now()
+	result := PingResult{Timestamp: start.UnixMilli()}
+
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		result.Lost = true
+		return result
+	}
+	result.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+	conn.Close()
+	return result
+}
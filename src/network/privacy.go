@@ -0,0 +1,120 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// MaskIP hides the host part of an address for screens others can see:
+// 203.0.113.32 becomes 203.0.x.x and an IPv6 address its /32 prefix
+func MaskIP(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return "hidden"
+	}
+	addr = addr.Unmap()
+	if addr.Is4() {
+		b := addr.As4()
+		return fmt.Sprintf("%d.%d.x.x", b[0], b[1])
+	}
+	prefix, _ := addr.Prefix(32)
+	return prefix.String()
+}
+
+// ASN is the autonomous system announcing an address, usually the ISP
+type ASN struct {
+	Number int
+	// Handle is the short registry name, e.g. CLOUDFLARENET
+	Handle string
+	// Name is the organization, e.g. Cloudflare, Inc.
+	Name string
+}
+
+// Label names the provider briefly, e.g. Comcast (AS7922)
+func (a ASN) Label() string {
+	name := strings.TrimRight(strings.SplitN(a.Name, " ", 2)[0], ",.")
+	if name == "" {
+		name = a.Handle
+	}
+	return fmt.Sprintf("%s (AS%d)", name, a.Number)
+}
+
+// LookupASN finds the autonomous system of an address with the Team Cymru IP to ASN
+// service, which answers over DNS, so no API key or database download is needed
+func LookupASN(ctx context.Context, ip string) (ASN, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ASN{}, fmt.Errorf("invalid address %q", ip)
+	}
+	addr = addr.Unmap()
+
+	zone := "origin.asn.cymru.com"
+	if addr.Is6() {
+		zone = "origin6.asn.cymru.com"
+	}
+	// "13335 | 1.1.1.0/24 | AU | apnic | 2011-08-11", several ASNs may be listed first
+	origin, err := lookupCymru(ctx, reverseName(addr)+"."+zone)
+	if err != nil {
+		return ASN{}, err
+	}
+	number, err := strconv.Atoi(strings.Fields(origin[0])[0])
+	if err != nil {
+		return ASN{}, fmt.Errorf("unexpected origin answer %q", strings.Join(origin, "|"))
+	}
+
+	// "13335 | US | arin | 2010-07-14 | CLOUDFLARENET - Cloudflare, Inc., US"
+	details, err := lookupCymru(ctx, fmt.Sprintf("AS%d.asn.cymru.com", number))
+	if err != nil {
+		return ASN{}, err
+	}
+	asn := ASN{Number: number}
+	if len(details) >= 5 {
+		description := details[4]
+		// Drop the trailing country code
+		if i := strings.LastIndex(description, ", "); i > 0 && len(description)-i == 4 {
+			description = description[:i]
+		}
+		asn.Handle, asn.Name, _ = strings.Cut(description, " - ")
+		if asn.Name == "" {
+			asn.Name = asn.Handle
+		}
+	}
+	return asn, nil
+}
+
+// lookupCymru returns the fields of the first TXT record of name
+func lookupCymru(ctx context.Context, name string) ([]string, error) {
+	records, err := net.DefaultResolver.LookupTXT(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("ASN lookup failed: %v", err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("ASN lookup failed: no answer for %s", name)
+	}
+	fields := strings.Split(records[0], "|")
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	if fields[0] == "" {
+		return nil, fmt.Errorf("ASN lookup failed: empty answer for %s", name)
+	}
+	return fields, nil
+}
+
+// reverseName is the reverse DNS label of an address without its in-addr.arpa or ip6.arpa zone
+func reverseName(addr netip.Addr) string {
+	if addr.Is4() {
+		b := addr.As4()
+		return fmt.Sprintf("%d.%d.%d.%d", b[3], b[2], b[1], b[0])
+	}
+	b := addr.As16()
+	nibbles := make([]string, 0, 32)
+	for i := len(b) - 1; i >= 0; i-- {
+		nibbles = append(nibbles, strconv.FormatUint(uint64(b[i]&0xf), 16), strconv.FormatUint(uint64(b[i]>>4), 16))
+	}
+	return strings.Join(nibbles, ".")
+}
@@ -0,0 +1,128 @@
+package network
+
+import (
+	"container/list"
+	"encoding/json"
+	"sync"
+)
+
+// responseCacheSize is how many controller responses are kept for conditional requests,
+// enough for every endpoint the screens poll
+const responseCacheSize = 32
+
+// cachedResponse is a response that carried an ETag or Last-Modified validator
+type cachedResponse struct {
+	key          string // method and path, with the query
+	etag         string
+	lastModified string
+	body         []byte
+	// data is the payload the decoder chain extracted from body, nil until decoded
+	data   json.RawMessage
+	format ResponseFormat
+}
+
+// responseCache keeps the most recently used responses with validators, so a GET can ask the
+// controller whether anything changed. An unchanged response (304) hands back the same body
+// with its key, and its payload is then not extracted from its envelope again. Controllers that send no
+// validators leave it empty, and a nil cache caches nothing.
+type responseCache struct {
+	mutex   sync.Mutex
+	order   *list.List // of *cachedResponse, most recently used first
+	entries map[string]*list.Element
+}
+
+// newResponseCache creates an empty cache
+func newResponseCache() *responseCache {
+	return &responseCache{order: list.New(), entries: map[string]*list.Element{}}
+}
+
+// get returns a copy of the response cached for key, or nil
+func (r *responseCache) get(key string) *cachedResponse {
+	if r == nil {
+		return nil
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	e, ok := r.entries[key]
+	if !ok {
+		return nil
+	}
+	r.order.MoveToFront(e)
+	cached := *e.Value.(*cachedResponse)
+	return &cached
+}
+
+// put caches a response, evicting the least recently used beyond responseCacheSize
+func (r *responseCache) put(resp *cachedResponse) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if e, ok := r.entries[resp.key]; ok {
+		r.order.Remove(e)
+	}
+	r.entries[resp.key] = r.order.PushFront(resp)
+	for r.order.Len() > responseCacheSize {
+		oldest := r.order.Back()
+		r.order.Remove(oldest)
+		delete(r.entries, oldest.Value.(*cachedResponse).key)
+	}
+}
+
+// remove drops the response cached for key, once the endpoint stopped sending validators
+func (r *responseCache) remove(key string) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if e, ok := r.entries[key]; ok {
+		r.order.Remove(e)
+		delete(r.entries, key)
+	}
+}
+
+// payload returns the payload already extracted from a cached response
+func (r *responseCache) payload(answer reply) (json.RawMessage, ResponseFormat, bool) {
+	if r == nil {
+		return nil, 0, false
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if cached := r.find(answer); cached != nil && cached.data != nil {
+		return cached.data, cached.format, true
+	}
+	return nil, 0, false
+}
+
+// setPayload remembers the payload extracted from a cached response
+func (r *responseCache) setPayload(answer reply, data json.RawMessage, format ResponseFormat) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if cached := r.find(answer); cached != nil {
+		cached.data, cached.format = data, format
+	}
+}
+
+// find returns the response answer is cached as, unless a newer response of the same request
+// replaced it since
+func (r *responseCache) find(answer reply) *cachedResponse {
+	e, ok := r.entries[answer.key]
+	if !ok || len(answer.body) == 0 {
+		return nil
+	}
+	cached := e.Value.(*cachedResponse)
+	if len(cached.body) != len(answer.body) || &cached.body[0] != &answer.body[0] {
+		return nil
+	}
+	return cached
+}
@@ -0,0 +1,52 @@
+package network
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// restartWindow is how long a controller answering as if restarting is taken to be updating
+// or rebooting. One still doing so after that is reported as failed.
+const restartWindow = 15 * time.Minute
+
+// restartStatus reports whether status is what UniFi OS answers while the Network application
+// restarts: its proxy is up, the application behind it is not
+func restartStatus(status int) bool {
+	return status == http.StatusBadGateway || status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout
+}
+
+// restarting records that the controller answered with status as if restarting, and returns
+// the error for the request
+func (c *UDMProClient) restarting(status int) error {
+	c.restartMutex.Lock()
+	defer c.restartMutex.Unlock()
+
+	if c.restartingSince.IsZero() {
+		fmt.Printf("Controller is restarting (status %d), waiting for it\n", status)
+		c.restartingSince = time.Now()
+	}
+	return fmt.Errorf("controller is restarting (status %d)", status)
+}
+
+// answered ends a restart once the controller answers a request again
+func (c *UDMProClient) answered() {
+	c.restartMutex.Lock()
+	defer c.restartMutex.Unlock()
+
+	if !c.restartingSince.IsZero() {
+		fmt.Printf("Controller is back after restarting for %s\n", time.Since(c.restartingSince).Round(time.Second))
+		c.restartingSince = time.Time{}
+	}
+}
+
+// Restarting reports whether the controller is in an update or reboot: it answered as if its
+// Network application were restarting, has not answered a request since, and has not been at
+// it for longer than restartWindow. A reboot that makes it unreachable for a while stays in
+// the window.
+func (c *UDMProClient) Restarting() bool {
+	c.restartMutex.Lock()
+	defer c.restartMutex.Unlock()
+
+	return !c.restartingSince.IsZero() && time.Since(c.restartingSince) < restartWindow
+}
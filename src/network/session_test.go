@@ -0,0 +1,109 @@
+package network
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// sessionServer is a UniFi OS console that issues a new session token per login and
+// rejects the old one once expire is called, reporting a different version every login
+type sessionServer struct {
+	mu     sync.Mutex
+	token  string
+	logins int
+}
+
+func (s *sessionServer) expire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = ""
+}
+
+func (s *sessionServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/":
+		return
+	case "/api/auth/login":
+		s.logins++
+		payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"csrfToken":"csrf-%d"}`, s.logins)))
+		s.token = "header." + payload + ".signature"
+		http.SetCookie(w, &http.Cookie{Name: "TOKEN", Value: s.token, Path: "/"})
+		return
+	}
+
+	if cookie, err := r.Cookie("TOKEN"); err != nil || s.token == "" || cookie.Value != s.token {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	switch r.URL.Path {
+	case "/proxy/network/api/self/sites":
+		fmt.Fprint(w, `{"meta":{"rc":"ok"},"data":[{"_id":"1","name":"default","desc":"Default"}]}`)
+	case "/proxy/network/api/s/default/self":
+		fmt.Fprint(w, `{"meta":{"rc":"ok"},"data":[{"is_super":true}]}`)
+	case "/proxy/network/api/s/default/stat/sysinfo":
+		// Alternate versions so every login switches the controller state in use
+		version := "8.1.113"
+		if s.logins%2 == 0 {
+			version = "7.5.187"
+		}
+		fmt.Fprintf(w, `{"meta":{"rc":"ok"},"data":[{"version":%q}]}`, version)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// TestParallelExpiringSession runs concurrent requests while the session expires under
+// them, so the relogin rewrites the session and capabilities as other requests read them.
+// Run with -race.
+func TestParallelExpiringSession(t *testing.T) {
+	server := &sessionServer{}
+	ts := httptest.NewTLSServer(server)
+	defer ts.Close()
+
+	c, err := NewUDMProClient(ts.URL, "readonly", "secret", "default", "9.0.114", DefaultTimeouts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := c.Login(ctx); err != nil {
+		t.Fatalf("login: %v", err)
+	}
+
+	fetch := func(ctx context.Context) error {
+		_, err := c.GetSysInfo(ctx)
+		return err
+	}
+	for round := 0; round < 5; round++ {
+		server.expire()
+		fetches := make([]func(context.Context) error, 8)
+		for i := range fetches {
+			fetches[i] = fetch
+		}
+		fetches = append(fetches, func(context.Context) error {
+			version, caps := c.Controller()
+			if version == "" || caps.Family == "" {
+				return fmt.Errorf("controller state missing: version %q, family %q", version, caps.Family)
+			}
+			return nil
+		})
+		if err := Parallel(ctx, fetches...); err != nil {
+			t.Fatalf("round %d: %v", round, err)
+		}
+	}
+
+	// One login per expiry on top of the first, however many requests were rejected together
+	if server.logins != 6 {
+		t.Errorf("got %d logins, want 6", server.logins)
+	}
+	if _, csrf := c.tokens(); csrf != "csrf-6" {
+		t.Errorf("got CSRF token %q, want the one of the last login", csrf)
+	}
+}
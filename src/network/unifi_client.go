@@ -2,6 +2,7 @@ package network
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/base64"
 	"encoding/json"
@@ -11,6 +12,7 @@ import (
 	"net/http/cookiejar"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -20,14 +22,74 @@ type UDMProClient struct {
 	Username   string
 	Password   string
 	Site       string
-	Version    string
 	HTTPClient *http.Client
-	IsUniFiOS  bool
-	AuthToken  string
-	CSRFToken  string
-	cache      *SpeedtestCache
-	session    *SessionCache
-	cacheMutex sync.RWMutex
+	// Version, IsUniFiOS and Caps describe the controller. They change once requests run,
+	// read them with Controller then.
+	Version   string
+	IsUniFiOS bool
+	// Caps are the API behaviours of the detected controller version
+	Caps Capabilities
+	// stateMutex guards Version, IsUniFiOS and Caps
+	stateMutex sync.RWMutex
+	// AuthToken and CSRFToken are the session in use, guarded by cacheMutex
+	AuthToken string
+	CSRFToken string
+	// WritesEnabled allows commands that change controller state (restart, locate, ...)
+	WritesEnabled bool
+	// Timeouts bound each kind of request
+	Timeouts Timeouts
+	// MaxRequests bounds the API requests in flight at once, DefaultMaxRequests if not positive
+	MaxRequests int
+	hooks       []Hooks
+	cache       *SpeedtestCache
+	responses   *responseCache
+	session     *SessionCache
+	cacheMutex  sync.RWMutex
+	// detected is set once the controller type is known
+	detected    bool
+	detectMutex sync.Mutex
+	// siteResolved is set once Site holds the ID of a site the controller knows
+	siteResolved bool
+	// slots holds a token per API request in flight, made on first use
+	slots     chan struct{}
+	slotsOnce sync.Once
+	// logins counts the logins after a session expired, so requests rejected together log in once
+	logins       atomic.Int64
+	reloginMutex sync.Mutex
+	// restartingSince is when the controller started answering as if restarting, zero otherwise
+	restartingSince time.Time
+	restartMutex    sync.Mutex
+}
+
+// DefaultMaxRequests is how many API requests a client sends at once unless configured. A
+// handful keeps a slow controller answering in parallel without a burst of connections when
+// every screen refreshes together.
+const DefaultMaxRequests = 4
+
+// Timeouts bound the controller requests by operation, so a controller that stopped
+// answering is given up on quickly where that matters most
+type Timeouts struct {
+	// Detect bounds the request telling UniFi OS from a classic controller
+	Detect time.Duration
+	// Login bounds the login request
+	Login time.Duration
+	// Query bounds each API request, including reading its response
+	Query time.Duration
+}
+
+// DefaultTimeouts are the timeouts of a client created without explicit ones
+var DefaultTimeouts = Timeouts{
+	Detect: 5 * time.Second,
+	Login:  10 * time.Second,
+	Query:  15 * time.Second,
+}
+
+// withTimeout bounds ctx by d, leaving it unbounded if d is not positive
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
 }
 
 // SpeedtestCache represents a cached speedtest result
@@ -74,22 +136,17 @@ type SpeedtestRequest struct {
 	End   int64    `json:"end,omitempty"`
 }
 
-// SpeedtestResponse represents the speedtest API response
-type SpeedtestResponse struct {
-	Meta struct {
-		RC  string `json:"rc"`
-		Msg string `json:"msg,omitempty"`
-	} `json:"meta"`
-	Data []struct {
-		XputDownload float64 `json:"xput_download"`
-		XputUpload   float64 `json:"xput_upload"`
-		Latency      float64 `json:"latency"`
-		Time         int64   `json:"time"`
-	} `json:"data"`
+// speedtestRow is a single entry of the archive.speedtest report
+type speedtestRow struct {
+	XputDownload float64 `json:"xput_download"`
+	XputUpload   float64 `json:"xput_upload"`
+	Latency      float64 `json:"latency"`
+	Time         int64   `json:"time"`
 }
 
-// NewUDMProClient creates a new UDM Pro API client
-func NewUDMProClient(baseURL, username, password, site, version string) (*UDMProClient, error) {
+// NewUDMProClient creates a new UDM Pro API client. It does not contact the controller:
+// the controller type is detected on first use, or earlier with Detect.
+func NewUDMProClient(baseURL, username, password, site, version string, timeouts Timeouts) (*UDMProClient, error) {
 	// Create cookie jar for session management
 	jar, err := cookiejar.New(nil)
 	if err != nil {
@@ -99,6 +156,8 @@ func NewUDMProClient(baseURL, username, password, site, version string) (*UDMPro
 	// Create HTTP client with TLS config (skip verification for local networks)
 	transport := &http.Transport{
 		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		// Keep the connections of concurrent requests for the next refresh
+		MaxIdleConnsPerHost: DefaultMaxRequests,
 	}
 
 	client := &UDMProClient{
@@ -107,7 +166,9 @@ func NewUDMProClient(baseURL, username, password, site, version string) (*UDMPro
 		Password:   password,
 		Site:       site,
 		Version:    version,
-		HTTPClient: &http.Client{Transport: transport, Jar: jar, Timeout: 30 * time.Second},
+		HTTPClient: &http.Client{Transport: transport, Jar: jar},
+		Timeouts:   timeouts,
+		responses:  newResponseCache(),
 		cache: &SpeedtestCache{
 			TTL: 24 * time.Hour, // Cache for 24 hours since tests run daily
 		},
@@ -116,31 +177,116 @@ func NewUDMProClient(baseURL, username, password, site, version string) (*UDMPro
 		},
 	}
 
-	// Detect controller type
-	if err := client.detectControllerType(); err != nil {
-		return nil, fmt.Errorf("failed to detect controller type: %v", err)
+	return client, nil
+}
+
+// Detect determines the controller type unless it is already known. It fails while the
+// controller is unreachable and can be called again until it succeeds.
+func (c *UDMProClient) Detect(ctx context.Context) error {
+	c.detectMutex.Lock()
+	defer c.detectMutex.Unlock()
+
+	if c.detected {
+		return nil
+	}
+	if err := c.detectControllerType(ctx); err != nil {
+		return fmt.Errorf("failed to detect controller type: %v", err)
 	}
+	c.detected = true
+	return nil
+}
 
-	return client, nil
+// Detected reports whether the controller type is known
+func (c *UDMProClient) Detected() bool {
+	c.detectMutex.Lock()
+	defer c.detectMutex.Unlock()
+	return c.detected
 }
 
 // detectControllerType determines if we're dealing with a UniFi OS controller
-func (c *UDMProClient) detectControllerType() error {
-	resp, err := c.HTTPClient.Get(c.BaseURL + "/")
+func (c *UDMProClient) detectControllerType(ctx context.Context) (err error) {
+	var status int
+	ctx, finish := c.begin(ctx, Call{Kind: "detect", Method: "GET", Path: "/"})
+	defer func() { finish(status, nil, err) }()
+
+	ctx, cancel := withTimeout(ctx, c.Timeouts.Detect)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", c.BaseURL+"/", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create detection request: %v", err)
+	}
+	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
-		if strings.Contains(err.Error(), "timeout") || strings.Contains(err.Error(), "deadline exceeded") {
-			return fmt.Errorf("network timeout - cannot reach UDM Pro at %s. Check IP address and network connectivity", c.BaseURL)
-		} else if strings.Contains(err.Error(), "connection refused") {
-			return fmt.Errorf("connection refused - UDM Pro at %s is not accessible. Check if device is running and firewall settings", c.BaseURL)
-		} else if strings.Contains(err.Error(), "no such host") {
-			return fmt.Errorf("host not found - invalid UDM Pro address: %s. Check IP address or hostname", c.BaseURL)
+		if reachErr := c.unreachable(err); reachErr != nil {
+			return reachErr
 		}
 		return fmt.Errorf("failed to detect controller type: %v", err)
 	}
 	defer resp.Body.Close()
+	status = resp.StatusCode
 
 	// If we get 200, it's UniFi OS
+	c.stateMutex.Lock()
 	c.IsUniFiOS = resp.StatusCode == 200
+	c.Caps = CapabilitiesFor(c.Version, c.IsUniFiOS)
+	fmt.Printf("Controller API family: %s (version %s)\n", c.Caps.Family, c.Version)
+	c.stateMutex.Unlock()
+	return nil
+}
+
+// Controller returns the controller version and the API capabilities in use. Unlike the
+// fields, it is safe to call while requests run.
+func (c *UDMProClient) Controller() (version string, caps Capabilities) {
+	c.stateMutex.RLock()
+	defer c.stateMutex.RUnlock()
+	return c.Version, c.Caps
+}
+
+// api returns the API capabilities in use and whether the controller runs UniFi OS
+func (c *UDMProClient) api() (Capabilities, bool) {
+	c.stateMutex.RLock()
+	defer c.stateMutex.RUnlock()
+	return c.Caps, c.IsUniFiOS
+}
+
+// unreachable explains a request that got no answer from the controller at all, or returns
+// nil for other failures
+func (c *UDMProClient) unreachable(err error) error {
+	if strings.Contains(err.Error(), "timeout") || strings.Contains(err.Error(), "deadline exceeded") {
+		return fmt.Errorf("network timeout after %s - cannot reach UDM Pro at %s. Check IP address and network connectivity", c.Timeouts.Detect, c.BaseURL)
+	} else if strings.Contains(err.Error(), "connection refused") {
+		return fmt.Errorf("connection refused - UDM Pro at %s is not accessible. Check if device is running and firewall settings", c.BaseURL)
+	} else if strings.Contains(err.Error(), "no such host") {
+		return fmt.Errorf("host not found - invalid UDM Pro address: %s. Check IP address or hostname", c.BaseURL)
+	}
+	return nil
+}
+
+// Probe checks the controller answers at all, with a HEAD request that needs no session. It
+// tells an offline controller from a login or data problem without the cost of a login, so
+// it can run between fetches. Any HTTP answer counts as reachable.
+func (c *UDMProClient) Probe(ctx context.Context) (err error) {
+	var status int
+	ctx, finish := c.begin(ctx, Call{Kind: "probe", Method: "HEAD", Path: "/"})
+	defer func() { finish(status, nil, err) }()
+
+	ctx, cancel := withTimeout(ctx, c.Timeouts.Detect)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "HEAD", c.BaseURL+"/", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create probe request: %v", err)
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		if reachErr := c.unreachable(err); reachErr != nil {
+			return reachErr
+		}
+		return fmt.Errorf("controller not reachable at %s: %v", c.BaseURL, err)
+	}
+	resp.Body.Close()
+	status = resp.StatusCode
 	return nil
 }
 
@@ -164,31 +310,66 @@ func (c *UDMProClient) cacheSession() {
 
 // useCachedSession restores cached session
 func (c *UDMProClient) useCachedSession() {
-	c.cacheMutex.RLock()
-	defer c.cacheMutex.RUnlock()
+	c.cacheMutex.Lock()
+	defer c.cacheMutex.Unlock()
 
 	c.AuthToken = c.session.AuthToken
 	c.CSRFToken = c.session.CSRFToken
 }
 
+// expireSession marks the cached session as expired, dropping its tokens too if clear is set
+func (c *UDMProClient) expireSession(clear bool) {
+	c.cacheMutex.Lock()
+	defer c.cacheMutex.Unlock()
+
+	if clear {
+		c.AuthToken = ""
+		c.CSRFToken = ""
+	}
+	c.session.Expires = time.Now()
+}
+
+// setTokens replaces the session tokens in use
+func (c *UDMProClient) setTokens(authToken, csrfToken string) {
+	c.cacheMutex.Lock()
+	defer c.cacheMutex.Unlock()
+
+	c.AuthToken = authToken
+	c.CSRFToken = csrfToken
+}
+
+// tokens returns the session tokens in use
+func (c *UDMProClient) tokens() (authToken, csrfToken string) {
+	c.cacheMutex.RLock()
+	defer c.cacheMutex.RUnlock()
+
+	return c.AuthToken, c.CSRFToken
+}
+
 // Login authenticates with the UniFi controller
-func (c *UDMProClient) Login() error {
+func (c *UDMProClient) Login(ctx context.Context) (err error) {
+	if err := c.Detect(ctx); err != nil {
+		return err
+	}
+
 	// Check if we have a valid cached session
 	if c.isSessionValid() {
 		fmt.Println("Using cached authentication session")
 		c.useCachedSession()
-		return nil
+		return c.resolveSite(ctx)
 	}
 
 	fmt.Println("No valid session - performing fresh login")
 
-	// Determine login endpoint based on controller type
-	var loginURL string
-	if c.IsUniFiOS {
-		loginURL = c.BaseURL + "/api/auth/login"
-	} else {
-		loginURL = c.BaseURL + "/api/login"
-	}
+	// The call covers checking the account and site after the login itself
+	var status int
+	var body []byte
+	caps, _ := c.api()
+	ctx, finish := c.begin(ctx, Call{Kind: "login", Method: "POST", Path: caps.LoginPath})
+	defer func() { finish(status, body, err) }()
+
+	// Login endpoint depends on the controller family
+	loginURL := c.BaseURL + caps.LoginPath
 
 	// Prepare login payload
 	loginData := LoginRequest{
@@ -202,7 +383,9 @@ func (c *UDMProClient) Login() error {
 	}
 
 	// Create login request (PHP client uses POST for login)
-	req, err := http.NewRequest("POST", loginURL, bytes.NewReader(jsonData))
+	loginCtx, cancel := withTimeout(ctx, c.Timeouts.Login)
+	defer cancel()
+	req, err := http.NewRequestWithContext(loginCtx, "POST", loginURL, bytes.NewReader(jsonData))
 	if err != nil {
 		return fmt.Errorf("failed to create login request: %v", err)
 	}
@@ -226,12 +409,16 @@ func (c *UDMProClient) Login() error {
 
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
+		if loginCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+			return fmt.Errorf("login request timed out after %s", c.Timeouts.Login)
+		}
 		return fmt.Errorf("login request failed: %v", err)
 	}
 	defer resp.Body.Close()
 
 	// Read response body for later processing
-	body, err := io.ReadAll(resp.Body)
+	status = resp.StatusCode
+	body, err = io.ReadAll(resp.Body)
 	if err != nil {
 		return fmt.Errorf("failed to read response body: %v", err)
 	}
@@ -251,8 +438,11 @@ func (c *UDMProClient) Login() error {
 		return fmt.Errorf("login failed with status: %d (rate limited) - please wait before retrying", resp.StatusCode)
 	}
 
+	if restartStatus(resp.StatusCode) {
+		return c.restarting(resp.StatusCode)
+	}
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("login failed with status: %d", resp.StatusCode)
+		return c.loginError(resp.StatusCode, body)
 	}
 
 	// Restore body for later processing
@@ -260,35 +450,69 @@ func (c *UDMProClient) Login() error {
 
 	// Extract authentication token from cookies (matching PHP client behavior)
 	for _, cookie := range resp.Cookies() {
-		if c.IsUniFiOS && cookie.Name == "TOKEN" {
-			c.AuthToken = cookie.Value
-			// Extract CSRF token from JWT for UniFi OS
+		if cookie.Name != caps.CookieName {
+			continue
+		}
+		c.setTokens(cookie.Value, "")
+		// Extract CSRF token from JWT where the family embeds it
+		if caps.CSRFFromJWT {
 			if err := c.extractCSRFToken(); err != nil {
 				return fmt.Errorf("failed to extract CSRF token: %v", err)
 			}
-		} else if !c.IsUniFiOS && cookie.Name == "unifises" {
-			c.AuthToken = cookie.Value
 		}
 	}
 
-	if c.AuthToken == "" {
+	if authToken, _ := c.tokens(); authToken == "" {
 		return fmt.Errorf("no authentication token found in response")
 	}
 
 	// Cache the successful session
 	c.cacheSession()
 
+	c.refreshCapabilities(ctx)
+
+	if err := c.resolveSite(ctx); err != nil {
+		c.expireSession(false)
+		return err
+	}
+
+	// A login that works but cannot read the site fails here, with the reason
+	if _, err := c.Preflight(ctx); err != nil {
+		c.expireSession(false)
+		return err
+	}
 	return nil
 }
 
+// refreshCapabilities asks the controller for its real version and switches
+// capabilities if it differs from the configured one
+func (c *UDMProClient) refreshCapabilities(ctx context.Context) {
+	info, err := c.GetSysInfo(ctx)
+	if err != nil || info.Version == "" {
+		return
+	}
+
+	c.stateMutex.Lock()
+	defer c.stateMutex.Unlock()
+	if info.Version == c.Version {
+		return
+	}
+	caps := CapabilitiesFor(info.Version, c.IsUniFiOS)
+	fmt.Printf("Controller reports version %s (configured %s), API family: %s\n", info.Version, c.Version, caps.Family)
+	c.Version = info.Version
+	c.Caps = caps
+}
+
 // extractCSRFToken extracts CSRF token from JWT token (UniFi OS only)
 func (c *UDMProClient) extractCSRFToken() error {
-	if !c.IsUniFiOS || c.AuthToken == "" {
+	caps, _ := c.api()
+	authToken, _ := c.tokens()
+	if !caps.CSRFFromJWT || authToken == "" {
 		return nil
 	}
 
 	// JWT format: header.payload.signature
-	parts := strings.Split(c.AuthToken, ".")
+	parts := strings.Split(authToken, ".")
 	if len(parts) != 3 {
 		return fmt.Errorf("invalid JWT format - expected 3 parts, got %d", len(parts))
 	}
@@ -323,8 +547,8 @@ func (c *UDMProClient) extractCSRFToken() error {
 	for _, field := range possibleFields {
 		if token, exists := jwtData[field]; exists {
 			if tokenStr, ok := token.(string); ok && tokenStr != "" {
-				c.CSRFToken = tokenStr
-				fmt.Printf("Extracted CSRF token from field '%s': %s...\n", field, c.CSRFToken[:min(10, len(c.CSRFToken))])
+				c.setTokens(authToken, tokenStr)
+				fmt.Printf("Extracted CSRF token from field '%s': %s...\n", field, tokenStr[:min(10, len(tokenStr))])
 				return nil
 			}
 		}
@@ -364,7 +588,7 @@ func (c *UDMProClient) setCachedSpeedtest(result *SpeedtestResult) {
 }
 
 // GetSpeedtestResults fetches speedtest results from the controller
-func (c *UDMProClient) GetSpeedtestResults() (*SpeedtestResult, error) {
+func (c *UDMProClient) GetSpeedtestResults(ctx context.Context) (*SpeedtestResult, error) {
 	// Check cache first
 	if cached := c.getCachedSpeedtest(); cached != nil {
 		return cached, nil
@@ -374,7 +598,7 @@ func (c *UDMProClient) GetSpeedtestResults() (*SpeedtestResult, error) {
 	end := time.Now().UnixMilli()
 	start := end - (24 * 60 * 60 * 1000) // 24 hours ago
 
-	result, err := c.GetSpeedtestResultsInRange(start, end)
+	result, err := c.GetSpeedtestResultsInRange(ctx, start, end)
 	if err != nil {
 		return nil, err
 	}
@@ -384,207 +608,244 @@ func (c *UDMProClient) GetSpeedtestResults() (*SpeedtestResult, error) {
 	return result, nil
 }
 
-// GetSpeedtestResultsInRange fetches speedtest results within a specific time range
-func (c *UDMProClient) GetSpeedtestResultsInRange(start, end int64) (*SpeedtestResult, error) {
-	// Build URL exactly like PHP client does
-	path := fmt.Sprintf("/api/s/%s/stat/report/archive.speedtest", c.Site)
-	apiURL := c.BaseURL + path
-
+// apiURL builds the full URL for a controller API path, adding the UniFi OS proxy prefix when needed
+func (c *UDMProClient) apiURL(path string) string {
 	// For UniFi OS, the PHP client automatically adds /proxy/network prefix (line 4690-4692 in PHP)
-	if c.IsUniFiOS {
-		apiURL = c.BaseURL + "/proxy/network" + path
+	caps, _ := c.api()
+	return c.BaseURL + caps.PathPrefix + path
+}
+
+// reply is the body of a controller response, with the key it is kept under in the response
+// cache, empty if it is not
+type reply struct {
+	body []byte
+	key  string
+}
+
+// request sends an authenticated API request and returns the response,
+// logging in again once if the session has expired
+func (c *UDMProClient) request(ctx context.Context, method, path string, payload any) (reply, error) {
+	logins := c.logins.Load()
+	status, answer, err := c.send(ctx, method, path, payload)
+	if err != nil {
+		return reply{}, err
 	}
 
-	speedtestReq := SpeedtestRequest{
-		Attrs: []string{"xput_download", "xput_upload", "latency", "time"},
-		Start: start,
-		End:   end,
+	if status == http.StatusUnauthorized {
+		if ctx.Value(reloginKey{}) != nil {
+			return reply{}, fmt.Errorf("request failed with status: %d right after logging in", status)
+		}
+		if err := c.relogin(ctx, logins); err != nil {
+			return reply{}, fmt.Errorf("re-authentication failed: %v", err)
+		}
+		// Retry the request with fresh authentication
+		c.retried(ctx, Call{Kind: "query", Method: method, Path: path})
+		return c.request(ctx, method, path, payload)
+	}
+
+	if status == http.StatusForbidden {
+		return reply{}, c.explainForbidden(ctx, method)
+	}
+	if restartStatus(status) {
+		return reply{}, c.restarting(status)
+	}
+	if status != http.StatusOK {
+		return reply{}, fmt.Errorf("request failed with status: %d", status)
+	}
+	c.answered()
+	return answer, nil
+}
+
+// reloginKey marks the context of the requests a relogin sends, which must not log in again
+type reloginKey struct{}
+
+// relogin logs in again after a request sent with the session of login number logins was
+// rejected. Requests rejected together wait for the first one's login instead of each
+// logging in.
+func (c *UDMProClient) relogin(ctx context.Context, logins int64) error {
+	c.reloginMutex.Lock()
+	defer c.reloginMutex.Unlock()
+
+	if c.logins.Load() != logins {
+		return nil
 	}
 
-	payload, err := json.Marshal(speedtestReq)
+	// Clear expired session and retry once (matching PHP client behavior)
+	c.expireSession(true)
+
+	err := c.Login(context.WithValue(ctx, reloginKey{}, true))
+	c.authRefreshed(ctx, err)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal speedtest request: %v", err)
+		return err
 	}
+	c.logins.Add(1)
+	return nil
+}
 
-	// PHP client uses GET by default, switches to POST when payload present (line 4710-4712)
-	req, err := http.NewRequest("GET", apiURL, nil)
+// acquire waits for a free request slot, returning a function that releases it
+func (c *UDMProClient) acquire(ctx context.Context) (func(), error) {
+	c.slotsOnce.Do(func() {
+		n := c.MaxRequests
+		if n <= 0 {
+			n = DefaultMaxRequests
+		}
+		c.slots = make(chan struct{}, n)
+	})
+
+	select {
+	case c.slots <- struct{}{}:
+		return func() { <-c.slots }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("request not sent: %v", ctx.Err())
+	}
+}
+
+// send sends one API request with the current session and returns the status and response
+func (c *UDMProClient) send(ctx context.Context, method, path string, payload any) (status int, answer reply, err error) {
+	if err := c.Detect(ctx); err != nil {
+		return 0, reply{}, err
+	}
+
+	release, err := c.acquire(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create speedtest request: %v", err)
+		return 0, reply{}, err
 	}
+	defer release()
 
-	// Since we have a payload, switch to POST (matching PHP client behavior)
-	req.Method = "POST"
-	req.Body = io.NopCloser(bytes.NewReader(payload))
+	ctx, finish := c.begin(ctx, Call{Kind: "query", Method: method, Path: path})
+	defer func() { finish(status, answer.body, err) }()
+	return c.exchange(ctx, method, path, payload)
+}
+
+// exchange sends a request and reads the answer, asking for a cached response's changes only
+func (c *UDMProClient) exchange(ctx context.Context, method, path string, payload any) (int, reply, error) {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return 0, reply{}, fmt.Errorf("failed to marshal request: %v", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	queryCtx, cancel := withTimeout(ctx, c.Timeouts.Query)
+	defer cancel()
+	req, err := http.NewRequestWithContext(queryCtx, method, c.apiURL(path), body)
+	if err != nil {
+		return 0, reply{}, fmt.Errorf("failed to create request: %v", err)
+	}
 
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Expect", "")
 
-	// Add CSRF token for UniFi OS only for POST requests (like PHP client does)
-	if c.IsUniFiOS && req.Method == "POST" && c.CSRFToken != "" {
-		req.Header["x-csrf-token"] = []string{c.CSRFToken}
-		fmt.Printf("Adding CSRF token to speedtest request: %s\n", c.CSRFToken[:10]+"...")
-	} else if c.IsUniFiOS && req.Method == "POST" {
+	// Add CSRF token for UniFi OS only for write requests (like PHP client does)
+	_, unifiOS := c.api()
+	_, csrfToken := c.tokens()
+	if unifiOS && method != "GET" && csrfToken != "" {
+		req.Header["x-csrf-token"] = []string{csrfToken}
+	} else if unifiOS && method != "GET" {
 		fmt.Printf("Warning: No CSRF token available for UniFi OS request\n")
 	}
 
+	// Ask whether a response cached with a validator changed since
+	key := method + " " + path
+	var cached *cachedResponse
+	if method == "GET" {
+		cached = c.responses.get(key)
+	}
+	if cached != nil && cached.etag != "" {
+		req.Header.Set("If-None-Match", cached.etag)
+	}
+	if cached != nil && cached.lastModified != "" {
+		req.Header.Set("If-Modified-Since", cached.lastModified)
+	}
+
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("speedtest request failed: %v", err)
+		if queryCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+			return 0, reply{}, fmt.Errorf("request timed out after %s", c.Timeouts.Query)
+		}
+		return 0, reply{}, fmt.Errorf("request failed: %v", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusUnauthorized {
-		// Clear expired session and retry once (matching PHP client behavior)
-		c.AuthToken = ""
-		c.CSRFToken = ""
-		c.session.Expires = time.Now() // Mark as expired
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, reply{}, fmt.Errorf("failed to read response body: %v", err)
+	}
+	answer := reply{body: data}
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+		return http.StatusOK, reply{body: cached.body, key: key}, nil
+	case method != "GET" || resp.StatusCode != http.StatusOK:
+	case resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != "":
+		c.responses.put(&cachedResponse{
+			key:          key,
+			etag:         resp.Header.Get("ETag"),
+			lastModified: resp.Header.Get("Last-Modified"),
+			body:         data,
+		})
+		answer.key = key
+	case cached != nil:
+		c.responses.remove(key)
+	}
+	return resp.StatusCode, answer, nil
+}
 
-		if err := c.Login(); err != nil {
-			return nil, fmt.Errorf("re-authentication failed: %v", err)
-		}
-		// Retry the request with fresh authentication
-		return c.GetSpeedtestResultsInRange(start, end)
+// GetSpeedtestResultsInRange fetches speedtest results within a specific time range
+func (c *UDMProClient) GetSpeedtestResultsInRange(ctx context.Context, start, end int64) (*SpeedtestResult, error) {
+	rows, err := c.speedtestRows(ctx, start, end)
+	if err != nil {
+		return nil, err
 	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("speedtest request failed with status: %d", resp.StatusCode)
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("no speedtest results found in response")
 	}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %v", err)
-	}
-
-	// Parse response using similar logic to PHP client (lines 4373-4435)
-	// Try to parse as standard UniFi API response with meta field first
-	var standardResp SpeedtestResponse
-	if err := json.Unmarshal(body, &standardResp); err == nil && standardResp.Meta.RC != "" {
-		// Check for API error in standard format
-		if standardResp.Meta.RC != "ok" {
-			if standardResp.Meta.RC == "error" {
-				errorMsg := "Unknown error from controller"
-				if standardResp.Meta.Msg != "" {
-					errorMsg = standardResp.Meta.Msg
-				}
-				return nil, fmt.Errorf("API error: %s", errorMsg)
+	// Find the most recent valid speedtest result (by timestamp, not zero values)
+	var mostRecent *speedtestRow
+	for i := range rows {
+		result := &rows[i]
+		if result.XputDownload > 0 || result.XputUpload > 0 {
+			if mostRecent == nil || result.Time > mostRecent.Time {
+				mostRecent = result
 			}
-			return nil, fmt.Errorf("API returned status: %s", standardResp.Meta.RC)
-		}
-		if len(standardResp.Data) == 0 {
-			return nil, fmt.Errorf("no speedtest results found in response")
 		}
-		// Find the most recent valid speedtest result (by timestamp, not zero values)
-		var mostRecent *struct {
-			XputDownload float64 `json:"xput_download"`
-			XputUpload   float64 `json:"xput_upload"`
-			Latency      float64 `json:"latency"`
-			Time         int64   `json:"time"`
-		}
-
-		for i := range standardResp.Data {
-			result := &standardResp.Data[i]
-			if result.XputDownload > 0 || result.XputUpload > 0 {
-				if mostRecent == nil || result.Time > mostRecent.Time {
-					mostRecent = result
-				}
-			}
-		}
-
-		if mostRecent == nil {
-			return nil, fmt.Errorf("no valid speedtest results found (all results have zero values)")
-		}
-		return c.convertSpeedtestResult(mostRecent), nil
 	}
 
-	// Try UniFi OS format (direct array without meta wrapper)
-	var uniFiOSResults []struct {
-		XputDownload float64 `json:"xput_download"`
-		XputUpload   float64 `json:"xput_upload"`
-		Latency      float64 `json:"latency"`
-		Time         int64   `json:"time"`
+	if mostRecent == nil {
+		return nil, fmt.Errorf("no valid speedtest results found (all results have zero values)")
 	}
+	return c.convertSpeedtestResult(mostRecent), nil
+}
 
-	if err := json.Unmarshal(body, &uniFiOSResults); err == nil && len(uniFiOSResults) > 0 {
-		// Find the most recent valid speedtest result (by timestamp, not zero values)
-		var mostRecent *struct {
-			XputDownload float64 `json:"xput_download"`
-			XputUpload   float64 `json:"xput_upload"`
-			Latency      float64 `json:"latency"`
-			Time         int64   `json:"time"`
-		}
-
-		for i := range uniFiOSResults {
-			result := &uniFiOSResults[i]
-			if result.XputDownload > 0 || result.XputUpload > 0 {
-				if mostRecent == nil || result.Time > mostRecent.Time {
-					mostRecent = result
-				}
-			}
-		}
-
-		if mostRecent == nil {
-			return nil, fmt.Errorf("no valid speedtest results found (all results have zero values)")
-		}
-		return c.convertSpeedtestResult(mostRecent), nil
-	}
-
-	// Try v2 API format (has errorCode instead of meta)
-	var v2Response struct {
-		ErrorCode int    `json:"errorCode"`
-		Message   string `json:"message"`
-		Data      []struct {
-			XputDownload float64 `json:"xput_download"`
-			XputUpload   float64 `json:"xput_upload"`
-			Latency      float64 `json:"latency"`
-			Time         int64   `json:"time"`
-		} `json:"data"`
-	}
-
-	if err := json.Unmarshal(body, &v2Response); err == nil {
-		if v2Response.ErrorCode != 0 {
-			errorMsg := "Unknown error from v2 API"
-			if v2Response.Message != "" {
-				errorMsg = v2Response.Message
-			}
-			return nil, fmt.Errorf("v2 API error (code %d): %s", v2Response.ErrorCode, errorMsg)
-		}
-		if len(v2Response.Data) > 0 {
-			// Find the most recent valid speedtest result (by timestamp, not zero values)
-			var mostRecent *struct {
-				XputDownload float64 `json:"xput_download"`
-				XputUpload   float64 `json:"xput_upload"`
-				Latency      float64 `json:"latency"`
-				Time         int64   `json:"time"`
-			}
+// speedtestRows fetches the speedtest archive between start and end (unix milliseconds) in one request
+func (c *UDMProClient) speedtestRows(ctx context.Context, start, end int64) ([]speedtestRow, error) {
+	// Build URL exactly like PHP client does
+	path := fmt.Sprintf("/api/s/%s/stat/report/archive.speedtest", c.Site)
 
-			for i := range v2Response.Data {
-				result := &v2Response.Data[i]
-				if result.XputDownload > 0 || result.XputUpload > 0 {
-					if mostRecent == nil || result.Time > mostRecent.Time {
-						mostRecent = result
-					}
-				}
-			}
+	speedtestReq := SpeedtestRequest{
+		Attrs: []string{"xput_download", "xput_upload", "latency", "time"},
+		Start: start,
+		End:   end,
+	}
 
-			if mostRecent == nil {
-				return nil, fmt.Errorf("no valid speedtest results found (all results have zero values)")
-			}
-			return c.convertSpeedtestResult(mostRecent), nil
-		}
+	// PHP client uses GET by default, switches to POST when payload present (line 4710-4712)
+	answer, err := c.request(ctx, "POST", path, speedtestReq)
+	if err != nil {
+		return nil, fmt.Errorf("speedtest %v", err)
 	}
 
-	// If all parsing attempts fail, return the raw response for debugging
-	return nil, fmt.Errorf("failed to parse speedtest response in any known format. Raw response: %s", string(body))
+	var rows []speedtestRow
+	if err := c.decodeResponse(ctx, answer, &rows); err != nil {
+		return nil, err
+	}
+	return rows, nil
 }
 
 // convertSpeedtestResult converts API response to our format
-func (c *UDMProClient) convertSpeedtestResult(data *struct {
-	XputDownload float64 `json:"xput_download"`
-	XputUpload   float64 `json:"xput_upload"`
-	Latency      float64 `json:"latency"`
-	Time         int64   `json:"time"`
-}) *SpeedtestResult {
+func (c *UDMProClient) convertSpeedtestResult(data *speedtestRow) *SpeedtestResult {
 	return &SpeedtestResult{
 		DownloadMbps: data.XputDownload, // API already returns Mbps
 		UploadMbps:   data.XputUpload,   // API already returns Mbps
@@ -602,17 +863,17 @@ func FormatSpeed(mbps float64) string {
 }
 
 // GetUDMProSpeedtest is a convenience function that creates a client and fetches results
-func GetUDMProSpeedtest(baseURL, username, password, site, version string) (*SpeedtestResult, error) {
-	client, err := NewUDMProClient(baseURL, username, password, site, version)
+func GetUDMProSpeedtest(ctx context.Context, baseURL, username, password, site, version string) (*SpeedtestResult, error) {
+	client, err := NewUDMProClient(baseURL, username, password, site, version, DefaultTimeouts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create client: %v", err)
 	}
 
-	if err := client.Login(); err != nil {
+	if err := client.Login(ctx); err != nil {
 		return nil, fmt.Errorf("login failed: %v", err)
 	}
 
-	result, err := client.GetSpeedtestResults()
+	result, err := client.GetSpeedtestResults(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch speedtest results: %v", err)
 	}
@@ -0,0 +1,45 @@
+package network
+
+import (
+	"context"
+	"fmt"
+)
+
+// Client is a device currently connected to the site
+type Client struct {
+	MAC       string `json:"mac"`
+	IP        string `json:"ip"`
+	Name      string `json:"name"` // alias set in the controller
+	Hostname  string `json:"hostname"`
+	NetworkID string `json:"network_id"`
+	Network   string `json:"network"`
+	FixedIP   bool   `json:"use_fixedip"`
+	Wired     bool   `json:"is_wired"`
+	FirstSeen int64  `json:"first_seen"` // seconds since epoch
+	LastSeen  int64  `json:"last_seen"`  // seconds since epoch
+}
+
+// DisplayName returns the client alias, falling back to its host name and MAC address
+func (c Client) DisplayName() string {
+	switch {
+	case c.Name != "":
+		return c.Name
+	case c.Hostname != "":
+		return c.Hostname
+	}
+	return c.MAC
+}
+
+// GetClients fetches the clients connected to the site
+func (c *UDMProClient) GetClients(ctx context.Context) ([]Client, error) {
+	answer, err := c.request(ctx, "GET", fmt.Sprintf("/api/s/%s/stat/sta", c.Site), nil)
+	if err != nil {
+		return nil, fmt.Errorf("client %v", err)
+	}
+
+	var clients []Client
+	if err := c.decodeResponse(ctx, answer, &clients); err != nil {
+		return nil, err
+	}
+	return clients, nil
+}
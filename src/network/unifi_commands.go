@@ -0,0 +1,67 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrWritesDisabled is returned by commands that change controller state unless WritesEnabled is set
+var ErrWritesDisabled = errors.New("controller write operations are disabled")
+
+// devmgrCommand is the cmd/devmgr payload
+type devmgrCommand struct {
+	Cmd        string `json:"cmd"`
+	MAC        string `json:"mac"`
+	RebootType string `json:"reboot_type,omitempty"`
+}
+
+// command sends a write command to the controller, refusing unless writes are enabled
+func (c *UDMProClient) command(ctx context.Context, manager string, payload any) error {
+	if !c.WritesEnabled {
+		return ErrWritesDisabled
+	}
+
+	answer, err := c.request(ctx, "POST", fmt.Sprintf("/api/s/%s/cmd/%s", c.Site, manager), payload)
+	if err != nil {
+		return fmt.Errorf("%s command %v", manager, err)
+	}
+
+	var data []struct{}
+	return c.decodeResponse(ctx, answer, &data)
+}
+
+// LocateDevice starts or stops flashing the LED of the device with the given MAC
+func (c *UDMProClient) LocateDevice(ctx context.Context, mac string, enable bool) error {
+	cmd := "unset-locate"
+	if enable {
+		cmd = "set-locate"
+	}
+	return c.command(ctx, "devmgr", devmgrCommand{Cmd: cmd, MAC: strings.ToLower(mac)})
+}
+
+// RestartDevice soft-reboots the device with the given MAC
+func (c *UDMProClient) RestartDevice(ctx context.Context, mac string) error {
+	return c.command(ctx, "devmgr", devmgrCommand{Cmd: "restart", MAC: strings.ToLower(mac), RebootType: "soft"})
+}
+
+// stamgrCommand is the cmd/stamgr payload
+type stamgrCommand struct {
+	Cmd string `json:"cmd"`
+	MAC string `json:"mac"`
+}
+
+// BlockClient blocks (or unblocks) the client with the given MAC from the network
+func (c *UDMProClient) BlockClient(ctx context.Context, mac string, block bool) error {
+	cmd := "unblock-sta"
+	if block {
+		cmd = "block-sta"
+	}
+	return c.command(ctx, "stamgr", stamgrCommand{Cmd: cmd, MAC: strings.ToLower(mac)})
+}
+
+// KickClient disconnects the client with the given MAC, forcing it to reconnect
+func (c *UDMProClient) KickClient(ctx context.Context, mac string) error {
+	return c.command(ctx, "stamgr", stamgrCommand{Cmd: "kick-sta", MAC: strings.ToLower(mac)})
+}
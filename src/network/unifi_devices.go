@@ -0,0 +1,255 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// WANInterface is one uplink of a gateway
+type WANInterface struct {
+	Name    string `json:"name"`
+	IfName  string `json:"ifname"`
+	IP      string `json:"ip"`
+	Up      bool   `json:"up"`
+	Enable  bool   `json:"enable"`
+	RxBytes int64  `json:"rx_bytes"`
+	TxBytes int64  `json:"tx_bytes"`
+}
+
+// Port is a single switch or gateway port
+type Port struct {
+	Index  int    `json:"port_idx"`
+	Name   string `json:"name"`
+	Up     bool   `json:"up"`
+	Enable bool   `json:"enable"`
+	Speed  int    `json:"speed"`
+}
+
+// VAP is a single SSID broadcast on one radio of an access point
+type VAP struct {
+	ESSID        string `json:"essid"`
+	Radio        string `json:"radio"`
+	NumSta       int    `json:"num_sta"`
+	Satisfaction int    `json:"satisfaction"`
+}
+
+// RadioStats is the channel utilization of one access point radio
+type RadioStats struct {
+	Name     string `json:"name"`
+	Radio    string `json:"radio"` // ng = 2.4 GHz, na = 5 GHz, 6e = 6 GHz
+	Channel  int    `json:"channel"`
+	CUTotal  int    `json:"cu_total"` // percent of airtime in use, by anyone
+	CUSelfRx int    `json:"cu_self_rx"`
+	CUSelfTx int    `json:"cu_self_tx"`
+	NumSta   int    `json:"num_sta"`
+}
+
+// Band returns the radio band as shown on the display
+func (r RadioStats) Band() string {
+	switch r.Radio {
+	case "ng":
+		return "2.4"
+	case "na":
+		return "5"
+	case "6e":
+		return "6"
+	}
+	return r.Radio
+}
+
+// Device is an adopted UniFi device, as returned by stat/device
+type Device struct {
+	MAC          string        `json:"mac"`
+	Name         string        `json:"name"`
+	Model        string        `json:"model"`
+	Type         string        `json:"type"`
+	Version      string        `json:"version"`
+	State        int           `json:"state"`
+	Upgrade      bool          `json:"upgradable"`
+	UpgradeTo    string        `json:"upgrade_to_firmware"`
+	PortTable    []Port        `json:"port_table"`
+	NumSta       int           `json:"num_sta"`
+	Satisfaction int           `json:"satisfaction"`
+	VAPTable     []VAP         `json:"vap_table"`
+	RadioStats   []RadioStats  `json:"radio_table_stats"`
+	RxBytes      int64         `json:"rx_bytes"`
+	TxBytes      int64         `json:"tx_bytes"`
+	WAN1         *WANInterface `json:"wan1,omitempty"`
+	WAN2         *WANInterface `json:"wan2,omitempty"`
+	Uplink       struct {
+		Name   string `json:"name"`
+		IfName string `json:"ifname"`
+		Up     bool   `json:"up"`
+	} `json:"uplink"`
+}
+
+// IsGateway reports whether the device routes the site's WAN traffic
+func (d Device) IsGateway() bool {
+	switch d.Type {
+	case "udm", "ugw", "uxg":
+		return true
+	}
+	return false
+}
+
+// GetDevices fetches all adopted devices of the site
+func (c *UDMProClient) GetDevices(ctx context.Context) ([]Device, error) {
+	answer, err := c.request(ctx, "GET", fmt.Sprintf("/api/s/%s/stat/device", c.Site), nil)
+	if err != nil {
+		return nil, fmt.Errorf("device %v", err)
+	}
+
+	var devices []Device
+	if err := c.decodeResponse(ctx, answer, &devices); err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+// IsAccessPoint reports whether the device is a wireless access point
+func (d Device) IsAccessPoint() bool {
+	return d.Type == "uap"
+}
+
+// DisplayName returns the device name, falling back to its MAC address
+func (d Device) DisplayName() string {
+	if d.Name != "" {
+		return d.Name
+	}
+	return d.MAC
+}
+
+// DualWANStatus is the state of both gateway uplinks
+type DualWANStatus struct {
+	WAN1   *WANInterface
+	WAN2   *WANInterface
+	Active int // 1 or 2, 0 if the active uplink could not be determined
+	HasLTE bool
+	LTERx  int64 // bytes received over the LTE backup
+	LTETx  int64 // bytes sent over the LTE backup
+}
+
+// OnBackup reports whether traffic is currently flowing over the secondary WAN
+func (s *DualWANStatus) OnBackup() bool {
+	return s.Active == 2
+}
+
+// GetDualWANStatus fetches the state of the gateway's primary and secondary WAN
+func (c *UDMProClient) GetDualWANStatus(ctx context.Context) (*DualWANStatus, error) {
+	devices, err := c.GetDevices(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &DualWANStatus{}
+	for _, d := range devices {
+		if d.Type == "ulte" {
+			// U-LTE backup counters are reported on the device itself
+			status.HasLTE = true
+			status.LTERx = d.RxBytes
+			status.LTETx = d.TxBytes
+			continue
+		}
+		if !d.IsGateway() || status.WAN1 != nil {
+			continue
+		}
+
+		status.WAN1 = d.WAN1
+		status.WAN2 = d.WAN2
+		uplink := d.Uplink.IfName
+		if uplink == "" {
+			uplink = d.Uplink.Name
+		}
+		if d.WAN1 != nil && uplink != "" && (uplink == d.WAN1.IfName || uplink == d.WAN1.Name) {
+			status.Active = 1
+		} else if d.WAN2 != nil && uplink != "" && (uplink == d.WAN2.IfName || uplink == d.WAN2.Name) {
+			status.Active = 2
+		}
+	}
+
+	if status.WAN1 == nil {
+		return nil, fmt.Errorf("no gateway found on site %s", c.Site)
+	}
+	return status, nil
+}
+
+// GetWANIP returns the address of the gateway's active WAN, the primary one if the active one
+// is unknown. Behind another router or carrier-grade NAT it is not the public address.
+func (c *UDMProClient) GetWANIP(ctx context.Context) (string, error) {
+	status, err := c.GetDualWANStatus(ctx)
+	if err != nil {
+		return "", err
+	}
+	wan := status.WAN1
+	if status.OnBackup() && status.WAN2 != nil {
+		wan = status.WAN2
+	}
+	if wan == nil || wan.IP == "" {
+		return "", errors.New("the gateway reports no WAN address")
+	}
+	return checkIP(wan.IP)
+}
+
+// FormatBytes formats a byte count with a binary unit (KB/MB/GB/TB)
+func FormatBytes(b int64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(b)/float64(div), "KMGT"[exp])
+}
+
+// SysInfo is the controller's own version and update state
+type SysInfo struct {
+	Version          string `json:"version"`
+	Build            string `json:"build"`
+	Hostname         string `json:"hostname"`
+	UpdateAvailable  bool   `json:"update_available"`
+	UpdateDownloaded bool   `json:"update_downloaded"`
+}
+
+// GetSysInfo fetches the controller version and whether an update is available
+func (c *UDMProClient) GetSysInfo(ctx context.Context) (*SysInfo, error) {
+	answer, err := c.request(ctx, "GET", fmt.Sprintf("/api/s/%s/stat/sysinfo", c.Site), nil)
+	if err != nil {
+		return nil, fmt.Errorf("sysinfo %v", err)
+	}
+
+	var list []SysInfo
+	if err := c.decodeResponse(ctx, answer, &list); err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("empty sysinfo response")
+	}
+	return &list[0], nil
+}
+
+// GetWANTraffic returns the bytes received and sent over the gateway's WAN interfaces.
+// The counters run from when each interface came up, so they restart with the gateway.
+func (c *UDMProClient) GetWANTraffic(ctx context.Context) (rx, tx int64, err error) {
+	devices, err := c.GetDevices(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, d := range devices {
+		if !d.IsGateway() {
+			continue
+		}
+		for _, w := range []*WANInterface{d.WAN1, d.WAN2} {
+			if w != nil {
+				rx += w.RxBytes
+				tx += w.TxBytes
+			}
+		}
+		return rx, tx, nil
+	}
+	return 0, 0, fmt.Errorf("no gateway found on site %s", c.Site)
+}
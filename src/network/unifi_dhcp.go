@@ -0,0 +1,85 @@
+package network
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sort"
+)
+
+// networkConf is a network of the site as configured in the controller
+type networkConf struct {
+	ID          string `json:"_id"`
+	Name        string `json:"name"`
+	Purpose     string `json:"purpose"`
+	DHCPEnabled bool   `json:"dhcpd_enabled"`
+	DHCPStart   string `json:"dhcpd_start"`
+	DHCPStop    string `json:"dhcpd_stop"`
+}
+
+// DHCPPool is the address range a network hands out and how much of it is in use
+type DHCPPool struct {
+	Network string `json:"network"`
+	Start   string `json:"start"`
+	Stop    string `json:"stop"`
+	Size    int    `json:"size"`
+	Leased  int    `json:"leased"` // connected clients with an address in the range
+}
+
+// Utilization returns the percentage of the pool in use
+func (p DHCPPool) Utilization() int {
+	if p.Size == 0 {
+		return 0
+	}
+	return p.Leased * 100 / p.Size
+}
+
+// GetDHCPPools fetches the DHCP ranges of the site's networks and counts the connected clients
+// holding an address in each, fullest pool first. Leases of clients that left but have not
+// expired yet are not reported by the controller, so a pool may be a little fuller than shown.
+func (c *UDMProClient) GetDHCPPools(ctx context.Context) ([]DHCPPool, error) {
+	var networks []networkConf
+	var clients []Client
+	err := Parallel(ctx, func(ctx context.Context) error {
+		answer, err := c.request(ctx, "GET", fmt.Sprintf("/api/s/%s/rest/networkconf", c.Site), nil)
+		if err != nil {
+			return fmt.Errorf("network %v", err)
+		}
+		return c.decodeResponse(ctx, answer, &networks)
+	}, func(ctx context.Context) (err error) {
+		clients, err = c.GetClients(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var pools []DHCPPool
+	for _, n := range networks {
+		start, stop := ipv4Number(n.DHCPStart), ipv4Number(n.DHCPStop)
+		if !n.DHCPEnabled || start == 0 || stop < start {
+			continue
+		}
+
+		pool := DHCPPool{Network: n.Name, Start: n.DHCPStart, Stop: n.DHCPStop, Size: int(stop-start) + 1}
+		for _, client := range clients {
+			if ip := ipv4Number(client.IP); ip >= start && ip <= stop {
+				pool.Leased++
+			}
+		}
+		pools = append(pools, pool)
+	}
+
+	sort.SliceStable(pools, func(i, j int) bool { return pools[i].Utilization() > pools[j].Utilization() })
+	return pools, nil
+}
+
+// ipv4Number returns an IPv4 address as a number, 0 if it is not one
+func ipv4Number(addr string) uint32 {
+	ip := net.ParseIP(addr).To4()
+	if ip == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(ip)
+}
@@ -0,0 +1,52 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// IPSEvent is a single intrusion prevention/detection alert
+type IPSEvent struct {
+	Signature string `json:"inner_alert_signature"`
+	Category  string `json:"inner_alert_category"`
+	Action    string `json:"inner_alert_action"`
+	SrcIP     string `json:"src_ip"`
+	DstIP     string `json:"dest_ip"`
+	Timestamp int64  `json:"timestamp"` // milliseconds since epoch
+}
+
+// Blocked reports whether the threat was dropped rather than only detected
+func (e IPSEvent) Blocked() bool {
+	return e.Action == "blocked" || e.Action == "drop"
+}
+
+// ipsEventRequest is the stat/ips/event query payload
+type ipsEventRequest struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+	Limit int   `json:"_limit"`
+}
+
+// GetIPSEvents fetches IPS/IDS events from the last period, most recent first
+func (c *UDMProClient) GetIPSEvents(ctx context.Context, period time.Duration) ([]IPSEvent, error) {
+	end := time.Now()
+	req := ipsEventRequest{
+		Start: end.Add(-period).UnixMilli(),
+		End:   end.UnixMilli(),
+		Limit: 10000,
+	}
+
+	answer, err := c.request(ctx, "POST", fmt.Sprintf("/api/s/%s/stat/ips/event", c.Site), req)
+	if err != nil {
+		return nil, fmt.Errorf("IPS event %v", err)
+	}
+
+	var list []IPSEvent
+	if err := c.decodeResponse(ctx, answer, &list); err != nil {
+		return nil, err
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Timestamp > list[j].Timestamp })
+	return list, nil
+}
@@ -0,0 +1,80 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Account is the logged in user as the controller sees it on the configured site
+type Account struct {
+	Name     string `json:"name"`
+	Email    string `json:"email,omitempty"`
+	SiteRole string `json:"site_role"` // admin or readonly, empty without access to the site
+	IsSuper  bool   `json:"is_super"`
+}
+
+// CanWrite reports whether the account may perform actions on the site
+func (a Account) CanWrite() bool {
+	return a.IsSuper || a.SiteRole == "admin"
+}
+
+// Preflight asks the controller who the logged in account is and checks it can read the
+// configured site. Most "403" reports are an account without access to the site, which
+// then fails with an error saying so instead of a bare status code. An inconclusive check,
+// e.g. on a controller without the endpoint, returns no account and no error.
+func (c *UDMProClient) Preflight(ctx context.Context) (*Account, error) {
+	status, answer, err := c.send(ctx, "GET", fmt.Sprintf("/api/s/%s/self", c.Site), nil)
+	if err != nil {
+		return nil, fmt.Errorf("account check %v", err)
+	}
+
+	var accounts []Account
+	decodeErr := c.decodeResponse(ctx, answer, &accounts)
+	switch {
+	case decodeErr != nil && strings.Contains(decodeErr.Error(), "NoSiteContext"):
+		return nil, fmt.Errorf("site %q does not exist on the controller", c.Site)
+	case status == http.StatusUnauthorized || status == http.StatusForbidden ||
+		decodeErr != nil && strings.Contains(decodeErr.Error(), "NoPermission"):
+		// The session was just established, so a rejection is about the site
+		return nil, fmt.Errorf("account %s lacks read access to site %q", c.Username, c.Site)
+	case status != http.StatusOK || decodeErr != nil || len(accounts) == 0:
+		fmt.Printf("Account check inconclusive (status %d): %v\n", status, decodeErr)
+		return nil, nil
+	}
+
+	account := accounts[0]
+	if !account.IsSuper && (account.SiteRole == "" || account.SiteRole == "none") {
+		return nil, fmt.Errorf("account %s lacks read access to site %q", c.Username, c.Site)
+	}
+	return &account, nil
+}
+
+// explainForbidden turns a 403 answer into the permission problem behind it, as far as the
+// account's role tells
+func (c *UDMProClient) explainForbidden(ctx context.Context, method string) error {
+	account, err := c.Preflight(ctx)
+	switch {
+	case err != nil:
+		return err
+	case account == nil:
+		return fmt.Errorf("request failed with status: %d", http.StatusForbidden)
+	case method != "GET" && !account.CanWrite():
+		return fmt.Errorf("account %s has read-only access to site %q", c.Username, c.Site)
+	default:
+		return fmt.Errorf("request failed with status: %d (account %s is %s on site %q)", http.StatusForbidden, c.Username, account.SiteRole, c.Site)
+	}
+}
+
+// loginError explains a rejected login. Cloud (UI.com) accounts cannot sign in to the console
+// with a password alone, which shows as a two-factor challenge or a plain rejection.
+func (c *UDMProClient) loginError(status int, body []byte) error {
+	if status == 499 || strings.Contains(string(body), "MFA") {
+		return fmt.Errorf("login failed with status: %d: account %s is cloud-only (UI.com sign-in with two-factor authentication), create a local-only account on the console", status, c.Username)
+	}
+	if _, unifiOS := c.api(); (status == http.StatusUnauthorized || status == http.StatusForbidden) && unifiOS && strings.Contains(c.Username, "@") {
+		return fmt.Errorf("login failed with status: %d: wrong password, or account %s is cloud-only, create a local-only account on the console", status, c.Username)
+	}
+	return fmt.Errorf("login failed with status: %d", status)
+}
@@ -0,0 +1,103 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SpeedtestSchedule is the controller's auto_speedtest setting
+type SpeedtestSchedule struct {
+	ID       string `json:"_id,omitempty"`
+	Key      string `json:"key"`
+	Enabled  bool   `json:"enabled"`
+	CronExpr string `json:"cron_expr"`
+}
+
+// Matches reports whether two schedules run the same tests
+func (s SpeedtestSchedule) Matches(other SpeedtestSchedule) bool {
+	if !s.Enabled || !other.Enabled {
+		return s.Enabled == other.Enabled
+	}
+	return strings.Join(strings.Fields(s.CronExpr), " ") == strings.Join(strings.Fields(other.CronExpr), " ")
+}
+
+// String describes the schedule for logs
+func (s SpeedtestSchedule) String() string {
+	if !s.Enabled {
+		return "off"
+	}
+	return s.CronExpr
+}
+
+// ParseSpeedtestSchedule reads a schedule given as an interval of whole hours dividing a
+// day (6h), a five field cron expression (0 */6 * * *) or off
+func ParseSpeedtestSchedule(value string) (SpeedtestSchedule, error) {
+	schedule := SpeedtestSchedule{Key: "auto_speedtest", Enabled: true}
+
+	if value == "off" {
+		schedule.Enabled = false
+		return schedule, nil
+	}
+	if len(strings.Fields(value)) == 5 {
+		schedule.CronExpr = value
+		return schedule, nil
+	}
+
+	interval, err := time.ParseDuration(value)
+	if err != nil {
+		return schedule, fmt.Errorf("%q is neither an interval, a cron expression nor off", value)
+	}
+	hours := int(interval / time.Hour)
+	if interval%time.Hour != 0 || hours < 1 || 24%hours != 0 {
+		return schedule, fmt.Errorf("interval %s must be a whole number of hours dividing a day", interval)
+	}
+	schedule.CronExpr = fmt.Sprintf("0 */%d * * *", hours)
+	if hours == 24 {
+		schedule.CronExpr = "0 0 * * *"
+	}
+	return schedule, nil
+}
+
+// GetSpeedtestSchedule fetches when the controller runs its speedtests
+func (c *UDMProClient) GetSpeedtestSchedule(ctx context.Context) (*SpeedtestSchedule, error) {
+	answer, err := c.request(ctx, "GET", fmt.Sprintf("/api/s/%s/get/setting/auto_speedtest", c.Site), nil)
+	if err != nil {
+		return nil, fmt.Errorf("speedtest schedule %v", err)
+	}
+
+	var list []SpeedtestSchedule
+	if err := c.decodeResponse(ctx, answer, &list); err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("controller has no speedtest schedule setting")
+	}
+	return &list[0], nil
+}
+
+// SetSpeedtestSchedule changes when the controller runs its speedtests, refusing unless writes are enabled
+func (c *UDMProClient) SetSpeedtestSchedule(ctx context.Context, schedule SpeedtestSchedule) error {
+	if !c.WritesEnabled {
+		return ErrWritesDisabled
+	}
+
+	current, err := c.GetSpeedtestSchedule(ctx)
+	if err != nil {
+		return err
+	}
+	schedule.ID, schedule.Key = current.ID, "auto_speedtest"
+	if !schedule.Enabled {
+		// Keep the expression, so enabling the schedule in the UI restores it
+		schedule.CronExpr = current.CronExpr
+	}
+
+	answer, err := c.request(ctx, "PUT", fmt.Sprintf("/api/s/%s/rest/setting/auto_speedtest/%s", c.Site, current.ID), schedule)
+	if err != nil {
+		return fmt.Errorf("speedtest schedule update %v", err)
+	}
+
+	var data []SpeedtestSchedule
+	return c.decodeResponse(ctx, answer, &data)
+}
@@ -0,0 +1,88 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Site is a site of the controller. Name is the internal ID used in API paths ("default" for
+// the first site), Desc the name shown in the UniFi interface.
+type Site struct {
+	ID   string `json:"_id"`
+	Name string `json:"name"`
+	Desc string `json:"desc"`
+	Role string `json:"role,omitempty"`
+}
+
+// String is the site as the UniFi interface shows it, with its ID when they differ
+func (s Site) String() string {
+	if s.Desc == "" || s.Desc == s.Name {
+		return s.Name
+	}
+	return fmt.Sprintf("%s (%s)", s.Desc, s.Name)
+}
+
+// ListSites returns the sites the logged in account can see
+func (c *UDMProClient) ListSites(ctx context.Context) ([]Site, error) {
+	status, answer, err := c.send(ctx, "GET", "/api/self/sites", nil)
+	if err != nil {
+		return nil, fmt.Errorf("site list %v", err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("site list failed with status: %d", status)
+	}
+
+	var sites []Site
+	if err := c.decodeResponse(ctx, answer, &sites); err != nil {
+		return nil, fmt.Errorf("site list %v", err)
+	}
+	return sites, nil
+}
+
+// resolveSite accepts the site as its ID or as the name shown in the UniFi interface, which
+// is what most people copy into the configuration, and switches to the ID. A site matching
+// neither fails with the sites there are. It runs once; an inconclusive listing keeps the site
+// as configured.
+func (c *UDMProClient) resolveSite(ctx context.Context) error {
+	if c.siteResolved {
+		return nil
+	}
+
+	sites, err := c.ListSites(ctx)
+	if err != nil || len(sites) == 0 {
+		fmt.Printf("Site check inconclusive: %v\n", err)
+		return nil
+	}
+
+	site, ok := FindSite(sites, c.Site)
+	if !ok {
+		var known []string
+		for _, s := range sites {
+			known = append(known, s.String())
+		}
+		return fmt.Errorf("site %q does not exist on the controller (sites: %s)", c.Site, strings.Join(known, ", "))
+	}
+	if site.Name != c.Site {
+		fmt.Printf("Site %q has the ID %q\n", c.Site, site.Name)
+		c.Site = site.Name
+	}
+	c.siteResolved = true
+	return nil
+}
+
+// FindSite looks a site up by ID, or by the name shown in the UniFi interface ignoring case
+func FindSite(sites []Site, name string) (Site, bool) {
+	for _, s := range sites {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	for _, s := range sites {
+		if strings.EqualFold(strings.TrimSpace(s.Desc), strings.TrimSpace(name)) {
+			return s, true
+		}
+	}
+	return Site{}, false
+}
@@ -0,0 +1,63 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// VPNSession is a remote-access VPN connection (L2TP, OpenVPN, WireGuard or Teleport)
+type VPNSession struct {
+	User       string `json:"name"`
+	Type       string `json:"vpn_type"`
+	RemoteIP   string `json:"remote_ip"`
+	InternalIP string `json:"ip"`
+	Start      int64  `json:"start"` // seconds since epoch
+	End        int64  `json:"end"`   // zero while the session is active
+	RxBytes    int64  `json:"rx_bytes"`
+	TxBytes    int64  `json:"tx_bytes"`
+}
+
+// Active reports whether the session is still connected
+func (s VPNSession) Active() bool {
+	return s.End == 0
+}
+
+// TypeLabel returns a short label for the VPN protocol
+func (s VPNSession) TypeLabel() string {
+	switch s.Type {
+	case "wireguard", "wireguard-server":
+		return "WG"
+	case "l2tp", "l2tp-server":
+		return "L2TP"
+	case "openvpn", "openvpn-server":
+		return "OVPN"
+	case "teleport":
+		return "Teleport"
+	case "":
+		return "VPN"
+	}
+	return s.Type
+}
+
+// GetVPNSessions fetches the active remote-access VPN sessions, most recent first
+func (c *UDMProClient) GetVPNSessions(ctx context.Context) ([]VPNSession, error) {
+	answer, err := c.request(ctx, "GET", fmt.Sprintf("/api/s/%s/stat/remoteuservpn", c.Site), nil)
+	if err != nil {
+		return nil, fmt.Errorf("VPN session %v", err)
+	}
+
+	var all []VPNSession
+	if err := c.decodeResponse(ctx, answer, &all); err != nil {
+		return nil, err
+	}
+
+	var active []VPNSession
+	for _, s := range all {
+		if s.Active() {
+			active = append(active, s)
+		}
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].Start > active[j].Start })
+	return active, nil
+}
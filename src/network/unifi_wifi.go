@@ -0,0 +1,101 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// ExperienceScore is the Wi-Fi satisfaction (0-100) of an access point or SSID
+type ExperienceScore struct {
+	Name    string
+	Score   int
+	Clients int
+}
+
+// WiFiExperience holds experience scores for all access points and SSIDs, lowest first
+type WiFiExperience struct {
+	APs   []ExperienceScore
+	SSIDs []ExperienceScore
+}
+
+// GetWiFiExperience fetches the satisfaction scores of access points and SSIDs with connected clients
+func (c *UDMProClient) GetWiFiExperience(ctx context.Context) (*WiFiExperience, error) {
+	devices, err := c.GetDevices(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	exp := &WiFiExperience{}
+	type ssidTotal struct{ weighted, clients int }
+	ssids := make(map[string]*ssidTotal)
+
+	for _, d := range devices {
+		if !d.IsAccessPoint() || d.NumSta == 0 || d.Satisfaction < 0 {
+			continue
+		}
+		exp.APs = append(exp.APs, ExperienceScore{Name: d.DisplayName(), Score: d.Satisfaction, Clients: d.NumSta})
+
+		for _, vap := range d.VAPTable {
+			if vap.NumSta == 0 || vap.Satisfaction < 0 {
+				continue
+			}
+			t, ok := ssids[vap.ESSID]
+			if !ok {
+				t = &ssidTotal{}
+				ssids[vap.ESSID] = t
+			}
+			// Weight by clients so a busy radio counts more than an idle one
+			t.weighted += vap.Satisfaction * vap.NumSta
+			t.clients += vap.NumSta
+		}
+	}
+
+	for name, t := range ssids {
+		exp.SSIDs = append(exp.SSIDs, ExperienceScore{Name: name, Score: t.weighted / t.clients, Clients: t.clients})
+	}
+
+	sort.Slice(exp.APs, func(i, j int) bool { return exp.APs[i].Score < exp.APs[j].Score })
+	sort.Slice(exp.SSIDs, func(i, j int) bool { return exp.SSIDs[i].Score < exp.SSIDs[j].Score })
+	return exp, nil
+}
+
+// Airtime is the channel utilization of each band of one access point
+type Airtime struct {
+	AP     string
+	Radios []RadioStats
+}
+
+// Busiest returns the highest channel utilization across the access point's radios
+func (a Airtime) Busiest() int {
+	busiest := 0
+	for _, r := range a.Radios {
+		busiest = max(busiest, r.CUTotal)
+	}
+	return busiest
+}
+
+// GetBusiestAirtime fetches radio statistics and returns the access point with the highest channel utilization
+func (c *UDMProClient) GetBusiestAirtime(ctx context.Context) (*Airtime, error) {
+	devices, err := c.GetDevices(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var busiest *Airtime
+	for _, d := range devices {
+		if !d.IsAccessPoint() || len(d.RadioStats) == 0 {
+			continue
+		}
+		a := &Airtime{AP: d.DisplayName(), Radios: d.RadioStats}
+		if busiest == nil || a.Busiest() > busiest.Busiest() {
+			busiest = a
+		}
+	}
+
+	if busiest == nil {
+		return nil, fmt.Errorf("no access point radio statistics on site %s", c.Site)
+	}
+	sort.Slice(busiest.Radios, func(i, j int) bool { return busiest.Radios[i].Radio > busiest.Radios[j].Radio })
+	return busiest, nil
+}
@@ -0,0 +1,186 @@
+package network
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// WANIPProvider looks up the public address the host reaches the internet from
+type WANIPProvider interface {
+	Name() string
+	WANIP(ctx context.Context) (string, error)
+}
+
+// The pages the providers read the address from
+const (
+	ipifyURL      = "https://api.ipify.org"
+	icanhazipURL  = "https://ipv4.icanhazip.com"
+	cloudflareURL = "https://1.1.1.1/cdn-cgi/trace"
+)
+
+// WANIPTTL is how long LookupCache keeps the address a provider reported
+const WANIPTTL = 10 * time.Minute
+
+// ExpireWANIP makes the providers ask their service again, e.g. after an address changed
+func ExpireWANIP() {
+	for _, url := range []string{ipifyURL, icanhazipURL, cloudflareURL} {
+		LookupCache.Expire(url)
+	}
+}
+
+// Ipify asks https://www.ipify.org
+type Ipify struct{}
+
+// Name identifies the provider in logs
+func (Ipify) Name() string {
+	return "ipify"
+}
+
+// WANIP returns the address ipify saw the request come from
+func (Ipify) WANIP(ctx context.Context) (string, error) {
+	text, err := lookupText(ctx, ipifyURL)
+	if err != nil {
+		return "", err
+	}
+	return checkIP(text)
+}
+
+// ICanHazIP asks https://icanhazip.com, over IPv4 like the other providers
+type ICanHazIP struct{}
+
+// Name identifies the provider in logs
+func (ICanHazIP) Name() string {
+	return "icanhazip"
+}
+
+// WANIP returns the address icanhazip saw the request come from
+func (ICanHazIP) WANIP(ctx context.Context) (string, error) {
+	text, err := lookupText(ctx, icanhazipURL)
+	if err != nil {
+		return "", err
+	}
+	return checkIP(text)
+}
+
+// CloudflareTrace reads the ip= line of Cloudflare's trace page
+type CloudflareTrace struct{}
+
+// Name identifies the provider in logs
+func (CloudflareTrace) Name() string {
+	return "cloudflare"
+}
+
+// WANIP returns the address Cloudflare saw the request come from
+func (CloudflareTrace) WANIP(ctx context.Context) (string, error) {
+	text, err := lookupText(ctx, cloudflareURL)
+	if err != nil {
+		return "", err
+	}
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		if ip, ok := strings.CutPrefix(scanner.Text(), "ip="); ok {
+			return checkIP(ip)
+		}
+	}
+	return "", errors.New("no ip= line in the trace")
+}
+
+// lookupText fetches a page through LookupCache
+func lookupText(ctx context.Context, url string) (string, error) {
+	body, err := LookupCache.Get(ctx, url, WANIPTTL)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// checkIP normalizes an address, rejecting anything else
+func checkIP(text string) (string, error) {
+	ip := net.ParseIP(strings.TrimSpace(text))
+	if ip == nil {
+		return "", fmt.Errorf("not an address: %.40q", text)
+	}
+	return ip.String(), nil
+}
+
+// DefaultWANIPTimeout is how long a provider of a chain may take, unless the chain sets another
+const DefaultWANIPTimeout = 5 * time.Second
+
+// WANIPChain asks its providers in turn until enough of them agree on the address
+type WANIPChain struct {
+	Providers []WANIPProvider
+	// Timeout is how long each provider may take. Zero is DefaultWANIPTimeout.
+	Timeout time.Duration
+	// Agree is how many providers must report the same address. Zero or one takes the first
+	// answer.
+	Agree int
+}
+
+// WANIPDisagreement is returned when every provider was asked and too few agreed
+type WANIPDisagreement struct {
+	// Answers are the addresses reported, by provider, in the order they were asked
+	Answers []WANIPAnswer
+	Agree   int
+}
+
+// WANIPAnswer is the address a provider reported
+type WANIPAnswer struct {
+	Provider, IP string
+}
+
+func (d *WANIPDisagreement) Error() string {
+	var answers []string
+	for _, a := range d.Answers {
+		answers = append(answers, a.Provider+" "+a.IP)
+	}
+	return fmt.Sprintf("fewer than %d WAN IP providers agree: %s", d.Agree, strings.Join(answers, ", "))
+}
+
+// WANIP asks the providers in order, moving to the next one when a provider fails or until
+// Agree of them reported the same address. When they all answered without agreeing, the error
+// is a *WANIPDisagreement; otherwise it lists why each provider failed.
+func (c *WANIPChain) WANIP(ctx context.Context) (string, error) {
+	if len(c.Providers) == 0 {
+		return "", errors.New("no WAN IP providers")
+	}
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DefaultWANIPTimeout
+	}
+	agree := max(c.Agree, 1)
+
+	var failures []string
+	var answers []WANIPAnswer
+	votes := map[string]int{}
+	for _, provider := range c.Providers {
+		providerCtx, cancel := context.WithTimeout(ctx, timeout)
+		ip, err := provider.WANIP(providerCtx)
+		cancel()
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", provider.Name(), err))
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+
+		answers = append(answers, WANIPAnswer{provider.Name(), ip})
+		votes[ip]++
+		if votes[ip] >= agree {
+			return ip, nil
+		}
+	}
+
+	if len(answers) > 1 {
+		return "", &WANIPDisagreement{Answers: answers, Agree: agree}
+	}
+	if len(answers) == 1 {
+		failures = append(failures, fmt.Sprintf("only %s answered (%s), %d must agree", answers[0].Provider, answers[0].IP, agree))
+	}
+	return "", errors.New(strings.Join(failures, "; "))
+}
@@ -0,0 +1,131 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Email sends notifications and digests through an SMTP server
+type Email struct {
+	Host     string
+	Port     int
+	Username string // no authentication if empty
+	Password string
+	TLS      string // starttls, tls (implicit, usually port 465) or none
+	From     string
+	To       []string
+}
+
+// Name identifies the target in logs
+func (e *Email) Name() string {
+	return "email"
+}
+
+// Send mails the notification with the severity in the subject
+func (e *Email) Send(ctx context.Context, n Notification) error {
+	body := n.Message
+	if n.Link != "" {
+		body += "\n\n" + n.LinkName + ": " + n.Link
+	}
+	return e.Mail(ctx, fmt.Sprintf("[cloudkey %s] %s", n.Severity, n.Title), body)
+}
+
+// Mail sends a plain text message to every recipient
+func (e *Email) Mail(ctx context.Context, subject, body string) error {
+	addr := net.JoinHostPort(e.Host, strconv.Itoa(e.Port))
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+
+	var conn net.Conn
+	var err error
+	if e.TLS == "tls" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: e.Host})
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, e.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if e.TLS == "starttls" {
+		if err := client.StartTLS(&tls.Config{ServerName: e.Host}); err != nil {
+			return fmt.Errorf("starttls: %v", err)
+		}
+	}
+	if e.Username != "" {
+		// PlainAuth refuses to send the password over a connection without TLS
+		if err := client.Auth(smtp.PlainAuth("", e.Username, e.Password, e.Host)); err != nil {
+			return fmt.Errorf("auth: %v", err)
+		}
+	}
+
+	if err := client.Mail(e.From); err != nil {
+		return err
+	}
+	for _, to := range e.To {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("recipient %s: %v", to, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(e.message(subject, body)); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// message formats the headers and body with CRLF line endings
+func (e *Email) message(subject, body string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", e.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(e.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
+
+// atLeast passes on notifications of at least a severity to another target
+type atLeast struct {
+	Target
+	min Severity
+}
+
+// AtLeast wraps target so it only receives notifications of severity min or above
+func AtLeast(target Target, min Severity) Target {
+	return &atLeast{Target: target, min: min}
+}
+
+// Send drops notifications below the minimum severity
+func (a *atLeast) Send(ctx context.Context, n Notification) error {
+	if n.Severity < a.min {
+		return nil
+	}
+	return a.Target.Send(ctx, n)
+}
@@ -0,0 +1,60 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// Gotify pushes to a Gotify server as an application
+type Gotify struct {
+	URL   string // server URL, e.g. https://gotify.example.com
+	Token string // application token
+}
+
+// Name identifies the target in logs
+func (t *Gotify) Name() string {
+	return "gotify"
+}
+
+// gotifyPriority maps severities onto Gotify's 0-10 scale, where the Android app
+// only makes a sound from 4 and shows critical ones as high priority from 8
+var gotifyPriority = map[Severity]int{Info: 4, Warning: 7, Critical: 10}
+
+type gotifyMessage struct {
+	Title    string         `json:"title"`
+	Message  string         `json:"message"`
+	Priority int            `json:"priority"`
+	Extras   map[string]any `json:"extras,omitempty"`
+}
+
+// Send posts the notification to the message endpoint
+func (t *Gotify) Send(ctx context.Context, n Notification) error {
+	msg := gotifyMessage{Title: n.Title, Message: n.Message, Priority: gotifyPriority[n.Severity]}
+	if n.Link != "" {
+		// Gotify has no action buttons; tapping the notification opens the link instead
+		msg.Extras = map[string]any{
+			"client::notification": map[string]any{"click": map[string]string{"url": n.Link}},
+		}
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", strings.TrimSuffix(t.URL, "/")+"/message", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Gotify-Key", t.Token)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkStatus(resp)
+}
@@ -0,0 +1,80 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"cloudkey/src/ratelimit"
+)
+
+// Severity orders notifications from informational to critical
+type Severity int
+
+const (
+	Info Severity = iota
+	Warning
+	Critical
+)
+
+// String returns the lowercase name of the severity
+func (s Severity) String() string {
+	switch s {
+	case Warning:
+		return "warning"
+	case Critical:
+		return "critical"
+	default:
+		return "info"
+	}
+}
+
+// ParseSeverity parses info, warning or critical
+func ParseSeverity(value string) (Severity, error) {
+	for _, s := range []Severity{Info, Warning, Critical} {
+		if s.String() == value {
+			return s, nil
+		}
+	}
+	return Info, fmt.Errorf("severity must be info, warning or critical, got %q", value)
+}
+
+// Notification is a message pushed to the phone
+type Notification struct {
+	Title    string
+	Message  string
+	Severity Severity
+	Tags     []string // emoji short codes shown by ntfy, e.g. warning or rotating_light
+	Link     string   // opened by the action button, typically the controller UI
+	LinkName string   // label of the action button
+}
+
+// Target delivers notifications to one push service
+type Target interface {
+	Name() string
+	Send(ctx context.Context, n Notification) error
+}
+
+// httpClient is shared by the targets; push services answer quickly or not at all
+var httpClient = &http.Client{Timeout: 10 * time.Second, Transport: ratelimit.Transport(nil)}
+
+// Send delivers the notification to every target, logging the ones that fail
+func Send(targets []Target, n Notification) {
+	for _, target := range targets {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		err := target.Send(ctx, n)
+		cancel()
+		if err != nil {
+			fmt.Printf("Notification to %s failed: %v\n", target.Name(), err)
+		}
+	}
+}
+
+// checkStatus turns a non-2xx answer into an error
+func checkStatus(resp *http.Response) error {
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Ntfy publishes to a topic of an ntfy server (https://ntfy.sh or self-hosted)
+type Ntfy struct {
+	URL   string // topic URL, e.g. https://ntfy.sh/my-rack
+	Token string // access token for protected topics, optional
+}
+
+// Name identifies the target in logs
+func (t *Ntfy) Name() string {
+	return "ntfy"
+}
+
+// ntfyPriority maps severities to ntfy priorities: default, high and urgent
+var ntfyPriority = map[Severity]string{Info: "3", Warning: "4", Critical: "5"}
+
+// Send publishes the notification using ntfy's header interface
+func (t *Ntfy) Send(ctx context.Context, n Notification) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", t.URL, strings.NewReader(n.Message))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Title", n.Title)
+	req.Header.Set("Priority", ntfyPriority[n.Severity])
+	if len(n.Tags) > 0 {
+		req.Header.Set("Tags", strings.Join(n.Tags, ","))
+	}
+	if n.Link != "" {
+		req.Header.Set("Actions", fmt.Sprintf("view, %s, %s", n.LinkName, n.Link))
+	}
+	if t.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+t.Token)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkStatus(resp)
+}
@@ -0,0 +1,136 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// telegramAPI is the Bot API endpoint, followed by the bot token and the method
+const telegramAPI = "https://api.telegram.org/bot"
+
+// Telegram sends messages through a Telegram bot to a fixed set of chats
+type Telegram struct {
+	Token string  // bot token from @BotFather
+	Chats []int64 // chats receiving alerts and allowed to send commands
+}
+
+// TelegramMessage is an incoming command or text
+type TelegramMessage struct {
+	UpdateID int64
+	ChatID   int64
+	Text     string
+}
+
+// Name identifies the target in logs
+func (t *Telegram) Name() string {
+	return "telegram"
+}
+
+// telegramIcons prefix the title since Telegram has no notification priorities
+var telegramIcons = map[Severity]string{Info: "ℹ️", Warning: "⚠️", Critical: "\U0001f6a8"}
+
+// Send sends the notification to every chat. Info notifications are delivered silently.
+func (t *Telegram) Send(ctx context.Context, n Notification) error {
+	text := telegramIcons[n.Severity] + " " + n.Title
+	if n.Message != "" {
+		text += "\n" + n.Message
+	}
+	for _, chat := range t.Chats {
+		if err := t.send(ctx, chat, text, n.Severity == Info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reply answers a command in the chat it came from
+func (t *Telegram) Reply(ctx context.Context, chat int64, text string) error {
+	return t.send(ctx, chat, text, false)
+}
+
+func (t *Telegram) send(ctx context.Context, chat int64, text string, silent bool) error {
+	body, err := json.Marshal(map[string]any{
+		"chat_id":              chat,
+		"text":                 text,
+		"disable_notification": silent,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", telegramAPI+t.Token+"/sendMessage", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return redactToken(err, t.Token)
+	}
+	defer resp.Body.Close()
+	return checkStatus(resp)
+}
+
+// Updates long-polls for messages after offset, waiting up to wait for one to arrive.
+// Messages from chats that are not configured are dropped.
+func (t *Telegram) Updates(ctx context.Context, offset int64, wait time.Duration) ([]TelegramMessage, error) {
+	query := url.Values{
+		"offset":          {strconv.FormatInt(offset, 10)},
+		"timeout":         {strconv.Itoa(int(wait.Seconds()))},
+		"allowed_updates": {`["message"]`},
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", telegramAPI+t.Token+"/getUpdates?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// The shared client would time out before the long poll returns
+	client := &http.Client{Timeout: wait + 10*time.Second, Transport: httpClient.Transport}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, redactToken(err, t.Token)
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Result []struct {
+			UpdateID int64 `json:"update_id"`
+			Message  *struct {
+				Chat struct {
+					ID int64 `json:"id"`
+				} `json:"chat"`
+				Text string `json:"text"`
+			} `json:"message"`
+		} `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode updates: %v", err)
+	}
+
+	var messages []TelegramMessage
+	for _, u := range result.Result {
+		m := TelegramMessage{UpdateID: u.UpdateID}
+		if u.Message != nil && slices.Contains(t.Chats, u.Message.Chat.ID) {
+			m.ChatID, m.Text = u.Message.Chat.ID, u.Message.Text
+		}
+		// Dropped updates are still returned so the offset moves past them
+		messages = append(messages, m)
+	}
+	return messages, nil
+}
+
+// redactToken keeps the bot token, which is part of every URL, out of logged errors
+func redactToken(err error, token string) error {
+	return fmt.Errorf("%s", strings.ReplaceAll(err.Error(), token, "<token>"))
+}
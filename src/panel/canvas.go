@@ -0,0 +1,183 @@
+package panel
+
+import (
+	"image"
+	"image/draw"
+	"log"
+	"math"
+	"sync"
+
+	"github.com/golang/freetype"
+	"github.com/golang/freetype/truetype"
+	"github.com/jnovack/cloudkey/fonts"
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font"
+)
+
+// Screens are laid out on the logical panel of the Cloud Key Gen2: three rows of text right
+// of a column of icons, on 160x60 pixels. A larger panel scales the whole layout, text being
+// drawn at the scaled size so it stays sharp, so a screen defined once fits any panel.
+var Logical = image.Rect(0, 0, 160, 60)
+
+// TextArea is where the rows of text go, right of the icons
+var TextArea = image.Rect(20, 0, 160, 60)
+
+const (
+	// RowHeight is the logical height of a row of text
+	RowHeight = 20
+	// IconSize is the logical size of the icon in front of a row
+	IconSize = 16
+	// TextX is where the text of a row starts, right of the icons
+	TextX = 22
+	// TextSize is the font size of a row of text
+	TextSize = 12
+	// Font is the font of the rows, one of github.com/jnovack/cloudkey/fonts
+	Font = "lato-regular"
+)
+
+// Canvas draws on an image in logical coordinates, scaled to the panel
+type Canvas struct {
+	draw.Image
+	// Scale is how many pixels of the image a logical pixel covers
+	Scale float64
+}
+
+// NewCanvas returns a canvas drawing on img at the scale fitting the logical panel into it
+func NewCanvas(img draw.Image) Canvas {
+	return Canvas{Image: img, Scale: ScaleFor(img.Bounds())}
+}
+
+// ScaleFor fits the logical panel into bounds, keeping its proportions. Panels smaller than
+// the logical one are drawn at its size and cropped.
+func ScaleFor(bounds image.Rectangle) float64 {
+	return max(1, min(float64(bounds.Dx())/float64(Logical.Dx()), float64(bounds.Dy())/float64(Logical.Dy())))
+}
+
+// Px converts a logical coordinate to pixels
+func (c Canvas) Px(v int) int {
+	return int(math.Round(float64(v) * c.Scale))
+}
+
+// Scaled converts a logical rectangle to pixels
+func (c Canvas) Scaled(r image.Rectangle) image.Rectangle {
+	return image.Rect(c.Px(r.Min.X), c.Px(r.Min.Y), c.Px(r.Max.X), c.Px(r.Max.Y))
+}
+
+// RowY is the logical top of a row of text
+func RowY(row int) int {
+	return 1 + row*RowHeight
+}
+
+// Row writes text on a row, right of the icons
+func (c Canvas) Row(row int, text string) {
+	c.Write(text, TextX, RowY(row), TextSize, Font)
+}
+
+// Icon draws an icon in front of a row
+func (c Canvas) Icon(row int, icon image.Image) {
+	c.DrawImage(image.Rect(2, 2+row*RowHeight, 2+IconSize, 2+row*RowHeight+IconSize), icon)
+}
+
+// DrawImage draws img over the logical rectangle r, scaled to fit it
+func (c Canvas) DrawImage(r image.Rectangle, img image.Image) {
+	if c.Scale == 1 && r.Size() == img.Bounds().Size() {
+		draw.Draw(c.Image, r, img, img.Bounds().Min, draw.Src)
+		return
+	}
+	// Nearest neighbour keeps the pixel art icons crisp
+	xdraw.NearestNeighbor.Scale(c.Image, c.Scaled(r), img, img.Bounds(), draw.Src, nil)
+}
+
+// Fill paints the logical rectangle r
+func (c Canvas) Fill(r image.Rectangle, src image.Image) {
+	draw.Draw(c.Image, c.Scaled(r), src, image.ZP, draw.Src)
+}
+
+// Clear blacks out the whole image
+func (c Canvas) Clear() {
+	draw.Draw(c.Image, c.Bounds(), image.Black, image.ZP, draw.Src)
+}
+
+// ClearText blacks out the text area, right of the icons
+func (c Canvas) ClearText() {
+	c.Fill(TextArea, image.Black)
+}
+
+// textStyle is a font at one size
+type textStyle struct {
+	font string
+	size float64
+}
+
+var (
+	// textMutex guards the caches below; freetype contexts and their glyph caches are
+	// not safe for concurrent use
+	textMutex    sync.Mutex
+	fontCache    = map[string]*truetype.Font{}
+	contextCache = map[textStyle]*freetype.Context{}
+)
+
+// loadFont parses a font once and keeps it, as parsing costs far more than drawing.
+// It must be called with textMutex held.
+func loadFont(fontname string) *truetype.Font {
+	f, ok := fontCache[fontname]
+	if !ok {
+		f = fonts.Load(fontname)
+		if f != nil {
+			fontCache[fontname] = f
+		}
+	}
+	return f
+}
+
+// textContext returns the context drawing a style, reused with its rasterizer buffers.
+// It must be called with textMutex held.
+func textContext(style textStyle) *freetype.Context {
+	c, ok := contextCache[style]
+	if !ok {
+		c = freetype.NewContext()
+		c.SetFont(loadFont(style.font)) // Set the font
+		c.SetFontSize(style.size)       // Set font size
+		c.SetDPI(72)                    // Fixed DPI
+		c.SetSrc(image.White)           // Color of Foreground
+		contextCache[style] = c
+	}
+	return c
+}
+
+// Write draws text in white with its top left at the logical x,y coordinate
+func (c Canvas) Write(text string, x, y int, size float64, fontname string) {
+	textMutex.Lock()
+	defer textMutex.Unlock()
+
+	size *= c.Scale
+	ctx := textContext(textStyle{fontname, size})
+	// Empty the glyph cache: it keeps one rendering per quarter pixel, so glyphs cached by
+	// earlier text would land slightly differently than on a fresh context
+	ctx.SetHinting(font.HintingNone)
+	ctx.SetClip(c.Bounds()) // Clip the text?
+	ctx.SetDst(c.Image)     // Send it where?
+	defer ctx.SetDst(nil)   // Do not keep the image alive
+
+	_, err := ctx.DrawString(text, freetype.Pt(c.Px(x), c.Px(y)+int(ctx.PointToFixed(math.Round(float64(size)+1))>>6))) // y is center of line, shift to top of line
+	if err != nil {
+		log.Println(err)
+		return
+	}
+}
+
+// Center writes text centered on the logical panel, with its top at y
+func (c Canvas) Center(text string, y int, size float64, fontname string) {
+	c.Write(text, Logical.Dx()/2-c.TextWidth(text, size, fontname)/2, y, size, fontname)
+}
+
+// TextWidth measures text drawn by Write, in logical pixels
+func (c Canvas) TextWidth(text string, size float64, fontname string) int {
+	textMutex.Lock()
+	f := loadFont(fontname)
+	textMutex.Unlock()
+
+	face := truetype.NewFace(f, &truetype.Options{Size: size * c.Scale, DPI: 72})
+	defer face.Close()
+	return int(math.Ceil(float64(font.MeasureString(face, text).Ceil()) / c.Scale))
+}
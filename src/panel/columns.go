@@ -0,0 +1,55 @@
+package panel
+
+import (
+	"image"
+	"strings"
+)
+
+const (
+	// ColumnRows is how many label/value pairs fit the panel in the compact layout
+	ColumnRows = 6
+	// ColumnSize is the font size of the compact layout
+	ColumnSize = 8
+	// columnGap is the space kept between a label and its value
+	columnGap = 4
+)
+
+// Pair is a row of the compact layout
+type Pair struct {
+	Label, Value string
+}
+
+// Columns clears the logical area and lays out up to ColumnRows pairs in it, labels on the
+// left and values aligned to the right. Values keep up to half of the width and labels get the
+// rest, both cut short with an ellipsis when they do not fit.
+func (c Canvas) Columns(area image.Rectangle, rows []Pair) {
+	c.Fill(area, image.Black)
+
+	rowHeight := area.Dy() / ColumnRows
+	for n, row := range rows[:min(len(rows), ColumnRows)] {
+		y := area.Min.Y + n*rowHeight - 1
+
+		value := c.Truncate(row.Value, area.Dx()/2, ColumnSize)
+		valueWidth := c.TextWidth(value, ColumnSize, Font)
+		if value != "" {
+			c.Write(value, area.Max.X-1-valueWidth, y, ColumnSize, Font)
+			valueWidth += columnGap
+		}
+		c.Write(c.Truncate(row.Label, area.Dx()-valueWidth, ColumnSize), area.Min.X+2, y, ColumnSize, Font)
+	}
+}
+
+// Truncate shortens text with an ellipsis until it is at most width logical pixels wide
+func (c Canvas) Truncate(text string, width int, size float64) string {
+	if c.TextWidth(text, size, Font) <= width {
+		return text
+	}
+	runes := []rune(strings.TrimSpace(text))
+	for len(runes) > 0 {
+		runes = []rune(strings.TrimSpace(string(runes[:len(runes)-1])))
+		if short := string(runes) + "…"; c.TextWidth(short, size, Font) <= width {
+			return short
+		}
+	}
+	return ""
+}
@@ -0,0 +1,28 @@
+// Package panel is the display engine of cloudkey: a registry of screens drawn off-screen
+// and swapped in whole, drawing primitives laid out on the 160x60 panel of the Cloud Key Gen2
+// and scaled to larger ones, and the interface a display driver implements. It is what other
+// projects need to build their own dashboard for a small panel:
+//
+//	var screens panel.Registry
+//	clock := screens.Add("clock", display.Bounds())
+//	go func() {
+//		for now := range time.Tick(time.Second) {
+//			clock.Redraw(func(screen draw.Image) {
+//				c := panel.NewCanvas(screen)
+//				c.ClearText()
+//				c.Row(1, now.Format("15:04:05"))
+//			})
+//		}
+//	}()
+//	panel.Run(ctx, display, &screens, 7*time.Second)
+//
+// A screen is redrawn as a whole, at most once per MinRedrawInterval, so collectors can call
+// Redraw as often as their data changes. Run shows the screens in turn and copies what changed
+// on the visible one to the display. The display is any draw.Image, such as a Linux
+// framebuffer opened with the framebuffer package or an image.RGBA in memory.
+//
+// It is a module of its own, github.com/llajas/cloudkey/src/panel, depending on neither the
+// collectors nor the rest of cloudkey, which draws its screens through it. The exported
+// identifiers are a stable API: releases only add to them, and a breaking change comes with a
+// new major version of the module.
+package panel
@@ -0,0 +1,27 @@
+package panel
+
+import (
+	"image"
+	"image/draw"
+)
+
+// Display is the panel the screens are shown on. The Linux framebuffer is one, as is an
+// image.RGBA for a panel kept in memory.
+type Display interface {
+	draw.Image
+}
+
+// Flusher is a Display that keeps what is drawn on it until told to send it to the panel,
+// such as an SPI or I2C panel. Run flushes the region it changed after every frame.
+type Flusher interface {
+	Display
+	Flush(r image.Rectangle) error
+}
+
+// flush sends r to the panel, if the display needs to be told
+func flush(display Display, r image.Rectangle) error {
+	if f, ok := display.(Flusher); ok {
+		return f.Flush(r)
+	}
+	return nil
+}
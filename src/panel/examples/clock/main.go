@@ -0,0 +1,63 @@
+// Command clock is a minimal dashboard built on the panel engine: the time and date on one
+// screen and the host on another, shown in turn on a Linux framebuffer.
+//
+//	go run ./examples/clock -framebuffer /dev/fb0
+package main
+
+import (
+	"context"
+	"flag"
+	"image/draw"
+	"log"
+	"os"
+	"os/signal"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/llajas/cloudkey/src/panel"
+	"github.com/llajas/cloudkey/src/panel/framebuffer"
+)
+
+func main() {
+	device := flag.String("framebuffer", "/dev/fb0", "framebuffer device of the panel")
+	dwell := flag.Duration("dwell", 5*time.Second, "how long each screen is shown")
+	flag.Parse()
+
+	display, err := framebuffer.Open(*device)
+	if err != nil {
+		log.Fatalf("failed to open framebuffer: %v", err)
+	}
+
+	var screens panel.Registry
+
+	clock := screens.Add("clock", display.Bounds())
+	go func() {
+		for now := range time.Tick(time.Second) {
+			clock.Redraw(func(screen draw.Image) {
+				c := panel.NewCanvas(screen)
+				c.Clear()
+				c.Center(now.Format("15:04:05"), 10, 20, panel.Font)
+				c.Center(now.Format("Mon 2 Jan"), 38, panel.TextSize, panel.Font)
+			})
+		}
+	}()
+
+	host := screens.Add("host", display.Bounds())
+	hostname, _ := os.Hostname()
+	host.Redraw(func(screen draw.Image) {
+		c := panel.NewCanvas(screen)
+		c.Clear()
+		c.Columns(panel.Logical, []panel.Pair{
+			{Label: "Host", Value: hostname},
+			{Label: "System", Value: runtime.GOOS + "/" + runtime.GOARCH},
+			{Label: "CPUs", Value: strconv.Itoa(runtime.NumCPU())},
+		})
+	})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := panel.Run(ctx, display, &screens, *dwell); err != nil {
+		log.Fatal(err)
+	}
+}
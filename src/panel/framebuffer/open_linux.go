@@ -0,0 +1,112 @@
+package framebuffer
+
+import (
+	"image"
+	"image/draw"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+func Open(name string) (draw.Image, error) {
+	file, err := os.OpenFile(name, os.O_RDWR, os.ModeDevice)
+	if err != nil {
+		return nil, err
+	}
+	var fixInfo FixScreenInfo
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), FBIOGET_FSCREENINFO, uintptr(unsafe.Pointer(&fixInfo))); errno != 0 {
+		return nil, &os.SyscallError{Syscall: "SYS_IOCTL", Err: errno}
+	}
+	if fixInfo.Type != FB_TYPE_PACKED_PIXELS {
+		return nil, UnsupportedError("fixInfo.Type != FB_TYPE_PACKED_PIXELS")
+	}
+	if fixInfo.Visual != FB_VISUAL_TRUECOLOR {
+		return nil, UnsupportedError("fixInfo.Visual != FB_VISUAL_TRUECOLOR")
+	}
+	var varInfo VarScreenInfo
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), FBIOGET_VSCREENINFO, uintptr(unsafe.Pointer(&varInfo))); errno != 0 {
+		return nil, &os.SyscallError{Syscall: "SYS_IOCTL", Err: errno}
+	}
+	//fmt.Println("Red.Offset =", varInfo.Red.Offset, "Red.Length =", varInfo.Red.Length, "Red.Msb_right =", varInfo.Red.Msb_right)
+	//fmt.Println("Green.Offset =", varInfo.Green.Offset, "Green.Length =", varInfo.Green.Length, "Green.Msb_right =", varInfo.Green.Msb_right)
+	//fmt.Println("Blue.Offset =", varInfo.Blue.Offset, "Blue.Length =", varInfo.Blue.Length, "Blue.Msb_right =", varInfo.Blue.Msb_right)
+	//fmt.Println("Transp.Offset =", varInfo.Transp.Offset, "Transp.Length =", varInfo.Transp.Length, "Transp.Msb_right =", varInfo.Transp.Msb_right)
+	//fmt.Println("varInfo.Xres =", varInfo.Xres, "varInfo.Yres =", varInfo.Yres, "varInfo.Xoffset =", varInfo.Xoffset, "varInfo.Yoffset =", varInfo.Yoffset)
+	mmap, err := syscall.Mmap(int(file.Fd()), 0, int(fixInfo.Smem_len), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+	switch varInfo.Bits_per_pixel {
+	case 32:
+		if varInfo.Blue.Length != 8 {
+			return nil, UnsupportedError("varInfo.Blue.Length != 8")
+		}
+		if varInfo.Blue.Offset != 0 {
+			return nil, UnsupportedError("varInfo.Blue.Offset != 0")
+		}
+		if varInfo.Green.Length != 8 {
+			return nil, UnsupportedError("varInfo.Green.Length != 8")
+		}
+		if varInfo.Green.Offset != 8 {
+			return nil, UnsupportedError("varInfo.Green.Offset != 8")
+		}
+		if varInfo.Red.Length != 8 {
+			return nil, UnsupportedError("varInfo.Red.Length != 8")
+		}
+		if varInfo.Red.Offset != 16 {
+			return nil, UnsupportedError("varInfo.Red.Offset != 16")
+		}
+		if varInfo.Transp.Length == 0 {
+			return &BGR32{mmap, int(fixInfo.Line_length), image.Rect(0, 0, int(varInfo.Xres), int(varInfo.Yres)).Add(image.Point{int(varInfo.Xoffset), int(varInfo.Yoffset)})}, nil
+		} else if varInfo.Transp.Length == 8 && varInfo.Transp.Offset == 24 {
+			return &NBGRA{mmap, int(fixInfo.Line_length), image.Rect(0, 0, int(varInfo.Xres), int(varInfo.Yres)).Add(image.Point{int(varInfo.Xoffset), int(varInfo.Yoffset)})}, nil
+		}
+	case 24:
+		if varInfo.Blue.Length != 8 {
+			return nil, UnsupportedError("varInfo.Blue.Length != 8")
+		}
+		if varInfo.Blue.Offset != 0 {
+			return nil, UnsupportedError("varInfo.Blue.Offset != 0")
+		}
+		if varInfo.Green.Length != 8 {
+			return nil, UnsupportedError("varInfo.Green.Length != 8")
+		}
+		if varInfo.Green.Offset != 8 {
+			return nil, UnsupportedError("varInfo.Green.Offset != 8")
+		}
+		if varInfo.Red.Length != 8 {
+			return nil, UnsupportedError("varInfo.Red.Length != 8")
+		}
+		if varInfo.Red.Offset != 16 {
+			return nil, UnsupportedError("varInfo.Red.Offset != 16")
+		}
+		if varInfo.Transp.Length != 0 {
+			return nil, UnsupportedError("varInfo.Transp.Length != 0")
+		}
+		return &BGR{mmap, int(fixInfo.Line_length), image.Rect(0, 0, int(varInfo.Xres), int(varInfo.Yres)).Add(image.Point{int(varInfo.Xoffset), int(varInfo.Yoffset)})}, nil
+	case 16:
+		if varInfo.Blue.Length != 5 {
+			return nil, UnsupportedError("varInfo.Blue.Length != 5")
+		}
+		if varInfo.Blue.Offset != 0 {
+			return nil, UnsupportedError("varInfo.Blue.Offset != 0")
+		}
+		if varInfo.Green.Length != 6 {
+			return nil, UnsupportedError("varInfo.Green.Length != 6")
+		}
+		if varInfo.Green.Offset != 5 {
+			return nil, UnsupportedError("varInfo.Green.Offset != 5")
+		}
+		if varInfo.Red.Length != 5 {
+			return nil, UnsupportedError("varInfo.Red.Length != 5")
+		}
+		if varInfo.Red.Offset != 11 {
+			return nil, UnsupportedError("varInfo.Red.Offset != 11")
+		}
+		if varInfo.Transp.Length != 0 {
+			return nil, UnsupportedError("varInfo.Transp.Length != 0")
+		}
+		return &BGR565{mmap, int(fixInfo.Line_length), image.Rect(0, 0, int(varInfo.Xres), int(varInfo.Yres)).Add(image.Point{int(varInfo.Xoffset), int(varInfo.Yoffset)})}, nil
+	}
+	return nil, UnsupportedError("unsupported pixel format")
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package framebuffer
+
+import "image/draw"
+
+// Open is only supported on Linux, other systems have no fbdev
+func Open(name string) (draw.Image, error) {
+	return nil, UnsupportedError("framebuffer devices are only supported on Linux")
+}
@@ -0,0 +1,9 @@
+module github.com/llajas/cloudkey/src/panel
+
+go 1.25.0
+
+require (
+	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0
+	github.com/jnovack/cloudkey v1.0.0-rc1
+	golang.org/x/image v0.25.0
+)
@@ -0,0 +1,80 @@
+package panel
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/draw"
+	"time"
+)
+
+// MaxFPS caps how often Run copies changes on the visible screen to the display
+const MaxFPS = 4
+
+// Run shows the screens of the registry in turn on the display, each for dwell, and copies
+// what changes on the visible one to the display at most MaxFPS times a second. Screens added
+// while it runs join the rotation. It returns when ctx is done, or with the error of a
+// Flusher.
+func Run(ctx context.Context, display Display, registry *Registry, dwell time.Duration) error {
+	presented := image.NewRGBA(display.Bounds())
+	ticker := time.NewTicker(time.Second / MaxFPS)
+	defer ticker.Stop()
+
+	next := time.Now()
+	visible := -1
+	for {
+		screens := registry.Screens()
+		if len(screens) > 0 {
+			var dirty image.Rectangle
+			if now := time.Now(); !now.Before(next) {
+				visible = (visible + 1) % len(screens)
+				next = now.Add(dwell)
+				// Mark the whole screen changed
+				draw.Draw(presented, presented.Bounds(), image.Transparent, image.ZP, draw.Src)
+			}
+
+			screens[visible].Frame(func(img *image.RGBA) {
+				dirty = DirtyRect(img, presented)
+				draw.Draw(display, dirty, img, dirty.Min, draw.Src)
+				draw.Draw(presented, dirty, img, dirty.Min, draw.Src)
+			})
+			if !dirty.Empty() {
+				if err := flush(display, dirty); err != nil {
+					return err
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// DirtyRect returns the smallest rectangle containing every pixel that differs between a and b
+func DirtyRect(a, b *image.RGBA) image.Rectangle {
+	var dirty image.Rectangle
+	bounds := a.Bounds()
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		rowA := a.Pix[a.PixOffset(bounds.Min.X, y):a.PixOffset(bounds.Max.X, y)]
+		rowB := b.Pix[b.PixOffset(bounds.Min.X, y):b.PixOffset(bounds.Max.X, y)]
+		if bytes.Equal(rowA, rowB) {
+			continue
+		}
+
+		first, last := -1, -1
+		for x := 0; x < len(rowA); x += 4 {
+			if !bytes.Equal(rowA[x:x+4], rowB[x:x+4]) {
+				if first < 0 {
+					first = x / 4
+				}
+				last = x / 4
+			}
+		}
+		dirty = dirty.Union(image.Rect(bounds.Min.X+first, y, bounds.Min.X+last+1, y+1))
+	}
+	return dirty
+}
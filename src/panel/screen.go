@@ -0,0 +1,194 @@
+package panel
+
+import (
+	"image"
+	"image/draw"
+	"sync"
+	"time"
+)
+
+// DefaultMinRedrawInterval is the shortest time between two redraws of a screen, unless the
+// registry sets another
+const DefaultMinRedrawInterval = time.Second
+
+// Registry is the screens of a panel in the order they were added. The zero value is ready to
+// use; set its fields before adding screens.
+type Registry struct {
+	// MinRedrawInterval is the shortest time between two redraws of a screen. Redraws asked
+	// for sooner are coalesced, so a burst of updates costs one redraw instead of flicker and
+	// CPU spikes. Zero is DefaultMinRedrawInterval.
+	MinRedrawInterval time.Duration
+	// OnReady runs once a screen has been drawn for the first time
+	OnReady func(s *Screen)
+	// OnCoalesce runs when a redraw replaces one that was not drawn yet
+	OnCoalesce func(s *Screen)
+	// Recover is deferred in the timer goroutines that run deferred redraws, which the
+	// caller's own recover does not cover. It can call recover to handle a panic in a
+	// redraw function; unset, such a panic ends the program.
+	Recover func()
+
+	mutex   sync.Mutex
+	screens []*Screen
+}
+
+// Add registers a new blank screen with the size of the panel
+func (r *Registry) Add(name string, bounds image.Rectangle) *Screen {
+	s := &Screen{
+		name:     name,
+		registry: r,
+		image:    image.NewRGBA(bounds),
+		back:     image.NewRGBA(bounds),
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.screens = append(r.screens, s)
+	return s
+}
+
+// Screens returns the screens in the order they were added
+func (r *Registry) Screens() []*Screen {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]*Screen(nil), r.screens...)
+}
+
+// Lookup returns the screen with a name
+func (r *Registry) Lookup(name string) (*Screen, bool) {
+	for _, s := range r.Screens() {
+		if s.name == name {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+// minRedrawInterval is the configured interval or its default
+func (r *Registry) minRedrawInterval() time.Duration {
+	if r.MinRedrawInterval > 0 {
+		return r.MinRedrawInterval
+	}
+	return DefaultMinRedrawInterval
+}
+
+// Screen is a single page of the panel, drawn off-screen and then swapped in whole
+type Screen struct {
+	name     string
+	registry *Registry
+	image    *image.RGBA // front buffer, what is shown
+	back     *image.RGBA // back buffer, drawn off-screen then swapped with image
+	mu       sync.Mutex  // held while the front buffer is read or swapped
+	drawMu   sync.Mutex  // held while the back buffer is drawn
+	drawn    bool        // the screen has been redrawn, guarded by drawMu
+
+	pendingMu sync.Mutex              // guards the fields below
+	pending   func(screen draw.Image) // latest redraw not drawn yet
+	scheduled bool                    // a flush of pending is scheduled or running
+	lastDraw  time.Time               // when the screen was last redrawn
+}
+
+// Name is the name the screen was added with
+func (s *Screen) Name() string {
+	return s.name
+}
+
+// Image is the front buffer, for drawing the parts that never change, such as icons, while
+// the screen is being built and not shown yet. Everything else goes through Redraw.
+func (s *Screen) Image() *image.RGBA {
+	return s.image
+}
+
+// Redraw runs fn on an off-screen copy of the screen and then swaps it in, so goroutines
+// sharing a screen never interleave their drawing and nothing ever shows a half-drawn frame.
+// fn must draw everything that can change: within MinRedrawInterval of the last redraw it is
+// deferred, and replaced if another redraw is asked for in the meantime.
+func (s *Screen) Redraw(fn func(screen draw.Image)) {
+	s.pendingMu.Lock()
+	if s.pending != nil && s.registry.OnCoalesce != nil {
+		s.registry.OnCoalesce(s)
+	}
+	s.pending = fn
+	if s.scheduled {
+		// The scheduled redraw will pick up fn
+		s.pendingMu.Unlock()
+		return
+	}
+	s.scheduled = true
+	wait := s.registry.minRedrawInterval() - time.Since(s.lastDraw)
+	s.pendingMu.Unlock()
+
+	if wait <= 0 {
+		s.flush()
+		return
+	}
+	time.AfterFunc(wait, s.flushLater)
+}
+
+// flushLater is flush run from a timer, under the registry's Recover
+func (s *Screen) flushLater() {
+	if s.registry.Recover != nil {
+		defer s.registry.Recover()
+	}
+	s.flush()
+}
+
+// flush draws the latest pending redraw
+func (s *Screen) flush() {
+	s.pendingMu.Lock()
+	fn := s.pending
+	s.pending = nil
+	s.scheduled = false
+	s.lastDraw = time.Now()
+	s.pendingMu.Unlock()
+
+	if fn == nil {
+		return
+	}
+
+	s.drawMu.Lock()
+	defer s.drawMu.Unlock()
+
+	// Start from the current frame so parts fn does not touch (e.g. icons) are kept
+	s.mu.Lock()
+	copy(s.back.Pix, s.image.Pix)
+	s.mu.Unlock()
+
+	fn(s.back)
+
+	s.mu.Lock()
+	s.image, s.back = s.back, s.image
+	s.mu.Unlock()
+
+	if !s.drawn {
+		s.drawn = true
+		if s.registry.OnReady != nil {
+			s.registry.OnReady(s)
+		}
+	}
+}
+
+// Frame runs fn with the front buffer, which is not swapped until fn returns
+func (s *Screen) Frame(fn func(img *image.RGBA)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fn(s.image)
+}
+
+// Placeholder runs fn with the front buffer unless the screen has been redrawn, e.g. to show
+// it is still loading. A redraw waits for fn to return.
+func (s *Screen) Placeholder(fn func(img *image.RGBA)) {
+	s.drawMu.Lock()
+	defer s.drawMu.Unlock()
+
+	if !s.drawn {
+		s.Frame(fn)
+	}
+}
+
+// LastDraw is when the screen was last redrawn, zero if never
+func (s *Screen) LastDraw() time.Time {
+	s.pendingMu.Lock()
+	defer s.pendingMu.Unlock()
+	return s.lastDraw
+}
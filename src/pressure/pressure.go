@@ -0,0 +1,80 @@
+package pressure
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ErrUnsupported is returned when the kernel does not expose pressure stall information
+var ErrUnsupported = errors.New("pressure stall information not available")
+
+// Averages are the share of time, in percent, that tasks were stalled over 10s, 60s and 300s
+type Averages struct {
+	Avg10  float64 `json:"avg10"`
+	Avg60  float64 `json:"avg60"`
+	Avg300 float64 `json:"avg300"`
+}
+
+// Pressure is the PSI of one resource. Some is when at least one task was stalled,
+// Full when all non-idle tasks were stalled at once.
+type Pressure struct {
+	Some Averages `json:"some"`
+	Full Averages `json:"full"`
+}
+
+// Memory reads /proc/pressure/memory
+func Memory() (Pressure, error) {
+	return Read("/proc/pressure/memory")
+}
+
+// Read parses a PSI file such as /proc/pressure/memory
+func Read(path string) (Pressure, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Pressure{}, ErrUnsupported
+	}
+	if err != nil {
+		return Pressure{}, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+
+	var p Pressure
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		var avg *Averages
+		switch fields[0] {
+		case "some":
+			avg = &p.Some
+		case "full":
+			avg = &p.Full
+		default:
+			continue
+		}
+
+		for _, f := range fields[1:] {
+			key, value, ok := strings.Cut(f, "=")
+			if !ok {
+				continue
+			}
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			switch key {
+			case "avg10":
+				avg.Avg10 = v
+			case "avg60":
+				avg.Avg60 = v
+			case "avg300":
+				avg.Avg300 = v
+			}
+		}
+	}
+	return p, nil
+}
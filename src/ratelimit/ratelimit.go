@@ -0,0 +1,171 @@
+// Package ratelimit holds back the requests cloudkey sends to each destination beyond a budget,
+// so a mis-tuned refresh interval or a retry loop cannot flood the controller or trip the
+// limits of a third-party service.
+package ratelimit
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"cloudkey/src/metrics"
+)
+
+// DefaultRate is how many requests a minute a destination gets unless configured otherwise
+const DefaultRate = 300
+
+// DefaultRates are the budgets of the third-party services looked up on a timer, which ask
+// clients to stay well below what the controller can take
+var DefaultRates = map[string]int{
+	"api.ipify.org":      10,
+	"ipv4.icanhazip.com": 10,
+	"1.1.1.1":            10,
+	"fw-update.ui.com":   10,
+	"api.github.com":     1,
+}
+
+var (
+	mu      sync.Mutex
+	rate    = DefaultRate
+	rates   map[string]int
+	buckets = map[string]*bucket{}
+)
+
+// bucket holds the requests a destination may still send right away
+type bucket struct {
+	tokens    float64
+	burst     float64
+	perSecond float64
+	last      time.Time
+}
+
+// Validate checks a default rate and the rates by destination before Configure
+func Validate(r int, byHost map[string]int) error {
+	if r < 0 {
+		return fmt.Errorf("rate must be 0 (unlimited) or more requests a minute, got %d", r)
+	}
+	for host, n := range byHost {
+		if n < 0 {
+			return fmt.Errorf("rate of %s must be 0 (unlimited) or more requests a minute, got %d", host, n)
+		}
+	}
+	return nil
+}
+
+// Configure allows each destination r requests a minute, or the rate byHost gives its host
+// name. A rate of 0 leaves a destination unlimited. Destinations may send a sixth of their
+// budget at once, the requests after that are spread over the minute.
+func Configure(r int, byHost map[string]int) error {
+	if err := Validate(r, byHost); err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	rate, rates = r, byHost
+	buckets = map[string]*bucket{}
+	return nil
+}
+
+// Rate is how many requests a minute host may send, 0 if it is unlimited
+func Rate(host string) int {
+	mu.Lock()
+	defer mu.Unlock()
+	return rateOf(host)
+}
+
+// rateOf is Rate with mu held
+func rateOf(host string) int {
+	if n, ok := rates[host]; ok {
+		return n
+	}
+	if n, ok := DefaultRates[host]; ok {
+		return n
+	}
+	return rate
+}
+
+// reserve takes one request of the budget of host and returns how long to wait before
+// sending it
+func reserve(host string) time.Duration {
+	mu.Lock()
+	defer mu.Unlock()
+
+	n := rateOf(host)
+	if n == 0 {
+		return 0
+	}
+	now := time.Now()
+	b, ok := buckets[host]
+	if !ok {
+		burst := max(1, float64(n/6))
+		b = &bucket{tokens: burst, burst: burst, perSecond: float64(n) / 60, last: now}
+		buckets[host] = b
+	}
+
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.perSecond)
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.perSecond * float64(time.Second))
+}
+
+// release gives back a request reserved but not sent
+func release(host string) {
+	mu.Lock()
+	defer mu.Unlock()
+	if b, ok := buckets[host]; ok {
+		b.tokens = min(b.burst, b.tokens+1)
+	}
+}
+
+// transport holds requests back in front of another transport
+type transport struct {
+	next http.RoundTripper
+}
+
+// Transport wraps next so requests wait for the budget of their destination. A request
+// whose deadline comes before its turn fails at once instead of being sent.
+func Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &transport{next: next}
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Hostname()
+	wait := reserve(host)
+	if wait == 0 {
+		return t.next.RoundTrip(req)
+	}
+
+	metrics.Add("cloudkey_outbound_throttled_total", "Requests held back by the outbound rate limit of their destination.", 1, "destination", host)
+	ctx := req.Context()
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+		release(host)
+		closeBody(req)
+		return nil, fmt.Errorf("rate limit of %d requests a minute to %s reached", Rate(host), host)
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		release(host)
+		closeBody(req)
+		return nil, ctx.Err()
+	case <-timer.C:
+		return t.next.RoundTrip(req)
+	}
+}
+
+// closeBody closes the body of a request that will not be sent, as a transport must
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}